@@ -4,9 +4,11 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
@@ -20,19 +22,18 @@ import (
 	"github.com/jhaxce/origindive/internal/version"
 	"github.com/jhaxce/origindive/pkg/asn"
 	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/geoip"
 	"github.com/jhaxce/origindive/pkg/ip"
 	"github.com/jhaxce/origindive/pkg/output"
+	"github.com/jhaxce/origindive/pkg/passive"
+	_ "github.com/jhaxce/origindive/pkg/passive/all" // Register built-in passive sources
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/cache"
 	"github.com/jhaxce/origindive/pkg/passive/censys"
-	"github.com/jhaxce/origindive/pkg/passive/ct"
-	passivedns "github.com/jhaxce/origindive/pkg/passive/dns"
-	"github.com/jhaxce/origindive/pkg/passive/dnsdumpster"
-	"github.com/jhaxce/origindive/pkg/passive/securitytrails"
-	"github.com/jhaxce/origindive/pkg/passive/shodan"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/passive/scoring"
 	"github.com/jhaxce/origindive/pkg/passive/subdomain"
-	"github.com/jhaxce/origindive/pkg/passive/viewdns"
-	"github.com/jhaxce/origindive/pkg/passive/virustotal"
-	"github.com/jhaxce/origindive/pkg/passive/wayback"
-	"github.com/jhaxce/origindive/pkg/passive/zoomeye"
+	"github.com/jhaxce/origindive/pkg/resolver"
 	"github.com/jhaxce/origindive/pkg/scanner"
 	"github.com/jhaxce/origindive/pkg/update"
 	"github.com/jhaxce/origindive/pkg/waf"
@@ -57,16 +58,43 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Shared DNS resolver for passive sources, PTR scoring and validation
+	dnsResolver, err := resolver.New(resolver.Config{Server: config.Resolver})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colors.RED, err, colors.NC)
+		os.Exit(1)
+	}
+
 	// Set WAF database path (user cache or repo default)
 	config.WAFDatabasePath = getWAFDatabasePath()
 
+	// Offline IP-to-ASN database for enriching hits and passive IPs
+	asnDB, err := loadASNDatabase(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colors.RED, err, colors.NC)
+		os.Exit(1)
+	}
+
+	// GeoIP databases for location enrichment and country filtering
+	var geoDB *geoip.Database
+	if len(config.GeoDatabases) > 0 {
+		geoDB, err = geoip.Open(config.GeoDatabases...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colors.RED, err, colors.NC)
+			os.Exit(1)
+		}
+	}
+
 	// Print banner once at the start
 	if !config.Quiet {
-		printBanner(config)
+		// The WAF database is opened once for the domain check and the
+		// database line
+		wafDB, wafInfo, wafErr := waf.OpenWAFDatabase(config.WAFDatabasePath)
+		printBanner(config, dnsResolver, asnDB, geoDB, wafDB, wafInfo, wafErr)
 	}
 
 	// Handle passive and auto modes
-	var passiveIPs []string
+	var passiveIPs []core.PassiveIP
 	if config.Mode == core.ModePassive || config.Mode == core.ModeAuto {
 		// Run passive reconnaissance
 		if !config.Quiet {
@@ -76,7 +104,7 @@ func main() {
 		}
 
 		var err error
-		passiveIPs, err = runPassiveRecon(config)
+		passiveIPs, err = runPassiveRecon(config, dnsResolver, asnDB, geoDB)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%sError during passive reconnaissance: %s%s\n", colors.RED, err, colors.NC)
 			if config.Mode == core.ModePassive {
@@ -105,23 +133,27 @@ func main() {
 				if outputFile == "" {
 					outputFile = generatePassiveFilename(config.Domain)
 				}
-				if err := savePassiveResults(outputFile, passiveIPs, config.Domain); err != nil {
+				if err := savePassiveResults(outputFile, passiveIPs, config.Domain, config.MinConfidence); err != nil {
 					fmt.Fprintf(os.Stderr, "%sError saving results: %s%s\n", colors.RED, err, colors.NC)
 					os.Exit(1)
 				}
 				fmt.Printf("%s[+] Results saved to: %s%s\n", colors.GREEN, outputFile, colors.NC)
 			}
 			// Always show results on console too
-			fmt.Printf("\n%sDiscovered IPs:%s\n", colors.CYAN, colors.NC)
-			for _, ipAddr := range passiveIPs {
-				fmt.Printf("  %s\n", ipAddr)
-			}
+			formatter := output.NewFormatter(config.Format, !config.NoColor, config.ShowAll)
+			fmt.Print(formatter.FormatPassiveIPs(passiveIPs))
 			os.Exit(0)
 		}
 
 		// Auto mode: use discovered IPs for active scan
 		if config.Mode == core.ModeAuto {
 			if len(passiveIPs) > 0 {
+				// Show the scored candidates handed to the active stage
+				if !config.Quiet {
+					formatter := output.NewFormatter(core.FormatText, !config.NoColor, config.ShowAll)
+					fmt.Print(formatter.FormatPassiveIPs(passiveIPs))
+				}
+
 				// Convert discovered IPs to IP ranges for scanning
 				// If expand-netmask was provided (e.g., -n /24 or -n 24), expand each IP to that CIDR
 				if config.ExpandNetmask != "" {
@@ -131,8 +163,8 @@ func main() {
 					if cidrBits[0] != '/' {
 						cidrBits = "/" + cidrBits
 					}
-					for _, ipAddr := range passiveIPs {
-						expandedRange, err := expandIPToCIDR(ipAddr, cidrBits)
+					for _, candidate := range passiveIPs {
+						expandedRange, err := expandIPToCIDR(candidate.IP, cidrBits)
 						if err == nil {
 							config.IPRanges = append(config.IPRanges, expandedRange)
 						}
@@ -142,8 +174,8 @@ func main() {
 					}
 				} else {
 					// Regular mode: scan discovered IPs only
-					for _, ipAddr := range passiveIPs {
-						ipInt, err := ip.ToUint32(net.ParseIP(ipAddr))
+					for _, candidate := range passiveIPs {
+						ipInt, err := ip.ToUint32(net.ParseIP(candidate.IP))
 						if err == nil {
 							config.IPRanges = append(config.IPRanges, [2]uint32{ipInt, ipInt})
 						}
@@ -162,29 +194,6 @@ func main() {
 		}
 	}
 
-	// Auto-generate WAF database if it doesn't exist
-	wafPath := getWAFDatabasePath()
-	if config.SkipWAF && !fileExists(wafPath) {
-		// Try fallback to repo default
-		if !fileExists("data/waf_ranges.json") {
-			if !config.Quiet {
-				fmt.Printf("%s[*] WAF database not found, generating from provider APIs...%s\n", colors.CYAN, colors.NC)
-			}
-			if err := updateWAFDatabase(); err != nil {
-				fmt.Fprintf(os.Stderr, "%s[!] Warning: Failed to generate WAF database: %s%s\n", colors.YELLOW, err, colors.NC)
-				fmt.Fprintf(os.Stderr, "%s[*] Continuing without WAF filtering%s\n", colors.YELLOW, colors.NC)
-				config.SkipWAF = false
-			} else if !config.Quiet {
-				fmt.Printf("%s[+] WAF database generated successfully%s\n", colors.GREEN, colors.NC)
-			}
-		} else {
-			// Copy repo default to user cache
-			if data, err := os.ReadFile("data/waf_ranges.json"); err == nil {
-				os.WriteFile(wafPath, data, 0644)
-			}
-		}
-	}
-
 	// Parse IP ranges (only for pure active mode, not auto/passive)
 	if config.Mode == core.ModeActive || (config.Mode == "" && (config.StartIP != "" || config.CIDR != "" || config.InputFile != "" || config.ASN != "")) {
 		if err := parseIPRanges(config); err != nil {
@@ -205,6 +214,9 @@ func main() {
 			fmt.Fprintf(os.Stderr, "%sError creating scanner: %s%s\n", colors.RED, err, colors.NC)
 			os.Exit(1)
 		}
+		s.SetResolver(dnsResolver)
+		s.SetASNDatabase(asnDB)
+		s.SetGeoDatabase(geoDB)
 	}
 
 	// Print active scan header for auto mode
@@ -266,6 +278,7 @@ func main() {
 		fmt.Fprintf(os.Stderr, "%sError during scan: %s%s\n", colors.RED, err, colors.NC)
 		os.Exit(1)
 	}
+	result.PassiveIPs = passiveIPs
 
 	// Stop progress display if not already stopped (validation stops it early)
 	if prog != nil && prog.IsRunning() {
@@ -290,6 +303,10 @@ func main() {
 			writer.WriteResult(*r)
 		}
 	}
+	// CDN edges answered for the domain but are not origins
+	for _, r := range result.CDNEdges {
+		writer.WriteResult(*r)
+	}
 	// Write 200 OK last (most important, near summary)
 	for _, r := range result.Success {
 		writer.WriteResult(*r)
@@ -375,6 +392,8 @@ func parseFlags() (*core.Config, bool) {
 	// Update flags
 	doUpdate := pflag.Bool("update", false, "Check and install updates")
 	updateWAF := pflag.Bool("update-waf", false, "Update WAF IP ranges database")
+	rollbackWAF := pflag.Bool("rollback-waf", false, "Restore the WAF IP ranges database from before the last update")
+	updateASNDB := pflag.Bool("update-asn-db", false, "Download the ip2asn IP-to-ASN database (to --asn-db or the cache directory)")
 
 	// Basic flags
 	pflag.StringVarP(&config.Domain, "domain", "d", "", "Target domain (required)")
@@ -417,7 +436,10 @@ func parseFlags() (*core.Config, bool) {
 	pflag.BoolVar(&config.SkipWAF, "skip-waf", false, "Skip known WAF/CDN IP ranges")
 	var skipProviders string
 	pflag.StringVar(&skipProviders, "skip-providers", "", "Comma-separated list of providers to skip")
-	pflag.StringVar(&config.CustomWAFFile, "custom-waf", "", "Custom WAF ranges file (JSON or text)")
+	var skipCategories string
+	pflag.StringVar(&skipCategories, "skip-categories", "", "Comma-separated provider categories --skip-waf skips: cdn, waf, cloud, hosting (default: cdn,waf)")
+	var customWAF string
+	pflag.StringVar(&customWAF, "custom-waf", "", "Comma-separated custom WAF ranges files (JSON or text)")
 	pflag.BoolVar(&config.ShowSkipped, "show-skipped", false, "Display skipped IPs")
 	pflag.BoolVar(&config.NoWAFUpdate, "no-waf-update", false, "Disable WAF database auto-update")
 
@@ -426,7 +448,27 @@ func parseFlags() (*core.Config, bool) {
 	pflag.BoolVar(&config.AutoScan, "auto-scan", false, "Auto-scan: passive then active")
 	pflag.Float64Var(&config.MinConfidence, "min-confidence", 0.7, "Minimum confidence score (0.0-1.0)")
 	var passiveSources string
-	pflag.StringVar(&passiveSources, "passive-sources", "", "Comma-separated passive sources (ct,dns,shodan,censys)")
+	pflag.StringVar(&passiveSources, "passive-sources", "", fmt.Sprintf("Comma-separated passive sources (%s; default: all with credentials)", strings.Join(passive.Names(), ",")))
+	listSources := pflag.Bool("list-sources", false, "List available passive sources and exit")
+	pflag.DurationVar(&config.PassiveCacheTTL, "cache-ttl", core.DefaultPassiveCacheTTL, "Reuse cached passive source results for this long (0 = always re-query)")
+	pflag.BoolVar(&config.NoCache, "no-cache", false, "Do not read or write the passive result cache")
+	pflag.BoolVar(&config.RefreshCache, "refresh", false, "Re-query passive sources and refresh the cache")
+	pflag.StringVar(&config.PassiveUserAgent, "passive-ua", "", "User-Agent for passive source APIs (default: origindive/<version>)")
+	pflag.BoolVar(&config.PassiveNoProxy, "passive-no-proxy", false, "Query passive sources directly even when --proxy is set")
+	pflag.BoolVar(&config.PassiveSiteFetch, "passive-site-fetch", false, "Let Shodan, Hunter.how and Censys request https://<domain>/, its favicon and certificate to build pivots (contacts the target)")
+	pflag.IntVar(&config.ShodanMaxCredits, "shodan-credits", 0, "Shodan query credits one search may spend (default: 10)")
+	pflag.StringVar(&config.CensysMode, "censys-mode", "", "Censys search mode: names (certificate names), cert (certificate fingerprints) or all (default: names)")
+	pflag.StringVar(&config.ASNDatabase, "asn-db", "", "Offline IP-to-ASN database: ip2asn or pfx2as file, plain or .gz (default: the copy --update-asn-db caches)")
+	var geoDBs, geoInclude, geoExclude string
+	pflag.StringVar(&geoDBs, "geo-db", "", "Comma-separated GeoIP MMDB files (GeoLite2/GeoIP2 City, Country or ASN, IPinfo, DB-IP)")
+	pflag.StringVar(&geoInclude, "geo-include", "", "Only keep candidates in these countries (comma-separated ISO codes, e.g. US,DE; needs --geo-db)")
+	pflag.StringVar(&geoExclude, "geo-exclude", "", "Drop candidates in these countries (comma-separated ISO codes; needs --geo-db)")
+	pflag.StringVar(&config.Resolver, "resolver", "", "DNS resolver for all lookups: system, IP[:port], udp://, tcp://, tls://host or https:// DoH URL (default: system)")
+	pflag.StringVar(&config.SubdomainWordlist, "wordlist", "", "Subdomain wordlist for DNS brute force (default: built-in common names)")
+	var resolvers string
+	pflag.StringVar(&resolvers, "resolvers", "", "Comma-separated DNS resolvers for brute force (IP[:port], system or resolver URLs; default: --resolver)")
+	pflag.IntVar(&config.ResolverRate, "resolver-rate", 0, "DNS queries per second per resolver (default: unlimited)")
+	pflag.BoolVar(&config.SubdomainPermutations, "permutations", false, "Also try dev-/-staging/numeric variants of found subdomains")
 
 	// Output flags
 	outputFlag := pflag.StringP("output", "o", "", "Output file path (use '-o' alone for auto-generated name, or '-o=file.txt' for custom)")
@@ -447,6 +489,9 @@ func parseFlags() (*core.Config, bool) {
 	// Handle --follow-redirect flag
 	config.MaxRedirects = *followRedirectFlag
 
+	// An explicit --cache-ttl (0 included) beats the config files
+	config.PassiveCacheTTLSet = pflag.Lookup("cache-ttl").Changed
+
 	// Check if -o flag was provided
 	outputFlagProvided := pflag.Lookup("output").Changed
 	config.OutputFile = *outputFlag
@@ -468,6 +513,12 @@ func parseFlags() (*core.Config, bool) {
 		os.Exit(0)
 	}
 
+	// Handle --list-sources
+	if *listSources {
+		printPassiveSources()
+		os.Exit(0)
+	}
+
 	// Handle --init-config
 	if *initConfig {
 		if err := initializeGlobalConfig(); err != nil {
@@ -488,6 +539,30 @@ func parseFlags() (*core.Config, bool) {
 		os.Exit(0)
 	}
 
+	// Handle --update-asn-db
+	if *updateASNDB {
+		path := getASNDatabasePath(config)
+		fmt.Printf("%s[*] Downloading IP-to-ASN database from %s...%s\n", colors.CYAN, asn.DefaultDatabaseURL, colors.NC)
+		db, err := asn.DownloadDatabase(asn.DefaultDatabaseURL, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sASN database update failed: %s%s\n", colors.RED, err, colors.NC)
+			os.Exit(1)
+		}
+		fmt.Printf("%s[+] ASN database saved to %s (%d ranges)%s\n", colors.GREEN, path, db.Len(), colors.NC)
+		os.Exit(0)
+	}
+
+	// Handle --rollback-waf
+	if *rollbackWAF {
+		wafPath := getWAFDatabasePath()
+		if err := waf.RollbackWAFDatabase(wafPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%sWAF rollback failed: %s%s\n", colors.RED, err, colors.NC)
+			os.Exit(1)
+		}
+		fmt.Printf("%s[+] WAF database restored to the previous version: %s%s\n", colors.GREEN, wafPath, colors.NC)
+		os.Exit(0)
+	}
+
 	// Handle update
 	if *doUpdate {
 		if err := update.Update(); err != nil {
@@ -502,9 +577,17 @@ func parseFlags() (*core.Config, bool) {
 		fmt.Printf("origindive v%s\n", version.Version)
 		fmt.Printf("Go version: %s\n", runtime.Version())
 		fmt.Printf("OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+		if _, info, err := waf.OpenWAFDatabase(getWAFDatabasePath()); err == nil {
+			fmt.Printf("WAF database: %s\n", info)
+		}
 		os.Exit(0)
 	}
 
+	// --custom-waf is set before merging so it replaces the configured files
+	if customWAF != "" {
+		config.CustomWAFFiles = strings.Split(customWAF, ",")
+	}
+
 	// Load global config first (lowest priority)
 	globalConfig, err := core.LoadGlobalConfig()
 	if err != nil {
@@ -550,10 +633,88 @@ func parseFlags() (*core.Config, bool) {
 		config.SkipProviders = strings.Split(skipProviders, ",")
 	}
 
+	// Parse and check skip categories
+	if skipCategories != "" {
+		config.SkipCategories = strings.Split(skipCategories, ",")
+	}
+	for i, category := range config.SkipCategories {
+		config.SkipCategories[i] = strings.ToLower(strings.TrimSpace(category))
+		if !waf.IsValidCategory(config.SkipCategories[i]) {
+			fmt.Fprintf(os.Stderr, "%sError: invalid provider category %q (use cdn, waf, cloud or hosting)%s\n", colors.RED, category, colors.NC)
+			os.Exit(1)
+		}
+	}
+
+	// Check custom WAF ranges files
+	for _, path := range config.CustomWAFPaths() {
+		if _, err := waf.LoadCustomProviders(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %s: %s%s\n", colors.RED, path, err, colors.NC)
+			os.Exit(1)
+		}
+	}
+
+	// Parse and check GeoIP databases and country filters
+	if geoDBs != "" {
+		config.GeoDatabases = strings.Split(geoDBs, ",")
+	}
+	if geoInclude != "" {
+		config.GeoInclude = strings.Split(geoInclude, ",")
+	}
+	if geoExclude != "" {
+		config.GeoExclude = strings.Split(geoExclude, ",")
+	}
+	for i, path := range config.GeoDatabases {
+		config.GeoDatabases[i] = strings.TrimSpace(path)
+	}
+	for _, codes := range [][]string{config.GeoInclude, config.GeoExclude} {
+		for i, code := range codes {
+			codes[i] = strings.ToUpper(strings.TrimSpace(code))
+		}
+	}
+	if _, err := geoip.NewFilter(config.GeoInclude, config.GeoExclude); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colors.RED, err, colors.NC)
+		os.Exit(1)
+	}
+	if (len(config.GeoInclude) > 0 || len(config.GeoExclude) > 0) && len(config.GeoDatabases) == 0 {
+		fmt.Fprintf(os.Stderr, "%sError: --geo-include/--geo-exclude require a GeoIP database (--geo-db)%s\n", colors.RED, colors.NC)
+		os.Exit(1)
+	}
+
 	// Parse passive sources
 	if passiveSources != "" {
 		config.PassiveSources = strings.Split(passiveSources, ",")
 	}
+	for _, name := range config.PassiveSources {
+		if _, ok := passive.Lookup(name); !ok && strings.TrimSpace(name) != "" {
+			fmt.Fprintf(os.Stderr, "%sUnknown passive source: %s (available: %s)%s\n", colors.RED, name, strings.Join(passive.Names(), ", "), colors.NC)
+			os.Exit(1)
+		}
+	}
+	if config.CensysMode != "" {
+		if _, err := censys.ParseMode(config.CensysMode); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colors.RED, err, colors.NC)
+			os.Exit(1)
+		}
+	}
+	if config.Resolver != "" {
+		if _, err := resolver.New(resolver.Config{Server: config.Resolver}); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colors.RED, err, colors.NC)
+			os.Exit(1)
+		}
+	}
+	if config.SubdomainWordlist != "" {
+		if _, err := subdomain.LoadWordlist(config.SubdomainWordlist); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colors.RED, err, colors.NC)
+			os.Exit(1)
+		}
+	}
+	if resolvers != "" {
+		config.Resolvers = strings.Split(resolvers, ",")
+		if _, err := subdomain.NewPool(config.Resolvers, config.ResolverRate); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colors.RED, err, colors.NC)
+			os.Exit(1)
+		}
+	}
 
 	// Handle input scrape flag (--input-scrape)
 	if inputScrape != "" {
@@ -635,6 +796,7 @@ func initializeGlobalConfig() error {
 	}
 
 	config := core.DefaultGlobalConfig()
+	config.PassiveSources = passive.Names()
 
 	fmt.Printf("%sAPI Keys Setup (optional - press Enter to skip)%s\n", colors.GREEN, colors.NC)
 	fmt.Println(strings.Repeat("─", 63))
@@ -755,6 +917,27 @@ func initializeGlobalConfig() error {
 		fmt.Printf("%s✓ Added %d ZoomEye key(s)%s\n", colors.GREEN, len(zoomeyeKeys), colors.NC)
 	}
 
+	// Hunter.how keys
+	fmt.Printf("\n%sHunter.how API Keys%s\n", colors.CYAN, colors.NC)
+	fmt.Printf("Get API key: https://hunter.how/search-api | Docs: https://hunter.how/search-api\n")
+	fmt.Println("Enter keys one at a time (empty line to finish):")
+	var hunterKeys []string
+	for i := 1; ; i++ {
+		fmt.Printf("  Key #%d: ", i)
+		if !scanner.Scan() {
+			break
+		}
+		key := strings.TrimSpace(scanner.Text())
+		if key == "" {
+			break
+		}
+		hunterKeys = append(hunterKeys, key)
+	}
+	if len(hunterKeys) > 0 {
+		config.HunterKeys = hunterKeys
+		fmt.Printf("%s✓ Added %d Hunter.how key(s)%s\n", colors.GREEN, len(hunterKeys), colors.NC)
+	}
+
 	// ViewDNS keys
 	fmt.Printf("\n%sViewDNS API Keys%s\n", colors.CYAN, colors.NC)
 	fmt.Printf("Get API key: https://viewdns.info/dashboard/api/account-details/ | Docs: https://viewdns.info/api/\n")
@@ -1081,317 +1264,350 @@ func scrapeIPsFromFile(path, domain string) (string, error) {
 // (Previously had a Censys-specific parser; removed in favor of generic scrape.)
 
 // runPassiveRecon performs passive reconnaissance to discover IPs related to the domain
-func runPassiveRecon(config *core.Config) ([]string, error) {
-	var discoveredIPs []string
+// Records from every source are scored, merged per IP and filtered by
+// config.MinConfidence; the result is sorted by confidence (highest first)
+func runPassiveRecon(config *core.Config, dnsResolver *resolver.Resolver, asnDB *asn.Database, geoDB *geoip.Database) ([]core.PassiveIP, error) {
+	var records []core.PassiveIP
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	// Channel for collecting IPs from different sources
-	ipChan := make(chan string, 100)
+	// Shared key rotation and rate limit state for every source
+	manager := api.NewManager(config.APIFailover.Enabled)
+	manager.SetCooldownPolicy(config.APIFailover.SkipOnRateLimit, config.APIFailover.RetryAfterCooldown)
+	statePath := getAPIStatePath()
+	if statePath != "" {
+		if err := manager.LoadState(statePath); err != nil && !config.Quiet {
+			fmt.Fprintf(os.Stderr, "%s[!] %s%s\n", colors.YELLOW, err, colors.NC)
+		}
+	}
+
+	// Shared HTTP client (retries, per-source limits, UA, optional proxy)
+	client, err := newPassiveHTTPClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("passive HTTP client: %w", err)
+	}
+
+	// Per-domain, per-source response cache
+	var store *cache.Cache
+	if dir := getPassiveCacheDir(); dir != "" && !config.NoCache {
+		store = cache.New(dir, config.PassiveCacheTTL)
+	}
 
 	// Start goroutines for each passive source (if enabled)
-	sources := getEnabledPassiveSources(config)
+	sources, explicit := getEnabledPassiveSources(config)
+	ipCounts := make(map[string]int)
+	cachedAt := make(map[string]time.Time)
 	wg.Add(len(sources))
 
 	for _, source := range sources {
 		go func(src string) {
 			defer wg.Done()
-			ips, err := queryPassiveSource(src, config)
+			ips, fetchedAt, err := queryPassiveSource(src, config, manager, client, dnsResolver, store)
 			if err != nil {
+				// Sources without keys are expected when running every source by default
+				if !explicit && errors.Is(err, passive.ErrMissingCredentials) {
+					return
+				}
+				recordSourceResult(manager, src, err)
 				if !config.Quiet && !config.SilentErrors {
 					fmt.Fprintf(os.Stderr, "%s[!] %s: %s%s\n", colors.YELLOW, src, err, colors.NC)
 				}
 				return
 			}
-			for _, ipAddr := range ips {
-				ipChan <- ipAddr
+			recordSourceResult(manager, src, nil)
+			mu.Lock()
+			records = append(records, ips...)
+			ipCounts[src] = len(scoring.MergeByIP(ips))
+			if !fetchedAt.IsZero() {
+				cachedAt[src] = fetchedAt
 			}
+			mu.Unlock()
 		}(source)
 	}
 
 	// Wait for all sources to complete
-	go func() {
-		wg.Wait()
-		close(ipChan)
-	}()
+	wg.Wait()
 
-	// Collect unique IPs
-	seen := make(map[string]bool)
-	for ipAddr := range ipChan {
-		mu.Lock()
-		if !seen[ipAddr] {
-			seen[ipAddr] = true
-			discoveredIPs = append(discoveredIPs, ipAddr)
+	if statePath != "" {
+		if err := manager.SaveState(statePath); err != nil && !config.Quiet {
+			fmt.Fprintf(os.Stderr, "%s[!] Failed to save API key state: %s%s\n", colors.YELLOW, err, colors.NC)
+		}
+	}
+	if !config.Quiet {
+		printSourceStatus(manager, ipCounts, cachedAt)
+		if len(cachedAt) > 0 {
+			fmt.Printf("%s[*] %d source(s) served from cache (use --refresh to re-query)%s\n", colors.CYAN, len(cachedAt), colors.NC)
 		}
-		mu.Unlock()
 	}
 
-	return discoveredIPs, nil
-}
+	if len(records) == 0 {
+		return nil, nil
+	}
 
-// getEnabledPassiveSources returns list of passive sources to query
-func getEnabledPassiveSources(config *core.Config) []string {
-	// Parse passive sources from config
-	if len(config.PassiveSources) == 0 {
-		return []string{"ct", "dns"} // Default: free sources only
+	// ASN, organization and location, for output and the asn, whois_org
+	// and country_code scoring signals
+	for i := range records {
+		asnDB.EnrichPassiveIP(&records[i])
+		geoDB.EnrichPassiveIP(&records[i])
 	}
 
-	// PassiveSources is already a slice of strings
-	return config.PassiveSources
-}
+	// Score, merge per IP and drop candidates below the threshold
+	scoringConfig := scoring.DefaultScoringConfig()
+	scoringConfig.MinConfidence = config.MinConfidence
+	scorer := scoring.NewScorer(config.Domain, scoringConfig)
+	scorer.SetResolver(dnsResolver)
+	ranked := scorer.Rank(context.Background(), records)
 
-// queryPassiveSource queries a specific passive intelligence source
-func queryPassiveSource(source string, config *core.Config) ([]string, error) {
 	if !config.Quiet {
-		fmt.Printf("%s[*] Querying %s...%s\n", colors.CYAN, source, colors.NC)
-	}
-
-	switch strings.ToLower(source) {
-	case "ct":
-		// Certificate Transparency logs (free)
-		return queryCertificateTransparency(config.Domain, config)
-	case "dns":
-		// DNS history (free via public resolvers)
-		return queryDNSHistory(config.Domain, config)
-	case "shodan":
-		// Shodan API (requires key)
-		return queryShodan(config.Domain, config)
-	case "censys":
-		// Censys API (requires key)
-		return queryCensys(config.Domain, config)
-	case "securitytrails":
-		// SecurityTrails API (requires key)
-		return querySecurityTrails(config.Domain, config)
-	case "zoomeye":
-		// ZoomEye API (requires key)
-		return queryZoomEye(config.Domain, config)
-	case "wayback":
-		// Wayback Machine (free)
-		return queryWayback(config.Domain, config)
-	case "virustotal":
-		// VirusTotal API (free tier with rate limits)
-		return queryVirusTotal(config.Domain, config)
-	case "viewdns":
-		// ViewDNS API (requires key)
-		return queryViewDNS(config.Domain, config)
-	case "dnsdumpster":
-		// DNSDumpster (free web scraping)
-		return queryDNSDumpster(config.Domain, config)
-	default:
-		return nil, fmt.Errorf("unknown passive source: %s", source)
+		stats := dnsResolver.Stats()
+		fmt.Printf("%s[*] DNS (%s): %d queries, %d cached, %d NXDOMAIN, %d errors%s\n",
+			colors.CYAN, dnsResolver, stats.Queries, stats.CacheHits, stats.NotFound, stats.Errors, colors.NC)
 	}
-}
 
-// passiveTimeout returns the timeout to use for passive intelligence sources.
-// It is at least 30s, but follows the CLI `-t` value if greater.
-func passiveTimeout(config *core.Config) time.Duration {
-	if config.Timeout > 30*time.Second {
-		return config.Timeout
+	if dropped := len(scoring.MergeByIP(records)) - len(ranked); dropped > 0 && !config.Quiet {
+		fmt.Printf("%s[*] %d IP(s) below minimum confidence %.2f filtered (use --min-confidence to adjust)%s\n",
+			colors.YELLOW, dropped, config.MinConfidence, colors.NC)
 	}
-	return 30 * time.Second
-}
 
-// queryCertificateTransparency searches CT logs for domain certificates
-func queryCertificateTransparency(domain string, config *core.Config) ([]string, error) {
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
+	classifyPassiveIPs(ranked, config)
 
-	ips, err := ct.SearchCrtSh(ctx, domain, t)
-	if err != nil {
-		return []string{}, fmt.Errorf("CT search failed: %w", err)
+	// Drop candidates outside --geo-include/--geo-exclude
+	if geoFilter, _ := geoip.NewFilter(config.GeoInclude, config.GeoExclude); geoFilter != nil && geoDB != nil {
+		kept := ranked[:0]
+		for _, candidate := range ranked {
+			if geoFilter.Allow(candidate.Country) {
+				kept = append(kept, candidate)
+			}
+		}
+		if dropped := len(ranked) - len(kept); dropped > 0 && !config.Quiet {
+			fmt.Printf("%s[*] %d IP(s) outside the --geo-include/--geo-exclude countries filtered%s\n", colors.YELLOW, dropped, colors.NC)
+		}
+		ranked = kept
 	}
 
-	return ips, nil
+	return ranked, nil
 }
 
-// queryDNSHistory queries DNS history and MX records for the domain
-func queryDNSHistory(domain string, config *core.Config) ([]string, error) {
-	var allIPs []string
-
-	// Phase 1: Subdomain enumeration
-	fmt.Printf("  → Enumerating subdomains...\n")
-	t := passiveTimeout(config)
-	subScanner := subdomain.NewScanner(domain, 20, t)
-	ctx, cancel := context.WithTimeout(context.Background(), t*4)
-	defer cancel()
-
-	subResults, err := subScanner.Scan(ctx, subdomain.CommonSubdomains)
-	if err == nil && len(subResults) > 0 {
-		subIPs := subScanner.GetAllIPs()
-		fmt.Printf("  → Found %d IPs from %d subdomains\n", len(subIPs), len(subResults))
-		allIPs = append(allIPs, subIPs...)
+// classifyPassiveIPs records the provider and category of each passive IP's
+// range (CDN edge, cloud compute, hosting) from the WAF database
+func classifyPassiveIPs(ips []core.PassiveIP, config *core.Config) {
+	classifier, err := waf.LoadClassifier(config.WAFDatabasePath, config.CustomWAFPaths())
+	if err != nil {
+		return // Custom files were checked at startup
 	}
-
-	// Phase 2: MX record analysis
-	fmt.Printf("  → Analyzing MX records...\n")
-	mxRecords, err := passivedns.LookupMX(ctx, domain, t)
-	if err == nil && len(mxRecords) > 0 {
-		mxIPs := passivedns.GetAllMXIPs(mxRecords)
-		fmt.Printf("  → Found %d IPs from %d MX records\n", len(mxIPs), len(mxRecords))
-		allIPs = append(allIPs, mxIPs...)
+	for i := range ips {
+		ips[i].Provider, ips[i].Category, _ = classifier.ClassifyString(ips[i].IP)
 	}
+}
 
-	if len(allIPs) == 0 {
-		return []string{}, fmt.Errorf("no IPs discovered from DNS enumeration")
+// getEnabledPassiveSources returns list of passive sources to query
+// When none are configured, every registered source is used and sources
+// without credentials are skipped quietly (explicit = false)
+func getEnabledPassiveSources(config *core.Config) (sources []string, explicit bool) {
+	if len(config.PassiveSources) == 0 {
+		return passive.Names(), false
 	}
 
-	// Deduplicate
-	seen := make(map[string]bool)
-	var unique []string
-	for _, ip := range allIPs {
-		if !seen[ip] {
-			seen[ip] = true
-			unique = append(unique, ip)
+	for _, name := range config.PassiveSources {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			sources = append(sources, name)
 		}
 	}
-
-	return unique, nil
+	return sources, true
 }
 
-// queryShodan searches Shodan for IPs hosting the domain
-func queryShodan(domain string, config *core.Config) ([]string, error) {
-	if len(config.ShodanKeys) == 0 {
-		return []string{}, fmt.Errorf("no Shodan API keys configured")
-	}
-
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
-
-	ips, err := shodan.SearchHostname(ctx, domain, config.ShodanKeys, t)
-	if err != nil {
-		return []string{}, fmt.Errorf("Shodan search failed: %w", err)
+// recordSourceResult updates a source's status in the manager
+// Rate limits on keyed sources are already recorded by the manager's
+// key rotation with the correct cooldown, so only other outcomes are set here
+func recordSourceResult(manager *api.Manager, name string, err error) {
+	source := api.Source(name)
+	if err != nil && manager.HasKeys(source) && api.IsRateLimitError(err) {
+		return
 	}
-
-	return ips, nil
+	manager.RecordResult(source, err)
 }
 
-// queryCensys searches Censys for IPs hosting the domain
-func queryCensys(domain string, config *core.Config) ([]string, error) {
-	if len(config.CensysTokens) == 0 {
-		return []string{}, fmt.Errorf("no Censys PAT tokens configured")
+// printSourceStatus prints per-source request counts and status after recon
+func printSourceStatus(manager *api.Manager, ipCounts map[string]int, cachedAt map[string]time.Time) {
+	statuses := manager.AllStatus()
+	if len(statuses) == 0 {
+		return
 	}
 
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
-
-	ips, err := censys.SearchHosts(ctx, domain, config.CensysTokens, config.CensysOrgID, t)
-	if err != nil {
-		return []string{}, fmt.Errorf("Censys search failed: %w", err)
+	names := make([]string, 0, len(statuses))
+	for source := range statuses {
+		names = append(names, string(source))
 	}
+	sort.Strings(names)
 
-	return ips, nil
-}
+	fmt.Printf("\n%s[*] Passive source status:%s\n", colors.CYAN, colors.NC)
+	fmt.Printf("    %-16s %-13s %-9s %-5s %s\n", "SOURCE", "STATUS", "REQUESTS", "IPS", "NOTE")
+	for _, name := range names {
+		status := statuses[api.Source(name)]
 
-// querySecurityTrails searches SecurityTrails for subdomains and historical IPs
-func querySecurityTrails(domain string, config *core.Config) ([]string, error) {
-	if len(config.SecurityTrailsKeys) == 0 {
-		return []string{}, fmt.Errorf("no SecurityTrails API keys configured")
-	}
+		requests := "-" // Free sources are not metered
+		if manager.HasKeys(status.Source) {
+			requests = fmt.Sprintf("%d", status.RequestsMade)
+		}
 
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
+		color := colors.GREEN
+		note := ""
+		if fetched, ok := cachedAt[name]; ok {
+			note = "cached " + formatAge(time.Since(fetched)) + " ago"
+		}
+		switch status.Status {
+		case api.StatusRateLimited:
+			color = colors.YELLOW
+			if !status.RateLimitEnd.IsZero() {
+				note = "cooling down until " + status.RateLimitEnd.Local().Format("2006-01-02 15:04")
+			}
+		case api.StatusError:
+			color = colors.RED
+			if status.LastError != nil {
+				note = status.LastError.Error()
+				if len(note) > 60 {
+					note = note[:57] + "..."
+				}
+			}
+		}
 
-	ips, err := securitytrails.SearchSubdomainsAndHistory(ctx, domain, config.SecurityTrailsKeys, t)
-	if err != nil {
-		return []string{}, fmt.Errorf("SecurityTrails search failed: %w", err)
+		fmt.Printf("    %-16s %s%-13s%s %-9s %-5d %s\n",
+			name, color, status.Status, colors.NC, requests, ipCounts[name], note)
 	}
-
-	return ips, nil
+	fmt.Println()
 }
 
-// queryZoomEye searches ZoomEye for hosts matching the domain
-func queryZoomEye(domain string, config *core.Config) ([]string, error) {
-	if len(config.ZoomEyeKeys) == 0 {
-		return []string{}, fmt.Errorf("no ZoomEye API keys configured")
+// formatAge formats a cache age rounded to minutes (e.g., "3h12m")
+func formatAge(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
 	}
+	return strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+}
 
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
-
-	ips, err := zoomeye.SearchHost(ctx, domain, config.ZoomEyeKeys, t)
-	if err != nil {
-		return []string{}, fmt.Errorf("ZoomEye search failed: %w", err)
+// getPassiveCacheDir returns the passive result cache directory
+// Returns empty string if the config directory is unknown
+func getPassiveCacheDir() string {
+	configDir := getConfigDir()
+	if configDir == "" {
+		return ""
 	}
-
-	return ips, nil
+	return filepath.Join(configDir, "cache", "passive")
 }
 
-// queryWayback searches the Wayback Machine CDX API for historical subdomains
-func queryWayback(domain string, config *core.Config) ([]string, error) {
-	// Wayback Machine is free - no API key needed
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
-
-	ips, err := wayback.SearchSubdomains(ctx, domain, t)
-	if err != nil {
-		return []string{}, fmt.Errorf("Wayback Machine search failed: %w", err)
+// getAPIStatePath returns the path of the persisted API key cooldown state
+// Returns empty string if the config directory is unknown
+func getAPIStatePath() string {
+	configDir := getConfigDir()
+	if configDir == "" {
+		return ""
 	}
-
-	return ips, nil
+	return filepath.Join(configDir, "api_state.json")
 }
 
-// queryVirusTotal searches VirusTotal for subdomains and DNS records
-func queryVirusTotal(domain string, config *core.Config) ([]string, error) {
-	if len(config.VirusTotalKeys) == 0 {
-		return []string{}, fmt.Errorf("no VirusTotal API keys configured")
+// queryPassiveSource creates a registered passive source and queries it
+// Fresh cached results are returned instead of querying the source, with
+// the time they were fetched (zero for live results). Successful live
+// results are written back to the cache.
+func queryPassiveSource(name string, config *core.Config, manager *api.Manager, client *httpclient.Client, dnsResolver *resolver.Resolver, store *cache.Cache) ([]core.PassiveIP, time.Time, error) {
+	opts := passive.Options{
+		Config:  config,
+		Timeout: passiveTimeout(config),
+		API:     manager,
+		HTTP:    client,
+		DNS:     dnsResolver,
+	}
+	if !config.Quiet {
+		opts.Logf = func(format string, args ...interface{}) {
+			fmt.Printf(format, args...)
+		}
 	}
 
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
-
-	ips, err := virustotal.SearchSubdomains(ctx, domain, config.VirusTotalKeys, t)
+	src, err := passive.New(name, opts)
 	if err != nil {
-		return []string{}, fmt.Errorf("VirusTotal search failed: %w", err)
+		return nil, time.Time{}, err
 	}
 
-	return ips, nil
-}
-
-// queryViewDNS searches ViewDNS reverse IP for related domains
-func queryViewDNS(domain string, config *core.Config) ([]string, error) {
-	if len(config.ViewDNSKeys) == 0 {
-		return []string{}, fmt.Errorf("no ViewDNS API keys configured")
+	// Sources whose options change their results are cached per option set
+	key := passive.CacheKey(src)
+	if store != nil && !config.RefreshCache {
+		if entry, ok := store.Get(config.Domain, key); ok {
+			if !config.Quiet {
+				fmt.Printf("%s[*] %s: %d record(s) from cache (fetched %s ago)%s\n",
+					colors.CYAN, src.Name(), len(entry.Records), formatAge(entry.Age()), colors.NC)
+			}
+			return entry.Records, entry.FetchedAt, nil
+		}
 	}
 
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
+	if !config.Quiet {
+		fmt.Printf("%s[*] Querying %s...%s\n", colors.CYAN, src.Name(), colors.NC)
+	}
 
-	ips, err := viewdns.SearchReverseIP(ctx, domain, config.ViewDNSKeys, t)
+	records, err := src.Search(context.Background(), config.Domain)
 	if err != nil {
-		return []string{}, fmt.Errorf("ViewDNS search failed: %w", err)
+		// Fall back to an expired entry rather than losing the source entirely
+		if store != nil && !errors.Is(err, passive.ErrMissingCredentials) {
+			if entry, ok := store.GetStale(config.Domain, key); ok {
+				if !config.Quiet {
+					fmt.Fprintf(os.Stderr, "%s[!] %s: %s; using stale cache from %s ago%s\n",
+						colors.YELLOW, src.Name(), err, formatAge(entry.Age()), colors.NC)
+				}
+				return entry.Records, entry.FetchedAt, nil
+			}
+		}
+		return nil, time.Time{}, err
 	}
 
-	return ips, nil
+	if store != nil {
+		if err := store.Put(config.Domain, key, records); err != nil && !config.Quiet {
+			fmt.Fprintf(os.Stderr, "%s[!] %s: %s%s\n", colors.YELLOW, src.Name(), err, colors.NC)
+		}
+	}
+	return records, time.Time{}, nil
 }
 
-// queryDNSDumpster scrapes DNSDumpster for subdomains and IPs
-func queryDNSDumpster(domain string, config *core.Config) ([]string, error) {
-	if len(config.DNSDumpsterKeys) == 0 {
-		return []string{}, fmt.Errorf("no DNSDumpster API keys configured")
+// passiveTimeout returns the timeout to use for passive intelligence sources.
+// It is at least 30s, but follows the CLI `-t` value if greater.
+func passiveTimeout(config *core.Config) time.Duration {
+	if config.Timeout > 30*time.Second {
+		return config.Timeout
 	}
+	return 30 * time.Second
+}
 
-	t := passiveTimeout(config)
-	ctx, cancel := context.WithTimeout(context.Background(), t)
-	defer cancel()
-
-	ips, err := dnsdumpster.SearchDomain(ctx, domain, config.DNSDumpsterKeys, t)
-	if err != nil {
-		return []string{}, fmt.Errorf("DNSDumpster search failed: %w", err)
+// newPassiveHTTPClient creates the HTTP client shared by passive sources
+// Requests go through --proxy unless --passive-no-proxy is set; proxies
+// from --proxy-auto are never used, since API keys would be sent through
+// untrusted public proxies
+func newPassiveHTTPClient(config *core.Config) (*httpclient.Client, error) {
+	cfg := httpclient.Config{
+		UserAgent: config.PassiveUserAgent,
+		Timeout:   passiveTimeout(config),
 	}
+	if config.ProxyURL != "" && !config.PassiveNoProxy {
+		cfg.ProxyURL = config.ProxyURL
+	}
+	return httpclient.New(cfg)
+}
 
-	return ips, nil
+// printPassiveSources lists the registered passive sources
+func printPassiveSources() {
+	fmt.Println("Available passive sources:")
+	for _, reg := range passive.All() {
+		creds := "free"
+		if reg.RequiresCredentials() {
+			creds = "requires " + strings.Join(reg.Credentials, ", ")
+		}
+		fmt.Printf("  %-16s %s (%s)\n", reg.Name, reg.Description, creds)
+	}
 }
 
 // savePassiveResults saves discovered IPs to output file
-func savePassiveResults(outputPath string, ips []string, domain string) error {
+// Each IP is preceded by a comment with its score and sources, so the file
+// can still be used as -i input
+func savePassiveResults(outputPath string, ips []core.PassiveIP, domain string, minConfidence float64) error {
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
@@ -1401,11 +1617,21 @@ func savePassiveResults(outputPath string, ips []string, domain string) error {
 	// Write header
 	fmt.Fprintf(file, "# Passive reconnaissance results for: %s\n", domain)
 	fmt.Fprintf(file, "# Discovered at: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(file, "# Minimum confidence: %.2f\n", minConfidence)
 	fmt.Fprintf(file, "# Total IPs: %d\n\n", len(ips))
 
 	// Write IPs
-	for _, ipAddr := range ips {
-		fmt.Fprintf(file, "%s\n", ipAddr)
+	for _, candidate := range ips {
+		sources := candidate.Sources
+		if len(sources) == 0 {
+			sources = []string{candidate.Source}
+		}
+		network := ""
+		if candidate.Provider != "" {
+			network = fmt.Sprintf(" provider=%s category=%s", candidate.Provider, candidate.Category)
+		}
+		fmt.Fprintf(file, "# confidence=%.2f sources=%s%s\n", candidate.Confidence, strings.Join(sources, ","), network)
+		fmt.Fprintf(file, "%s\n", candidate.IP)
 	}
 
 	return nil
@@ -1501,38 +1727,53 @@ func getGlobalConfigPath() string {
 }
 
 // getWAFDatabasePath returns the path to user's WAF database cache
-// Returns user cache path (preferred) or repo default (fallback)
+// The file may not exist yet: the database embedded in the binary is used
+// until --update-waf writes it
 func getWAFDatabasePath() string {
 	configDir := getConfigDir()
 	if configDir == "" {
-		return "data/waf_ranges.json" // Fallback to repo default
+		return "data/waf_ranges.json" // No home directory; use the working directory
 	}
 
-	var userPath string
 	if runtime.GOOS == "windows" {
-		userPath = fmt.Sprintf("%s\\waf_ranges.json", configDir)
-	} else {
-		userPath = fmt.Sprintf("%s/waf_ranges.json", configDir)
+		return fmt.Sprintf("%s\\waf_ranges.json", configDir)
 	}
+	return fmt.Sprintf("%s/waf_ranges.json", configDir)
+}
 
-	// Check if user cache exists
-	if fileExists(userPath) {
-		return userPath
+// getWAFSourcesPath returns the user's WAF update sources file, or "" to
+// use the sources embedded in the binary
+func getWAFSourcesPath() string {
+	configDir := getConfigDir()
+	if configDir == "" {
+		return ""
 	}
 
-	// User cache doesn't exist - copy repo default to user cache if available
-	if fileExists("data/waf_ranges.json") {
-		if data, err := os.ReadFile("data/waf_ranges.json"); err == nil {
-			if err := os.WriteFile(userPath, data, 0644); err == nil {
-				return userPath // Successfully copied to user cache
-			}
-		}
-		// If copy failed, use repo default
-		return "data/waf_ranges.json"
+	path := filepath.Join(configDir, "waf_sources.json")
+	if fileExists(path) {
+		return path
+	}
+	return ""
+}
+
+// getASNDatabasePath returns the IP-to-ASN database path: --asn-db /
+// asn_database, or the copy --update-asn-db caches
+func getASNDatabasePath(config *core.Config) string {
+	if config.ASNDatabase != "" {
+		return config.ASNDatabase
 	}
+	return asn.DefaultDatabasePath()
+}
 
-	// Neither exists, return user path (will be created on update)
-	return userPath
+// loadASNDatabase loads the IP-to-ASN database. Without one configured,
+// the cached download is used if present and enrichment is skipped
+// otherwise (nil database).
+func loadASNDatabase(config *core.Config) (*asn.Database, error) {
+	path := getASNDatabasePath(config)
+	if config.ASNDatabase == "" && !fileExists(path) {
+		return nil, nil
+	}
+	return asn.LoadDatabase(path)
 }
 
 // createMinimalGlobalConfig creates a minimal config template
@@ -1568,6 +1809,10 @@ virustotal_keys:
 zoomeye_keys:
   # - "YOUR_ZOOMEYE_KEY_HERE"
 
+# Hunter.how API keys (https://hunter.how/search-api)
+hunter_keys:
+  # - "YOUR_HUNTER_KEY_HERE"
+
 # ViewDNS API keys (https://viewdns.info/api/)
 viewdns_keys:
   # - "YOUR_VIEWDNS_KEY_HERE"
@@ -1588,12 +1833,13 @@ no_progress: false
 // updateWAFDatabase updates the WAF IP ranges database
 func updateWAFDatabase() error {
 	wafPath := getWAFDatabasePath()
-	updater, err := waf.NewUpdater("data/waf_sources.json", wafPath)
+	updater, err := waf.NewUpdater(getWAFSourcesPath(), wafPath)
 	if err != nil {
 		return fmt.Errorf("failed to create updater: %w", err)
 	}
 
-	return updater.Update()
+	_, err = updater.Update()
+	return err
 }
 
 // generatePassiveFilename creates a filename for passive scan results
@@ -1709,44 +1955,33 @@ func getRerunCommand(config *core.Config) string {
 	return cmd
 }
 
-// checkDomainWAF checks if a domain's current IP is behind WAF/CDN
-func checkDomainWAF(domain, wafDBPath string) (bool, string) {
-	// Resolve domain to IP
-	ips, err := net.LookupIP(domain)
-	if err != nil || len(ips) == 0 {
-		return false, ""
-	}
-
-	// Load WAF database
-	db, err := waf.LoadWAFDatabase(wafDBPath)
+// checkDomainWAF reports the WAFs/CDNs a domain appears to be behind, from
+// its CNAME chain (cdn_cnames.json next to wafDBPath) and its addresses
+// (db, nil when the WAF database could not be loaded)
+func checkDomainWAF(domain string, db *waf.WAFDatabase, wafDBPath string, dnsResolver *resolver.Resolver) []waf.Detection {
+	cnames, err := waf.OpenCNAMEDatabase(getCNAMEDatabasePath(wafDBPath))
 	if err != nil {
-		return false, ""
+		cnames = nil
 	}
-
-	// Create range set and add all providers
-	rangeSet := waf.NewRangeSet()
-	for i := range db.Providers {
-		rangeSet.AddProvider(&db.Providers[i])
+	if db == nil && cnames == nil {
+		return nil
 	}
 
-	// Check each resolved IP
-	for _, ip := range ips {
-		// Only check IPv4
-		if ipv4 := ip.To4(); ipv4 != nil {
-			if providerID, found := rangeSet.FindProvider(ipv4); found {
-				// Get provider name
-				if provider := db.GetProvider(providerID); provider != nil {
-					return true, provider.Name
-				}
-				return true, providerID
-			}
-		}
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return waf.DetectDomain(ctx, dnsResolver, db, cnames, domain)
+}
 
-	return false, ""
+// getCNAMEDatabasePath returns the CDN CNAME pattern table stored alongside
+// the WAF database; when it does not exist the embedded table is used
+func getCNAMEDatabasePath(wafDBPath string) string {
+	if wafDBPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(wafDBPath), "cdn_cnames.json")
 }
 
-func printBanner(config *core.Config) {
+func printBanner(config *core.Config, dnsResolver *resolver.Resolver, asnDB *asn.Database, geoDB *geoip.Database, wafDB *waf.WAFDatabase, wafInfo waf.DatabaseInfo, wafErr error) {
 	fmt.Println()
 	fmt.Printf("%s           _      _         ___         %s\n", colors.CYAN, colors.NC)
 	fmt.Printf("%s ___  ____(_)__ _(_)__  ___/ (_)  _____ %s\n", colors.CYAN, colors.NC)
@@ -1761,12 +1996,34 @@ func printBanner(config *core.Config) {
 	fmt.Printf("%s[*]%s Domain: %s\n", colors.BLUE, colors.NC, config.Domain)
 
 	// Check if domain is behind WAF/CDN
-	if behindWAF, provider := checkDomainWAF(config.Domain, config.WAFDatabasePath); behindWAF {
-		fmt.Printf("%s[!]%s Domain appears to be behind %s%s%s\n", colors.YELLOW, colors.NC, colors.BOLD, provider, colors.NC)
+	for _, d := range checkDomainWAF(config.Domain, wafDB, config.WAFDatabasePath, dnsResolver) {
+		fmt.Printf("%s[!]%s Domain appears to be behind %s%s%s (%s %s)\n", colors.YELLOW, colors.NC, colors.BOLD, d.Name, colors.NC, strings.ToUpper(d.Method), d.Evidence)
 	}
 
 	fmt.Printf("%s[*]%s Mode: %s\n", colors.BLUE, colors.NC, config.Mode)
 	fmt.Printf("%s[*]%s Workers: %d\n", colors.BLUE, colors.NC, config.Workers)
 	fmt.Printf("%s[*]%s Timeout: %s\n", colors.BLUE, colors.NC, config.Timeout)
+
+	// Report which WAF database is in use and warn when it is stale
+	if wafErr != nil {
+		fmt.Printf("%s[!]%s WAF database: %s\n", colors.YELLOW, colors.NC, wafErr)
+	} else {
+		fmt.Printf("%s[*]%s WAF database: %s\n", colors.BLUE, colors.NC, wafInfo)
+		if wafInfo.IsStale() {
+			fmt.Printf("%s[!]%s WAF database is stale; run %sorigindive --update-waf%s to refresh it\n", colors.YELLOW, colors.NC, colors.BOLD, colors.NC)
+		}
+	}
+	if asnDB != nil {
+		fmt.Printf("%s[*]%s ASN database: %s (%d ranges)\n", colors.BLUE, colors.NC, getASNDatabasePath(config), asnDB.Len())
+	}
+	if geoDB != nil {
+		fmt.Printf("%s[*]%s GeoIP database: %s\n", colors.BLUE, colors.NC, geoDB)
+		if len(config.GeoInclude) > 0 {
+			fmt.Printf("%s[*]%s Countries included: %s\n", colors.BLUE, colors.NC, strings.Join(config.GeoInclude, ", "))
+		}
+		if len(config.GeoExclude) > 0 {
+			fmt.Printf("%s[*]%s Countries excluded: %s\n", colors.BLUE, colors.NC, strings.Join(config.GeoExclude, ", "))
+		}
+	}
 	fmt.Println()
 }
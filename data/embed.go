@@ -0,0 +1,26 @@
+// Package data embeds the default WAF ranges database, its update sources,
+// the CDN CNAME pattern table and the CDN edge signatures, so a binary
+// installed with go install works without the repository checkout
+package data
+
+import _ "embed"
+
+// WAFRanges is the default WAF ranges database (waf_ranges.json)
+//
+//go:embed waf_ranges.json
+var WAFRanges []byte
+
+// WAFSources is the default update source list (waf_sources.json)
+//
+//go:embed waf_sources.json
+var WAFSources []byte
+
+// CDNCNAMEs is the default CDN CNAME pattern table (cdn_cnames.json)
+//
+//go:embed cdn_cnames.json
+var CDNCNAMEs []byte
+
+// CDNSignatures is the default CDN edge signature table (cdn_signatures.json)
+//
+//go:embed cdn_signatures.json
+var CDNSignatures []byte
@@ -0,0 +1,344 @@
+package asn
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+// DefaultDatabaseURL is the ip2asn table downloaded by --update-asn-db
+// (IPv4 and IPv6, refreshed hourly by iptoasn.com)
+const DefaultDatabaseURL = "https://iptoasn.com/data/ip2asn-combined.tsv.gz"
+
+// downloadTimeout bounds a database download (the combined table is ~7 MB)
+var downloadTimeout = 5 * time.Minute
+
+// Record is the ASN that announces an IP
+type Record struct {
+	ASN     int    // AS number
+	Org     string // AS description, e.g. "CLOUDFLARENET - Cloudflare, Inc."; empty for pfx2as files
+	Country string // Country the AS is registered in; empty for pfx2as files
+}
+
+// String returns "AS<n> <org>", or just "AS<n>" without an organization
+func (r Record) String() string {
+	if r.Org == "" {
+		return fmt.Sprintf("AS%d", r.ASN)
+	}
+	return fmt.Sprintf("AS%d %s", r.ASN, r.Org)
+}
+
+// ipRange is one announced range; parent is the enclosing range
+// (pfx2as prefixes nest) or -1
+type ipRange struct {
+	start, end netip.Addr
+	record     Record
+	parent     int
+}
+
+// Database is an offline IP-to-ASN table loaded from an ip2asn
+// (range_start, range_end, AS_number, country_code, AS_description) or
+// CAIDA pfx2as (prefix, length, ASN) file, plain or gzipped
+type Database struct {
+	ranges []ipRange
+}
+
+// LoadDatabase loads an ip2asn or pfx2as file
+func LoadDatabase(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ASN database: %w", err)
+	}
+	defer f.Close()
+
+	db, err := ParseDatabase(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// ParseDatabase parses an ip2asn or pfx2as table, detecting gzip and the
+// format of each line
+func ParseDatabase(r io.Reader) (*Database, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip: %w", err)
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	db := &Database{}
+	orgs := make(map[string]string) // Interned AS descriptions
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r, ok, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if !ok {
+			continue
+		}
+		if org, seen := orgs[r.record.Org]; seen {
+			r.record.Org = org
+		} else {
+			orgs[r.record.Org] = r.record.Org
+		}
+		db.ranges = append(db.ranges, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ASN database: %w", err)
+	}
+	if len(db.ranges) == 0 {
+		return nil, fmt.Errorf("no ASN ranges found")
+	}
+
+	db.index()
+	return db, nil
+}
+
+// parseLine parses one ip2asn or pfx2as line. Unrouted ranges (AS 0)
+// are skipped (ok = false).
+func parseLine(line string) (ipRange, bool, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 3 {
+		fields = strings.Fields(line)
+	}
+
+	var r ipRange
+	switch {
+	case len(fields) >= 5: // ip2asn
+		start, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return r, false, fmt.Errorf("invalid range start %q", fields[0])
+		}
+		end, err := netip.ParseAddr(fields[1])
+		if err != nil || end.Less(start) || start.Is4() != end.Is4() {
+			return r, false, fmt.Errorf("invalid range end %q", fields[1])
+		}
+		r.start, r.end = start.Unmap(), end.Unmap()
+		r.record.Country = strings.TrimSpace(fields[3])
+		r.record.Org = strings.TrimSpace(fields[4])
+		if r.record.Country == "None" {
+			r.record.Country = ""
+		}
+		if r.record.Org == "Not routed" {
+			r.record.Org = ""
+		}
+
+	case len(fields) == 3: // pfx2as
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return r, false, fmt.Errorf("invalid prefix %q", fields[0])
+		}
+		bits, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return r, false, fmt.Errorf("invalid prefix length %q", fields[1])
+		}
+		prefix, err := addr.Unmap().Prefix(bits)
+		if err != nil {
+			return r, false, fmt.Errorf("invalid prefix %s/%s", fields[0], fields[1])
+		}
+		r.start, r.end = prefix.Addr(), lastAddr(prefix)
+
+	default:
+		return r, false, fmt.Errorf("unrecognized format (want ip2asn or pfx2as)")
+	}
+
+	// pfx2as multi-origin ("64500_64501") and AS sets ("64500,64501") use the first AS
+	asnField := fields[2]
+	if len(fields) == 3 {
+		asnField = strings.FieldsFunc(asnField, func(c rune) bool { return c == '_' || c == ',' })[0]
+	}
+	asn, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(asnField), "AS"))
+	if err != nil {
+		return r, false, fmt.Errorf("invalid AS number %q", fields[2])
+	}
+	if asn == 0 {
+		return r, false, nil
+	}
+	r.record.ASN = asn
+
+	return r, true, nil
+}
+
+// lastAddr returns the last address of a prefix
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// index sorts the ranges by start (widest first on ties) and links each
+// range to the one enclosing it, so Lookup finds the most specific match
+func (db *Database) index() {
+	sort.Slice(db.ranges, func(i, j int) bool {
+		a, b := db.ranges[i], db.ranges[j]
+		if c := a.start.Compare(b.start); c != 0 {
+			return c < 0
+		}
+		return b.end.Less(a.end)
+	})
+
+	var stack []int
+	for i := range db.ranges {
+		r := &db.ranges[i]
+		for len(stack) > 0 && db.ranges[stack[len(stack)-1]].end.Less(r.start) {
+			stack = stack[:len(stack)-1]
+		}
+		r.parent = -1
+		if len(stack) > 0 {
+			r.parent = stack[len(stack)-1]
+		}
+		stack = append(stack, i)
+	}
+}
+
+// Len returns the number of announced ranges
+func (db *Database) Len() int {
+	if db == nil {
+		return 0
+	}
+	return len(db.ranges)
+}
+
+// Lookup returns the ASN announcing ip
+func (db *Database) Lookup(ip net.IP) (Record, bool) {
+	if db == nil {
+		return Record{}, false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return Record{}, false
+	}
+	addr = addr.Unmap()
+
+	// Last range starting at or before addr, then outwards through enclosing ranges
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].start)
+	}) - 1
+	for i >= 0 {
+		r := &db.ranges[i]
+		if r.start.Is4() == addr.Is4() && !r.end.Less(addr) && !addr.Less(r.start) {
+			return r.record, true
+		}
+		i = r.parent
+	}
+
+	return Record{}, false
+}
+
+// LookupString returns the ASN announcing an IP address string
+func (db *Database) LookupString(ipStr string) (Record, bool) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return Record{}, false
+	}
+	return db.Lookup(ip)
+}
+
+// EnrichResult records the ASN and organization of an active scan result
+func (db *Database) EnrichResult(result *core.IPResult) {
+	if rec, ok := db.LookupString(result.IP); ok {
+		result.ASN = rec.ASN
+		result.ASOrg = rec.Org
+	}
+}
+
+// EnrichPassiveIP records the ASN and organization of a passive IP. The
+// "asn" and "whois_org" metadata used by scoring are filled in unless a
+// source already reported them.
+func (db *Database) EnrichPassiveIP(ip *core.PassiveIP) {
+	rec, ok := db.LookupString(ip.IP)
+	if !ok {
+		return
+	}
+
+	ip.ASN = rec.ASN
+	ip.ASOrg = rec.Org
+	if ip.Metadata == nil {
+		ip.Metadata = make(map[string]interface{})
+	}
+	if _, exists := ip.Metadata["asn"]; !exists {
+		ip.Metadata["asn"] = rec.String()
+	}
+	if _, exists := ip.Metadata["whois_org"]; !exists && rec.Org != "" {
+		ip.Metadata["whois_org"] = rec.Org
+	}
+}
+
+// DefaultDatabasePath returns where --update-asn-db caches the ip2asn table
+func DefaultDatabasePath() string {
+	return filepath.Join(getDefaultCacheDir(), "ip2asn-combined.tsv.gz")
+}
+
+// DownloadDatabase downloads an ip2asn or pfx2as table to path and returns
+// it parsed. The file is only replaced once the download parses, so a
+// failed download keeps the previous copy.
+func DownloadDatabase(url, path string) (*Database, error) {
+	client := &http.Client{Timeout: downloadTimeout}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "origindive/3.1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	db, err := ParseDatabase(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("downloaded ASN database is invalid: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write ASN database: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write ASN database: %w", err)
+	}
+
+	return db, nil
+}
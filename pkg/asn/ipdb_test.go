@@ -0,0 +1,184 @@
+package asn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+const testIP2ASN = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"198.51.100.0\t198.51.100.255\t64500\tDE\tEXAMPLE-NET Example GmbH\n" +
+	"2001:db8::\t2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\t64501\tNL\tEXAMPLE6\n"
+
+const testPfx2as = "10.0.0.0\t8\t64500\n" +
+	"10.1.0.0\t16\t64501_64502\n" +
+	"10.1.2.0\t24\t64503,64504\n" +
+	"10.3.0.0\t16\t64505\n"
+
+func TestParseDatabase_IP2ASN(t *testing.T) {
+	db, err := ParseDatabase(strings.NewReader(testIP2ASN))
+	if err != nil {
+		t.Fatalf("ParseDatabase() error: %v", err)
+	}
+	if db.Len() != 3 {
+		t.Errorf("Len() = %d, want 3 (unrouted range skipped)", db.Len())
+	}
+
+	tests := []struct {
+		ip      string
+		want    Record
+		wantHit bool
+	}{
+		{"1.0.0.1", Record{ASN: 13335, Org: "CLOUDFLARENET", Country: "US"}, true},
+		{"1.0.2.1", Record{}, false},
+		{"198.51.100.255", Record{ASN: 64500, Org: "EXAMPLE-NET Example GmbH", Country: "DE"}, true},
+		{"198.51.101.0", Record{}, false},
+		{"2001:db8::1", Record{ASN: 64501, Org: "EXAMPLE6", Country: "NL"}, true},
+		{"::ffff:1.0.0.1", Record{ASN: 13335, Org: "CLOUDFLARENET", Country: "US"}, true},
+		{"not-an-ip", Record{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := db.LookupString(tt.ip)
+		if ok != tt.wantHit || got != tt.want {
+			t.Errorf("LookupString(%s) = %+v, %v; want %+v, %v", tt.ip, got, ok, tt.want, tt.wantHit)
+		}
+	}
+}
+
+func TestParseDatabase_Pfx2asLongestMatch(t *testing.T) {
+	db, err := ParseDatabase(strings.NewReader(testPfx2as))
+	if err != nil {
+		t.Fatalf("ParseDatabase() error: %v", err)
+	}
+
+	tests := map[string]int{
+		"10.1.2.3":   64503, // /24 inside the /16 inside the /8
+		"10.1.3.1":   64501, // Back out to the /16
+		"10.2.0.1":   64500, // Between the /16s
+		"10.3.255.1": 64505,
+		"10.255.0.1": 64500,
+	}
+	for ip, want := range tests {
+		got, ok := db.LookupString(ip)
+		if !ok || got.ASN != want {
+			t.Errorf("LookupString(%s) = %+v, %v; want AS%d", ip, got, ok, want)
+		}
+	}
+	if _, ok := db.LookupString("11.0.0.1"); ok {
+		t.Error("LookupString(11.0.0.1) should miss")
+	}
+}
+
+func TestLoadDatabase_Gzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(testIP2ASN))
+	gz.Close()
+
+	path := filepath.Join(t.TempDir(), "ip2asn.tsv.gz")
+	os.WriteFile(path, buf.Bytes(), 0644)
+
+	db, err := LoadDatabase(path)
+	if err != nil {
+		t.Fatalf("LoadDatabase() error: %v", err)
+	}
+	if rec, ok := db.LookupString("198.51.100.7"); !ok || rec.ASN != 64500 {
+		t.Errorf("LookupString() = %+v, %v", rec, ok)
+	}
+}
+
+func TestParseDatabase_Invalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":       "# comment only\n",
+		"bad start":   "1.0.0\t1.0.0.255\t13335\tUS\tX\n",
+		"bad asn":     "10.0.0.0\t8\tASX\n",
+		"bad prefix":  "10.0.0.0\t33\t64500\n",
+		"two columns": "10.0.0.0/8 64500\n",
+	} {
+		if _, err := ParseDatabase(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: ParseDatabase() should fail", name)
+		}
+	}
+
+	if _, err := LoadDatabase(filepath.Join(t.TempDir(), "missing.tsv")); err == nil {
+		t.Error("LoadDatabase() should fail for a missing file")
+	}
+}
+
+func TestDatabase_Enrich(t *testing.T) {
+	db, _ := ParseDatabase(strings.NewReader(testIP2ASN))
+
+	result := &core.IPResult{IP: "198.51.100.7"}
+	db.EnrichResult(result)
+	if result.ASN != 64500 || result.ASOrg != "EXAMPLE-NET Example GmbH" {
+		t.Errorf("EnrichResult() = %d %q", result.ASN, result.ASOrg)
+	}
+
+	ip := &core.PassiveIP{IP: "198.51.100.7"}
+	db.EnrichPassiveIP(ip)
+	if ip.ASN != 64500 || ip.Metadata["asn"] != "AS64500 EXAMPLE-NET Example GmbH" || ip.Metadata["whois_org"] != "EXAMPLE-NET Example GmbH" {
+		t.Errorf("EnrichPassiveIP() = %+v", ip)
+	}
+
+	// Metadata reported by a source is kept
+	shodan := &core.PassiveIP{IP: "1.0.0.1", Metadata: map[string]interface{}{"asn": "AS13335"}}
+	db.EnrichPassiveIP(shodan)
+	if shodan.Metadata["asn"] != "AS13335" || shodan.ASOrg != "CLOUDFLARENET" {
+		t.Errorf("EnrichPassiveIP() = %+v", shodan)
+	}
+
+	// A nil database leaves results untouched
+	var none *Database
+	untouched := &core.PassiveIP{IP: "198.51.100.7"}
+	none.EnrichPassiveIP(untouched)
+	none.EnrichResult(result)
+	if untouched.ASN != 0 || untouched.Metadata != nil || none.Len() != 0 {
+		t.Errorf("nil database enriched %+v", untouched)
+	}
+}
+
+func TestDownloadDatabase(t *testing.T) {
+	body := testIP2ASN
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "asn", "ip2asn.tsv")
+	db, err := DownloadDatabase(srv.URL, path)
+	if err != nil {
+		t.Fatalf("DownloadDatabase() error: %v", err)
+	}
+	if db.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", db.Len())
+	}
+	if cached, err := LoadDatabase(path); err != nil || cached.Len() != 3 {
+		t.Errorf("cached copy = %v, %v", cached, err)
+	}
+
+	// An invalid download keeps the previous copy
+	body = "<html>maintenance</html>\n"
+	if _, err := DownloadDatabase(srv.URL, path); err == nil {
+		t.Error("DownloadDatabase() should reject an invalid table")
+	}
+	if cached, err := LoadDatabase(path); err != nil || cached.Len() != 3 {
+		t.Errorf("previous copy = %v, %v", cached, err)
+	}
+}
+
+func TestRecord_String(t *testing.T) {
+	if s := (Record{ASN: 64500, Org: "EXAMPLE"}).String(); s != "AS64500 EXAMPLE" {
+		t.Errorf("String() = %q", s)
+	}
+	if s := (Record{ASN: 64500}).String(); s != "AS64500" {
+		t.Errorf("String() = %q", s)
+	}
+}
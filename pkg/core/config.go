@@ -4,6 +4,7 @@ package core
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -19,6 +20,7 @@ type PassiveConfig struct {
 	DNSDumpsterKeys    []string `yaml:"dnsdumpster_keys" json:"dnsdumpster_keys"`
 	VirusTotalKeys     []string `yaml:"virustotal_keys" json:"virustotal_keys"`
 	ViewDNSKeys        []string `yaml:"viewdns_keys" json:"viewdns_keys"`
+	HunterKeys         []string `yaml:"hunter_keys" json:"hunter_keys"`
 }
 
 // Config holds all configuration for origindive
@@ -67,7 +69,9 @@ type Config struct {
 	// WAF filtering
 	SkipWAF         bool     `yaml:"skip_waf" json:"skip_waf"`
 	SkipProviders   []string `yaml:"skip_providers" json:"skip_providers"`
+	SkipCategories  []string `yaml:"skip_categories" json:"skip_categories"` // Provider categories --skip-waf skips (default: cdn, waf)
 	CustomWAFFile   string   `yaml:"custom_waf_file" json:"custom_waf_file"`
+	CustomWAFFiles  []string `yaml:"custom_waf_files" json:"custom_waf_files"` // More custom ranges files (JSON or text)
 	ShowSkipped     bool     `yaml:"show_skipped" json:"show_skipped"`
 	NoWAFUpdate     bool     `yaml:"no_waf_update" json:"no_waf_update"`
 	WAFDatabasePath string   `yaml:"-" json:"-"` // Runtime-computed path to WAF database
@@ -78,10 +82,46 @@ type Config struct {
 	MinConfidence  float64  `yaml:"min_confidence" json:"min_confidence"`
 	PassiveSources []string `yaml:"passive_sources" json:"passive_sources"`
 
+	// Passive response cache
+	PassiveCacheTTL    time.Duration `yaml:"passive_cache_ttl" json:"passive_cache_ttl"` // How long cached source results are reused
+	PassiveCacheTTLSet bool          `yaml:"-" json:"-"`                                 // TTL given by --cache-ttl or the scan config file (0 included)
+	NoCache            bool          `yaml:"no_cache" json:"no_cache"`                   // Neither read nor write the cache
+	RefreshCache       bool          `yaml:"refresh_cache" json:"refresh_cache"`         // Re-query sources and overwrite the cache
+
+	// Passive source HTTP client
+	PassiveUserAgent string `yaml:"passive_user_agent" json:"passive_user_agent"` // User-Agent sent to passive APIs (default: origindive/<version>)
+	PassiveNoProxy   bool   `yaml:"passive_no_proxy" json:"passive_no_proxy"`     // Query passive APIs directly even when --proxy is set
+	PassiveSiteFetch bool   `yaml:"passive_site_fetch" json:"passive_site_fetch"` // Let sources fetch the target site for title, favicon and certificate pivots
+
+	// DNS resolver for all lookups ("system", IP[:port], udp://, tcp://, tls:// or https:// DoH URL)
+	Resolver string `yaml:"resolver" json:"resolver"`
+
+	// Offline IP-to-ASN database (ip2asn or pfx2as file; default: the copy --update-asn-db caches)
+	ASNDatabase string `yaml:"asn_database" json:"asn_database"`
+
+	// Offline GeoIP (MMDB files: GeoLite2/GeoIP2, IPinfo, DB-IP) and country filtering
+	GeoDatabases []string `yaml:"geo_databases" json:"geo_databases"` // e.g. GeoLite2-City.mmdb, GeoLite2-ASN.mmdb
+	GeoInclude   []string `yaml:"geo_include" json:"geo_include"`     // Only keep IPs in these countries (ISO codes)
+	GeoExclude   []string `yaml:"geo_exclude" json:"geo_exclude"`     // Drop IPs in these countries
+
+	// DNS subdomain brute force
+	SubdomainWordlist     string   `yaml:"subdomain_wordlist" json:"subdomain_wordlist"`         // Wordlist file (default: built-in common names)
+	Resolvers             []string `yaml:"resolvers" json:"resolvers"`                           // DNS resolvers for brute forcing (IP[:port], "system" or a Resolver URL)
+	ResolverRate          int      `yaml:"resolver_rate" json:"resolver_rate"`                   // Queries per second per resolver (0 = unlimited)
+	SubdomainPermutations bool     `yaml:"subdomain_permutations" json:"subdomain_permutations"` // Try dev-/-staging/numeric variants of found names
+
+	// Passive source base URL overrides (source name -> URL, e.g., an enterprise mirror)
+	PassiveEndpoints map[string]string `yaml:"passive_endpoints" json:"passive_endpoints"`
+
+	// API key rotation and rate limit handling (zero value = use global config)
+	APIFailover APIFailoverConfig `yaml:"api_failover" json:"api_failover"`
+
 	// API Keys for passive sources (flat structure for easier YAML editing)
 	ShodanKeys         []string `yaml:"shodan_keys" json:"shodan_keys"`
-	CensysTokens       []string `yaml:"censys_tokens" json:"censys_tokens"` // PAT tokens (Bearer auth)
-	CensysOrgID        string   `yaml:"censys_org_id" json:"censys_org_id"` // Organization ID for paid plans
+	ShodanMaxCredits   int      `yaml:"shodan_max_credits" json:"shodan_max_credits"` // Query credits one search may spend (0 = default 10)
+	CensysTokens       []string `yaml:"censys_tokens" json:"censys_tokens"`           // PAT tokens (Bearer auth)
+	CensysOrgID        string   `yaml:"censys_org_id" json:"censys_org_id"`           // Organization ID for paid plans
+	CensysMode         string   `yaml:"censys_mode" json:"censys_mode"`               // names (default), cert or all
 	SecurityTrailsKeys []string `yaml:"securitytrails_keys" json:"securitytrails_keys"`
 	ZoomEyeKeys        []string `yaml:"zoomeye_keys" json:"zoomeye_keys"`
 	DNSDumpsterKeys    []string `yaml:"dnsdumpster_keys" json:"dnsdumpster_keys"`
@@ -118,19 +158,23 @@ const (
 	FormatCSV  OutputFormat = "csv"
 )
 
+// DefaultPassiveCacheTTL is how long passive source results are reused
+const DefaultPassiveCacheTTL = 24 * time.Hour
+
 // DefaultConfig returns a configuration with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
-		Mode:           ModeActive,
-		HTTPMethod:     "GET",
-		Timeout:        5 * time.Second,
-		ConnectTimeout: 3 * time.Second,
-		Workers:        10,
-		MaxRedirects:   0,
-		Format:         FormatText,
-		MinConfidence:  0.7,
-		// Use all passive sources by default (filtered by API key availability)
-		PassiveSources: []string{"ct", "dns", "shodan", "censys", "securitytrails", "zoomeye", "wayback", "virustotal", "viewdns", "dnsdumpster"},
+		Mode:            ModeActive,
+		HTTPMethod:      "GET",
+		Timeout:         5 * time.Second,
+		ConnectTimeout:  3 * time.Second,
+		Workers:         10,
+		MaxRedirects:    0,
+		Format:          FormatText,
+		MinConfidence:   0.7,
+		PassiveCacheTTL: DefaultPassiveCacheTTL,
+		// PassiveSources left empty: every registered source is used
+		// (sources without configured credentials are skipped)
 	}
 }
 
@@ -169,9 +213,29 @@ func LoadFromFile(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// An explicit passive_cache_ttl (even 0 or the default) beats the global config
+	var keys map[string]interface{}
+	if yaml.Unmarshal(data, &keys) == nil {
+		_, config.PassiveCacheTTLSet = keys["passive_cache_ttl"]
+	}
+
 	return config, nil
 }
 
+// CustomWAFPaths returns every custom WAF ranges file, without duplicates
+func (c *Config) CustomWAFPaths() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, path := range append([]string{c.CustomWAFFile}, c.CustomWAFFiles...) {
+		path = strings.TrimSpace(path)
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
 // MergeWithCLI merges CLI flags with config (CLI takes precedence)
 func (c *Config) MergeWithCLI(cli *Config) {
 	// Only merge non-zero/non-empty CLI values
@@ -217,9 +281,16 @@ func (c *Config) MergeWithCLI(cli *Config) {
 	if len(cli.SkipProviders) > 0 {
 		c.SkipProviders = cli.SkipProviders
 	}
+	if len(cli.SkipCategories) > 0 {
+		c.SkipCategories = cli.SkipCategories
+	}
 	if cli.CustomWAFFile != "" {
 		c.CustomWAFFile = cli.CustomWAFFile
 	}
+	if len(cli.CustomWAFFiles) > 0 {
+		c.CustomWAFFile = cli.CustomWAFFile // --custom-waf replaces every configured file
+		c.CustomWAFFiles = cli.CustomWAFFiles
+	}
 	if cli.ShowSkipped {
 		c.ShowSkipped = cli.ShowSkipped
 	}
@@ -238,6 +309,58 @@ func (c *Config) MergeWithCLI(cli *Config) {
 	if len(cli.PassiveSources) > 0 {
 		c.PassiveSources = cli.PassiveSources
 	}
+	if cli.PassiveCacheTTLSet {
+		c.PassiveCacheTTL = cli.PassiveCacheTTL
+		c.PassiveCacheTTLSet = true
+	}
+	if cli.NoCache {
+		c.NoCache = cli.NoCache
+	}
+	if cli.RefreshCache {
+		c.RefreshCache = cli.RefreshCache
+	}
+	if cli.PassiveUserAgent != "" {
+		c.PassiveUserAgent = cli.PassiveUserAgent
+	}
+	if cli.PassiveNoProxy {
+		c.PassiveNoProxy = cli.PassiveNoProxy
+	}
+	if cli.PassiveSiteFetch {
+		c.PassiveSiteFetch = cli.PassiveSiteFetch
+	}
+	if cli.ShodanMaxCredits > 0 {
+		c.ShodanMaxCredits = cli.ShodanMaxCredits
+	}
+	if cli.CensysMode != "" {
+		c.CensysMode = cli.CensysMode
+	}
+	if cli.Resolver != "" {
+		c.Resolver = cli.Resolver
+	}
+	if cli.ASNDatabase != "" {
+		c.ASNDatabase = cli.ASNDatabase
+	}
+	if len(cli.GeoDatabases) > 0 {
+		c.GeoDatabases = cli.GeoDatabases
+	}
+	if len(cli.GeoInclude) > 0 {
+		c.GeoInclude = cli.GeoInclude
+	}
+	if len(cli.GeoExclude) > 0 {
+		c.GeoExclude = cli.GeoExclude
+	}
+	if cli.SubdomainWordlist != "" {
+		c.SubdomainWordlist = cli.SubdomainWordlist
+	}
+	if len(cli.Resolvers) > 0 {
+		c.Resolvers = cli.Resolvers
+	}
+	if cli.ResolverRate > 0 {
+		c.ResolverRate = cli.ResolverRate
+	}
+	if cli.SubdomainPermutations {
+		c.SubdomainPermutations = cli.SubdomainPermutations
+	}
 	// Note: API keys now loaded from global config only, not CLI
 	if cli.OutputFile != "" {
 		c.OutputFile = cli.OutputFile
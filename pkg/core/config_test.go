@@ -3,6 +3,7 @@ package core
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -202,6 +203,89 @@ func TestMergeWithCLI(t *testing.T) {
 	}
 }
 
+func TestMergeWithCLI_PassiveCacheTTL(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "scan.yaml")
+	if err := os.WriteFile(configPath, []byte("passive_cache_ttl: 6h\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+	global := &GlobalConfig{PassiveCacheTTL: "1h"}
+
+	tests := []struct {
+		name string
+		cli  *Config
+		want time.Duration
+	}{
+		{"flag not given", DefaultConfig(), 6 * time.Hour},
+		{"explicit 0", &Config{PassiveCacheTTL: 0, PassiveCacheTTLSet: true}, 0},
+		{"explicit default", &Config{PassiveCacheTTL: DefaultPassiveCacheTTL, PassiveCacheTTLSet: true}, DefaultPassiveCacheTTL},
+	}
+	for _, tt := range tests {
+		config, err := LoadFromFile(configPath)
+		if err != nil {
+			t.Fatalf("LoadFromFile() error = %v", err)
+		}
+		config.MergeWithCLI(tt.cli)
+		global.MergeIntoConfig(config)
+		if config.PassiveCacheTTL != tt.want {
+			t.Errorf("%s: PassiveCacheTTL = %v, want %v", tt.name, config.PassiveCacheTTL, tt.want)
+		}
+	}
+
+	// An explicit 0 in the scan config file beats the global config
+	if err := os.WriteFile(configPath, []byte("passive_cache_ttl: 0s\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+	config, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	config.MergeWithCLI(DefaultConfig())
+	global.MergeIntoConfig(config)
+	if config.PassiveCacheTTL != 0 {
+		t.Errorf("PassiveCacheTTL = %v, want 0 from the scan config file", config.PassiveCacheTTL)
+	}
+}
+
+func TestCustomWAFPaths(t *testing.T) {
+	c := &Config{CustomWAFFile: "a.txt", CustomWAFFiles: []string{"b.json", " a.txt ", ""}}
+	if got := strings.Join(c.CustomWAFPaths(), ","); got != "a.txt,b.json" {
+		t.Errorf("CustomWAFPaths() = %s, want a.txt,b.json", got)
+	}
+
+	// --custom-waf replaces the configured files
+	c.MergeWithCLI(&Config{CustomWAFFiles: []string{"c.txt"}})
+	if got := strings.Join(c.CustomWAFPaths(), ","); got != "c.txt" {
+		t.Errorf("CustomWAFPaths() after CLI = %s, want c.txt", got)
+	}
+}
+
+// --custom-waf replaces custom_waf_file and custom_waf_files from a config file
+func TestMergeWithCLI_CustomWAFReplacesFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "scan.yaml")
+	data := "custom_waf_file: office.txt\ncustom_waf_files:\n  - partners.json\n"
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	config, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	cli := DefaultConfig()
+	cli.CustomWAFFiles = []string{"cli-a.txt", "cli-b.txt"}
+	config.MergeWithCLI(cli)
+	if got := strings.Join(config.CustomWAFPaths(), ","); got != "cli-a.txt,cli-b.txt" {
+		t.Errorf("CustomWAFPaths() = %s, want only the CLI files", got)
+	}
+
+	// Without --custom-waf the configured files are kept
+	config, _ = LoadFromFile(configPath)
+	config.MergeWithCLI(DefaultConfig())
+	if got := strings.Join(config.CustomWAFPaths(), ","); got != "office.txt,partners.json" {
+		t.Errorf("CustomWAFPaths() = %s, want the configured files", got)
+	}
+}
+
 func TestMergeWithCLI_EmptyCLIDoesNotOverride(t *testing.T) {
 	fileConfig := &Config{
 		Domain:  "example.com",
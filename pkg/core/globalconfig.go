@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,13 +18,16 @@ import (
 type GlobalConfig struct {
 	// API keys for passive sources (multiple keys per service for rotation)
 	ShodanKeys         []string           `yaml:"shodan_keys,omitempty" json:"shodan_keys,omitempty"`
-	CensysTokens       []string           `yaml:"censys_tokens,omitempty" json:"censys_tokens,omitempty"` // PAT tokens (Bearer auth)
-	CensysOrgID        string             `yaml:"censys_org_id,omitempty" json:"censys_org_id,omitempty"` // Organization ID for paid plans
+	ShodanMaxCredits   int                `yaml:"shodan_max_credits,omitempty" json:"shodan_max_credits,omitempty"` // Query credits per search (default: 10)
+	CensysTokens       []string           `yaml:"censys_tokens,omitempty" json:"censys_tokens,omitempty"`           // PAT tokens (Bearer auth)
+	CensysOrgID        string             `yaml:"censys_org_id,omitempty" json:"censys_org_id,omitempty"`           // Organization ID for paid plans
+	CensysMode         string             `yaml:"censys_mode,omitempty" json:"censys_mode,omitempty"`               // names (default), cert or all
 	SecurityTrailsKeys []string           `yaml:"securitytrails_keys,omitempty" json:"securitytrails_keys,omitempty"`
 	ZoomEyeKeys        []string           `yaml:"zoomeye_keys,omitempty" json:"zoomeye_keys,omitempty"`
 	DNSDumpsterKeys    []string           `yaml:"dnsdumpster_keys,omitempty" json:"dnsdumpster_keys,omitempty"`
 	VirusTotalKeys     []string           `yaml:"virustotal_keys,omitempty" json:"virustotal_keys,omitempty"`
 	ViewDNSKeys        []string           `yaml:"viewdns_keys,omitempty" json:"viewdns_keys,omitempty"`
+	HunterKeys         []string           `yaml:"hunter_keys,omitempty" json:"hunter_keys,omitempty"`
 	CensysCreds        []CensysCredential `yaml:"censys_creds,omitempty" json:"censys_creds,omitempty"` // Legacy format (deprecated)
 
 	// Proxy service API keys
@@ -39,14 +44,38 @@ type GlobalConfig struct {
 	Workers int `yaml:"workers,omitempty" json:"workers,omitempty"`
 
 	// WAF filtering (global defaults)
-	SkipWAF       bool     `yaml:"skip_waf,omitempty" json:"skip_waf,omitempty"`
-	SkipProviders []string `yaml:"skip_providers,omitempty" json:"skip_providers,omitempty"`
-	ShowSkipped   bool     `yaml:"show_skipped,omitempty" json:"show_skipped,omitempty"`
-	NoWAFUpdate   bool     `yaml:"no_waf_update,omitempty" json:"no_waf_update,omitempty"`
+	SkipWAF        bool     `yaml:"skip_waf,omitempty" json:"skip_waf,omitempty"`
+	SkipProviders  []string `yaml:"skip_providers,omitempty" json:"skip_providers,omitempty"`
+	SkipCategories []string `yaml:"skip_categories,omitempty" json:"skip_categories,omitempty"` // e.g., cdn, waf, cloud
+	ShowSkipped    bool     `yaml:"show_skipped,omitempty" json:"show_skipped,omitempty"`
+	NoWAFUpdate    bool     `yaml:"no_waf_update,omitempty" json:"no_waf_update,omitempty"`
 
 	// Passive scan (global defaults)
-	PassiveSources []string `yaml:"passive_sources,omitempty" json:"passive_sources,omitempty"`
-	MinConfidence  float64  `yaml:"min_confidence,omitempty" json:"min_confidence,omitempty"`
+	PassiveSources  []string `yaml:"passive_sources,omitempty" json:"passive_sources,omitempty"`
+	MinConfidence   float64  `yaml:"min_confidence,omitempty" json:"min_confidence,omitempty"`
+	PassiveCacheTTL string   `yaml:"passive_cache_ttl,omitempty" json:"passive_cache_ttl,omitempty"` // e.g., "24h"; "0" disables cache reads
+
+	PassiveUserAgent string `yaml:"passive_user_agent,omitempty" json:"passive_user_agent,omitempty"` // Default: origindive/<version>
+	PassiveNoProxy   bool   `yaml:"passive_no_proxy,omitempty" json:"passive_no_proxy,omitempty"`     // Never route passive APIs through --proxy
+	PassiveSiteFetch bool   `yaml:"passive_site_fetch,omitempty" json:"passive_site_fetch,omitempty"` // Let sources contact the target site for pivots
+
+	// Passive source base URL overrides (source name -> URL)
+	PassiveEndpoints map[string]string `yaml:"passive_endpoints,omitempty" json:"passive_endpoints,omitempty"`
+
+	// DNS resolver for all lookups
+	Resolver string `yaml:"resolver,omitempty" json:"resolver,omitempty"` // "system", IP[:port], tls://host or https:// DoH URL
+
+	// Offline IP-to-ASN database
+	ASNDatabase string `yaml:"asn_database,omitempty" json:"asn_database,omitempty"` // ip2asn or pfx2as file (.tsv or .gz)
+
+	// Offline GeoIP and country filtering
+	GeoDatabases []string `yaml:"geo_databases,omitempty" json:"geo_databases,omitempty"` // MMDB files
+	GeoInclude   []string `yaml:"geo_include,omitempty" json:"geo_include,omitempty"`     // ISO country codes to keep
+	GeoExclude   []string `yaml:"geo_exclude,omitempty" json:"geo_exclude,omitempty"`     // ISO country codes to drop
+
+	// DNS subdomain brute force
+	Resolvers    []string `yaml:"resolvers,omitempty" json:"resolvers,omitempty"`         // IP[:port] or "system"
+	ResolverRate int      `yaml:"resolver_rate,omitempty" json:"resolver_rate,omitempty"` // Queries per second per resolver
 
 	// Output (global defaults)
 	Format     string `yaml:"format,omitempty" json:"format,omitempty"`
@@ -157,6 +186,9 @@ func formatGlobalConfigYAML(config *GlobalConfig) string {
 		for _, key := range config.ShodanKeys {
 			sb.WriteString(fmt.Sprintf("  - %s\n", key))
 		}
+		if config.ShodanMaxCredits > 0 {
+			sb.WriteString(fmt.Sprintf("shodan_max_credits: %d  # query credits one search may spend\n", config.ShodanMaxCredits))
+		}
 		sb.WriteString("\n")
 	}
 
@@ -170,6 +202,9 @@ func formatGlobalConfigYAML(config *GlobalConfig) string {
 		if config.CensysOrgID != "" {
 			sb.WriteString(fmt.Sprintf("censys_org_id: %s\n", config.CensysOrgID))
 		}
+		if config.CensysMode != "" {
+			sb.WriteString(fmt.Sprintf("censys_mode: %s  # names, cert or all\n", config.CensysMode))
+		}
 		sb.WriteString("\n")
 	}
 
@@ -203,6 +238,16 @@ func formatGlobalConfigYAML(config *GlobalConfig) string {
 		sb.WriteString("\n")
 	}
 
+	if len(config.HunterKeys) > 0 {
+		sb.WriteString("# Hunter.how\n")
+		sb.WriteString("# Get API key: https://hunter.how/search-api | Docs: https://hunter.how/search-api\n")
+		sb.WriteString("hunter_keys:\n")
+		for _, key := range config.HunterKeys {
+			sb.WriteString(fmt.Sprintf("  - %s\n", key))
+		}
+		sb.WriteString("\n")
+	}
+
 	if len(config.ViewDNSKeys) > 0 {
 		sb.WriteString("# ViewDNS\n")
 		sb.WriteString("# Get API key: https://viewdns.info/dashboard/api/account-details/ | Docs: https://viewdns.info/api/\n")
@@ -275,6 +320,12 @@ func formatGlobalConfigYAML(config *GlobalConfig) string {
 			sb.WriteString(fmt.Sprintf("  - %s\n", provider))
 		}
 	}
+	if len(config.SkipCategories) > 0 {
+		sb.WriteString("skip_categories:\n")
+		for _, category := range config.SkipCategories {
+			sb.WriteString(fmt.Sprintf("  - %s\n", category))
+		}
+	}
 	if config.ShowSkipped {
 		sb.WriteString("show_skipped: true\n")
 	}
@@ -293,6 +344,56 @@ func formatGlobalConfigYAML(config *GlobalConfig) string {
 	if config.MinConfidence > 0 {
 		sb.WriteString(fmt.Sprintf("min_confidence: %.1f\n", config.MinConfidence))
 	}
+	if config.PassiveCacheTTL != "" {
+		sb.WriteString(fmt.Sprintf("passive_cache_ttl: %s  # reuse passive results this long (0 = always re-query)\n", config.PassiveCacheTTL))
+	}
+	if config.PassiveUserAgent != "" {
+		sb.WriteString(fmt.Sprintf("passive_user_agent: %q\n", config.PassiveUserAgent))
+	}
+	if config.PassiveNoProxy {
+		sb.WriteString("passive_no_proxy: true  # query passive APIs directly even with --proxy\n")
+	}
+	if config.PassiveSiteFetch {
+		sb.WriteString("passive_site_fetch: true  # fetch the target site for title, favicon and certificate pivots\n")
+	}
+	if len(config.PassiveEndpoints) > 0 {
+		sb.WriteString("passive_endpoints:  # base URL overrides (mirrors, internal instances)\n")
+		names := make([]string, 0, len(config.PassiveEndpoints))
+		for name := range config.PassiveEndpoints {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", name, config.PassiveEndpoints[name]))
+		}
+	}
+	if config.Resolver != "" {
+		sb.WriteString(fmt.Sprintf("resolver: %s  # DNS resolver for all lookups\n", config.Resolver))
+	}
+	if len(config.Resolvers) > 0 {
+		sb.WriteString("resolvers:  # DNS resolvers for subdomain brute force\n")
+		for _, resolver := range config.Resolvers {
+			sb.WriteString(fmt.Sprintf("  - %s\n", resolver))
+		}
+	}
+	if config.ResolverRate > 0 {
+		sb.WriteString(fmt.Sprintf("resolver_rate: %d  # queries per second per resolver\n", config.ResolverRate))
+	}
+	if config.ASNDatabase != "" {
+		sb.WriteString(fmt.Sprintf("asn_database: %s  # offline IP-to-ASN table (ip2asn or pfx2as)\n", config.ASNDatabase))
+	}
+	if len(config.GeoDatabases) > 0 {
+		sb.WriteString("geo_databases:  # GeoIP MMDB files (GeoLite2, IPinfo, DB-IP)\n")
+		for _, path := range config.GeoDatabases {
+			sb.WriteString(fmt.Sprintf("  - %s\n", path))
+		}
+	}
+	if len(config.GeoInclude) > 0 {
+		sb.WriteString(fmt.Sprintf("geo_include: [%s]  # only keep IPs in these countries\n", strings.Join(config.GeoInclude, ", ")))
+	}
+	if len(config.GeoExclude) > 0 {
+		sb.WriteString(fmt.Sprintf("geo_exclude: [%s]  # drop IPs in these countries\n", strings.Join(config.GeoExclude, ", ")))
+	}
 	sb.WriteString("\n")
 
 	sb.WriteString("# Output Settings\n")
@@ -330,8 +431,7 @@ func DefaultGlobalConfig() *GlobalConfig {
 		ConnectTimeout: "3s",
 		Workers:        20,
 		SkipWAF:        true,
-		PassiveSources: []string{"ct", "dns", "shodan", "censys", "securitytrails", "zoomeye", "wayback", "virustotal", "viewdns", "dnsdumpster"},
-		MinConfidence:  0.7,
+		MinConfidence:  0.7, // PassiveSources empty = all registered sources
 		Format:         "text",
 		APIFailover: APIFailoverConfig{
 			Enabled:            true,
@@ -347,12 +447,18 @@ func (gc *GlobalConfig) MergeIntoConfig(c *Config) {
 	if len(c.ShodanKeys) == 0 && len(gc.ShodanKeys) > 0 {
 		c.ShodanKeys = gc.ShodanKeys
 	}
+	if c.ShodanMaxCredits == 0 && gc.ShodanMaxCredits > 0 {
+		c.ShodanMaxCredits = gc.ShodanMaxCredits
+	}
 	if len(c.CensysTokens) == 0 && len(gc.CensysTokens) > 0 {
 		c.CensysTokens = gc.CensysTokens
 	}
 	if c.CensysOrgID == "" && gc.CensysOrgID != "" {
 		c.CensysOrgID = gc.CensysOrgID
 	}
+	if c.CensysMode == "" && gc.CensysMode != "" {
+		c.CensysMode = gc.CensysMode
+	}
 	if len(c.SecurityTrailsKeys) == 0 && len(gc.SecurityTrailsKeys) > 0 {
 		c.SecurityTrailsKeys = gc.SecurityTrailsKeys
 	}
@@ -368,6 +474,9 @@ func (gc *GlobalConfig) MergeIntoConfig(c *Config) {
 	if len(c.ViewDNSKeys) == 0 && len(gc.ViewDNSKeys) > 0 {
 		c.ViewDNSKeys = gc.ViewDNSKeys
 	}
+	if len(c.HunterKeys) == 0 && len(gc.HunterKeys) > 0 {
+		c.HunterKeys = gc.HunterKeys
+	}
 
 	// Proxy service keys
 	if c.WebshareAPIKey == "" && len(gc.WebshareKeys) > 0 {
@@ -403,6 +512,9 @@ func (gc *GlobalConfig) MergeIntoConfig(c *Config) {
 	if len(c.SkipProviders) == 0 && len(gc.SkipProviders) > 0 {
 		c.SkipProviders = gc.SkipProviders
 	}
+	if len(c.SkipCategories) == 0 && len(gc.SkipCategories) > 0 {
+		c.SkipCategories = gc.SkipCategories
+	}
 	if !c.ShowSkipped && gc.ShowSkipped {
 		c.ShowSkipped = gc.ShowSkipped
 	}
@@ -417,6 +529,53 @@ func (gc *GlobalConfig) MergeIntoConfig(c *Config) {
 	if c.MinConfidence == 0.7 && gc.MinConfidence != 0 { // 0.7 is package default
 		c.MinConfidence = gc.MinConfidence
 	}
+	if !c.PassiveCacheTTLSet && gc.PassiveCacheTTL != "" {
+		if ttl, err := time.ParseDuration(gc.PassiveCacheTTL); err == nil {
+			c.PassiveCacheTTL = ttl
+		}
+	}
+	if c.PassiveUserAgent == "" && gc.PassiveUserAgent != "" {
+		c.PassiveUserAgent = gc.PassiveUserAgent
+	}
+	if !c.PassiveNoProxy && gc.PassiveNoProxy {
+		c.PassiveNoProxy = gc.PassiveNoProxy
+	}
+	if !c.PassiveSiteFetch && gc.PassiveSiteFetch {
+		c.PassiveSiteFetch = gc.PassiveSiteFetch
+	}
+	for name, url := range gc.PassiveEndpoints {
+		if _, set := c.PassiveEndpoints[name]; set {
+			continue // Scan config overrides global endpoints per source
+		}
+		if c.PassiveEndpoints == nil {
+			c.PassiveEndpoints = make(map[string]string)
+		}
+		c.PassiveEndpoints[name] = url
+	}
+	if c.Resolver == "" && gc.Resolver != "" {
+		c.Resolver = gc.Resolver
+	}
+	if len(c.Resolvers) == 0 && len(gc.Resolvers) > 0 {
+		c.Resolvers = gc.Resolvers
+	}
+	if c.ResolverRate == 0 && gc.ResolverRate > 0 {
+		c.ResolverRate = gc.ResolverRate
+	}
+	if c.ASNDatabase == "" && gc.ASNDatabase != "" {
+		c.ASNDatabase = gc.ASNDatabase
+	}
+	if len(c.GeoDatabases) == 0 && len(gc.GeoDatabases) > 0 {
+		c.GeoDatabases = gc.GeoDatabases
+	}
+	if len(c.GeoInclude) == 0 && len(gc.GeoInclude) > 0 {
+		c.GeoInclude = gc.GeoInclude
+	}
+	if len(c.GeoExclude) == 0 && len(gc.GeoExclude) > 0 {
+		c.GeoExclude = gc.GeoExclude
+	}
+	if c.APIFailover == (APIFailoverConfig{}) {
+		c.APIFailover = gc.APIFailover
+	}
 
 	// Output settings
 	if c.Format == "" || c.Format == FormatText {
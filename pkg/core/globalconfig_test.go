@@ -6,6 +6,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultGlobalConfig(t *testing.T) {
@@ -73,6 +74,7 @@ func TestSaveAndLoadGlobalConfig(t *testing.T) {
 	// Create test config
 	testConfig := DefaultGlobalConfig()
 	testConfig.ShodanKeys = []string{"test_shodan_key"}
+	testConfig.HunterKeys = []string{"test_hunter_key"}
 	testConfig.Workers = 50
 	testConfig.SkipWAF = false
 	testConfig.NoWAFUpdate = true // Set another field to verify
@@ -92,6 +94,9 @@ func TestSaveAndLoadGlobalConfig(t *testing.T) {
 	if len(loaded.ShodanKeys) != 1 || loaded.ShodanKeys[0] != "test_shodan_key" {
 		t.Errorf("ShodanKeys = %v, want [test_shodan_key]", loaded.ShodanKeys)
 	}
+	if len(loaded.HunterKeys) != 1 || loaded.HunterKeys[0] != "test_hunter_key" {
+		t.Errorf("HunterKeys = %v, want [test_hunter_key]", loaded.HunterKeys)
+	}
 	if loaded.Workers != 50 {
 		t.Errorf("Workers = %d, want 50", loaded.Workers)
 	}
@@ -189,6 +194,203 @@ func TestMergeIntoConfig_ScanConfigTakesPrecedence(t *testing.T) {
 	}
 }
 
+func TestMergeIntoConfig_APIFailover(t *testing.T) {
+	gc := DefaultGlobalConfig()
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.APIFailover != gc.APIFailover {
+		t.Errorf("APIFailover = %+v, want global %+v", scanConfig.APIFailover, gc.APIFailover)
+	}
+
+	// Settings from a scan config file take precedence
+	scanConfig = &Config{APIFailover: APIFailoverConfig{RetryAfterCooldown: true}}
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.APIFailover.Enabled || !scanConfig.APIFailover.RetryAfterCooldown {
+		t.Errorf("APIFailover = %+v, want scan config values", scanConfig.APIFailover)
+	}
+}
+
+func TestMergeIntoConfig_PassiveCacheTTL(t *testing.T) {
+	gc := &GlobalConfig{PassiveCacheTTL: "6h"}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.PassiveCacheTTL != 6*time.Hour {
+		t.Errorf("PassiveCacheTTL = %v, want 6h from global", scanConfig.PassiveCacheTTL)
+	}
+
+	// An explicit CLI value takes precedence, even 0 or the default
+	for _, ttl := range []time.Duration{time.Hour, 0, DefaultPassiveCacheTTL} {
+		scanConfig = DefaultConfig()
+		scanConfig.PassiveCacheTTL = ttl
+		scanConfig.PassiveCacheTTLSet = true
+		gc.MergeIntoConfig(scanConfig)
+		if scanConfig.PassiveCacheTTL != ttl {
+			t.Errorf("PassiveCacheTTL = %v, want %v from CLI", scanConfig.PassiveCacheTTL, ttl)
+		}
+	}
+
+	// Invalid durations are ignored
+	scanConfig = DefaultConfig()
+	(&GlobalConfig{PassiveCacheTTL: "soon"}).MergeIntoConfig(scanConfig)
+	if scanConfig.PassiveCacheTTL != DefaultPassiveCacheTTL {
+		t.Errorf("PassiveCacheTTL = %v, want default", scanConfig.PassiveCacheTTL)
+	}
+}
+
+func TestMergeIntoConfig_PassiveEndpoints(t *testing.T) {
+	gc := &GlobalConfig{PassiveEndpoints: map[string]string{
+		"ct":     "https://crt.internal",
+		"shodan": "https://shodan.mirror",
+	}}
+
+	scanConfig := DefaultConfig()
+	scanConfig.PassiveEndpoints = map[string]string{"shodan": "http://127.0.0.1:8080"}
+	gc.MergeIntoConfig(scanConfig)
+
+	if got := scanConfig.PassiveEndpoints["ct"]; got != "https://crt.internal" {
+		t.Errorf("PassiveEndpoints[ct] = %q, want global value", got)
+	}
+	if got := scanConfig.PassiveEndpoints["shodan"]; got != "http://127.0.0.1:8080" {
+		t.Errorf("PassiveEndpoints[shodan] = %q, want scan config value", got)
+	}
+}
+
+func TestMergeIntoConfig_PassiveHTTP(t *testing.T) {
+	gc := &GlobalConfig{PassiveUserAgent: "recon-team/1.0", PassiveNoProxy: true, PassiveSiteFetch: true}
+
+	scanConfig := DefaultConfig()
+	if scanConfig.PassiveSiteFetch {
+		t.Error("PassiveSiteFetch should be off by default")
+	}
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.PassiveUserAgent != "recon-team/1.0" || !scanConfig.PassiveNoProxy || !scanConfig.PassiveSiteFetch {
+		t.Errorf("PassiveUserAgent = %q, PassiveNoProxy = %v, PassiveSiteFetch = %v; want global values", scanConfig.PassiveUserAgent, scanConfig.PassiveNoProxy, scanConfig.PassiveSiteFetch)
+	}
+
+	// A CLI User-Agent takes precedence
+	scanConfig = DefaultConfig()
+	scanConfig.PassiveUserAgent = "cli/2.0"
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.PassiveUserAgent != "cli/2.0" {
+		t.Errorf("PassiveUserAgent = %q, want CLI value", scanConfig.PassiveUserAgent)
+	}
+}
+
+func TestMergeIntoConfig_ShodanMaxCredits(t *testing.T) {
+	gc := &GlobalConfig{ShodanMaxCredits: 25}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.ShodanMaxCredits != 25 {
+		t.Errorf("ShodanMaxCredits = %d, want 25", scanConfig.ShodanMaxCredits)
+	}
+
+	// --shodan-credits takes precedence
+	scanConfig = DefaultConfig()
+	scanConfig.ShodanMaxCredits = 3
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.ShodanMaxCredits != 3 {
+		t.Errorf("ShodanMaxCredits = %d, want CLI value 3", scanConfig.ShodanMaxCredits)
+	}
+}
+
+func TestMergeIntoConfig_CensysMode(t *testing.T) {
+	gc := &GlobalConfig{CensysMode: "all"}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.CensysMode != "all" {
+		t.Errorf("CensysMode = %q, want all", scanConfig.CensysMode)
+	}
+
+	// --censys-mode takes precedence
+	scanConfig = DefaultConfig()
+	scanConfig.CensysMode = "cert"
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.CensysMode != "cert" {
+		t.Errorf("CensysMode = %q, want CLI value cert", scanConfig.CensysMode)
+	}
+}
+
+func TestMergeIntoConfig_Resolvers(t *testing.T) {
+	gc := &GlobalConfig{Resolvers: []string{"9.9.9.9", "system"}, ResolverRate: 50}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if len(scanConfig.Resolvers) != 2 || scanConfig.ResolverRate != 50 {
+		t.Errorf("Resolvers = %v, ResolverRate = %d; want global values", scanConfig.Resolvers, scanConfig.ResolverRate)
+	}
+
+	// --resolvers and --resolver-rate take precedence
+	scanConfig = DefaultConfig()
+	scanConfig.Resolvers = []string{"1.1.1.1"}
+	scanConfig.ResolverRate = 5
+	gc.MergeIntoConfig(scanConfig)
+	if len(scanConfig.Resolvers) != 1 || scanConfig.ResolverRate != 5 {
+		t.Errorf("Resolvers = %v, ResolverRate = %d; want CLI values", scanConfig.Resolvers, scanConfig.ResolverRate)
+	}
+}
+
+func TestMergeIntoConfig_Resolver(t *testing.T) {
+	gc := &GlobalConfig{Resolver: "https://cloudflare-dns.com/dns-query"}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.Resolver != gc.Resolver {
+		t.Errorf("Resolver = %q, want global value", scanConfig.Resolver)
+	}
+
+	// --resolver takes precedence
+	scanConfig = DefaultConfig()
+	scanConfig.Resolver = "tls://1.1.1.1"
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.Resolver != "tls://1.1.1.1" {
+		t.Errorf("Resolver = %q, want CLI value", scanConfig.Resolver)
+	}
+}
+
+func TestMergeIntoConfig_SkipCategories(t *testing.T) {
+	gc := &GlobalConfig{SkipCategories: []string{"cdn", "waf", "cloud"}}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if len(scanConfig.SkipCategories) != 3 {
+		t.Errorf("SkipCategories = %v, want global value", scanConfig.SkipCategories)
+	}
+
+	// --skip-categories takes precedence
+	scanConfig = DefaultConfig()
+	scanConfig.SkipCategories = []string{"hosting"}
+	gc.MergeIntoConfig(scanConfig)
+	if len(scanConfig.SkipCategories) != 1 || scanConfig.SkipCategories[0] != "hosting" {
+		t.Errorf("SkipCategories = %v, want CLI value", scanConfig.SkipCategories)
+	}
+
+	if yaml := formatGlobalConfigYAML(gc); !strings.Contains(yaml, "skip_categories:\n  - cdn\n  - waf\n  - cloud\n") {
+		t.Errorf("formatGlobalConfigYAML() = %q", yaml)
+	}
+}
+
+func TestMergeIntoConfig_HunterKeys(t *testing.T) {
+	gc := &GlobalConfig{HunterKeys: []string{"global_hunter_1", "global_hunter_2"}}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if len(scanConfig.HunterKeys) != 2 || scanConfig.HunterKeys[0] != "global_hunter_1" {
+		t.Errorf("HunterKeys = %v, want global keys", scanConfig.HunterKeys)
+	}
+
+	// Keys from the scan config take precedence
+	scanConfig = DefaultConfig()
+	scanConfig.HunterKeys = []string{"scan_hunter"}
+	gc.MergeIntoConfig(scanConfig)
+	if len(scanConfig.HunterKeys) != 1 || scanConfig.HunterKeys[0] != "scan_hunter" {
+		t.Errorf("HunterKeys = %v, want scan config keys", scanConfig.HunterKeys)
+	}
+}
+
 func TestGetShodanKey(t *testing.T) {
 	gc := &GlobalConfig{
 		ShodanKeys: []string{"key1", "key2", "key3"},
@@ -227,3 +429,55 @@ func TestGetCensysCred(t *testing.T) {
 		t.Errorf("GetCensysCred() = (%s, %s), want empty strings", id2, secret2)
 	}
 }
+
+func TestMergeIntoConfig_ASNDatabase(t *testing.T) {
+	gc := &GlobalConfig{ASNDatabase: "/data/ip2asn-v4.tsv"}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.ASNDatabase != gc.ASNDatabase {
+		t.Errorf("ASNDatabase = %q, want global value", scanConfig.ASNDatabase)
+	}
+
+	// --asn-db takes precedence
+	scanConfig = DefaultConfig()
+	scanConfig.ASNDatabase = "pfx2as.gz"
+	gc.MergeIntoConfig(scanConfig)
+	if scanConfig.ASNDatabase != "pfx2as.gz" {
+		t.Errorf("ASNDatabase = %q, want CLI value", scanConfig.ASNDatabase)
+	}
+
+	if yaml := formatGlobalConfigYAML(gc); !strings.Contains(yaml, "asn_database: /data/ip2asn-v4.tsv") {
+		t.Errorf("formatGlobalConfigYAML() = %q", yaml)
+	}
+}
+
+func TestMergeIntoConfig_Geo(t *testing.T) {
+	gc := &GlobalConfig{
+		GeoDatabases: []string{"GeoLite2-City.mmdb", "GeoLite2-ASN.mmdb"},
+		GeoInclude:   []string{"US", "DE"},
+		GeoExclude:   []string{"CN"},
+	}
+
+	scanConfig := DefaultConfig()
+	gc.MergeIntoConfig(scanConfig)
+	if len(scanConfig.GeoDatabases) != 2 || len(scanConfig.GeoInclude) != 2 || len(scanConfig.GeoExclude) != 1 {
+		t.Errorf("Geo = %v %v %v, want global values", scanConfig.GeoDatabases, scanConfig.GeoInclude, scanConfig.GeoExclude)
+	}
+
+	// --geo-db and --geo-include take precedence
+	scanConfig = DefaultConfig()
+	scanConfig.GeoDatabases = []string{"country.mmdb"}
+	scanConfig.GeoInclude = []string{"FR"}
+	gc.MergeIntoConfig(scanConfig)
+	if len(scanConfig.GeoDatabases) != 1 || scanConfig.GeoInclude[0] != "FR" {
+		t.Errorf("Geo = %v %v, want CLI values", scanConfig.GeoDatabases, scanConfig.GeoInclude)
+	}
+
+	yaml := formatGlobalConfigYAML(gc)
+	for _, want := range []string{"geo_databases:", "  - GeoLite2-ASN.mmdb\n", "geo_include: [US, DE]", "geo_exclude: [CN]"} {
+		if !strings.Contains(yaml, want) {
+			t.Errorf("formatGlobalConfigYAML() missing %q", want)
+		}
+	}
+}
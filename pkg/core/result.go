@@ -11,11 +11,12 @@ type ScanResult struct {
 	EndTime   time.Time `json:"end_time"`
 
 	// Results by category
-	Success   []*IPResult `json:"success"`   // 200 OK
-	Redirects []*IPResult `json:"redirects"` // 3xx
-	Other     []*IPResult `json:"other"`     // 4xx, 5xx
-	Timeouts  []*IPResult `json:"timeouts"`  // Timeout
-	Errors    []*IPResult `json:"errors"`    // Connection errors
+	Success   []*IPResult `json:"success"`             // 200 OK
+	Redirects []*IPResult `json:"redirects"`           // 3xx
+	Other     []*IPResult `json:"other"`               // 4xx, 5xx
+	Timeouts  []*IPResult `json:"timeouts"`            // Timeout
+	Errors    []*IPResult `json:"errors"`              // Connection errors
+	CDNEdges  []*IPResult `json:"cdn_edges,omitempty"` // Answered by a CDN edge, not origin candidates
 
 	// Passive scan results (if applicable)
 	PassiveIPs []PassiveIP `json:"passive_ips,omitempty"`
@@ -28,7 +29,8 @@ type ScanResult struct {
 type ScanSummary struct {
 	TotalIPs                   uint64            `json:"total_ips"`
 	ScannedIPs                 uint64            `json:"scanned_ips"`
-	SkippedIPs                 uint64            `json:"skipped_ips"` // WAF IPs
+	SkippedIPs                 uint64            `json:"skipped_ips"`               // WAF IPs
+	GeoSkippedIPs              uint64            `json:"geo_skipped_ips,omitempty"` // IPs outside --geo-include/--geo-exclude
 	SuccessCount               uint64            `json:"success_count"`
 	SuccessIPs                 []string          `json:"success_ips,omitempty"`          // List of 200 OK IPs
 	FalsePositiveCount         uint64            `json:"false_positive_count,omitempty"` // IPs with Host header warnings
@@ -38,7 +40,12 @@ type ScanSummary struct {
 	PossibleOriginRelatedCount uint64            `json:"possible_origin_related_count,omitempty"`
 	PossibleOriginRelatedIPs   []string          `json:"possible_origin_related_ips,omitempty"`
 	Duration                   time.Duration     `json:"duration"`
-	WAFStats                   map[string]uint64 `json:"waf_stats,omitempty"` // provider -> count
+	WAFStats                   map[string]uint64 `json:"waf_stats,omitempty"`      // provider -> count
+	EgressStats                map[string]uint64 `json:"egress_stats,omitempty"`   // exit IP -> requests sent
+	CDNEdgeCount               uint64            `json:"cdn_edge_count,omitempty"` // Responses identified as CDN edges
+	EdgeStats                  map[string]uint64 `json:"edge_stats,omitempty"`     // CDN -> edges found
+	PageStats                  map[string]uint64 `json:"page_stats,omitempty"`     // Page class -> CDN/WAF pages served
+	ASNStats                   map[string]uint64 `json:"asn_stats,omitempty"`      // "AS<n> <org>" -> 200 OK hits
 }
 
 // IPResult represents the result of scanning a single IP
@@ -54,16 +61,46 @@ type IPResult struct {
 	PTR                string   `json:"ptr,omitempty"`            // Reverse DNS PTR record
 	RedirectChain      []string `json:"redirect_chain,omitempty"` // Redirect URLs if --follow-redirect is used
 	Error              string   `json:"error,omitempty"`
-	Provider           string   `json:"provider,omitempty"` // WAF provider if skipped
+	Provider           string   `json:"provider,omitempty"` // Provider of the IP's range (the WAF provider if skipped)
+	Category           string   `json:"category,omitempty"` // Category of that provider: cdn, waf, cloud, hosting
 	PossibleOrigin     bool     `json:"possible_origin,omitempty"`
 	PossibleOriginDest string   `json:"possible_origin_dest,omitempty"`
+	Proxy              string   `json:"proxy,omitempty"`         // Proxy that sent the request (scheme://host:port, no credentials)
+	ExitIP             string   `json:"exit_ip,omitempty"`       // Public IP the proxy egressed from (if verified)
+	EdgeProvider       string   `json:"edge_provider,omitempty"` // CDN whose edge answered, from the response signature
+	EdgeEvidence       string   `json:"edge_evidence,omitempty"` // Header or body marker that identified the edge
+	PageClass          string   `json:"page_class,omitempty"`    // CDN/WAF page served: block, challenge or error
+	ASN                int      `json:"asn,omitempty"`           // AS announcing the IP (offline ASN database)
+	ASOrg              string   `json:"as_org,omitempty"`        // Organization of that AS
+	Country            string   `json:"country,omitempty"`       // ISO country code (GeoIP database)
+	City               string   `json:"city,omitempty"`          // City (GeoIP database)
+}
+
+// Page classes of CDN/WAF pages (IPResult.PageClass)
+const (
+	PageClassBlock     = "block"     // The WAF refused the request (access denied, 1020)
+	PageClassChallenge = "challenge" // JavaScript or CAPTCHA interstitial
+	PageClassError     = "error"     // The CDN could not serve the request (1003, unknown domain)
+)
+
+// IsCDNEdge reports whether the response came from a CDN edge rather than
+// an origin
+func (r *IPResult) IsCDNEdge() bool {
+	return r.EdgeProvider != ""
 }
 
 // PassiveIP represents an IP discovered through passive reconnaissance
 type PassiveIP struct {
 	IP         string                 `json:"ip"`
-	Source     string                 `json:"source"`     // "ct", "dns", "shodan", etc.
-	Confidence float64                `json:"confidence"` // 0.0 - 1.0
+	Source     string                 `json:"source"`             // "ct", "dns", "shodan", etc.
+	Sources    []string               `json:"sources,omitempty"`  // All sources that reported the IP (set when merged)
+	Confidence float64                `json:"confidence"`         // 0.0 - 1.0
+	Provider   string                 `json:"provider,omitempty"` // Provider of the IP's range in the WAF database
+	Category   string                 `json:"category,omitempty"` // Category of that provider: cdn, waf, cloud, hosting
+	ASN        int                    `json:"asn,omitempty"`      // AS announcing the IP (offline ASN database)
+	ASOrg      string                 `json:"as_org,omitempty"`   // Organization of that AS
+	Country    string                 `json:"country,omitempty"`  // ISO country code (GeoIP database)
+	City       string                 `json:"city,omitempty"`     // City (GeoIP database)
 	FirstSeen  time.Time              `json:"first_seen"`
 	LastSeen   time.Time              `json:"last_seen"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
@@ -87,7 +124,13 @@ func NewScanResult(domain string, mode ScanMode) *ScanResult {
 }
 
 // AddResult adds an IP result to the appropriate category
+// CDN edges are kept apart so they are never taken for origins
 func (sr *ScanResult) AddResult(result *IPResult) {
+	if result.IsCDNEdge() {
+		sr.CDNEdges = append(sr.CDNEdges, result)
+		return
+	}
+
 	switch result.Status {
 	case "200":
 		sr.Success = append(sr.Success, result)
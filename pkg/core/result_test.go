@@ -70,6 +70,11 @@ func TestAddResult(t *testing.T) {
 			&IPResult{IP: "192.0.2.5", Status: "4xx", HTTPCode: 404},
 			func(s *ScanResult) int { return len(s.Other) },
 		},
+		{
+			"CDN edge (200)",
+			&IPResult{IP: "192.0.2.6", Status: "200", HTTPCode: 200, EdgeProvider: "cloudflare", EdgeEvidence: "cf-ray: 1-LAX"},
+			func(s *ScanResult) int { return len(s.CDNEdges) },
+		},
 	}
 
 	for _, tt := range tests {
@@ -84,6 +89,14 @@ func TestAddResult(t *testing.T) {
 	}
 }
 
+func TestAddResult_CDNEdgeNotSuccess(t *testing.T) {
+	sr := NewScanResult("example.com", ModeActive)
+	sr.AddResult(&IPResult{IP: "192.0.2.1", Status: "200", EdgeProvider: "fastly"})
+	if len(sr.Success) != 0 || len(sr.CDNEdges) != 1 {
+		t.Errorf("success/edges = %d/%d, want 0/1", len(sr.Success), len(sr.CDNEdges))
+	}
+}
+
 func TestFinalize(t *testing.T) {
 	sr := NewScanResult("example.com", ModeActive)
 	sr.StartTime = time.Now().Add(-5 * time.Second)
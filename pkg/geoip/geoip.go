@@ -0,0 +1,244 @@
+// Package geoip provides offline country, city and ASN lookups from
+// user-supplied MMDB files (GeoLite2/GeoIP2, IPinfo, DB-IP) and country
+// filtering of candidate IPs
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+// Location is what the GeoIP databases know about an IP
+type Location struct {
+	CountryCode string // ISO 3166-1 alpha-2, e.g. "DE"
+	Country     string // English country name
+	City        string // English city name
+	ASN         int
+	ASOrg       string
+}
+
+// Database looks IPs up in one or more MMDB files, e.g. GeoLite2-City
+// and GeoLite2-ASN. Each field comes from the first file that has it.
+type Database struct {
+	readers []*Reader
+	paths   []string
+}
+
+// Open loads the MMDB files at paths
+func Open(paths ...string) (*Database, error) {
+	db := &Database{}
+	for _, path := range paths {
+		r, err := OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		db.readers = append(db.readers, r)
+		db.paths = append(db.paths, path)
+	}
+	if len(db.readers) == 0 {
+		return nil, fmt.Errorf("no GeoIP database given")
+	}
+	return db, nil
+}
+
+// String lists the loaded files and their database types
+func (db *Database) String() string {
+	if db == nil {
+		return ""
+	}
+	parts := make([]string, len(db.readers))
+	for i, r := range db.readers {
+		parts[i] = db.paths[i]
+		if r.Metadata.DatabaseType != "" {
+			parts[i] += " (" + r.Metadata.DatabaseType + ")"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Lookup returns the location of ip
+func (db *Database) Lookup(ip net.IP) (Location, bool) {
+	var loc Location
+	if db == nil || ip == nil {
+		return loc, false
+	}
+
+	found := false
+	for _, r := range db.readers {
+		record, ok, err := r.Lookup(ip)
+		if err != nil || !ok {
+			continue
+		}
+		fields, ok := record.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		found = true
+		loc.merge(parseRecord(fields))
+	}
+	return loc, found
+}
+
+// LookupString returns the location of an IP address string
+func (db *Database) LookupString(ipStr string) (Location, bool) {
+	return db.Lookup(net.ParseIP(ipStr))
+}
+
+// merge fills loc's empty fields from other
+func (loc *Location) merge(other Location) {
+	if loc.CountryCode == "" {
+		loc.CountryCode, loc.Country = other.CountryCode, other.Country
+	}
+	if loc.City == "" {
+		loc.City = other.City
+	}
+	if loc.ASN == 0 {
+		loc.ASN, loc.ASOrg = other.ASN, other.ASOrg
+	}
+}
+
+// parseRecord reads the MaxMind/DB-IP layout (country.iso_code,
+// city.names.en, autonomous_system_number) and the flat IPinfo layout
+// (country, country_name, city, asn "AS13335", as_name)
+func parseRecord(fields map[string]interface{}) Location {
+	var loc Location
+
+	for _, key := range []string{"country", "registered_country"} {
+		switch v := fields[key].(type) {
+		case map[string]interface{}:
+			loc.CountryCode, _ = v["iso_code"].(string)
+			loc.Country = englishName(v)
+		case string:
+			loc.CountryCode = v
+			loc.Country, _ = fields["country_name"].(string)
+		}
+		if loc.CountryCode != "" {
+			break
+		}
+	}
+	loc.CountryCode = strings.ToUpper(loc.CountryCode)
+
+	switch v := fields["city"].(type) {
+	case map[string]interface{}:
+		loc.City = englishName(v)
+	case string:
+		loc.City = v
+	}
+
+	if n := toUint(fields["autonomous_system_number"]); n > 0 {
+		loc.ASN = int(n)
+		loc.ASOrg, _ = fields["autonomous_system_organization"].(string)
+	} else if s, ok := fields["asn"].(string); ok {
+		if n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(s), "AS")); err == nil {
+			loc.ASN = n
+			loc.ASOrg, _ = fields["as_name"].(string)
+		}
+	}
+
+	return loc
+}
+
+// englishName returns names.en of a MaxMind country or city record
+func englishName(record map[string]interface{}) string {
+	names, _ := record["names"].(map[string]interface{})
+	name, _ := names["en"].(string)
+	return name
+}
+
+// EnrichResult records the country, city and (if not yet known) ASN of an
+// active scan result
+func (db *Database) EnrichResult(result *core.IPResult) {
+	loc, ok := db.LookupString(result.IP)
+	if !ok {
+		return
+	}
+	result.Country = loc.CountryCode
+	result.City = loc.City
+	if result.ASN == 0 {
+		result.ASN, result.ASOrg = loc.ASN, loc.ASOrg
+	}
+}
+
+// EnrichPassiveIP records the country, city and (if not yet known) ASN of
+// a passive IP. The "country_code" and "asn" metadata used by scoring are
+// filled in unless a source already reported them.
+func (db *Database) EnrichPassiveIP(ip *core.PassiveIP) {
+	loc, ok := db.LookupString(ip.IP)
+	if !ok {
+		return
+	}
+
+	ip.Country = loc.CountryCode
+	ip.City = loc.City
+	if ip.ASN == 0 {
+		ip.ASN, ip.ASOrg = loc.ASN, loc.ASOrg
+	}
+
+	if ip.Metadata == nil {
+		ip.Metadata = make(map[string]interface{})
+	}
+	if _, exists := ip.Metadata["country_code"]; !exists && loc.CountryCode != "" {
+		ip.Metadata["country_code"] = loc.CountryCode
+	}
+	if _, exists := ip.Metadata["asn"]; !exists && loc.ASN != 0 {
+		ip.Metadata["asn"] = strings.TrimSpace(fmt.Sprintf("AS%d %s", loc.ASN, loc.ASOrg))
+	}
+}
+
+// Filter keeps IPs by country: only the included countries (when any are
+// given) and none of the excluded ones
+type Filter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// NewFilter creates a country filter from ISO 3166-1 alpha-2 codes
+// Returns nil (allow everything) when both lists are empty.
+func NewFilter(include, exclude []string) (*Filter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	f := &Filter{}
+	var err error
+	if f.include, err = countrySet(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = countrySet(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// countrySet validates and upper-cases country codes
+func countrySet(codes []string) (map[string]bool, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, fmt.Errorf("invalid country code %q (want ISO 3166-1 alpha-2, e.g. US)", code)
+		}
+		set[code] = true
+	}
+	return set, nil
+}
+
+// Allow reports whether an IP in countryCode passes the filter. An IP
+// with no known country fails an include list but passes an exclude list.
+func (f *Filter) Allow(countryCode string) bool {
+	if f == nil {
+		return true
+	}
+	countryCode = strings.ToUpper(countryCode)
+	if f.include != nil && !f.include[countryCode] {
+		return false
+	}
+	return !f.exclude[countryCode]
+}
@@ -0,0 +1,155 @@
+package geoip
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+// testDatabases writes GeoLite2-City, GeoLite2-ASN and IPinfo style files
+func testDatabases(t *testing.T) (city, asn, ipinfo string) {
+	city = writeMMDB(t, "GeoLite2-City.mmdb", buildMMDB(t, 6, 28, "GeoLite2-City", []testNet{
+		{"198.51.100.0/24", map[string]interface{}{
+			"city":     map[string]interface{}{"geoname_id": uint32(2925533), "names": map[string]interface{}{"de": "Frankfurt am Main", "en": "Frankfurt am Main"}},
+			"country":  map[string]interface{}{"iso_code": "DE", "names": map[string]interface{}{"en": "Germany"}},
+			"location": map[string]interface{}{"latitude": 50.1188, "longitude": 8.6843},
+		}},
+		{"2001:db8::/32", map[string]interface{}{
+			"registered_country": map[string]interface{}{"iso_code": "NL", "names": map[string]interface{}{"en": "Netherlands"}},
+		}},
+	}))
+	asn = writeMMDB(t, "GeoLite2-ASN.mmdb", buildMMDB(t, 4, 24, "GeoLite2-ASN", []testNet{
+		{"198.51.100.0/24", map[string]interface{}{"autonomous_system_number": uint32(64500), "autonomous_system_organization": "EXAMPLE-NET"}},
+	}))
+	ipinfo = writeMMDB(t, "country_asn.mmdb", buildMMDB(t, 6, 32, "ipinfo country_asn.mmdb", []testNet{
+		{"203.0.113.0/24", map[string]interface{}{"country": "us", "country_name": "United States", "asn": "AS64501", "as_name": "Example Hosting", "as_domain": "example.net"}},
+	}))
+	return city, asn, ipinfo
+}
+
+func TestDatabase_Lookup(t *testing.T) {
+	city, asn, ipinfo := testDatabases(t)
+	db, err := Open(city, asn, ipinfo)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		want    Location
+		wantHit bool
+	}{
+		// City and ASN fields merged from two files
+		{"198.51.100.7", Location{CountryCode: "DE", Country: "Germany", City: "Frankfurt am Main", ASN: 64500, ASOrg: "EXAMPLE-NET"}, true},
+		// Registered country when there is no country
+		{"2001:db8::1", Location{CountryCode: "NL", Country: "Netherlands"}, true},
+		// Flat IPinfo layout
+		{"203.0.113.9", Location{CountryCode: "US", Country: "United States", ASN: 64501, ASOrg: "Example Hosting"}, true},
+		{"192.0.2.1", Location{}, false},
+		{"not-an-ip", Location{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := db.LookupString(tt.ip)
+		if ok != tt.wantHit || got != tt.want {
+			t.Errorf("LookupString(%s) = %+v, %v; want %+v, %v", tt.ip, got, ok, tt.want, tt.wantHit)
+		}
+	}
+
+	if s := db.String(); s != city+" (GeoLite2-City), "+asn+" (GeoLite2-ASN), "+ipinfo+" (ipinfo country_asn.mmdb)" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	if _, err := Open(); err == nil {
+		t.Error("Open() with no files should fail")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("Open() should fail for a missing file")
+	}
+	if _, err := Open(writeMMDB(t, "bad.mmdb", []byte("not a database"))); err == nil {
+		t.Error("Open() should fail for an invalid file")
+	}
+}
+
+func TestDatabase_Enrich(t *testing.T) {
+	city, asn, _ := testDatabases(t)
+	db, err := Open(city, asn)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	result := &core.IPResult{IP: "198.51.100.7"}
+	db.EnrichResult(result)
+	if result.Country != "DE" || result.City != "Frankfurt am Main" || result.ASN != 64500 {
+		t.Errorf("EnrichResult() = %+v", result)
+	}
+
+	// An ASN already known (e.g. from the ip2asn table) is kept
+	known := &core.IPResult{IP: "198.51.100.7", ASN: 64999, ASOrg: "OTHER"}
+	db.EnrichResult(known)
+	if known.ASN != 64999 || known.ASOrg != "OTHER" || known.Country != "DE" {
+		t.Errorf("EnrichResult() = %+v", known)
+	}
+
+	ip := &core.PassiveIP{IP: "198.51.100.7"}
+	db.EnrichPassiveIP(ip)
+	if ip.Country != "DE" || ip.City != "Frankfurt am Main" || ip.Metadata["country_code"] != "DE" || ip.Metadata["asn"] != "AS64500 EXAMPLE-NET" {
+		t.Errorf("EnrichPassiveIP() = %+v", ip)
+	}
+
+	// Metadata reported by a source is kept
+	censys := &core.PassiveIP{IP: "198.51.100.7", Metadata: map[string]interface{}{"country_code": "FR", "asn": "AS64510"}}
+	db.EnrichPassiveIP(censys)
+	if censys.Metadata["country_code"] != "FR" || censys.Metadata["asn"] != "AS64510" || censys.Country != "DE" {
+		t.Errorf("EnrichPassiveIP() = %+v", censys)
+	}
+
+	// A nil database or an unknown IP leaves results untouched
+	var none *Database
+	untouched := &core.PassiveIP{IP: "198.51.100.7"}
+	none.EnrichPassiveIP(untouched)
+	db.EnrichPassiveIP(&core.PassiveIP{IP: "192.0.2.1"})
+	if untouched.Country != "" || untouched.Metadata != nil {
+		t.Errorf("nil database enriched %+v", untouched)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	if f, err := NewFilter(nil, nil); f != nil || err != nil {
+		t.Errorf("NewFilter(nil, nil) = %v, %v; want nil filter", f, err)
+	}
+	var none *Filter
+	if !none.Allow("CN") {
+		t.Error("nil filter should allow everything")
+	}
+
+	include, err := NewFilter([]string{"us", " DE "}, nil)
+	if err != nil {
+		t.Fatalf("NewFilter() error: %v", err)
+	}
+	for code, want := range map[string]bool{"US": true, "de": true, "FR": false, "": false} {
+		if got := include.Allow(code); got != want {
+			t.Errorf("include.Allow(%q) = %v, want %v", code, got, want)
+		}
+	}
+
+	exclude, _ := NewFilter(nil, []string{"CN", "RU"})
+	for code, want := range map[string]bool{"CN": false, "ru": false, "US": true, "": true} {
+		if got := exclude.Allow(code); got != want {
+			t.Errorf("exclude.Allow(%q) = %v, want %v", code, got, want)
+		}
+	}
+
+	both, _ := NewFilter([]string{"US", "CA"}, []string{"CA"})
+	if !both.Allow("US") || both.Allow("CA") {
+		t.Error("exclude should win over include")
+	}
+
+	for _, bad := range []string{"USA", "1A", "", "u"} {
+		if _, err := NewFilter([]string{bad}, nil); err == nil {
+			t.Errorf("NewFilter(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of an MMDB file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// ErrInvalidDatabase is returned for files that are not valid MMDB databases
+var ErrInvalidDatabase = errors.New("invalid MMDB database")
+
+// MMDB data section types
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDepth bounds nested maps/arrays so a corrupt file cannot recurse forever
+const maxDepth = 32
+
+// Metadata describes an MMDB database
+type Metadata struct {
+	DatabaseType string // e.g. "GeoLite2-City", "ipinfo country_asn.mmdb"
+	BuildEpoch   uint64 // Build time (Unix seconds)
+	IPVersion    uint   // 4 or 6
+	NodeCount    uint
+	RecordSize   uint // 24, 28 or 32 bits
+}
+
+// Reader reads a MaxMind DB (MMDB) file: the format of GeoLite2/GeoIP2,
+// IPinfo and DB-IP downloads
+type Reader struct {
+	Metadata Metadata
+
+	buf       []byte
+	data      decoder // Data section
+	treeSize  uint    // Search tree size in bytes
+	ipv4Start uint    // Node reached after the 96 zero bits of ::/96
+}
+
+// OpenReader reads an MMDB file into memory
+func OpenReader(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+	r, err := NewReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// NewReader parses an MMDB database held in buf
+func NewReader(buf []byte) (*Reader, error) {
+	markerAt := bytes.LastIndex(buf, metadataMarker)
+	if markerAt < 0 {
+		return nil, fmt.Errorf("%w: metadata marker not found", ErrInvalidDatabase)
+	}
+
+	meta := decoder{buf: buf[markerAt+len(metadataMarker):]}
+	value, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidDatabase, err)
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+
+	r := &Reader{buf: buf}
+	r.Metadata.DatabaseType, _ = fields["database_type"].(string)
+	r.Metadata.BuildEpoch = toUint(fields["build_epoch"])
+	r.Metadata.IPVersion = uint(toUint(fields["ip_version"]))
+	r.Metadata.NodeCount = uint(toUint(fields["node_count"]))
+	r.Metadata.RecordSize = uint(toUint(fields["record_size"]))
+
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, r.Metadata.RecordSize)
+	}
+	if r.Metadata.IPVersion != 4 && r.Metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", ErrInvalidDatabase, r.Metadata.IPVersion)
+	}
+
+	r.treeSize = r.Metadata.NodeCount * r.Metadata.RecordSize / 4
+	dataStart := r.treeSize + 16 // 16-byte separator after the search tree
+	if dataStart > uint(markerAt) {
+		return nil, fmt.Errorf("%w: search tree larger than file", ErrInvalidDatabase)
+	}
+	r.data = decoder{buf: buf[dataStart:markerAt]}
+
+	if r.Metadata.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.Metadata.NodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Lookup returns the data record for ip, typically a map of the
+// database's fields. ok is false when the database has no record for ip.
+func (r *Reader) Lookup(ip net.IP) (record interface{}, ok bool, err error) {
+	node := uint(0)
+	addr := ip.To4()
+	if addr != nil {
+		node = r.ipv4Start
+	} else {
+		if r.Metadata.IPVersion == 4 {
+			return nil, false, nil
+		}
+		if addr = ip.To16(); addr == nil {
+			return nil, false, fmt.Errorf("invalid IP address")
+		}
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	for i := 0; i < len(addr)*8 && node < nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-uint(i%8))) & 1
+		node = r.readNode(node, bit)
+	}
+
+	switch {
+	case node == nodeCount:
+		return nil, false, nil
+	case node < nodeCount:
+		return nil, false, fmt.Errorf("%w: search tree deeper than the address", ErrInvalidDatabase)
+	}
+
+	offset := node - nodeCount - 16
+	record, _, err = r.data.decode(offset, 0)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	return record, true, nil
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of a search
+// tree node. Out-of-range nodes read as "not found".
+func (r *Reader) readNode(node, bit uint) uint {
+	size := r.Metadata.RecordSize
+	offset := node * size / 4
+	if offset+size/4 > r.treeSize {
+		return r.Metadata.NodeCount
+	}
+	b := r.buf[offset:]
+
+	switch size {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// decoder decodes values from an MMDB data section; pointers are offsets
+// from the start of buf
+type decoder struct {
+	buf []byte
+}
+
+// decode decodes the value at offset, returning it and the offset after it
+func (d decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("data nested deeper than %d", maxDepth)
+	}
+
+	typeNum, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typeNum == typePointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	switch typeNum {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			key, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key is %T, not a string", key)
+			}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+		}
+		return m, offset, nil
+
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid boolean size %d", size)
+		}
+		return size == 1, offset, nil
+	}
+
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	next := offset + size
+
+	switch typeNum {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		maxSize := uint(8)
+		switch typeNum {
+		case typeUint16:
+			maxSize = 2
+		case typeUint32:
+			maxSize = 4
+		}
+		if size > maxSize {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size %d", size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid int32 size %d", size)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("invalid uint128 size %d", size)
+		}
+		return new(big.Int).SetBytes(b), next, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typeNum)
+	}
+}
+
+// control reads a control byte (and any extended type and size bytes),
+// returning the type, the payload size and the payload offset. For
+// pointers, size holds the control byte's low 5 bits.
+func (d decoder) control(offset uint) (typeNum, size, next uint, err error) {
+	b, err := d.bytes(offset, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	ctrl := b[0]
+	offset++
+
+	typeNum = uint(ctrl >> 5)
+	if typeNum == typeExtended {
+		ext, err := d.bytes(offset, 1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		typeNum = 7 + uint(ext[0])
+		offset++
+		if typeNum < typeInt32 || typeNum > typeFloat {
+			return 0, 0, 0, fmt.Errorf("invalid extended type %d", typeNum)
+		}
+	}
+
+	size = uint(ctrl & 0x1f)
+	if typeNum == typePointer || size < 29 {
+		return typeNum, size, offset, nil
+	}
+
+	n := size - 28 // 1, 2 or 3 more size bytes
+	sb, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var extra uint
+	for _, c := range sb {
+		extra = extra<<8 | uint(c)
+	}
+	switch size {
+	case 29:
+		size = 29 + extra
+	case 30:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+	return typeNum, size, offset + n, nil
+}
+
+// pointer decodes a pointer whose control byte low bits are size,
+// returning the target offset and the offset after the pointer
+func (d decoder) pointer(size, offset uint) (target, next uint, err error) {
+	n := (size>>3)&3 + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var v uint
+	if n < 4 {
+		v = size & 7
+	}
+	for _, c := range b {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+// bytes returns n bytes at offset, or an error past the end of the section
+func (d decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset > uint(len(d.buf)) || n > uint(len(d.buf))-offset {
+		return nil, fmt.Errorf("data offset %d+%d out of range", offset, n)
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+// toUint converts a decoded unsigned integer to uint64 (0 otherwise)
+func toUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		if n > 0 {
+			return uint64(n)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,399 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testNet is a network and the record stored for it in a test database
+type testNet struct {
+	cidr   string
+	record interface{}
+}
+
+// mmdbPointer encodes a pointer to an earlier data section offset
+type mmdbPointer uint
+
+// mmdbEncoder encodes values in the MMDB data section format
+type mmdbEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *mmdbEncoder) control(typeNum, size int) {
+	first := byte(0)
+	var ext []byte
+	if typeNum <= typeMap {
+		first = byte(typeNum << 5)
+	} else {
+		ext = []byte{byte(typeNum - 7)}
+	}
+
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 285:
+		first |= 29
+		sizeBytes = []byte{byte(size - 29)}
+	case size < 65821:
+		first |= 30
+		v := size - 285
+		sizeBytes = []byte{byte(v >> 8), byte(v)}
+	default:
+		first |= 31
+		v := size - 65821
+		sizeBytes = []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+	e.buf.WriteByte(first)
+	e.buf.Write(ext)
+	e.buf.Write(sizeBytes)
+}
+
+func (e *mmdbEncoder) uint(typeNum int, v uint64) {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	e.control(typeNum, len(b))
+	e.buf.Write(b)
+}
+
+func (e *mmdbEncoder) encode(v interface{}) {
+	switch v := v.(type) {
+	case mmdbPointer:
+		if v < 2048 {
+			e.buf.Write([]byte{byte(typePointer<<5) | byte(v>>8), byte(v)})
+		} else {
+			p := v - 2048
+			e.buf.Write([]byte{byte(typePointer<<5) | 1<<3 | byte(p>>16&7), byte(p >> 8), byte(p)})
+		}
+	case string:
+		e.control(typeString, len(v))
+		e.buf.WriteString(v)
+	case []byte:
+		e.control(typeBytes, len(v))
+		e.buf.Write(v)
+	case float64:
+		e.control(typeDouble, 8)
+		binary.Write(&e.buf, binary.BigEndian, math.Float64bits(v))
+	case float32:
+		e.control(typeFloat, 4)
+		binary.Write(&e.buf, binary.BigEndian, math.Float32bits(v))
+	case uint16:
+		e.uint(typeUint16, uint64(v))
+	case uint32:
+		e.uint(typeUint32, uint64(v))
+	case uint64:
+		e.uint(typeUint64, v)
+	case *big.Int:
+		e.control(typeUint128, len(v.Bytes()))
+		e.buf.Write(v.Bytes())
+	case int32:
+		e.control(typeInt32, 4)
+		binary.Write(&e.buf, binary.BigEndian, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		e.control(typeBool, size)
+	case []interface{}:
+		e.control(typeArray, len(v))
+		for _, item := range v {
+			e.encode(item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.control(typeMap, len(v))
+		for _, k := range keys {
+			e.encode(k)
+			e.encode(v[k])
+		}
+	default:
+		panic("unsupported test value")
+	}
+}
+
+// buildMMDB writes a database with the given networks. IPv4 networks go
+// under ::/96 in IPv6 databases, as MaxMind's writer stores them.
+func buildMMDB(t *testing.T, ipVersion, recordSize int, dbType string, nets []testNet) []byte {
+	t.Helper()
+
+	// Data section: one record per network, in order
+	var data mmdbEncoder
+	offsets := make([]int, len(nets))
+	for i, n := range nets {
+		offsets[i] = data.buf.Len()
+		data.encode(n.record)
+	}
+
+	// Search tree; children >= 0 are nodes, -1 is empty, <= -2 is data
+	type node struct{ child [2]int }
+	nodes := []node{{[2]int{-1, -1}}}
+	for i, n := range nets {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatalf("bad test network %s", n.cidr)
+		}
+		ones, bits := ipnet.Mask.Size()
+		addr := []byte(ipnet.IP)
+		if bits == 32 && ipVersion == 6 {
+			addr = append(make([]byte, 12), addr...)
+			ones += 96
+		}
+
+		cur := 0
+		for b := 0; b < ones; b++ {
+			bit := int(addr[b/8]>>(7-uint(b%8))) & 1
+			if b == ones-1 {
+				nodes[cur].child[bit] = -2 - i
+				break
+			}
+			if child := nodes[cur].child[bit]; child < 0 {
+				// A more specific network inherits the enclosing one's data
+				nodes = append(nodes, node{[2]int{child, child}})
+				nodes[cur].child[bit] = len(nodes) - 1
+			}
+			cur = nodes[cur].child[bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	value := func(child int) uint64 {
+		switch {
+		case child >= 0:
+			return uint64(child)
+		case child == -1:
+			return uint64(nodeCount)
+		default:
+			return uint64(nodeCount + 16 + offsets[-2-child])
+		}
+	}
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		l, r := value(n.child[0]), value(n.child[1])
+		switch recordSize {
+		case 24:
+			out.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(r >> 16), byte(r >> 8), byte(r)})
+		case 28:
+			out.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(l>>24)<<4 | byte(r>>24&0x0F), byte(r >> 16), byte(r >> 8), byte(r)})
+		case 32:
+			binary.Write(&out, binary.BigEndian, uint32(l))
+			binary.Write(&out, binary.BigEndian, uint32(r))
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.buf.Bytes())
+
+	out.Write(metadataMarker)
+	var meta mmdbEncoder
+	meta.encode(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1760000000),
+		"database_type":               dbType,
+		"description":                 map[string]interface{}{"en": "test database"},
+		"ip_version":                  uint16(ipVersion),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})
+	out.Write(meta.buf.Bytes())
+
+	return out.Bytes()
+}
+
+// writeMMDB writes a test database to a temp file
+func writeMMDB(t *testing.T, name string, buf []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReader_RecordSizes(t *testing.T) {
+	nets := []testNet{
+		{"198.51.100.0/24", map[string]interface{}{"n": "v4"}},
+		{"198.51.100.128/25", map[string]interface{}{"n": "v4-upper"}},
+		{"2001:db8::/32", map[string]interface{}{"n": "v6"}},
+	}
+
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			testNets := nets
+			if ipVersion == 4 {
+				testNets = nets[:2]
+			}
+			r, err := NewReader(buildMMDB(t, ipVersion, recordSize, "Test", testNets))
+			if err != nil {
+				t.Fatalf("v%d/%d: NewReader() error: %v", ipVersion, recordSize, err)
+			}
+			if r.Metadata.DatabaseType != "Test" || r.Metadata.RecordSize != uint(recordSize) || r.Metadata.BuildEpoch != 1760000000 {
+				t.Errorf("v%d/%d: Metadata = %+v", ipVersion, recordSize, r.Metadata)
+			}
+
+			want := map[string]string{
+				"198.51.100.1":        "v4",
+				"198.51.100.200":      "v4-upper",
+				"198.51.101.1":        "",
+				"::ffff:198.51.100.5": "v4",
+			}
+			if ipVersion == 6 {
+				want["2001:db8:1::1"] = "v6"
+				want["2001:db9::1"] = ""
+			} else {
+				want["2001:db8:1::1"] = ""
+			}
+			for ip, n := range want {
+				record, ok, err := r.Lookup(net.ParseIP(ip))
+				if err != nil {
+					t.Fatalf("v%d/%d: Lookup(%s) error: %v", ipVersion, recordSize, ip, err)
+				}
+				got := ""
+				if ok {
+					got, _ = record.(map[string]interface{})["n"].(string)
+				}
+				if got != n {
+					t.Errorf("v%d/%d: Lookup(%s) = %q, want %q", ipVersion, recordSize, ip, got, n)
+				}
+			}
+		}
+	}
+}
+
+func TestDecoder_Types(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	huge := strings.Repeat("y", 70000)
+
+	var e mmdbEncoder
+	e.encode("shared")
+	sharedAt := mmdbPointer(0)
+	start := uint(e.buf.Len())
+	e.encode(map[string]interface{}{
+		"ptr":     sharedAt,
+		"long":    long,
+		"huge":    huge,
+		"double":  1.5,
+		"float":   float32(2.5),
+		"u16":     uint16(443),
+		"u32":     uint32(64500),
+		"u64":     uint64(1 << 40),
+		"u128":    new(big.Int).Lsh(big.NewInt(1), 100),
+		"i32":     int32(-7),
+		"yes":     true,
+		"no":      false,
+		"list":    []interface{}{"a", uint32(1)},
+		"bytes":   []byte{1, 2},
+		"zero":    uint32(0),
+		"nested":  map[string]interface{}{"names": map[string]interface{}{"en": "Berlin"}},
+		"pointer": mmdbPointer(0),
+	})
+
+	value, next, err := decoder{buf: e.buf.Bytes()}.decode(start, 0)
+	if err != nil {
+		t.Fatalf("decode() error: %v", err)
+	}
+	if next != uint(e.buf.Len()) {
+		t.Errorf("decode() ended at %d, want %d", next, e.buf.Len())
+	}
+
+	m := value.(map[string]interface{})
+	checks := map[string]interface{}{
+		"ptr":    "shared",
+		"long":   long,
+		"huge":   huge,
+		"double": 1.5,
+		"float":  float32(2.5),
+		"u16":    uint64(443),
+		"u32":    uint64(64500),
+		"u64":    uint64(1 << 40),
+		"i32":    int64(-7),
+		"yes":    true,
+		"no":     false,
+		"zero":   uint64(0),
+	}
+	for k, want := range checks {
+		if m[k] != want {
+			t.Errorf("%s = %v (%T), want %v", k, m[k], m[k], want)
+		}
+	}
+	if u := m["u128"].(*big.Int); u.Cmp(new(big.Int).Lsh(big.NewInt(1), 100)) != 0 {
+		t.Errorf("u128 = %v", u)
+	}
+	if l := m["list"].([]interface{}); len(l) != 2 || l[0] != "a" || l[1] != uint64(1) {
+		t.Errorf("list = %v", l)
+	}
+	if !bytes.Equal(m["bytes"].([]byte), []byte{1, 2}) {
+		t.Errorf("bytes = %v", m["bytes"])
+	}
+	if englishName(m["nested"].(map[string]interface{})) != "Berlin" {
+		t.Errorf("nested = %v", m["nested"])
+	}
+
+	// Two-byte pointers (offset 2048 and up)
+	var far mmdbEncoder
+	far.encode(strings.Repeat("z", 3000))
+	at := far.buf.Len()
+	far.encode("target")
+	far.encode(mmdbPointer(at))
+	value, _, err = decoder{buf: far.buf.Bytes()}.decode(uint(far.buf.Len()-3), 0)
+	if err != nil || value != "target" {
+		t.Errorf("far pointer = %v, %v", value, err)
+	}
+}
+
+func TestNewReader_Invalid(t *testing.T) {
+	valid := buildMMDB(t, 6, 24, "Test", []testNet{{"198.51.100.0/24", map[string]interface{}{"n": "v4"}}})
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"no marker": []byte("not a database"),
+		"truncated metadata": func() []byte {
+			at := bytes.LastIndex(valid, metadataMarker) + len(metadataMarker)
+			return valid[:at+5]
+		}(),
+		"tree larger than file": func() []byte {
+			var meta mmdbEncoder
+			meta.encode(map[string]interface{}{"ip_version": uint16(6), "node_count": uint32(1000), "record_size": uint16(24)})
+			return append(append([]byte{}, metadataMarker...), meta.buf.Bytes()...)
+		}(),
+		"bad record size": func() []byte {
+			var meta mmdbEncoder
+			meta.encode(map[string]interface{}{"ip_version": uint16(6), "node_count": uint32(0), "record_size": uint16(20)})
+			return append(append([]byte{}, metadataMarker...), meta.buf.Bytes()...)
+		}(),
+	}
+	for name, buf := range tests {
+		if _, err := NewReader(buf); !errors.Is(err, ErrInvalidDatabase) {
+			t.Errorf("%s: NewReader() error = %v, want ErrInvalidDatabase", name, err)
+		}
+	}
+
+	if _, err := OpenReader(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("OpenReader() should fail for a missing file")
+	}
+
+	// A data pointer past the data section is reported on lookup
+	corrupt := append([]byte{}, valid...)
+	r, _ := NewReader(corrupt)
+	r.data.buf = r.data.buf[:1]
+	if _, _, err := r.Lookup(net.ParseIP("198.51.100.1")); !errors.Is(err, ErrInvalidDatabase) {
+		t.Errorf("Lookup() on truncated data = %v, want ErrInvalidDatabase", err)
+	}
+}
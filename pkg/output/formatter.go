@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/jhaxce/origindive/pkg/core"
 )
@@ -76,6 +77,16 @@ func (f *Formatter) FormatResult(result core.IPResult) string {
 
 // formatTextResult formats a result in text format with colors
 func (f *Formatter) formatTextResult(result core.IPResult) string {
+	// A CDN edge answered; show which CDN and why instead of the status
+	if result.IsCDNEdge() {
+		edge := result.EdgeProvider
+		if result.PageClass != "" {
+			edge += " " + result.PageClass + " page"
+		}
+		return fmt.Sprintf("%s[C]%s %s --> HTTP %d %sCDN edge: %s%s (%s)",
+			f.yellow, f.nc, result.IP, result.HTTPCode, f.yellow, edge, f.nc, result.EdgeEvidence)
+	}
+
 	switch result.Status {
 	case "200":
 		msg := fmt.Sprintf("%s[+]%s %s --> %s200 OK%s (%s)",
@@ -86,6 +97,16 @@ func (f *Formatter) formatTextResult(result core.IPResult) string {
 			msg += fmt.Sprintf(" | %s\"%s\"%s", f.cyan, result.Title, f.nc)
 		}
 
+		// Add the AS announcing the IP
+		if label := asnLabel(result.ASN, result.ASOrg); label != "" {
+			msg += fmt.Sprintf(" | %s%s%s", f.blue, label, f.nc)
+		}
+
+		// Add the GeoIP location
+		if label := geoLabel(result.Country, result.City); label != "" {
+			msg += fmt.Sprintf(" | %s%s%s", f.blue, label, f.nc)
+		}
+
 		// // Add PTR if available
 		// if result.PTR != "" {
 		// 	msg += fmt.Sprintf(" | %sPTR:%s %s", f.yellow, f.nc, result.PTR)
@@ -96,6 +117,16 @@ func (f *Formatter) formatTextResult(result core.IPResult) string {
 			msg += fmt.Sprintf(" [%s%s%s]", f.magenta, result.BodyHash, f.nc)
 		}
 
+		// Add the provider of the IP's range (e.g. "cloud: aws-ec2")
+		if label := networkLabel(result.Provider, result.Category); label != "" {
+			msg += fmt.Sprintf(" %s(%s)%s", f.yellow, label, f.nc)
+		}
+
+		// Add egress identity if the request went through a proxy
+		if egress := egressLabel(result); egress != "" {
+			msg += fmt.Sprintf(" %svia %s%s", f.blue, egress, f.nc)
+		}
+
 		// Add redirect chain if available
 		if len(result.RedirectChain) > 0 {
 			msg += fmt.Sprintf("\n%s    Redirect chain:%s", f.yellow, f.nc)
@@ -258,6 +289,47 @@ func (f *Formatter) formatTextSummary(summary core.ScanSummary) string {
 
 	if summary.SkippedIPs > 0 {
 		sb.WriteString(fmt.Sprintf("%s[S]%s WAF IPs Skipped: %s%d%s\n", f.yellow, f.nc, f.yellow, summary.SkippedIPs, f.nc))
+		for _, stat := range sortStats(summary.WAFStats) {
+			if stat.count > 0 {
+				sb.WriteString(fmt.Sprintf("    %s%s%s: %d\n", f.cyan, stat.name, f.nc, stat.count))
+			}
+		}
+	}
+
+	if summary.GeoSkippedIPs > 0 {
+		sb.WriteString(fmt.Sprintf("%s[G]%s Skipped by country: %s%d%s\n", f.yellow, f.nc, f.yellow, summary.GeoSkippedIPs, f.nc))
+	}
+
+	if summary.CDNEdgeCount > 0 {
+		sb.WriteString(fmt.Sprintf("%s[C]%s CDN Edges (not origins): %s%d%s\n", f.yellow, f.nc, f.yellow, summary.CDNEdgeCount, f.nc))
+		for _, stat := range sortStats(summary.EdgeStats) {
+			sb.WriteString(fmt.Sprintf("    %s%s%s: %d\n", f.cyan, stat.name, f.nc, stat.count))
+		}
+	}
+
+	if len(summary.PageStats) > 0 {
+		var pages uint64
+		for _, count := range summary.PageStats {
+			pages += count
+		}
+		sb.WriteString(fmt.Sprintf("%s[B]%s CDN/WAF Pages: %s%d%s\n", f.yellow, f.nc, f.yellow, pages, f.nc))
+		for _, stat := range sortStats(summary.PageStats) {
+			sb.WriteString(fmt.Sprintf("    %s%s%s: %d\n", f.cyan, stat.name, f.nc, stat.count))
+		}
+	}
+
+	if len(summary.ASNStats) > 0 {
+		sb.WriteString(fmt.Sprintf("%s[A]%s Hits by ASN: %s%d%s\n", f.blue, f.nc, f.blue, len(summary.ASNStats), f.nc))
+		for _, stat := range sortStats(summary.ASNStats) {
+			sb.WriteString(fmt.Sprintf("    %s%s%s: %d\n", f.cyan, stat.name, f.nc, stat.count))
+		}
+	}
+
+	if len(summary.EgressStats) > 0 {
+		sb.WriteString(fmt.Sprintf("%s[E]%s Egress IPs: %s%d%s\n", f.blue, f.nc, f.blue, len(summary.EgressStats), f.nc))
+		for _, stat := range sortStats(summary.EgressStats) {
+			sb.WriteString(fmt.Sprintf("    %s%s%s: %d requests\n", f.cyan, stat.name, f.nc, stat.count))
+		}
 	}
 
 	sb.WriteString(fmt.Sprintf("%s[T]%s Duration: %s%.2fs%s\n", f.blue, f.nc, f.blue, summary.Duration.Seconds(), f.nc))
@@ -272,15 +344,186 @@ func (f *Formatter) formatTextSummary(summary core.ScanSummary) string {
 	return sb.String()
 }
 
+// FormatPassiveIPs formats scored passive candidates with their confidence
+// and contributing sources
+func (f *Formatter) FormatPassiveIPs(ips []core.PassiveIP) string {
+	switch f.format {
+	case core.FormatJSON:
+		data, _ := json.MarshalIndent(ips, "", "  ")
+		return string(data) + "\n"
+	case core.FormatCSV:
+		var sb strings.Builder
+		sb.WriteString("IP,Confidence,Sources,FirstSeen,LastSeen,Provider,Category,ASN,ASOrg,Country,City\n")
+		for _, p := range ips {
+			sb.WriteString(fmt.Sprintf("%s,%.2f,%s,%s,%s,%s,%s,%s,%s,%s,%s\n",
+				p.IP, p.Confidence, strings.Join(passiveSources(p), ";"),
+				formatSeen(p.FirstSeen), formatSeen(p.LastSeen), p.Provider, p.Category,
+				formatASN(p.ASN), csvField(p.ASOrg), p.Country, csvField(p.City)))
+		}
+		return sb.String()
+	default:
+		return f.formatTextPassiveIPs(ips)
+	}
+}
+
+// formatTextPassiveIPs formats passive candidates as a text table
+func (f *Formatter) formatTextPassiveIPs(ips []core.PassiveIP) string {
+	var sb strings.Builder
+
+	sb.WriteString(f.cyan + "═══════════════════════════════════════════════════════════════\n")
+	sb.WriteString(f.bold + "Passive Candidates\n" + f.nc)
+	sb.WriteString(f.cyan + "═══════════════════════════════════════════════════════════════" + f.nc + "\n")
+	sb.WriteString(fmt.Sprintf("%s  %-18s %-6s %-10s %s%s\n", f.bold, "IP", "SCORE", "LAST SEEN", "SOURCES", f.nc))
+
+	for _, p := range ips {
+		scoreColor := f.red
+		switch {
+		case p.Confidence >= 0.8:
+			scoreColor = f.green
+		case p.Confidence >= 0.6:
+			scoreColor = f.yellow
+		}
+
+		lastSeen := formatSeen(p.LastSeen)
+		if lastSeen == "" {
+			lastSeen = "-"
+		}
+
+		network := ""
+		if label := networkLabel(p.Provider, p.Category); label != "" {
+			network = fmt.Sprintf(" %s(%s)%s", f.yellow, label, f.nc)
+		}
+		if label := asnLabel(p.ASN, p.ASOrg); label != "" {
+			network += fmt.Sprintf(" %s%s%s", f.blue, label, f.nc)
+		}
+		if label := geoLabel(p.Country, p.City); label != "" {
+			network += fmt.Sprintf(" %s[%s]%s", f.blue, label, f.nc)
+		}
+
+		sb.WriteString(fmt.Sprintf("  %-18s %s%-6.2f%s %-10s %s%s\n",
+			p.IP, scoreColor, p.Confidence, f.nc, lastSeen, strings.Join(passiveSources(p), ", "), network))
+	}
+
+	sb.WriteString(f.cyan + "═══════════════════════════════════════════════════════════════" + f.nc + "\n")
+	return sb.String()
+}
+
+// passiveSources returns the contributing sources of a passive record
+func passiveSources(p core.PassiveIP) []string {
+	if len(p.Sources) > 0 {
+		return p.Sources
+	}
+	if p.Source != "" {
+		return []string{p.Source}
+	}
+	return nil
+}
+
+// formatSeen formats a sighting date ("" if unknown)
+func formatSeen(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// networkLabel describes the provider range an IP belongs to
+// ("" outside the WAF database)
+func networkLabel(provider, category string) string {
+	switch {
+	case provider == "":
+		return ""
+	case category == "":
+		return provider
+	default:
+		return category + ": " + provider
+	}
+}
+
+// asnLabel describes the AS announcing an IP, e.g. "AS13335 CLOUDFLARENET"
+// ("" without ASN data)
+func asnLabel(asn int, org string) string {
+	switch {
+	case asn == 0:
+		return ""
+	case org == "":
+		return formatASN(asn)
+	default:
+		return formatASN(asn) + " " + org
+	}
+}
+
+// geoLabel describes a GeoIP location, e.g. "Frankfurt, DE" ("" if unknown)
+func geoLabel(country, city string) string {
+	switch {
+	case country == "":
+		return city
+	case city == "":
+		return country
+	default:
+		return city + ", " + country
+	}
+}
+
+// formatASN formats an AS number as "AS<n>" ("" for none)
+func formatASN(asn int) string {
+	if asn == 0 {
+		return ""
+	}
+	return fmt.Sprintf("AS%d", asn)
+}
+
+// csvField quotes a value for hand-built CSV rows when it contains a comma
+// or quote (AS descriptions often do)
+func csvField(value string) string {
+	if !strings.ContainsAny(value, ",\"\n") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// egressLabel describes the proxy egress of a result ("" for direct requests)
+func egressLabel(result core.IPResult) string {
+	switch {
+	case result.ExitIP != "" && result.Proxy != "":
+		return fmt.Sprintf("%s (%s)", result.ExitIP, result.Proxy)
+	case result.ExitIP != "":
+		return result.ExitIP
+	default:
+		return result.Proxy
+	}
+}
+
+// statEntry is a single name/count pair from a summary stats map
+type statEntry struct {
+	name  string
+	count uint64
+}
+
+// sortStats orders a stats map by count (descending), then name
+func sortStats(stats map[string]uint64) []statEntry {
+	entries := make([]statEntry, 0, len(stats))
+	for name, count := range stats {
+		entries = append(entries, statEntry{name, count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].name < entries[j].name
+	})
+	return entries
+}
+
 // FormatCSVHeader returns CSV header row
 func (f *Formatter) FormatCSVHeader() string {
-	return "IP,Status,HTTPCode,ResponseTime,Error\n"
+	return "IP,Status,HTTPCode,ResponseTime,Error,Provider,Category,EdgeProvider,PageClass,ASN,ASOrg,Country,City\n"
 }
 
 // WriteCSVResults writes results in CSV format
 func (f *Formatter) WriteCSVResults(results []*core.IPResult, writer *csv.Writer) error {
 	// Write header
-	if err := writer.Write([]string{"IP", "Status", "HTTPCode", "ResponseTime", "Error"}); err != nil {
+	if err := writer.Write([]string{"IP", "Status", "HTTPCode", "ResponseTime", "Error", "Provider", "Category", "EdgeProvider", "PageClass", "ASN", "ASOrg", "Country", "City"}); err != nil {
 		return err
 	}
 
@@ -292,6 +535,14 @@ func (f *Formatter) WriteCSVResults(results []*core.IPResult, writer *csv.Writer
 			fmt.Sprintf("%d", r.HTTPCode),
 			r.ResponseTime,
 			r.Error,
+			r.Provider,
+			r.Category,
+			r.EdgeProvider,
+			r.PageClass,
+			formatASN(r.ASN),
+			r.ASOrg,
+			r.Country,
+			r.City,
 		}
 		if err := writer.Write(record); err != nil {
 			return err
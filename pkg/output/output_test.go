@@ -600,3 +600,225 @@ func TestReplaceAll(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatter_FormatEgress(t *testing.T) {
+	f := NewFormatter(core.FormatText, false, false)
+
+	result := f.FormatResult(core.IPResult{
+		IP:           "1.2.3.4",
+		Status:       "200",
+		ResponseTime: "10ms",
+		Proxy:        "http://10.0.0.1:8080",
+		ExitIP:       "203.0.113.10",
+	})
+	if !strings.Contains(result, "via 203.0.113.10 (http://10.0.0.1:8080)") {
+		t.Errorf("FormatResult() should show egress identity, got %q", result)
+	}
+
+	summary := f.FormatSummary(core.ScanSummary{
+		ScannedIPs: 10,
+		EgressStats: map[string]uint64{
+			"203.0.113.10": 7,
+			"203.0.113.11": 3,
+		},
+	})
+	for _, substr := range []string{"Egress IPs: 2", "203.0.113.10: 7 requests", "203.0.113.11: 3 requests"} {
+		if !strings.Contains(summary, substr) {
+			t.Errorf("FormatSummary() should contain %q, got %q", substr, summary)
+		}
+	}
+	if strings.Index(summary, "203.0.113.10") > strings.Index(summary, "203.0.113.11") {
+		t.Error("FormatSummary() should list busiest exit IP first")
+	}
+}
+
+func TestFormatter_FormatSummaryWAFStats(t *testing.T) {
+	f := NewFormatter(core.FormatText, false, false)
+
+	summary := f.FormatSummary(core.ScanSummary{
+		ScannedIPs: 10,
+		SkippedIPs: 300,
+		WAFStats: map[string]uint64{
+			"cloudflare":    256,
+			"custom-office": 44,
+			"fastly":        0,
+		},
+	})
+	for _, substr := range []string{"WAF IPs Skipped: 300", "cloudflare: 256", "custom-office: 44"} {
+		if !strings.Contains(summary, substr) {
+			t.Errorf("FormatSummary() should contain %q, got %q", substr, summary)
+		}
+	}
+	if strings.Contains(summary, "fastly") {
+		t.Error("FormatSummary() should omit providers with nothing skipped")
+	}
+}
+
+func TestFormatter_FormatPassiveIPs(t *testing.T) {
+	seen := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	ips := []core.PassiveIP{
+		{IP: "192.0.2.1", Source: "shodan", Sources: []string{"securitytrails", "shodan"}, Confidence: 0.85, LastSeen: seen},
+		{IP: "192.0.2.2", Source: "ct", Confidence: 0.48},
+	}
+
+	text := NewFormatter(core.FormatText, false, false).FormatPassiveIPs(ips)
+	for _, substr := range []string{"Passive Candidates", "192.0.2.1", "0.85", "2025-03-01", "securitytrails, shodan", "192.0.2.2", "0.48", "ct"} {
+		if !strings.Contains(text, substr) {
+			t.Errorf("FormatPassiveIPs() text should contain %q, got %q", substr, text)
+		}
+	}
+
+	var decoded []core.PassiveIP
+	data := NewFormatter(core.FormatJSON, false, false).FormatPassiveIPs(ips)
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+		t.Fatalf("FormatPassiveIPs() JSON invalid: %v", err)
+	}
+	if len(decoded) != 2 || decoded[0].Confidence != 0.85 || len(decoded[0].Sources) != 2 {
+		t.Errorf("FormatPassiveIPs() JSON round-trip mismatch: %+v", decoded)
+	}
+
+	csvOut := NewFormatter(core.FormatCSV, false, false).FormatPassiveIPs(ips)
+	if !strings.Contains(csvOut, "192.0.2.1,0.85,securitytrails;shodan,,2025-03-01") {
+		t.Errorf("FormatPassiveIPs() CSV row mismatch, got %q", csvOut)
+	}
+}
+
+func TestFormatter_ProviderCategory(t *testing.T) {
+	f := NewFormatter(core.FormatText, false, false)
+
+	line := f.FormatResult(core.IPResult{IP: "3.1.1.1", Status: "200", ResponseTime: "10ms", Provider: "aws-ec2", Category: "cloud"})
+	if !strings.Contains(line, "(cloud: aws-ec2)") {
+		t.Errorf("FormatResult() = %q, want the provider category", line)
+	}
+	if line := f.FormatResult(core.IPResult{IP: "192.0.2.1", Status: "200", ResponseTime: "10ms"}); strings.Contains(line, ": ") {
+		t.Errorf("FormatResult() = %q, want no provider label", line)
+	}
+
+	ips := []core.PassiveIP{{IP: "5.9.1.1", Source: "shodan", Confidence: 0.7, Provider: "hetzner", Category: "hosting"}}
+	if text := f.FormatPassiveIPs(ips); !strings.Contains(text, "(hosting: hetzner)") {
+		t.Errorf("FormatPassiveIPs() = %q, want the provider category", text)
+	}
+	if csvOut := NewFormatter(core.FormatCSV, false, false).FormatPassiveIPs(ips); !strings.Contains(csvOut, ",Provider,Category,ASN,ASOrg,Country,City\n") || !strings.Contains(csvOut, ",hetzner,hosting,,,,\n") {
+		t.Errorf("FormatPassiveIPs() CSV = %q", csvOut)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := f.WriteCSVResults([]*core.IPResult{{IP: "3.1.1.1", Status: "200", HTTPCode: 200, Provider: "aws-ec2", Category: "cloud"}}, w); err != nil {
+		t.Fatalf("WriteCSVResults() error: %v", err)
+	}
+	w.Flush()
+	if !strings.Contains(buf.String(), "3.1.1.1,200,200,,,aws-ec2,cloud") {
+		t.Errorf("WriteCSVResults() = %q", buf.String())
+	}
+}
+
+func TestFormatter_CDNEdge(t *testing.T) {
+	f := NewFormatter(core.FormatText, false, false)
+
+	edge := core.IPResult{IP: "104.16.1.1", Status: "200", HTTPCode: 200, EdgeProvider: "cloudflare", EdgeEvidence: "cf-ray: 8f1c-LAX"}
+	if line := f.FormatResult(edge); !strings.Contains(line, "CDN edge: cloudflare") || !strings.Contains(line, "(cf-ray: 8f1c-LAX)") || strings.Contains(line, "200 OK") {
+		t.Errorf("FormatResult() = %q, want a CDN edge line", line)
+	}
+
+	summary := f.FormatSummary(core.ScanSummary{CDNEdgeCount: 3, EdgeStats: map[string]uint64{"cloudflare": 2, "fastly": 1}})
+	if !strings.Contains(summary, "CDN Edges (not origins): 3") || !strings.Contains(summary, "cloudflare: 2") {
+		t.Errorf("FormatSummary() = %q, want the CDN edge counts", summary)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := f.WriteCSVResults([]*core.IPResult{&edge}, w); err != nil {
+		t.Fatalf("WriteCSVResults() error: %v", err)
+	}
+	if !strings.Contains(buf.String(), ",EdgeProvider,PageClass,ASN,ASOrg,Country,City\n") || !strings.Contains(buf.String(), ",cloudflare,,,,,\n") {
+		t.Errorf("WriteCSVResults() = %q", buf.String())
+	}
+}
+
+func TestFormatter_PageClass(t *testing.T) {
+	f := NewFormatter(core.FormatText, false, false)
+
+	block := core.IPResult{IP: "104.16.1.1", Status: "4xx", HTTPCode: 403, EdgeProvider: "cloudflare", EdgeEvidence: "cf-ray: 8f1c-LAX", PageClass: core.PageClassBlock}
+	if line := f.FormatResult(block); !strings.Contains(line, "CDN edge: cloudflare block page") {
+		t.Errorf("FormatResult() = %q, want the page class", line)
+	}
+
+	summary := f.FormatSummary(core.ScanSummary{
+		SuccessCount: 1,
+		CDNEdgeCount: 3,
+		EdgeStats:    map[string]uint64{"cloudflare": 3},
+		PageStats:    map[string]uint64{core.PageClassBlock: 2, core.PageClassChallenge: 1},
+	})
+	for _, want := range []string{"200 OK: 1", "CDN Edges (not origins): 3", "CDN/WAF Pages: 3", "block: 2", "challenge: 1"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("FormatSummary() = %q, want %q", summary, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	f.WriteCSVResults([]*core.IPResult{&block}, w)
+	if !strings.Contains(buf.String(), ",cloudflare,block,,,,\n") {
+		t.Errorf("WriteCSVResults() = %q", buf.String())
+	}
+}
+
+func TestFormatter_ASN(t *testing.T) {
+	f := NewFormatter(core.FormatText, false, false)
+
+	hit := core.IPResult{IP: "198.51.100.7", Status: "200", HTTPCode: 200, ResponseTime: "10ms", ASN: 64500, ASOrg: "EXAMPLE-NET, Inc."}
+	if line := f.FormatResult(hit); !strings.Contains(line, "| AS64500 EXAMPLE-NET, Inc.") {
+		t.Errorf("FormatResult() = %q, want the ASN", line)
+	}
+
+	summary := f.FormatSummary(core.ScanSummary{SuccessCount: 3, ASNStats: map[string]uint64{"AS64500 EXAMPLE-NET": 2, "AS64501": 1}})
+	for _, want := range []string{"Hits by ASN: 2", "AS64500 EXAMPLE-NET: 2", "AS64501: 1"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("FormatSummary() = %q, want %q", summary, want)
+		}
+	}
+
+	ips := []core.PassiveIP{{IP: "198.51.100.7", Source: "ct", Confidence: 0.7, ASN: 64500, ASOrg: "EXAMPLE-NET, Inc."}}
+	if text := f.FormatPassiveIPs(ips); !strings.Contains(text, "AS64500 EXAMPLE-NET, Inc.") {
+		t.Errorf("FormatPassiveIPs() = %q, want the ASN", text)
+	}
+	if csvOut := NewFormatter(core.FormatCSV, false, false).FormatPassiveIPs(ips); !strings.Contains(csvOut, `,AS64500,"EXAMPLE-NET, Inc."`) {
+		t.Errorf("FormatPassiveIPs() CSV = %q", csvOut)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	f.WriteCSVResults([]*core.IPResult{&hit}, w)
+	if !strings.Contains(buf.String(), `,AS64500,"EXAMPLE-NET, Inc."`) {
+		t.Errorf("WriteCSVResults() = %q", buf.String())
+	}
+}
+
+func TestFormatter_Geo(t *testing.T) {
+	f := NewFormatter(core.FormatText, false, false)
+
+	hit := core.IPResult{IP: "198.51.100.7", Status: "200", HTTPCode: 200, ResponseTime: "10ms", Country: "DE", City: "Frankfurt am Main"}
+	if line := f.FormatResult(hit); !strings.Contains(line, "| Frankfurt am Main, DE") {
+		t.Errorf("FormatResult() = %q, want the location", line)
+	}
+
+	if summary := f.FormatSummary(core.ScanSummary{GeoSkippedIPs: 12}); !strings.Contains(summary, "Skipped by country: 12") {
+		t.Errorf("FormatSummary() = %q, want the geo skip count", summary)
+	}
+
+	ips := []core.PassiveIP{{IP: "198.51.100.7", Source: "ct", Confidence: 0.7, Country: "DE"}}
+	if text := f.FormatPassiveIPs(ips); !strings.Contains(text, "[DE]") {
+		t.Errorf("FormatPassiveIPs() = %q, want the country", text)
+	}
+	if csvOut := NewFormatter(core.FormatCSV, false, false).FormatPassiveIPs(ips); !strings.Contains(csvOut, ",DE,\n") {
+		t.Errorf("FormatPassiveIPs() CSV = %q", csvOut)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	f.WriteCSVResults([]*core.IPResult{&hit}, w)
+	if !strings.Contains(buf.String(), ",DE,Frankfurt am Main\n") {
+		t.Errorf("WriteCSVResults() = %q", buf.String())
+	}
+}
@@ -134,6 +134,7 @@ func (w *Writer) WriteCSV(result *core.ScanResult) error {
 	allResults = append(allResults, result.Other...)
 	allResults = append(allResults, result.Timeouts...)
 	allResults = append(allResults, result.Errors...)
+	allResults = append(allResults, result.CDNEdges...)
 
 	return w.formatter.WriteCSVResults(allResults, writer)
 }
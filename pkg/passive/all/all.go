@@ -0,0 +1,19 @@
+// Package all registers every built-in passive source with the passive
+// registry. Import it for its side effects:
+//
+//	import _ "github.com/jhaxce/origindive/pkg/passive/all"
+package all
+
+import (
+	_ "github.com/jhaxce/origindive/pkg/passive/censys"
+	_ "github.com/jhaxce/origindive/pkg/passive/ct"
+	_ "github.com/jhaxce/origindive/pkg/passive/dns"
+	_ "github.com/jhaxce/origindive/pkg/passive/dnsdumpster"
+	_ "github.com/jhaxce/origindive/pkg/passive/hunter"
+	_ "github.com/jhaxce/origindive/pkg/passive/securitytrails"
+	_ "github.com/jhaxce/origindive/pkg/passive/shodan"
+	_ "github.com/jhaxce/origindive/pkg/passive/viewdns"
+	_ "github.com/jhaxce/origindive/pkg/passive/virustotal"
+	_ "github.com/jhaxce/origindive/pkg/passive/wayback"
+	_ "github.com/jhaxce/origindive/pkg/passive/zoomeye"
+)
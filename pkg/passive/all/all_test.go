@@ -0,0 +1,82 @@
+package all
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+)
+
+func TestAllSourcesRegistered(t *testing.T) {
+	want := []string{
+		"censys", "ct", "dns", "dnsdumpster", "hunter", "securitytrails",
+		"shodan", "viewdns", "virustotal", "wayback", "zoomeye",
+	}
+
+	got := passive.Names()
+	if len(got) != len(want) {
+		t.Fatalf("Names() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Names()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAllSources_Factories(t *testing.T) {
+	opts := passive.Options{Config: core.DefaultConfig(), Timeout: time.Second}
+
+	for _, reg := range passive.All() {
+		t.Run(reg.Name, func(t *testing.T) {
+			if reg.Description == "" {
+				t.Error("Description is empty")
+			}
+
+			src, err := reg.Factory(opts)
+			if reg.RequiresCredentials() {
+				// No keys configured: factory must report missing credentials
+				if !errors.Is(err, passive.ErrMissingCredentials) {
+					t.Errorf("Factory() error = %v, want ErrMissingCredentials", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Factory() error: %v", err)
+			}
+			if src.Name() != reg.Name {
+				t.Errorf("Name() = %s, want %s", src.Name(), reg.Name)
+			}
+			if len(src.RequiredCredentials()) != 0 {
+				t.Errorf("RequiredCredentials() = %v, want none for free source", src.RequiredCredentials())
+			}
+		})
+	}
+}
+
+func TestAllSources_FactoriesWithKeys(t *testing.T) {
+	config := core.DefaultConfig()
+	config.ShodanKeys = []string{"k"}
+	config.CensysTokens = []string{"k"}
+	config.SecurityTrailsKeys = []string{"k"}
+	config.ZoomEyeKeys = []string{"k"}
+	config.HunterKeys = []string{"k"}
+	config.VirusTotalKeys = []string{"k"}
+	config.ViewDNSKeys = []string{"k"}
+	config.DNSDumpsterKeys = []string{"k"}
+
+	for _, name := range passive.Names() {
+		src, err := passive.New(name, passive.Options{Config: config, Timeout: time.Second})
+		if err != nil {
+			t.Errorf("New(%s) error: %v", name, err)
+			continue
+		}
+		reg, _ := passive.Lookup(name)
+		if len(src.RequiredCredentials()) != len(reg.Credentials) {
+			t.Errorf("%s: RequiredCredentials() = %v, registration lists %v", name, src.RequiredCredentials(), reg.Credentials)
+		}
+	}
+}
@@ -17,6 +17,7 @@ const (
 	SourceSecurityTrails Source = "securitytrails"
 	SourceVirusTotal     Source = "virustotal"
 	SourceZoomEye        Source = "zoomeye"
+	SourceHunter         Source = "hunter"
 	SourceCT             Source = "ct"
 	SourceViewDNS        Source = "viewdns"
 	SourceDNSDumpster    Source = "dnsdumpster"
@@ -54,8 +55,13 @@ type Manager struct {
 	// Multiple API keys per source for rotation
 	shodanKeys   []string
 	censysCreds  []CensysCredential
-	currentIndex map[Source]int // Current key index for each source
-	mu           sync.RWMutex
+	keys         map[Source][]string             // Keys used by Do, per source
+	currentIndex map[Source]int                  // Current key index for each source
+	cooldowns    map[Source]map[string]time.Time // Key fingerprint -> cooldown end
+
+	skipCooling        bool // Skip keys still in cooldown
+	retryAfterCooldown bool // Wait for a cooldown to end instead of failing
+	mu                 sync.RWMutex
 }
 
 // CensysCredential holds Censys API credentials
@@ -69,7 +75,10 @@ func NewManager(failoverEnabled bool) *Manager {
 	return &Manager{
 		sources:      make(map[Source]*APIStatus),
 		failover:     failoverEnabled,
+		keys:         make(map[Source][]string),
 		currentIndex: make(map[Source]int),
+		cooldowns:    make(map[Source]map[string]time.Time),
+		skipCooling:  true,
 	}
 }
 
@@ -78,6 +87,7 @@ func (m *Manager) SetShodanKeys(keys []string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.shodanKeys = keys
+	m.keys[SourceShodan] = keys
 	m.currentIndex[SourceShodan] = 0
 }
 
@@ -116,7 +126,14 @@ func (m *Manager) GetCurrentKey(source Source) (interface{}, error) {
 		return m.censysCreds[idx], nil
 
 	default:
-		return nil, nil // CT and DNS don't need keys
+		keys := m.keys[source]
+		if len(keys) == 0 {
+			return nil, nil // CT and DNS don't need keys
+		}
+		if idx >= len(keys) {
+			return nil, fmt.Errorf("all %s keys exhausted", source)
+		}
+		return keys[idx], nil
 	}
 }
 
@@ -139,6 +156,12 @@ func (m *Manager) RotateKey(source Source) bool {
 			m.currentIndex[source] = idx + 1
 			return true
 		}
+
+	default:
+		if idx+1 < len(m.keys[source]) {
+			m.currentIndex[source] = idx + 1
+			return true
+		}
 	}
 
 	return false // No more keys available
@@ -0,0 +1,377 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuotaCooldown is how long a key stays out of rotation after a quota or
+// credit exhaustion error (these reset daily or monthly, not per minute)
+const QuotaCooldown = 24 * time.Hour
+
+// DefaultCooldowns is how long a key stays out of rotation after a plain
+// rate limit response without a Retry-After header, per source
+var DefaultCooldowns = map[Source]time.Duration{
+	SourceShodan:         1 * time.Minute,  // 1 request/second on paid plans
+	SourceCensys:         5 * time.Minute,  // Per-minute query budget
+	SourceSecurityTrails: QuotaCooldown,    // Monthly query quota
+	SourceVirusTotal:     1 * time.Minute,  // 4 requests/minute on free keys
+	SourceZoomEye:        QuotaCooldown,    // Daily/monthly credits
+	SourceHunter:         QuotaCooldown,    // Daily API pull quota
+	SourceViewDNS:        QuotaCooldown,    // Daily query quota
+	SourceDNSDumpster:    10 * time.Minute, // 1 request/2s, daily cap
+}
+
+// defaultCooldown is used for sources without an entry in DefaultCooldowns
+const defaultCooldown = 1 * time.Hour
+
+// RateLimitError is returned by sources when an API rejects a request
+// because of rate limits or exhausted quota
+type RateLimitError struct {
+	StatusCode int           // HTTP status code (e.g., 429)
+	RetryAfter time.Duration // Parsed Retry-After header (0 = not provided)
+	Message    string        // API error message
+}
+
+// Error implements the error interface
+func (e *RateLimitError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("rate limit exceeded (HTTP %d): %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("rate limit exceeded (HTTP %d)", e.StatusCode)
+}
+
+// NewRateLimitError creates a RateLimitError from an HTTP response
+// The Retry-After header is honored in both delay-seconds and HTTP-date form
+func NewRateLimitError(resp *http.Response, message string) *RateLimitError {
+	e := &RateLimitError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(message)}
+	e.RetryAfter = RetryAfter(resp)
+	return e
+}
+
+// RetryAfter returns the delay requested by a response's Retry-After
+// header, or 0 if it is absent, invalid or in the past
+func RetryAfter(resp *http.Response) time.Duration {
+	return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+}
+
+// parseRetryAfter parses a Retry-After header value relative to now
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// IsRateLimitError reports whether err is a rate limit or quota error
+func IsRateLimitError(err error) bool {
+	var rle *RateLimitError
+	if errors.As(err, &rle) {
+		return true
+	}
+	return isRateLimitError(err)
+}
+
+// isQuotaError reports whether err describes exhausted quota or credits
+func isQuotaError(err error) bool {
+	msg := err.Error()
+	return contains(msg, "quota") ||
+		contains(msg, "credits") ||
+		contains(msg, "limit reached") ||
+		contains(msg, "limit_reached")
+}
+
+// CooldownFor returns how long a key should stay out of rotation after err
+// A Retry-After value wins; quota errors cool down for QuotaCooldown;
+// otherwise the source's entry in DefaultCooldowns is used
+func CooldownFor(source Source, err error) time.Duration {
+	var rle *RateLimitError
+	if errors.As(err, &rle) && rle.RetryAfter > 0 {
+		return rle.RetryAfter
+	}
+	if isQuotaError(err) {
+		return QuotaCooldown
+	}
+	if d, ok := DefaultCooldowns[source]; ok {
+		return d
+	}
+	return defaultCooldown
+}
+
+// SetKeys sets the API keys used for rotation by Do
+// Blank keys are ignored
+func (m *Manager) SetKeys(source Source, keys []string) {
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			valid = append(valid, key)
+		}
+	}
+
+	m.mu.Lock()
+	m.keys[source] = valid
+	m.currentIndex[source] = 0
+	m.mu.Unlock()
+
+	m.RegisterSource(source)
+}
+
+// HasKeys reports whether any keys are configured for a source
+func (m *Manager) HasKeys(source Source) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.keys[source]) > 0
+}
+
+// SetCooldownPolicy configures how Do treats keys in cooldown
+// skipCooling skips keys whose cooldown has not expired (otherwise they are
+// tried anyway); retryAfterCooldown waits for the earliest cooldown to end
+// when every key is cooling down and the context deadline allows it
+func (m *Manager) SetCooldownPolicy(skipCooling, retryAfterCooldown bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.skipCooling = skipCooling
+	m.retryAfterCooldown = retryAfterCooldown
+}
+
+// Do calls fn with the source's keys, starting at the current key
+// Rate limit errors put the key into cooldown (see CooldownFor) and, with
+// failover enabled, rotate to the next key. Any other error is returned
+// immediately. When every key is rate limited the source is marked
+// StatusRateLimited until the earliest key cooldown ends.
+func (m *Manager) Do(ctx context.Context, source Source, fn func(key string) error) error {
+	m.RegisterSource(source)
+
+	m.mu.RLock()
+	keys := m.keys[source]
+	start := m.currentIndex[source]
+	skipCooling := m.skipCooling
+	retry := m.retryAfterCooldown
+	m.mu.RUnlock()
+
+	if len(keys) == 0 {
+		return fmt.Errorf("no valid API keys found")
+	}
+
+	for attempt := 0; ; attempt++ {
+		var lastErr error
+		for i := 0; i < len(keys); i++ {
+			idx := (start + i) % len(keys)
+			key := keys[idx]
+
+			if until := m.keyCooldown(source, key); skipCooling && time.Now().Before(until) {
+				lastErr = fmt.Errorf("key %d/%d cooling down until %s", idx+1, len(keys), until.Format(time.RFC3339))
+				continue
+			}
+
+			m.IncrementRequests(source)
+			err := fn(key)
+			if err == nil {
+				m.setCurrent(source, idx)
+				m.setStatus(source, StatusAvailable, nil, time.Time{})
+				return nil
+			}
+
+			if !IsRateLimitError(err) {
+				m.setStatus(source, StatusError, err, time.Time{})
+				return fmt.Errorf("key %d/%d failed: %w", idx+1, len(keys), err)
+			}
+
+			m.coolKey(source, key, CooldownFor(source, err))
+			lastErr = fmt.Errorf("key %d/%d rate limited: %w", idx+1, len(keys), err)
+			if !m.failover {
+				break // No rotation without failover
+			}
+		}
+
+		// Every key is rate limited or cooling down
+		until := m.earliestCooldown(source, keys)
+		m.setStatus(source, StatusRateLimited, lastErr, until)
+
+		if attempt == 0 && retry && waitUntil(ctx, until) {
+			continue
+		}
+		return fmt.Errorf("all %d API keys exhausted: %w", len(keys), lastErr)
+	}
+}
+
+// waitUntil sleeps until t if the context deadline allows it
+// Returns false without waiting when it would outlive the context
+func waitUntil(ctx context.Context, t time.Time) bool {
+	if deadline, ok := ctx.Deadline(); ok && t.After(deadline) {
+		return false
+	}
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// setCurrent records the key index that last succeeded
+func (m *Manager) setCurrent(source Source, idx int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.currentIndex[source] = idx
+}
+
+// setStatus updates a registered source's status
+func (m *Manager) setStatus(source Source, s Status, err error, rateLimitEnd time.Time) {
+	m.mu.RLock()
+	status, exists := m.sources[source]
+	m.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	status.Status = s
+	status.LastError = err
+	status.LastChecked = time.Now()
+	status.RateLimitEnd = rateLimitEnd
+}
+
+// RecordResult updates a source's status from the outcome of a query
+// Used for sources that do not go through Do (e.g., free sources)
+func (m *Manager) RecordResult(source Source, err error) {
+	m.RegisterSource(source)
+	switch {
+	case err == nil:
+		m.setStatus(source, StatusAvailable, nil, time.Time{})
+	case IsRateLimitError(err):
+		m.setStatus(source, StatusRateLimited, err, time.Now().Add(CooldownFor(source, err)))
+	default:
+		m.setStatus(source, StatusError, err, time.Time{})
+	}
+}
+
+// keyFingerprint identifies a key without storing it
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// keyCooldown returns when a key's cooldown ends (zero if none)
+func (m *Manager) keyCooldown(source Source, key string) time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cooldowns[source][keyFingerprint(key)]
+}
+
+// coolKey takes a key out of rotation for d
+func (m *Manager) coolKey(source Source, key string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cooldowns[source] == nil {
+		m.cooldowns[source] = make(map[string]time.Time)
+	}
+	m.cooldowns[source][keyFingerprint(key)] = time.Now().Add(d)
+}
+
+// earliestCooldown returns when the first of the given keys becomes usable
+func (m *Manager) earliestCooldown(source Source, keys []string) time.Time {
+	var earliest time.Time
+	for _, key := range keys {
+		until := m.keyCooldown(source, key)
+		if until.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || until.Before(earliest) {
+			earliest = until
+		}
+	}
+	return earliest
+}
+
+// managerState is the on-disk form of key cooldowns
+// Keys are stored as truncated SHA-256 fingerprints, never in clear text
+type managerState struct {
+	Cooldowns map[Source]map[string]time.Time `json:"cooldowns"`
+}
+
+// LoadState restores key cooldowns saved by SaveState
+// A missing file is not an error; expired cooldowns are dropped
+func (m *Manager) LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read API state: %w", err)
+	}
+
+	var state managerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse API state: %w", err)
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for source, keys := range state.Cooldowns {
+		for fp, until := range keys {
+			if !until.After(now) {
+				continue
+			}
+			if m.cooldowns[source] == nil {
+				m.cooldowns[source] = make(map[string]time.Time)
+			}
+			m.cooldowns[source][fp] = until
+		}
+	}
+	return nil
+}
+
+// SaveState writes active key cooldowns so later runs skip exhausted keys
+func (m *Manager) SaveState(path string) error {
+	now := time.Now()
+	state := managerState{Cooldowns: make(map[Source]map[string]time.Time)}
+
+	m.mu.RLock()
+	for source, keys := range m.cooldowns {
+		for fp, until := range keys {
+			if !until.After(now) {
+				continue
+			}
+			if state.Cooldowns[source] == nil {
+				state.Cooldowns[source] = make(map[string]time.Time)
+			}
+			state.Cooldowns[source][fp] = until
+		}
+	}
+	m.mu.RUnlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimitError_RetryAfter(t *testing.T) {
+	resp := &http.Response{StatusCode: 429, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "120")
+
+	err := NewRateLimitError(resp, " slow down ")
+	if err.RetryAfter != 2*time.Minute {
+		t.Errorf("RetryAfter = %v, want 2m", err.RetryAfter)
+	}
+	if err.Error() != "rate limit exceeded (HTTP 429): slow down" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-5", 0},
+		{"Wed, 01 Jan 2025 12:05:00 GMT", 5 * time.Minute},
+		{"Wed, 01 Jan 2025 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCooldownFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		source Source
+		err    error
+		want   time.Duration
+	}{
+		{"retry-after wins", SourceSecurityTrails, &RateLimitError{StatusCode: 429, RetryAfter: 42 * time.Second}, 42 * time.Second},
+		{"quota message", SourceShodan, errors.New("Query quota exceeded"), QuotaCooldown},
+		{"source default", SourceVirusTotal, &RateLimitError{StatusCode: 429}, DefaultCooldowns[SourceVirusTotal]},
+		{"unknown source", Source("other"), errors.New("HTTP 429"), defaultCooldown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CooldownFor(tt.source, tt.err); got != tt.want {
+				t.Errorf("CooldownFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRateLimitError_Wrapped(t *testing.T) {
+	err := errors.Join(errors.New("context"), &RateLimitError{StatusCode: 204})
+	if !IsRateLimitError(err) {
+		t.Error("Expected wrapped RateLimitError to be detected")
+	}
+	if IsRateLimitError(errors.New("invalid API key")) {
+		t.Error("Expected plain error not to be a rate limit")
+	}
+}
+
+func TestManager_Do_RotatesOnRateLimit(t *testing.T) {
+	m := NewManager(true)
+	m.SetKeys(SourceShodan, []string{"key1", " ", "key2"})
+
+	var used []string
+	err := m.Do(context.Background(), SourceShodan, func(key string) error {
+		used = append(used, key)
+		if key == "key1" {
+			return &RateLimitError{StatusCode: 429}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if strings.Join(used, ",") != "key1,key2" {
+		t.Errorf("Keys used = %v, want key1,key2", used)
+	}
+
+	status, _ := m.GetStatus(SourceShodan)
+	if status.Status != StatusAvailable || status.RequestsMade != 2 {
+		t.Errorf("Status = %s, requests = %d; want available, 2", status.Status, status.RequestsMade)
+	}
+
+	// The rate-limited key stays in cooldown and the working key is reused
+	used = nil
+	m.Do(context.Background(), SourceShodan, func(key string) error {
+		used = append(used, key)
+		return nil
+	})
+	if strings.Join(used, ",") != "key2" {
+		t.Errorf("Keys used on second call = %v, want key2", used)
+	}
+}
+
+func TestManager_Do_AllKeysExhausted(t *testing.T) {
+	m := NewManager(true)
+	m.SetKeys(SourceVirusTotal, []string{"a", "b"})
+
+	err := m.Do(context.Background(), SourceVirusTotal, func(key string) error {
+		return &RateLimitError{StatusCode: 429, RetryAfter: time.Hour}
+	})
+	if err == nil || !strings.Contains(err.Error(), "all 2 API keys exhausted") {
+		t.Fatalf("Do() error = %v, want exhausted error", err)
+	}
+
+	status, _ := m.GetStatus(SourceVirusTotal)
+	if status.Status != StatusRateLimited {
+		t.Errorf("Status = %s, want rate_limited", status.Status)
+	}
+	if d := time.Until(status.RateLimitEnd); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("RateLimitEnd in %v, want ~1h", d)
+	}
+
+	// Keys in cooldown are not called again
+	called := false
+	m.Do(context.Background(), SourceVirusTotal, func(key string) error {
+		called = true
+		return nil
+	})
+	if called {
+		t.Error("Expected cooling keys to be skipped")
+	}
+
+	// Unless cooldowns are ignored
+	m.SetCooldownPolicy(false, false)
+	if err := m.Do(context.Background(), SourceVirusTotal, func(key string) error { return nil }); err != nil {
+		t.Errorf("Do() with skipCooling=false error = %v", err)
+	}
+}
+
+func TestManager_Do_OtherErrorStops(t *testing.T) {
+	m := NewManager(true)
+	m.SetKeys(SourceZoomEye, []string{"a", "b"})
+
+	calls := 0
+	err := m.Do(context.Background(), SourceZoomEye, func(key string) error {
+		calls++
+		return errors.New("invalid API key")
+	})
+	if err == nil || err.Error() != "key 1/2 failed: invalid API key" {
+		t.Errorf("Do() error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestManager_Do_NoFailover(t *testing.T) {
+	m := NewManager(false)
+	m.SetKeys(SourceShodan, []string{"a", "b"})
+
+	calls := 0
+	err := m.Do(context.Background(), SourceShodan, func(key string) error {
+		calls++
+		return &RateLimitError{StatusCode: 429}
+	})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if calls != 1 {
+		t.Errorf("Expected no rotation without failover, got %d calls", calls)
+	}
+}
+
+func TestManager_Do_NoKeys(t *testing.T) {
+	m := NewManager(true)
+	m.SetKeys(SourceShodan, []string{"", "  "})
+
+	err := m.Do(context.Background(), SourceShodan, func(key string) error { return nil })
+	if err == nil || err.Error() != "no valid API keys found" {
+		t.Errorf("Do() error = %v, want no valid API keys found", err)
+	}
+	if m.HasKeys(SourceShodan) {
+		t.Error("HasKeys() should be false for blank keys")
+	}
+}
+
+func TestManager_Do_RetryAfterCooldown(t *testing.T) {
+	m := NewManager(true)
+	m.SetKeys(SourceCensys, []string{"token"})
+	m.SetCooldownPolicy(true, true)
+
+	calls := 0
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := m.Do(ctx, SourceCensys, func(key string) error {
+		calls++
+		if calls == 1 {
+			return &RateLimitError{StatusCode: 429, RetryAfter: 50 * time.Millisecond}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected retry after cooldown, got %d calls", calls)
+	}
+}
+
+func TestManager_RecordResult(t *testing.T) {
+	m := NewManager(true)
+
+	m.RecordResult(SourceCT, nil)
+	if s, _ := m.GetStatus(SourceCT); s.Status != StatusAvailable {
+		t.Errorf("Status = %s, want available", s.Status)
+	}
+
+	m.RecordResult(SourceCT, errors.New("HTTP 429 too many requests"))
+	if s, _ := m.GetStatus(SourceCT); s.Status != StatusRateLimited || s.RateLimitEnd.IsZero() {
+		t.Errorf("Status = %s, RateLimitEnd = %v; want rate_limited with end", s.Status, s.RateLimitEnd)
+	}
+
+	m.RecordResult(SourceCT, errors.New("connection refused"))
+	if s, _ := m.GetStatus(SourceCT); s.Status != StatusError {
+		t.Errorf("Status = %s, want error", s.Status)
+	}
+}
+
+func TestManager_SaveLoadState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "api_state.json")
+
+	m := NewManager(true)
+	m.coolKey(SourceShodan, "secret-key", time.Hour)
+	m.coolKey(SourceShodan, "expired-key", -time.Minute)
+	if err := m.SaveState(path); err != nil {
+		t.Fatalf("SaveState() error = %v", err)
+	}
+
+	loaded := NewManager(true)
+	if err := loaded.LoadState(path); err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if loaded.keyCooldown(SourceShodan, "secret-key").IsZero() {
+		t.Error("Expected active cooldown to be restored")
+	}
+	if !loaded.keyCooldown(SourceShodan, "expired-key").IsZero() {
+		t.Error("Expected expired cooldown to be dropped")
+	}
+
+	// A missing file is not an error
+	if err := NewManager(true).LoadState(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("LoadState() on missing file error = %v", err)
+	}
+}
@@ -3,10 +3,12 @@ package api
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -254,6 +256,65 @@ func ZoomEyeValidator(apiKey string) func(context.Context) error {
 	}
 }
 
+// HunterValidator validates Hunter.how API key
+func HunterValidator(apiKey string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		return validateHunter(ctx, "https://api.hunter.how", apiKey)
+	}
+}
+
+// validateHunter runs a one-row search against baseURL
+// Hunter.how has no account endpoint, and most errors arrive as HTTP 200
+// with the status in the JSON "code" field
+func validateHunter(ctx context.Context, baseURL, apiKey string) error {
+	if apiKey == "" || strings.Contains(apiKey, "YOUR_") {
+		return fmt.Errorf("hunter API key not configured")
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	params := url.Values{}
+	params.Set("api-key", apiKey)
+	params.Set("query", base64.URLEncoding.EncodeToString([]byte(`domain="example.com"`)))
+	params.Set("page", "1")
+	params.Set("page_size", "1")
+	params.Set("start_time", today)
+	params.Set("end_time", today)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("hunter API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	code := resp.StatusCode
+	if code == 200 {
+		var result struct {
+			Code int `json:"code"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && result.Code != 0 {
+			code = result.Code
+		}
+	}
+
+	if code == 401 || code == 403 {
+		return fmt.Errorf("hunter API key is invalid")
+	}
+	if code == 429 {
+		return fmt.Errorf("hunter rate limit exceeded")
+	}
+	if code != 200 {
+		return fmt.Errorf("hunter API returned status %d", code)
+	}
+
+	return nil
+}
+
 // ViewDNSValidator validates ViewDNS API access (free service)
 func ViewDNSValidator() func(context.Context) error {
 	return func(ctx context.Context) error {
@@ -327,7 +388,7 @@ func DNSValidator() func(context.Context) error {
 }
 
 // GetValidator returns the appropriate validator for a source
-func GetValidator(source Source, shodanKey, censysID, censysSecret, stKey, vtKey, zeKey string) func(context.Context) error {
+func GetValidator(source Source, shodanKey, censysID, censysSecret, stKey, vtKey, zeKey, hunterKey string) func(context.Context) error {
 	switch source {
 	case SourceShodan:
 		return ShodanValidator(shodanKey)
@@ -339,6 +400,8 @@ func GetValidator(source Source, shodanKey, censysID, censysSecret, stKey, vtKey
 		return VirusTotalValidator(vtKey)
 	case SourceZoomEye:
 		return ZoomEyeValidator(zeKey)
+	case SourceHunter:
+		return HunterValidator(hunterKey)
 	case SourceCT:
 		return CTValidator()
 	case SourceViewDNS:
@@ -357,7 +420,7 @@ func GetValidator(source Source, shodanKey, censysID, censysSecret, stKey, vtKey
 }
 
 // ValidateAllSources checks all configured sources and returns available ones
-func ValidateAllSources(ctx context.Context, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys []string, censysOrgID string) map[string]bool {
+func ValidateAllSources(ctx context.Context, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, hunterKeys []string, censysOrgID string) map[string]bool {
 	available := make(map[string]bool)
 
 	// Sources that always work (no API key needed)
@@ -402,12 +465,19 @@ func ValidateAllSources(ctx context.Context, shodanKeys, censysTokens, stKeys, v
 		}
 	}
 
+	// Validate Hunter
+	if len(hunterKeys) > 0 && hunterKeys[0] != "" {
+		if err := HunterValidator(hunterKeys[0])(ctx); err == nil {
+			available["hunter"] = true
+		}
+	}
+
 	return available
 }
 
 // GetAvailableSources returns list of available source IDs
-func GetAvailableSources(ctx context.Context, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys []string, censysOrgID string) []string {
-	availableMap := ValidateAllSources(ctx, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, censysOrgID)
+func GetAvailableSources(ctx context.Context, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, hunterKeys []string, censysOrgID string) []string {
+	availableMap := ValidateAllSources(ctx, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, hunterKeys, censysOrgID)
 
 	var sources []string
 	for source := range availableMap {
@@ -419,12 +489,12 @@ func GetAvailableSources(ctx context.Context, shodanKeys, censysTokens, stKeys,
 
 // FilterRequestedSources filters requested sources by availability
 // If requestedSources is empty, returns all available sources (auto mode)
-func FilterRequestedSources(ctx context.Context, requestedSources []string, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys []string, censysOrgID string) []string {
-	availableMap := ValidateAllSources(ctx, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, censysOrgID)
+func FilterRequestedSources(ctx context.Context, requestedSources []string, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, hunterKeys []string, censysOrgID string) []string {
+	availableMap := ValidateAllSources(ctx, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, hunterKeys, censysOrgID)
 
 	// Auto mode: use all available
 	if len(requestedSources) == 0 {
-		return GetAvailableSources(ctx, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, censysOrgID)
+		return GetAvailableSources(ctx, shodanKeys, censysTokens, stKeys, vtKeys, zeKeys, hunterKeys, censysOrgID)
 	}
 
 	// Filter requested by available
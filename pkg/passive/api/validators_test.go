@@ -150,7 +150,7 @@ func TestDNSValidator(t *testing.T) {
 }
 
 func TestGetValidator_Shodan(t *testing.T) {
-	validator := GetValidator(SourceShodan, "test_key", "", "", "", "", "")
+	validator := GetValidator(SourceShodan, "test_key", "", "", "", "", "", "")
 	if validator == nil {
 		t.Fatal("GetValidator returned nil for Shodan")
 	}
@@ -163,7 +163,7 @@ func TestGetValidator_Shodan(t *testing.T) {
 }
 
 func TestGetValidator_Censys(t *testing.T) {
-	validator := GetValidator(SourceCensys, "", "id", "secret", "", "", "")
+	validator := GetValidator(SourceCensys, "", "id", "secret", "", "", "", "")
 	if validator == nil {
 		t.Fatal("GetValidator returned nil for Censys")
 	}
@@ -176,7 +176,7 @@ func TestGetValidator_Censys(t *testing.T) {
 }
 
 func TestGetValidator_CT(t *testing.T) {
-	validator := GetValidator(SourceCT, "", "", "", "", "", "")
+	validator := GetValidator(SourceCT, "", "", "", "", "", "", "")
 	if validator == nil {
 		t.Fatal("GetValidator returned nil for CT")
 	}
@@ -192,7 +192,7 @@ func TestGetValidator_CT(t *testing.T) {
 }
 
 func TestGetValidator_DNS(t *testing.T) {
-	validator := GetValidator(SourceDNS, "", "", "", "", "", "")
+	validator := GetValidator(SourceDNS, "", "", "", "", "", "", "")
 	if validator == nil {
 		t.Fatal("GetValidator returned nil for DNS")
 	}
@@ -205,7 +205,7 @@ func TestGetValidator_DNS(t *testing.T) {
 }
 
 func TestGetValidator_Unknown(t *testing.T) {
-	validator := GetValidator("unknown_source", "", "", "", "", "", "")
+	validator := GetValidator("unknown_source", "", "", "", "", "", "", "")
 	if validator == nil {
 		t.Fatal("GetValidator returned nil for unknown source")
 	}
@@ -219,6 +219,54 @@ func TestGetValidator_Unknown(t *testing.T) {
 	}
 }
 
+func TestHunterValidator_EmptyKey(t *testing.T) {
+	for _, key := range []string{"", "YOUR_HUNTER_API_KEY"} {
+		err := HunterValidator(key)(context.Background())
+		if err == nil || !contains(err.Error(), "not configured") {
+			t.Errorf("HunterValidator(%q) error = %v, want not configured", key, err)
+		}
+	}
+}
+
+func TestValidateHunter_Codes(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"valid", 200, `{"code":200,"data":{"total":0,"list":[]}}`, ""},
+		{"invalid key in JSON", 200, `{"code":401,"message":"api key is invalid"}`, "invalid"},
+		{"quota in JSON", 200, `{"code":429,"message":"daily limit reached"}`, "rate limit"},
+		{"http rate limit", 429, ``, "rate limit"},
+		{"server error", 500, ``, "status 500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/search" || r.URL.Query().Get("api-key") != "test_key" {
+					t.Errorf("unexpected request: %s", r.URL)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			err := validateHunter(context.Background(), server.URL, "test_key")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateHunter() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateHunter() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestShodanValidator_StatusCodes(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -341,11 +389,12 @@ func TestGetValidator_AllSources(t *testing.T) {
 		SourceCensys,
 		SourceCT,
 		SourceDNS,
+		SourceHunter,
 	}
 
 	for _, source := range sources {
 		t.Run(string(source), func(t *testing.T) {
-			validator := GetValidator(source, "key", "id", "secret", "stkey", "vtkey", "zekey")
+			validator := GetValidator(source, "key", "id", "secret", "stkey", "vtkey", "zekey", "hunterkey")
 			if validator == nil {
 				t.Fatalf("GetValidator returned nil for %s", source)
 			}
@@ -0,0 +1,180 @@
+// Package cache stores passive source results on disk so repeated recon
+// on the same domain does not re-query (and re-bill) every source
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+// Entry is the cached result of one source for one domain
+type Entry struct {
+	Domain    string           `json:"domain"`
+	Source    string           `json:"source"`
+	FetchedAt time.Time        `json:"fetched_at"`
+	Records   []core.PassiveIP `json:"records"`
+}
+
+// Age returns how long ago the entry was fetched
+func (e *Entry) Age() time.Duration {
+	return time.Since(e.FetchedAt)
+}
+
+// Cache is a per-domain, per-source response cache
+// Entries are stored as <dir>/<domain>/<source>.json
+type Cache struct {
+	dir string
+	ttl time.Duration
+}
+
+// New creates a cache rooted at dir whose entries expire after ttl
+func New(dir string, ttl time.Duration) *Cache {
+	return &Cache{dir: dir, ttl: ttl}
+}
+
+// Path returns the file used for a domain/source entry
+func (c *Cache) Path(domain, source string) string {
+	return filepath.Join(c.dir, sanitize(domain), sanitize(source)+".json")
+}
+
+// Get returns the cached entry for a domain/source if it exists and has
+// not expired. Unreadable or corrupt entries are treated as misses.
+func (c *Cache) Get(domain, source string) (*Entry, bool) {
+	entry, ok := c.GetStale(domain, source)
+	if !ok || c.ttl <= 0 || entry.Age() > c.ttl {
+		return nil, false
+	}
+	return entry, true
+}
+
+// GetStale returns the cached entry for a domain/source regardless of TTL
+// Used as a fallback when a live query fails (e.g., source outage)
+func (c *Cache) GetStale(domain, source string) (*Entry, bool) {
+	data, err := os.ReadFile(c.Path(domain, source))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.FetchedAt.IsZero() {
+		return nil, false
+	}
+	for _, rec := range entry.Records {
+		normalizeMetadata(rec.Metadata)
+	}
+	return &entry, true
+}
+
+// normalizeMetadata restores the list types sources store in metadata
+// JSON decodes []int (e.g. "ports") and []string as []interface{}, so cached
+// results would otherwise differ from live ones.
+func normalizeMetadata(metadata map[string]interface{}) {
+	for key, value := range metadata {
+		list, ok := value.([]interface{})
+		if !ok || len(list) == 0 {
+			continue
+		}
+		if ints, ok := intList(list); ok {
+			metadata[key] = ints
+		} else if strs, ok := stringList(list); ok {
+			metadata[key] = strs
+		}
+	}
+}
+
+// intList converts a decoded JSON array of whole numbers to []int
+func intList(list []interface{}) ([]int, bool) {
+	ints := make([]int, len(list))
+	for i, v := range list {
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) {
+			return nil, false
+		}
+		ints[i] = int(f)
+	}
+	return ints, true
+}
+
+// stringList converts a decoded JSON array of strings to []string
+func stringList(list []interface{}) ([]string, bool) {
+	strs := make([]string, len(list))
+	for i, v := range list {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		strs[i] = s
+	}
+	return strs, true
+}
+
+// Put stores the records of a successful source query
+func (c *Cache) Put(domain, source string, records []core.PassiveIP) error {
+	entry := Entry{
+		Domain:    strings.ToLower(domain),
+		Source:    source,
+		FetchedAt: time.Now(),
+		Records:   records,
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	path := c.Path(domain, source)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it
+// into place, so readers never see a partial entry. Each write has its own
+// temp file, as concurrent runs may store the same entry.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// sanitize makes a domain or source name safe to use as a path element
+func sanitize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.Trim(name, ".")
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_", "..", "_")
+	if name = replacer.Replace(name); name == "" {
+		return "_"
+	}
+	return name
+}
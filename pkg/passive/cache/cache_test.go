@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+func TestCache_PutGet(t *testing.T) {
+	c := New(t.TempDir(), time.Hour)
+
+	seen := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	records := []core.PassiveIP{
+		{IP: "192.0.2.1", Source: "shodan", FirstSeen: seen, LastSeen: seen},
+		{IP: "192.0.2.2", Source: "shodan", Metadata: map[string]interface{}{"hostname": "www.example.com"}},
+	}
+
+	if err := c.Put("Example.com", "shodan", records); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	entry, ok := c.Get("example.com", "shodan")
+	if !ok {
+		t.Fatal("Get() miss after Put()")
+	}
+	if len(entry.Records) != 2 || entry.Records[0].IP != "192.0.2.1" {
+		t.Errorf("Unexpected records: %+v", entry.Records)
+	}
+	if !entry.Records[0].LastSeen.Equal(seen) {
+		t.Errorf("LastSeen = %v, want %v", entry.Records[0].LastSeen, seen)
+	}
+	if entry.Records[1].Metadata["hostname"] != "www.example.com" {
+		t.Errorf("Metadata not preserved: %v", entry.Records[1].Metadata)
+	}
+	if entry.Age() > time.Minute {
+		t.Errorf("Age() = %v, want fresh entry", entry.Age())
+	}
+
+	if _, ok := c.Get("example.com", "censys"); ok {
+		t.Error("Get() hit for a source that was never stored")
+	}
+}
+
+func TestCache_ConcurrentPut(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, time.Hour)
+
+	// Concurrent runs storing the same entry must not share a temp file
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- c.Put("example.com", "shodan", []core.PassiveIP{{IP: fmt.Sprintf("192.0.2.%d", i), Source: "shodan"}})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Put() error = %v", err)
+		}
+	}
+
+	if entry, ok := c.Get("example.com", "shodan"); !ok || len(entry.Records) != 1 {
+		t.Fatalf("Get() = %+v, %v after concurrent Put()", entry, ok)
+	}
+	files, _ := os.ReadDir(filepath.Dir(c.Path("example.com", "shodan")))
+	if len(files) != 1 {
+		t.Errorf("cache directory has %d files, want only the entry", len(files))
+	}
+}
+
+func TestCache_MetadataTypes(t *testing.T) {
+	c := New(t.TempDir(), time.Hour)
+
+	metadata := map[string]interface{}{
+		"ports":          []int{80, 443},
+		"hostnames":      []string{"api.example.com", "www.example.com"},
+		"shodan_queries": []string{"ssl"},
+		"asn":            "AS64500",
+		"mixed":          []interface{}{"a", 1.5},
+	}
+	if err := c.Put("example.com", "shodan", []core.PassiveIP{{IP: "192.0.2.1", Metadata: metadata}}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	entry, ok := c.Get("example.com", "shodan")
+	if !ok {
+		t.Fatal("Get() miss after Put()")
+	}
+	if got := entry.Records[0].Metadata; !reflect.DeepEqual(got, metadata) {
+		t.Errorf("Metadata = %#v, want %#v", got, metadata)
+	}
+}
+
+func TestCache_Expired(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, time.Hour)
+
+	entry := Entry{Domain: "example.com", Source: "ct", FetchedAt: time.Now().Add(-2 * time.Hour)}
+	data, _ := json.Marshal(entry)
+	path := c.Path("example.com", "ct")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, data, 0644)
+
+	if _, ok := c.Get("example.com", "ct"); ok {
+		t.Error("Get() should miss for an expired entry")
+	}
+
+	// A longer TTL accepts the same entry
+	if _, ok := New(dir, 3*time.Hour).Get("example.com", "ct"); !ok {
+		t.Error("Get() should hit within TTL")
+	}
+
+	// A zero TTL disables reads
+	if _, ok := New(dir, 0).Get("example.com", "ct"); ok {
+		t.Error("Get() should miss with zero TTL")
+	}
+
+	// Expired entries remain available as a fallback
+	if _, ok := c.GetStale("example.com", "ct"); !ok {
+		t.Error("GetStale() should return expired entries")
+	}
+}
+
+func TestCache_Corrupt(t *testing.T) {
+	c := New(t.TempDir(), time.Hour)
+	path := c.Path("example.com", "ct")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte("{not json"), 0644)
+
+	if _, ok := c.Get("example.com", "ct"); ok {
+		t.Error("Get() should miss for a corrupt entry")
+	}
+
+	// Put overwrites the corrupt entry
+	if err := c.Put("example.com", "ct", nil); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, ok := c.Get("example.com", "ct"); !ok {
+		t.Error("Get() should hit after overwrite")
+	}
+}
+
+func TestCache_Path(t *testing.T) {
+	c := New("/cache", time.Hour)
+
+	path := c.Path("Example.COM.", "Shodan")
+	if path != filepath.Join("/cache", "example.com", "shodan.json") {
+		t.Errorf("Path() = %s", path)
+	}
+
+	// Path traversal in names stays inside the cache directory
+	path = c.Path("../../etc", "passwd")
+	if !strings.HasPrefix(path, filepath.Clean("/cache")+string(filepath.Separator)) || strings.Contains(path, "..") {
+		t.Errorf("Path() escaped cache dir: %s", path)
+	}
+}
@@ -4,13 +4,43 @@ package censys
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/hostsearch"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+// DefaultBaseURL is the Censys Platform API base URL
+const DefaultBaseURL = "https://api.platform.censys.io"
+
+// Search modes (censys_mode / --censys-mode)
+const (
+	ModeNames = "names" // Hosts whose certificates name the domain (default)
+	ModeCert  = "cert"  // Hosts presenting the domain's certificates, by SHA-256
+	ModeAll   = "all"   // Both, merged
+)
+
+// Modes lists the valid search modes
+var Modes = []string{ModeNames, ModeCert, ModeAll}
+
+const (
+	// DefaultMaxPages is how many result pages are fetched per query
+	DefaultMaxPages = 5
+
+	// pageSize is the number of hits requested per page (API maximum)
+	pageSize = 100
+
+	// maxCertPivots caps the fingerprints combined into one host query
+	maxCertPivots = 25
 )
 
 // CensysV3Request represents the request body for Censys v3 Global Search API
@@ -31,28 +61,62 @@ type CensysResponse struct {
 
 // CensysResult contains the search results
 type CensysResult struct {
-	Query string      `json:"query"`
-	Total int         `json:"total"`
-	Hits  []CensysHit `json:"hits"`
-	Links CensysLinks `json:"links"`
+	Query         string      `json:"query"`
+	Total         int         `json:"total"`
+	TotalHits     int         `json:"total_hits"`
+	Hits          []CensysHit `json:"hits"`
+	Links         CensysLinks `json:"links"`
+	NextPageToken string      `json:"next_page_token"` // v3 cursor ("" on the last page)
 }
 
-// CensysHit represents a single host result
+// CensysHit represents a single search hit
+// v3 wraps documents in host_v1 / certificate_v1 resources; flat host hits
+// (mirrors and older responses) are read as-is
 type CensysHit struct {
-	IP       string                 `json:"ip"`
-	Services []CensysService        `json:"services"`
-	Location CensysLocation         `json:"location"`
-	Names    []string               `json:"names"`
-	Metadata map[string]interface{} `json:"metadata"`
+	IP               string                 `json:"ip"`
+	Services         []CensysService        `json:"services"`
+	Location         CensysLocation         `json:"location"`
+	AutonomousSystem CensysAS               `json:"autonomous_system"`
+	Names            []string               `json:"names"`
+	Metadata         map[string]interface{} `json:"metadata"`
+
+	HostV1        *CensysHostResource `json:"host_v1,omitempty"`
+	CertificateV1 *CensysCertResource `json:"certificate_v1,omitempty"`
+}
+
+// CensysHostResource wraps a v3 host document
+type CensysHostResource struct {
+	Resource CensysHit `json:"resource"`
+}
+
+// CensysCertResource wraps a v3 certificate document
+type CensysCertResource struct {
+	Resource CensysCertificate `json:"resource"`
+}
+
+// CensysCertificate contains certificate data
+type CensysCertificate struct {
+	FingerprintSHA256 string   `json:"fingerprint_sha256"`
+	Names             []string `json:"names"`
+}
+
+// Host returns the host document of the hit
+func (h CensysHit) Host() CensysHit {
+	if h.HostV1 != nil {
+		return h.HostV1.Resource
+	}
+	return h
 }
 
 // CensysService represents a service on the host
 type CensysService struct {
 	Port            int                    `json:"port"`
 	ServiceName     string                 `json:"service_name"`
+	Protocol        string                 `json:"protocol"` // v3 name of ServiceName
 	TransportProto  string                 `json:"transport_protocol"`
 	ExtendedService map[string]interface{} `json:"extended_service_name"`
 	HTTP            CensysHTTP             `json:"http,omitempty"`
+	Cert            *CensysCertificate     `json:"cert,omitempty"`
 }
 
 // CensysHTTP contains HTTP-specific service data
@@ -76,80 +140,188 @@ type CensysHTTPResponse struct {
 // CensysLocation contains geolocation data
 type CensysLocation struct {
 	Country     string     `json:"country"`
+	CountryCode string     `json:"country_code"`
 	City        string     `json:"city"`
 	Coordinates [2]float64 `json:"coordinates"`
 }
 
+// CensysAS contains the host's autonomous system
+type CensysAS struct {
+	ASN       int    `json:"asn"`
+	Name      string `json:"name"`
+	BGPPrefix string `json:"bgp_prefix"`
+}
+
 // CensysLinks contains pagination links
 type CensysLinks struct {
 	Next string `json:"next"`
 	Prev string `json:"prev"`
 }
 
-// SearchHosts queries Censys for hosts matching the domain
+// SearchHosts queries Censys for hosts whose certificates name the domain
 func SearchHosts(ctx context.Context, domain string, tokens []string, orgID string, timeout time.Duration) ([]string, error) {
 	if len(tokens) == 0 {
 		return []string{}, fmt.Errorf("no Censys PAT tokens provided")
 	}
 
-	// Try each token until one works (rotation for rate limits)
-	var lastErr error
-	for i, token := range tokens {
-		token = strings.TrimSpace(token)
-		if token == "" {
-			continue
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceCensys, tokens)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	s := &searcher{manager: manager, client: httpclient.Default(), baseURL: DefaultBaseURL, orgID: orgID}
+	records, err := s.search(ctx, domain, ModeNames, nil)
+	if err != nil {
+		return []string{}, err
+	}
+
+	ips := make([]string, 0, len(records))
+	for _, rec := range records {
+		ips = append(ips, rec.IP)
+	}
+	return ips, nil
+}
+
+// searcher runs Censys queries through the manager's token rotation
+type searcher struct {
+	manager *api.Manager
+	client  *httpclient.Client
+	baseURL string
+	orgID   string
+}
+
+// search collects hosts for the domain in the given mode
+// live holds certificate fingerprints already known (e.g., from the CDN);
+// in cert mode they are combined with the domain's certificates on Censys.
+// In all mode a failed certificate pivot keeps the name search results.
+func (s *searcher) search(ctx context.Context, domain, mode string, live []string) ([]core.PassiveIP, error) {
+	if !s.manager.HasKeys(api.SourceCensys) {
+		return []core.PassiveIP{}, fmt.Errorf("no valid PAT tokens found")
+	}
+
+	hosts := hostsearch.NewHostSet(SourceName, "censys_queries")
+
+	if mode == ModeNames || mode == ModeAll {
+		if err := s.hosts(ctx, ModeNames, namesQuery(domain), nil, hosts); err != nil {
+			return []core.PassiveIP{}, err
 		}
+	}
 
-		ips, err := searchWithToken(ctx, domain, token, orgID, timeout)
-		if err == nil {
-			return ips, nil
+	if mode == ModeCert || mode == ModeAll {
+		if err := s.certHosts(ctx, domain, live, hosts); err != nil && (mode == ModeCert || hosts.Len() == 0) {
+			return []core.PassiveIP{}, err
 		}
+	}
 
-		// Check for rate limit errors
-		if strings.Contains(err.Error(), "rate limit") || strings.Contains(err.Error(), "429") {
-			lastErr = fmt.Errorf("token %d/%d rate limited: %w", i+1, len(tokens), err)
-			continue // Try next token
+	return hosts.Records(time.Now()), nil
+}
+
+// certHosts adds the hosts presenting the domain's certificates
+func (s *searcher) certHosts(ctx context.Context, domain string, live []string, hosts *hostsearch.HostSet) error {
+	fingerprints, err := s.certFingerprints(ctx, domain, live)
+	if err != nil {
+		return err
+	}
+	if len(fingerprints) == 0 {
+		return fmt.Errorf("no certificates found for %s", domain)
+	}
+	return s.hosts(ctx, ModeCert, certQuery(fingerprints), fingerprints, hosts)
+}
+
+// namesQuery matches hosts whose certificates list the domain
+func namesQuery(domain string) string {
+	return fmt.Sprintf(`host.services.cert.names: "%s"`, domain)
+}
+
+// certQuery matches hosts presenting any of the certificates
+func certQuery(fingerprints []string) string {
+	terms := make([]string, len(fingerprints))
+	for i, fp := range fingerprints {
+		terms[i] = fmt.Sprintf(`host.services.cert.fingerprint_sha256: "%s"`, fp)
+	}
+	return strings.Join(terms, " or ")
+}
+
+// certFingerprints returns the SHA-256 fingerprints to pivot on: the live
+// ones first, then certificates Censys has indexed for the domain (from CT
+// logs and scans), capped at maxCertPivots
+func (s *searcher) certFingerprints(ctx context.Context, domain string, live []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var fingerprints []string
+	add := func(fp string) {
+		fp = strings.ToLower(strings.TrimSpace(fp))
+		if fp != "" && !seen[fp] && len(fingerprints) < maxCertPivots {
+			seen[fp] = true
+			fingerprints = append(fingerprints, fp)
 		}
+	}
 
-		// For other errors, return immediately (invalid token, network issue, etc.)
-		return []string{}, fmt.Errorf("token %d/%d failed: %w", i+1, len(tokens), err)
+	for _, fp := range live {
+		add(fp)
 	}
 
-	if lastErr != nil {
-		return []string{}, fmt.Errorf("all %d PAT tokens exhausted: %w", len(tokens), lastErr)
+	resp, err := s.query(ctx, CensysV3Request{
+		Query:    fmt.Sprintf(`cert.names: "%s"`, domain),
+		PageSize: maxCertPivots,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("certificate search failed: %w", err)
+	}
+	for _, hit := range resp.Result.Hits {
+		if hit.CertificateV1 != nil {
+			add(hit.CertificateV1.Resource.FingerprintSHA256)
+		}
 	}
 
-	return []string{}, fmt.Errorf("no valid PAT tokens found")
+	return fingerprints, nil
 }
 
-// searchWithToken performs the search with a single PAT token
-func searchWithToken(ctx context.Context, domain, token, orgID string, timeout time.Duration) ([]string, error) {
-	// Build CenQL query for v3 Global Search API
-	// Search for domain in certificate names: host.services.cert.names: "example.com"
-	query := fmt.Sprintf(`host.services.cert.names: "%s"`, domain)
-
-	// Create POST request body
-	reqBody := CensysV3Request{
-		Query:    query,
-		PageSize: 100, // Max 100 results per page
+// hosts pages through a host query, following the v3 cursor for up to
+// DefaultMaxPages pages, and adds every IPv4 hit to set under the given label
+// For certificate queries, fingerprints are the ones searched for
+func (s *searcher) hosts(ctx context.Context, label, query string, fingerprints []string, set *hostsearch.HostSet) error {
+	req := CensysV3Request{Query: query, PageSize: pageSize}
+	fetch := func(ctx context.Context, q hostsearch.Query, page int) (bool, error) {
+		resp, err := s.query(ctx, req)
+		if err != nil {
+			return false, err
+		}
+		addHits(set, q.Name, resp.Result.Hits, fingerprints)
+		req.PageToken = resp.Result.NextPageToken
+		return req.PageToken != "" && len(resp.Result.Hits) > 0, nil
 	}
 
+	// A failure after the first page keeps the pages already read
+	queries := []hostsearch.Query{{Name: label, Query: query}}
+	return hostsearch.Run(ctx, queries, hostsearch.Limits{MaxPages: DefaultMaxPages}, fetch, nil)
+}
+
+// query sends one search request, rotating tokens on rate limits
+func (s *searcher) query(ctx context.Context, body CensysV3Request) (*CensysResponse, error) {
+	var resp *CensysResponse
+	err := s.manager.Do(ctx, api.SourceCensys, func(token string) error {
+		var err error
+		resp, err = queryWithToken(ctx, s.client, s.baseURL, token, s.orgID, body)
+		return err
+	})
+	return resp, err
+}
+
+// queryWithToken sends one search request with a single PAT token
+func queryWithToken(ctx context.Context, client *httpclient.Client, baseURL, token, orgID string, reqBody CensysV3Request) (*CensysResponse, error) {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// v3 Global Search API endpoint (POST)
-	url := "https://api.platform.censys.io/v3/global/search/query"
+	url := baseURL + "/v3/global/search/query"
 	// Add organization_id query parameter if provided (for paid plans)
 	if orgID != "" {
 		url += fmt.Sprintf("?organization_id=%s", orgID)
 	}
 
-	client := &http.Client{
-		Timeout: timeout,
-	}
-
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -159,20 +331,18 @@ func searchWithToken(ctx context.Context, domain, token, orgID string, timeout t
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "origindive/1.0")
 
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("Censys request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	// Check for HTTP errors
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, api.NewRateLimitError(resp.Response, "")
+	}
 	if resp.StatusCode != http.StatusOK {
 		// Try to parse error from JSON with nested error structure (401 errors)
 		var errorResponse struct {
@@ -222,31 +392,62 @@ func searchWithToken(ctx context.Context, domain, token, orgID string, timeout t
 		return nil, fmt.Errorf("Censys API error: %s", censysResp.Error)
 	}
 
-	// Extract unique IPv4 addresses
-	ipSet := make(map[string]bool)
-	for _, hit := range censysResp.Result.Hits {
-		ip := strings.TrimSpace(hit.IP)
-		if ip == "" {
-			continue
-		}
+	return &censysResp, nil
+}
 
-		// Validate and filter IPv4 only
-		parsedIP := net.ParseIP(ip)
-		if parsedIP == nil {
-			continue
-		}
+// LiveFingerprint returns the SHA-256 fingerprint of the certificate the
+// site presents (usually the CDN edge), fetched through the shared client
+func LiveFingerprint(ctx context.Context, client *httpclient.Client, siteURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", siteURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(ctx, SourceName, req)
+	if err != nil {
+		return "", err
+	}
+	return leafFingerprint(resp.TLS)
+}
 
-		// Check if IPv4 (To4() returns nil for IPv6)
-		if parsedIP.To4() != nil {
-			ipSet[ip] = true
-		}
+// leafFingerprint returns the hex SHA-256 of the peer's leaf certificate
+func leafFingerprint(state *tls.ConnectionState) (string, error) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return "", fmt.Errorf("no TLS certificate presented")
 	}
+	sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
 
-	// Convert to slice
-	ips := make([]string, 0, len(ipSet))
-	for ip := range ipSet {
-		ips = append(ips, ip)
+// addHits records the IPv4 host hits found by the labeled query
+// Services presenting one of fingerprints are noted on the host
+func addHits(hosts *hostsearch.HostSet, label string, hits []CensysHit, fingerprints []string) {
+	wanted := make(map[string]bool, len(fingerprints))
+	for _, fp := range fingerprints {
+		wanted[fp] = true
 	}
 
-	return ips, nil
+	for _, hit := range hits {
+		doc := hit.Host()
+		h := hosts.Add(doc.IP, label)
+		if h == nil {
+			continue
+		}
+
+		for _, svc := range doc.Services {
+			h.AddPort(svc.Port)
+			if svc.Cert != nil {
+				if fp := strings.ToLower(svc.Cert.FingerprintSHA256); wanted[fp] {
+					h.AddValue("cert_sha256", fp)
+				}
+			}
+		}
+		if doc.AutonomousSystem.ASN != 0 {
+			h.SetField("asn", fmt.Sprintf("AS%d", doc.AutonomousSystem.ASN))
+		}
+		h.SetField("organization", doc.AutonomousSystem.Name)
+		h.SetField("bgp_prefix", doc.AutonomousSystem.BGPPrefix)
+		h.SetField("country_code", doc.Location.CountryCode)
+		h.SetField("country", doc.Location.Country)
+		h.SetField("city", doc.Location.City)
+	}
 }
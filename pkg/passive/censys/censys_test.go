@@ -2,13 +2,67 @@ package censys
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
 )
 
+// newSearcher returns a searcher with one token against a mock API
+func newSearcher(baseURL string) *searcher {
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceCensys, []string{"test_token"})
+	return &searcher{manager: manager, client: httpclient.Default(), baseURL: baseURL}
+}
+
+// searchServer runs a search for example.com against a mock API
+func searchServer(t *testing.T, baseURL, mode string) []core.PassiveIP {
+	t.Helper()
+	records, err := newSearcher(baseURL).search(context.Background(), "example.com", mode, nil)
+	if err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+	return records
+}
+
+func assertIPs(t *testing.T, records []core.PassiveIP, want []string) {
+	t.Helper()
+	got := make([]string, 0, len(records))
+	for _, rec := range records {
+		got = append(got, rec.IP)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IPs = %v, want %v", got, want)
+	}
+}
+
+// decodeRequest reads a search request body
+func decodeRequest(t *testing.T, r *http.Request) CensysV3Request {
+	t.Helper()
+	var req CensysV3Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		t.Errorf("invalid request body: %v", err)
+	}
+	return req
+}
+
+// hostHit wraps a host document the way the v3 API does
+func hostHit(h CensysHit) CensysHit {
+	return CensysHit{HostV1: &CensysHostResource{Resource: h}}
+}
+
 func TestSearchHosts_NoTokens(t *testing.T) {
 	ctx := context.Background()
 	_, err := SearchHosts(ctx, "example.com", []string{}, "", 5*time.Second)
@@ -97,7 +151,7 @@ func TestSearchHosts_RateLimitRotation(t *testing.T) {
 	}
 }
 
-func TestSearchWithToken_HTTPError(t *testing.T) {
+func TestQueryWithToken_HTTPError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(CensysResponse{
@@ -109,13 +163,13 @@ func TestSearchWithToken_HTTPError(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithToken(ctx, "example.com", "invalid_token", "", 1*time.Second)
+	_, err := queryWithToken(ctx, httpclient.Default(), server.URL, "invalid_token", "", CensysV3Request{Query: namesQuery("example.com")})
 	if err == nil {
 		t.Log("Expected error for invalid token")
 	}
 }
 
-func TestSearchWithToken_InvalidJSON(t *testing.T) {
+func TestQueryWithToken_InvalidJSON(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte("{invalid json"))
@@ -123,7 +177,7 @@ func TestSearchWithToken_InvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithToken(ctx, "example.com", "test_token", "", 1*time.Second)
+	_, err := queryWithToken(ctx, httpclient.Default(), server.URL, "test_token", "", CensysV3Request{Query: namesQuery("example.com")})
 	if err == nil {
 		t.Log("Expected JSON parsing error")
 	}
@@ -196,7 +250,7 @@ func TestCensysService(t *testing.T) {
 	}
 }
 
-func TestSearchWithToken_IPv6Filtering(t *testing.T) {
+func TestSearch_IPv6Filtering(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := CensysResponse{
 			Code:   200,
@@ -213,14 +267,10 @@ func TestSearchWithToken_IPv6Filtering(t *testing.T) {
 	}))
 	defer server.Close()
 
-	ctx := context.Background()
-	_, err := searchWithToken(ctx, "example.com", "test_token", "", 1*time.Second)
-	if err == nil {
-		t.Log("IPv6 filtering test completed")
-	}
+	assertIPs(t, searchServer(t, server.URL, ModeNames), []string{"192.168.1.1"})
 }
 
-func TestSearchWithToken_EmptyIP(t *testing.T) {
+func TestSearch_EmptyIP(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := CensysResponse{
 			Code:   200,
@@ -237,11 +287,7 @@ func TestSearchWithToken_EmptyIP(t *testing.T) {
 	}))
 	defer server.Close()
 
-	ctx := context.Background()
-	_, err := searchWithToken(ctx, "example.com", "test_token", "", 1*time.Second)
-	if err == nil {
-		t.Log("Empty IP filtering test completed")
-	}
+	assertIPs(t, searchServer(t, server.URL, ModeNames), []string{"192.168.1.1"})
 }
 
 func TestSearchHosts_ContextCancellation(t *testing.T) {
@@ -279,7 +325,7 @@ func TestSearchHosts_WhitespaceTokens(t *testing.T) {
 	}
 }
 
-func TestSearchWithToken_LongErrorBody(t *testing.T) {
+func TestQueryWithToken_LongErrorBody(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		longMsg := make([]byte, 300)
@@ -291,7 +337,7 @@ func TestSearchWithToken_LongErrorBody(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithToken(ctx, "example.com", "test_token", "", 1*time.Second)
+	_, err := queryWithToken(ctx, httpclient.Default(), server.URL, "test_token", "", CensysV3Request{Query: namesQuery("example.com")})
 	if err == nil {
 		t.Log("Expected error for bad request")
 	}
@@ -311,3 +357,193 @@ func TestCensysLocation(t *testing.T) {
 		t.Errorf("Latitude = %f", loc.Coordinates[0])
 	}
 }
+
+func TestSearch_FollowsCursor(t *testing.T) {
+	var mu sync.Mutex
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := decodeRequest(t, r)
+		mu.Lock()
+		tokens = append(tokens, req.PageToken)
+		mu.Unlock()
+
+		resp := CensysResponse{}
+		switch req.PageToken {
+		case "":
+			resp.Result = CensysResult{Hits: []CensysHit{hostHit(CensysHit{IP: "198.51.100.1"})}, NextPageToken: "p2"}
+		case "p2":
+			resp.Result = CensysResult{Hits: []CensysHit{hostHit(CensysHit{IP: "198.51.100.2"})}, NextPageToken: "p3"}
+		case "p3":
+			resp.Result = CensysResult{Hits: []CensysHit{hostHit(CensysHit{IP: "198.51.100.3"})}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	assertIPs(t, searchServer(t, server.URL, ModeNames), []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"})
+	if want := []string{"", "p2", "p3"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("page tokens = %q, want %q", tokens, want)
+	}
+}
+
+func TestSearch_StopsAtMaxPages(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(CensysResponse{Result: CensysResult{
+			Hits:          []CensysHit{hostHit(CensysHit{IP: "198.51.100.1"})},
+			NextPageToken: "more",
+		}})
+	}))
+	defer server.Close()
+
+	searchServer(t, server.URL, ModeNames)
+	if calls != DefaultMaxPages {
+		t.Errorf("calls = %d, want %d", calls, DefaultMaxPages)
+	}
+}
+
+func TestSearch_CertMode(t *testing.T) {
+	const ctFP = "aa11"
+	const liveFP = "bb22"
+	var hostQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := decodeRequest(t, r)
+		switch {
+		case req.Query == `cert.names: "example.com"`:
+			json.NewEncoder(w).Encode(CensysResponse{Result: CensysResult{Hits: []CensysHit{
+				{CertificateV1: &CensysCertResource{Resource: CensysCertificate{FingerprintSHA256: strings.ToUpper(ctFP)}}},
+				{CertificateV1: &CensysCertResource{Resource: CensysCertificate{FingerprintSHA256: liveFP}}}, // Duplicate of the live one
+			}}})
+		case strings.HasPrefix(req.Query, "host.services.cert.fingerprint_sha256"):
+			hostQuery = req.Query
+			json.NewEncoder(w).Encode(CensysResponse{Result: CensysResult{Hits: []CensysHit{hostHit(CensysHit{
+				IP: "203.0.113.9",
+				Services: []CensysService{
+					{Port: 443, Cert: &CensysCertificate{FingerprintSHA256: ctFP}},
+					{Port: 8443, Cert: &CensysCertificate{FingerprintSHA256: "unrelated"}},
+				},
+				AutonomousSystem: CensysAS{ASN: 64500, Name: "EXAMPLE-HOSTING", BGPPrefix: "203.0.113.0/24"},
+				Location:         CensysLocation{Country: "Netherlands", CountryCode: "NL", City: "Amsterdam"},
+			})}}})
+		default:
+			t.Errorf("unexpected query in cert mode: %s", req.Query)
+			json.NewEncoder(w).Encode(CensysResponse{})
+		}
+	}))
+	defer server.Close()
+
+	records, err := newSearcher(server.URL).search(context.Background(), "example.com", ModeCert, []string{liveFP})
+	if err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+
+	want := `host.services.cert.fingerprint_sha256: "bb22" or host.services.cert.fingerprint_sha256: "aa11"`
+	if hostQuery != want {
+		t.Errorf("host query = %s, want %s", hostQuery, want)
+	}
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+
+	wantMeta := map[string]interface{}{
+		"censys_queries": []string{ModeCert},
+		"ports":          []int{443, 8443},
+		"cert_sha256":    []string{ctFP},
+		"asn":            "AS64500",
+		"organization":   "EXAMPLE-HOSTING",
+		"bgp_prefix":     "203.0.113.0/24",
+		"country_code":   "NL",
+		"country":        "Netherlands",
+		"city":           "Amsterdam",
+	}
+	if !reflect.DeepEqual(records[0].Metadata, wantMeta) {
+		t.Errorf("Metadata = %v, want %v", records[0].Metadata, wantMeta)
+	}
+}
+
+func TestSearch_CertModeNoCertificates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(CensysResponse{})
+	}))
+	defer server.Close()
+
+	if _, err := newSearcher(server.URL).search(context.Background(), "example.com", ModeCert, nil); err == nil {
+		t.Error("search() in cert mode without certificates should fail")
+	}
+}
+
+func TestSearch_AllModeKeepsNamesOnCertFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := decodeRequest(t, r)
+		if req.Query == namesQuery("example.com") {
+			json.NewEncoder(w).Encode(CensysResponse{Result: CensysResult{Hits: []CensysHit{{IP: "192.0.2.5"}}}})
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"title":"Forbidden","status":403,"detail":"certificate search requires a paid plan"}`))
+	}))
+	defer server.Close()
+
+	records := searchServer(t, server.URL, ModeAll)
+	assertIPs(t, records, []string{"192.0.2.5"})
+	if q := records[0].Metadata["censys_queries"]; !reflect.DeepEqual(q, []string{ModeNames}) {
+		t.Errorf("censys_queries = %v, want [names]", q)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", ModeNames, false},
+		{"cert", ModeCert, false},
+		{" ALL ", ModeAll, false},
+		{"fingerprint", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMode(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseMode(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLeafFingerprint(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cert := server.Certificate()
+	sum := sha256.Sum256(cert.Raw)
+	got, err := leafFingerprint(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}})
+	if err != nil || got != hex.EncodeToString(sum[:]) {
+		t.Errorf("leafFingerprint() = %q, %v; want %x", got, err, sum)
+	}
+
+	if _, err := leafFingerprint(nil); err == nil {
+		t.Error("leafFingerprint(nil) should fail for plain HTTP")
+	}
+}
+
+func TestSource_CacheVariant(t *testing.T) {
+	s := NewSource([]string{"token"}, "", "", time.Second)
+	tests := []struct {
+		mode      string
+		siteFetch bool
+		want      string
+	}{
+		{ModeNames, false, ""},
+		{ModeNames, true, ""}, // Names mode never fetches the site
+		{ModeCert, false, "cert"},
+		{ModeAll, true, "all+site"},
+	}
+	for _, tt := range tests {
+		s.SetMode(tt.mode)
+		s.SetSiteFetch(tt.siteFetch)
+		if got := s.CacheVariant(); got != tt.want {
+			t.Errorf("CacheVariant(%s, site=%v) = %q, want %q", tt.mode, tt.siteFetch, got, tt.want)
+		}
+	}
+}
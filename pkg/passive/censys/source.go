@@ -0,0 +1,129 @@
+package censys
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+// SourceName is the registry name of the Censys source
+const SourceName = "censys"
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "Censys Platform host search by certificate names or fingerprints (v3 API)",
+		Credentials: []string{"censys_tokens", "censys_org_id"},
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			if len(opts.Config.CensysTokens) == 0 {
+				return nil, passive.MissingCredentials(SourceName, "censys_tokens")
+			}
+			mode, err := ParseMode(opts.Config.CensysMode)
+			if err != nil {
+				return nil, err
+			}
+			return &Source{
+				manager:   opts.KeyManager(api.SourceCensys, opts.Config.CensysTokens),
+				orgID:     opts.Config.CensysOrgID,
+				mode:      mode,
+				client:    opts.HTTPClient(),
+				baseURL:   opts.BaseURL(SourceName, DefaultBaseURL),
+				siteFetch: opts.Config.PassiveSiteFetch,
+				timeout:   opts.Timeout,
+			}, nil
+		},
+	})
+}
+
+// Source implements passive.Source for Censys
+type Source struct {
+	manager   *api.Manager
+	orgID     string
+	mode      string // ModeNames, ModeCert or ModeAll
+	client    *httpclient.Client
+	baseURL   string
+	siteFetch bool // Fingerprint the certificate the target site presents
+	timeout   time.Duration
+}
+
+// NewSource creates a Censys source using PAT tokens and an optional organization ID
+// An empty baseURL uses DefaultBaseURL
+func NewSource(tokens []string, orgID, baseURL string, timeout time.Duration) *Source {
+	return &Source{
+		manager: passive.Options{}.KeyManager(api.SourceCensys, tokens),
+		orgID:   orgID,
+		mode:    ModeNames,
+		client:  httpclient.Default(),
+		baseURL: passive.Endpoint(baseURL, DefaultBaseURL),
+		timeout: timeout,
+	}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns the config keys the source needs
+func (s *Source) RequiredCredentials() []string {
+	return []string{"censys_tokens", "censys_org_id"}
+}
+
+// SetMode selects the search mode (ModeNames, ModeCert or ModeAll)
+func (s *Source) SetMode(mode string) { s.mode = mode }
+
+// SetSiteFetch lets certificate modes connect to https://<domain>
+func (s *Source) SetSiteFetch(enabled bool) { s.siteFetch = enabled }
+
+// CacheVariant describes the mode and, for certificate modes, whether the
+// site's certificate is fingerprinted ("" for the default names mode)
+func (s *Source) CacheVariant() string {
+	if s.mode == ModeNames {
+		return ""
+	}
+	if s.siteFetch {
+		return s.mode + "+site"
+	}
+	return s.mode
+}
+
+// Search queries Censys for IPs related to the domain
+// With site fetching on, certificate modes first fingerprint the certificate
+// https://<domain> presents, so hosts serving the CDN's certificate are
+// found as well
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var live []string
+	if s.mode != ModeNames && s.siteFetch {
+		if fp, err := LiveFingerprint(ctx, s.client, "https://"+domain); err == nil {
+			live = append(live, fp)
+		}
+	}
+
+	searcher := &searcher{manager: s.manager, client: s.client, baseURL: s.baseURL, orgID: s.orgID}
+	records, err := searcher.search(ctx, domain, s.mode, live)
+	if err != nil {
+		return nil, fmt.Errorf("Censys search failed: %w", err)
+	}
+	return records, nil
+}
+
+// ParseMode validates a censys_mode value ("" selects ModeNames)
+func ParseMode(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		return ModeNames, nil
+	}
+	for _, m := range Modes {
+		if mode == m {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid censys_mode %q (valid: %s)", mode, strings.Join(Modes, ", "))
+}
@@ -5,13 +5,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
+// DefaultBaseURL is the crt.sh base URL
+// Override it with passive_endpoints.ct to use an internal crt.sh instance
+const DefaultBaseURL = "https://crt.sh"
+
 // CTEntry represents a certificate transparency log entry
 type CTEntry struct {
 	IssuerCAID     int    `json:"issuer_ca_id"`
@@ -26,15 +32,16 @@ type CTEntry struct {
 
 // SearchCrtSh queries crt.sh for certificates matching the domain
 func SearchCrtSh(ctx context.Context, domain string, timeout time.Duration) ([]string, error) {
+	return search(ctx, httpclient.Default(), resolver.Default(), DefaultBaseURL, domain, timeout)
+}
+
+// search queries the crt.sh instance at baseURL
+func search(ctx context.Context, client *httpclient.Client, dns *resolver.Resolver, baseURL, domain string, timeout time.Duration) ([]string, error) {
 	// Use JSON API endpoint (not HTML)
-	url := fmt.Sprintf("https://crt.sh/json?q=%s", domain)
+	url := fmt.Sprintf("%s/json?q=%s", baseURL, domain)
 
-	ips, err := searchCrtShURL(ctx, url, domain, timeout)
+	ips, err := searchCrtShURL(ctx, client, dns, url, domain, timeout)
 	if err != nil {
-		// Check if crt.sh is down (502/503/504)
-		if strings.Contains(err.Error(), "502") || strings.Contains(err.Error(), "503") || strings.Contains(err.Error(), "504") {
-			return []string{}, fmt.Errorf("crt.sh appears to be down (gateway error). Please try again later: %w", err)
-		}
 		return []string{}, err
 	}
 
@@ -42,32 +49,29 @@ func SearchCrtSh(ctx context.Context, domain string, timeout time.Duration) ([]s
 }
 
 // searchCrtShURL performs the actual HTTP request and parsing
-func searchCrtShURL(ctx context.Context, url, domain string, timeout time.Duration) ([]string, error) {
-	client := &http.Client{
-		Timeout: timeout,
-	}
-
+func searchCrtShURL(ctx context.Context, client *httpclient.Client, dns *resolver.Resolver, url, domain string, timeout time.Duration) ([]string, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "origindive/1.0")
-
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("crt.sh request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		// Read error body but don't include HTML content in error message
-		body, _ := io.ReadAll(resp.Body)
-		bodyStr := string(body)
 
-		// If it's HTML (starts with <), give a clean error message
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return nil, api.NewRateLimitError(resp.Response, "crt.sh")
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// Still failing after the client's retries
+		return nil, fmt.Errorf("crt.sh appears to be down (gateway error %d). Please try again later", resp.StatusCode)
+	default:
+		// Don't include HTML content in the error message
+		bodyStr := string(resp.Data)
 		if strings.HasPrefix(strings.TrimSpace(bodyStr), "<") {
-			return nil, fmt.Errorf("crt.sh returned status %d (gateway error - service may be down)", resp.StatusCode)
+			return nil, fmt.Errorf("crt.sh returned status %d (service may be down)", resp.StatusCode)
 		}
 
 		// For non-HTML errors, include truncated message
@@ -77,10 +81,7 @@ func searchCrtShURL(ctx context.Context, url, domain string, timeout time.Durati
 		return nil, fmt.Errorf("crt.sh returned status %d: %s", resp.StatusCode, strings.TrimSpace(bodyStr))
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	var entries []CTEntry
 	if err := json.Unmarshal(body, &entries); err != nil {
@@ -108,19 +109,18 @@ func searchCrtShURL(ctx context.Context, url, domain string, timeout time.Durati
 	}
 
 	// Resolve subdomains to IPs
-	return resolveSubdomainsToIPs(ctx, subdomains, timeout)
+	return resolveSubdomainsToIPs(ctx, dns, subdomains, timeout)
 }
 
 // resolveSubdomainsToIPs resolves a list of subdomains to their IP addresses
-func resolveSubdomainsToIPs(ctx context.Context, subdomains []string, timeout time.Duration) ([]string, error) {
+func resolveSubdomainsToIPs(ctx context.Context, dns *resolver.Resolver, subdomains []string, timeout time.Duration) ([]string, error) {
 	ipSet := make(map[string]bool)
-	resolver := &net.Resolver{}
 
 	for _, subdomain := range subdomains {
 		// Create context with timeout for each lookup
 		lookupCtx, cancel := context.WithTimeout(ctx, timeout)
 
-		ips, err := resolver.LookupIP(lookupCtx, "ip4", subdomain)
+		ips, err := dns.LookupIPv4(lookupCtx, subdomain)
 		cancel()
 
 		if err != nil {
@@ -128,9 +128,7 @@ func resolveSubdomainsToIPs(ctx context.Context, subdomains []string, timeout ti
 		}
 
 		for _, ip := range ips {
-			if ip.To4() != nil {
-				ipSet[ip.String()] = true
-			}
+			ipSet[ip] = true
 		}
 	}
 
@@ -7,6 +7,9 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
 func TestSearchCrtSh_ValidResponse(t *testing.T) {
@@ -138,7 +141,7 @@ func TestExtractSubdomains_FromNameValue(t *testing.T) {
 func TestResolveSubdomainsToIPs_Empty(t *testing.T) {
 	ctx := context.Background()
 
-	ips, err := resolveSubdomainsToIPs(ctx, []string{}, 3*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{}, 3*time.Second)
 	if err != nil {
 		t.Errorf("Should not error on empty list: %v", err)
 	}
@@ -150,7 +153,7 @@ func TestResolveSubdomainsToIPs_Empty(t *testing.T) {
 func TestResolveSubdomainsToIPs_InvalidDomain(t *testing.T) {
 	ctx := context.Background()
 
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"invalid-domain-99999.test"}, 2*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"invalid-domain-99999.test"}, 2*time.Second)
 	if err != nil {
 		t.Errorf("Should not error, just skip failed resolutions: %v", err)
 	}
@@ -164,7 +167,7 @@ func TestResolveSubdomainsToIPs_ValidDomain(t *testing.T) {
 	ctx := context.Background()
 
 	// Use a well-known domain
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com"}, 5*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com"}, 5*time.Second)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -180,7 +183,7 @@ func TestResolveSubdomainsToIPs_Deduplication(t *testing.T) {
 	ctx := context.Background()
 
 	// Two domains that might resolve to same IPs
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com", "google.com"}, 5*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com", "google.com"}, 5*time.Second)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -199,7 +202,7 @@ func TestResolveSubdomainsToIPs_ContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // Cancel immediately
 
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com"}, 5*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com"}, 5*time.Second)
 	if err != nil {
 		t.Errorf("Should not error on cancellation, just skip: %v", err)
 	}
@@ -212,7 +215,7 @@ func TestResolveSubdomainsToIPs_IPv4Only(t *testing.T) {
 	ctx := context.Background()
 
 	// google.com has both IPv4 and IPv6
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com"}, 5*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com"}, 5*time.Second)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -301,7 +304,7 @@ func TestSearchCrtShURL_DirectCall(t *testing.T) {
 	defer cancel()
 
 	url := "https://crt.sh/?q=example.com&output=json"
-	_, err := searchCrtShURL(ctx, url, "example.com", 5*time.Second)
+	_, err := searchCrtShURL(ctx, httpclient.Default(), resolver.Default(), url, "example.com", 5*time.Second)
 	if err == nil {
 		t.Log("searchCrtShURL succeeded (might have network access)")
 	}
@@ -0,0 +1,238 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ErrAXFRRefused is returned when a nameserver does not allow the transfer
+var ErrAXFRRefused = errors.New("zone transfer refused")
+
+// maxAXFRRecords caps the address records read from one transfer
+const maxAXFRRecords = 100000
+
+// axfrPort is the port zone transfers are requested on
+var axfrPort = "53"
+
+// AXFRRecord is an A or AAAA record from a zone transfer
+type AXFRRecord struct {
+	Name string // Owner name, lowercase and without the trailing dot
+	IP   string
+}
+
+// AXFRResult is the outcome of a zone transfer attempt against one nameserver
+type AXFRResult struct {
+	Nameserver string // NS host name
+	Addr       string // Address that answered (or the last one tried)
+	Allowed    bool
+	Records    []AXFRRecord
+	Err        error
+}
+
+// NSResolver looks up nameservers and their addresses; *net.Resolver
+// implements it
+type NSResolver interface {
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// AttemptAXFR looks up the nameservers of domain and requests a zone
+// transfer from each of them over TCP, trying every address of a nameserver
+// until one allows it. Each attempt gets its own timeout. Results are in NS
+// record order.
+func AttemptAXFR(ctx context.Context, r NSResolver, domain string, timeout time.Duration) ([]AXFRResult, error) {
+	nsCtx, cancel := context.WithTimeout(ctx, timeout)
+	nameservers, err := r.LookupNS(nsCtx, domain)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("NS lookup failed: %w", err)
+	}
+	if len(nameservers) == 0 {
+		return nil, fmt.Errorf("no NS records found")
+	}
+
+	results := make([]AXFRResult, len(nameservers))
+	var wg sync.WaitGroup
+	for i, ns := range nameservers {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			results[i] = transferFrom(ctx, r, host, domain, timeout)
+		}(i, strings.TrimSuffix(ns.Host, "."))
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// transferFrom tries a zone transfer against each address of nameserver
+func transferFrom(ctx context.Context, r NSResolver, nameserver, domain string, timeout time.Duration) AXFRResult {
+	result := AXFRResult{Nameserver: nameserver}
+
+	hostCtx, cancel := context.WithTimeout(ctx, timeout)
+	addrs, err := r.LookupHost(hostCtx, nameserver)
+	cancel()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for _, ip := range addrs {
+		result.Addr = net.JoinHostPort(ip, axfrPort)
+		axfrCtx, cancel := context.WithTimeout(ctx, timeout)
+		result.Records, result.Err = TransferZone(axfrCtx, result.Addr, domain)
+		cancel()
+		if result.Err == nil {
+			result.Allowed = true
+			return result
+		}
+		if errors.Is(result.Err, ErrAXFRRefused) {
+			return result // Other addresses of the same server answer the same way
+		}
+	}
+	return result
+}
+
+// TransferZone requests a full zone transfer (AXFR) of domain from the
+// nameserver at addr (host:port) and returns the A and AAAA records in the
+// zone. It returns ErrAXFRRefused when the server declines.
+func TransferZone(ctx context.Context, addr, domain string) ([]AXFRRecord, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(domain, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	query, err := axfrQuery(id, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	var records []AXFRRecord
+	soas := 0
+	for first := true; soas < 2; first = false {
+		msg, err := readTCPMessage(conn)
+		if err != nil {
+			if first && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+				// Servers that refuse often just close the connection
+				return nil, ErrAXFRRefused
+			}
+			if first {
+				return nil, err
+			}
+			return records, fmt.Errorf("zone transfer incomplete: %w", err)
+		}
+
+		var p dnsmessage.Parser
+		header, err := p.Start(msg)
+		if err != nil {
+			return records, fmt.Errorf("invalid AXFR response: %w", err)
+		}
+		if header.ID != id {
+			return records, fmt.Errorf("AXFR response ID mismatch")
+		}
+		if header.RCode != dnsmessage.RCodeSuccess {
+			return nil, fmt.Errorf("%w: %s", ErrAXFRRefused, header.RCode)
+		}
+		if err := p.SkipAllQuestions(); err != nil {
+			return records, fmt.Errorf("invalid AXFR response: %w", err)
+		}
+
+		answers := 0
+		for {
+			h, err := p.AnswerHeader()
+			if err == dnsmessage.ErrSectionDone {
+				break
+			}
+			if err != nil {
+				return records, fmt.Errorf("invalid AXFR response: %w", err)
+			}
+			answers++
+
+			owner := strings.ToLower(strings.TrimSuffix(h.Name.String(), "."))
+			switch h.Type {
+			case dnsmessage.TypeSOA:
+				soas++
+				err = p.SkipAnswer()
+			case dnsmessage.TypeA:
+				var a dnsmessage.AResource
+				if a, err = p.AResource(); err == nil {
+					records = append(records, AXFRRecord{Name: owner, IP: net.IP(a.A[:]).String()})
+				}
+			case dnsmessage.TypeAAAA:
+				var aaaa dnsmessage.AAAAResource
+				if aaaa, err = p.AAAAResource(); err == nil {
+					records = append(records, AXFRRecord{Name: owner, IP: net.IP(aaaa.AAAA[:]).String()})
+				}
+			default:
+				err = p.SkipAnswer()
+			}
+			if err != nil {
+				return records, fmt.Errorf("invalid AXFR response: %w", err)
+			}
+			if len(records) >= maxAXFRRecords {
+				return records, nil
+			}
+		}
+
+		// A NOERROR answer without records is a refusal too
+		if first && answers == 0 {
+			return nil, ErrAXFRRefused
+		}
+	}
+
+	return records, nil
+}
+
+// axfrQuery builds a length-prefixed AXFR query for name
+func axfrQuery(id uint16, name dnsmessage.Name) ([]byte, error) {
+	b := dnsmessage.NewBuilder(make([]byte, 2, 514), dnsmessage.Header{ID: id})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypeAXFR, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(msg, uint16(len(msg)-2))
+	return msg, nil
+}
+
+// readTCPMessage reads one length-prefixed DNS message
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,247 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// axfrServer is a TCP nameserver that answers AXFR queries according to mode:
+// "allow" transfers a two-message zone, "refuse" answers REFUSED, "empty"
+// answers NOERROR without records and "close" hangs up
+type axfrServer struct {
+	ln   net.Listener
+	mode string
+}
+
+func startAXFRServer(t *testing.T, addr, mode string) *axfrServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", addr, err)
+	}
+	s := &axfrServer{ln: ln, mode: mode}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *axfrServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			msg, err := readTCPMessage(conn)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			header, err := p.Start(msg)
+			if err != nil {
+				return
+			}
+			q, err := p.Question()
+			if err != nil || q.Type != dnsmessage.TypeAXFR {
+				return
+			}
+			for _, reply := range s.replies(header.ID, q) {
+				if _, err := conn.Write(reply); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (s *axfrServer) replies(id uint16, q dnsmessage.Question) [][]byte {
+	soa := func(b *dnsmessage.Builder) {
+		b.SOAResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 3600}, dnsmessage.SOAResource{
+			NS:     dnsmessage.MustNewName("ns1.example.com."),
+			MBox:   dnsmessage.MustNewName("hostmaster.example.com."),
+			Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, MinTTL: 300,
+		})
+	}
+	a := func(b *dnsmessage.Builder, name string, ip [4]byte) {
+		b.AResource(dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET}, dnsmessage.AResource{A: ip})
+	}
+	message := func(rcode dnsmessage.RCode, answers func(*dnsmessage.Builder)) []byte {
+		b := dnsmessage.NewBuilder(make([]byte, 2, 512), dnsmessage.Header{ID: id, Response: true, Authoritative: true, RCode: rcode})
+		b.EnableCompression()
+		b.StartQuestions()
+		b.Question(q)
+		b.StartAnswers()
+		if answers != nil {
+			answers(&b)
+		}
+		msg, _ := b.Finish()
+		msg[0], msg[1] = byte((len(msg)-2)>>8), byte(len(msg)-2)
+		return msg
+	}
+
+	switch s.mode {
+	case "allow":
+		return [][]byte{
+			message(dnsmessage.RCodeSuccess, func(b *dnsmessage.Builder) {
+				soa(b)
+				a(b, "www.example.com.", [4]byte{192, 0, 2, 1})
+				a(b, "Origin.Example.com.", [4]byte{203, 0, 113, 5})
+			}),
+			message(dnsmessage.RCodeSuccess, func(b *dnsmessage.Builder) {
+				b.AAAAResource(dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("v6.example.com."), Class: dnsmessage.ClassINET},
+					dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}})
+				b.MXResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET},
+					dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mail.example.com.")})
+				soa(b)
+			}),
+		}
+	case "refuse":
+		return [][]byte{message(dnsmessage.RCodeRefused, nil)}
+	case "empty":
+		return [][]byte{message(dnsmessage.RCodeSuccess, nil)}
+	}
+	return nil
+}
+
+func TestTransferZone(t *testing.T) {
+	srv := startAXFRServer(t, "127.0.0.1:0", "allow")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	records, err := TransferZone(ctx, srv.ln.Addr().String(), "example.com")
+	if err != nil {
+		t.Fatalf("TransferZone() error = %v", err)
+	}
+	want := []AXFRRecord{
+		{Name: "www.example.com", IP: "192.0.2.1"},
+		{Name: "origin.example.com", IP: "203.0.113.5"},
+		{Name: "v6.example.com", IP: "2001:db8::1"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("TransferZone() = %v, want %v", records, want)
+	}
+}
+
+func TestTransferZone_Refused(t *testing.T) {
+	for _, mode := range []string{"refuse", "empty", "close"} {
+		t.Run(mode, func(t *testing.T) {
+			srv := startAXFRServer(t, "127.0.0.1:0", mode)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			records, err := TransferZone(ctx, srv.ln.Addr().String(), "example.com")
+			if !errors.Is(err, ErrAXFRRefused) {
+				t.Errorf("TransferZone() error = %v, want ErrAXFRRefused", err)
+			}
+			if records != nil {
+				t.Errorf("TransferZone() records = %v, want none", records)
+			}
+		})
+	}
+}
+
+func TestTransferZone_Timeout(t *testing.T) {
+	// A server that accepts but never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	hold := make(chan struct{})
+	defer close(hold)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				<-hold
+				conn.Close()
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := TransferZone(ctx, ln.Addr().String(), "example.com"); err == nil || errors.Is(err, ErrAXFRRefused) {
+		t.Errorf("TransferZone() error = %v, want a timeout", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("TransferZone() ignored the context deadline")
+	}
+}
+
+// nsResolver serves NS records and nameserver addresses from tables
+type nsResolver struct {
+	ns    []string
+	hosts map[string][]string
+}
+
+func (r *nsResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	var out []*net.NS
+	for _, host := range r.ns {
+		out = append(out, &net.NS{Host: host + "."})
+	}
+	return out, nil
+}
+
+func (r *nsResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestAttemptAXFR(t *testing.T) {
+	// Two nameservers on the same port of different loopback addresses
+	open := startAXFRServer(t, "127.0.0.1:0", "allow")
+	_, port, _ := net.SplitHostPort(open.ln.Addr().String())
+	startAXFRServer(t, net.JoinHostPort("127.0.0.2", port), "refuse")
+
+	defer func(old string) { axfrPort = old }(axfrPort)
+	axfrPort = port
+
+	r := &nsResolver{
+		ns: []string{"ns1.example.com", "ns2.example.com", "ns3.example.com"},
+		hosts: map[string][]string{
+			"ns1.example.com": {"127.0.0.2"},
+			"ns2.example.com": {"127.0.0.1"},
+		},
+	}
+	results, err := AttemptAXFR(context.Background(), r, "example.com", 5*time.Second)
+	if err != nil {
+		t.Fatalf("AttemptAXFR() error = %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("AttemptAXFR() = %d results, want 3", len(results))
+	}
+
+	if results[0].Nameserver != "ns1.example.com" || results[0].Allowed || !errors.Is(results[0].Err, ErrAXFRRefused) {
+		t.Errorf("ns1 = %+v, want refused", results[0])
+	}
+	if !results[1].Allowed || results[1].Addr != open.ln.Addr().String() || len(results[1].Records) != 3 {
+		t.Errorf("ns2 = %+v, want allowed with 3 records", results[1])
+	}
+	if results[2].Allowed || results[2].Err == nil {
+		t.Errorf("ns3 = %+v, want unresolvable", results[2])
+	}
+
+	var ips []string
+	for _, rec := range results[1].Records {
+		ips = append(ips, rec.IP)
+	}
+	sort.Strings(ips)
+	if !reflect.DeepEqual(ips, []string{"192.0.2.1", "2001:db8::1", "203.0.113.5"}) {
+		t.Errorf("records = %v", ips)
+	}
+}
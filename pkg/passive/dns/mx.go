@@ -4,8 +4,9 @@ package dns
 import (
 	"context"
 	"fmt"
-	"net"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
 // MXRecord represents an MX record with resolved IPs
@@ -17,15 +18,16 @@ type MXRecord struct {
 
 // LookupMX queries MX records and resolves them to IPs
 func LookupMX(ctx context.Context, domain string, timeout time.Duration) ([]MXRecord, error) {
-	resolver := &net.Resolver{
-		PreferGo: true,
-	}
+	return lookupMX(ctx, resolver.Default(), domain, timeout)
+}
 
+// lookupMX queries MX records through r and resolves them to IPs
+func lookupMX(ctx context.Context, r *resolver.Resolver, domain string, timeout time.Duration) ([]MXRecord, error) {
 	mxCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// Lookup MX records
-	mxRecords, err := resolver.LookupMX(mxCtx, domain)
+	mxRecords, err := r.LookupMX(mxCtx, domain)
 	if err != nil {
 		return nil, fmt.Errorf("MX lookup failed: %w", err)
 	}
@@ -37,7 +39,7 @@ func LookupMX(ctx context.Context, domain string, timeout time.Duration) ([]MXRe
 	// Resolve each MX host to IPs
 	var results []MXRecord
 	for _, mx := range mxRecords {
-		ips, err := resolveHost(ctx, mx.Host, timeout)
+		ips, err := resolveHost(ctx, r, mx.Host, timeout)
 		if err != nil {
 			// Continue even if one MX fails
 			continue
@@ -54,28 +56,11 @@ func LookupMX(ctx context.Context, domain string, timeout time.Duration) ([]MXRe
 }
 
 // resolveHost resolves a hostname to IPv4 addresses
-func resolveHost(ctx context.Context, host string, timeout time.Duration) ([]string, error) {
-	resolver := &net.Resolver{
-		PreferGo: true,
-	}
-
+func resolveHost(ctx context.Context, r *resolver.Resolver, host string, timeout time.Duration) ([]string, error) {
 	hostCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	ips, err := resolver.LookupHost(hostCtx, host)
-	if err != nil {
-		return nil, err
-	}
-
-	// Filter IPv4 only
-	var ipv4s []string
-	for _, ip := range ips {
-		if net.ParseIP(ip).To4() != nil {
-			ipv4s = append(ipv4s, ip)
-		}
-	}
-
-	return ipv4s, nil
+	return r.LookupIPv4(hostCtx, host)
 }
 
 // GetAllMXIPs extracts all unique IPs from MX records
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
 func TestLookupMX_ValidDomain(t *testing.T) {
@@ -77,7 +79,7 @@ func TestResolveHost_ValidHost(t *testing.T) {
 
 	for _, host := range hosts {
 		t.Run(host, func(t *testing.T) {
-			ips, err := resolveHost(ctx, host, timeout)
+			ips, err := resolveHost(ctx, resolver.Default(), host, timeout)
 			if err != nil {
 				t.Logf("Resolve failed (expected in test env): %v", err)
 				return
@@ -96,7 +98,7 @@ func TestResolveHost_InvalidHost(t *testing.T) {
 	ctx := context.Background()
 	timeout := 2 * time.Second
 
-	_, err := resolveHost(ctx, "invalid-host-99999.test", timeout)
+	_, err := resolveHost(ctx, resolver.Default(), "invalid-host-99999.test", timeout)
 	if err == nil {
 		t.Error("Expected error for invalid host")
 	}
@@ -107,7 +109,7 @@ func TestResolveHost_IPv4Only(t *testing.T) {
 	timeout := 5 * time.Second
 
 	// Use host that has both IPv4 and IPv6
-	ips, err := resolveHost(ctx, "google.com", timeout)
+	ips, err := resolveHost(ctx, resolver.Default(), "google.com", timeout)
 	if err != nil {
 		t.Logf("Resolve failed (expected in test env): %v", err)
 		return
@@ -247,7 +249,7 @@ func TestResolveHost_Localhost(t *testing.T) {
 	ctx := context.Background()
 	timeout := 2 * time.Second
 
-	ips, err := resolveHost(ctx, "localhost", timeout)
+	ips, err := resolveHost(ctx, resolver.Default(), "localhost", timeout)
 	if err != nil {
 		t.Logf("Localhost resolve failed: %v", err)
 		return
@@ -0,0 +1,245 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/subdomain"
+)
+
+// SourceName is the registry name of the DNS source
+const SourceName = "dns"
+
+// subdomainWorkers is the number of concurrent subdomain lookups
+const subdomainWorkers = 20
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "Subdomain brute force, MX records, zone transfers (AXFR) and SPF/DMARC/TXT record mining",
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			source := NewSource(opts)
+			if opts.Config == nil {
+				return source, nil
+			}
+			if opts.Config.SubdomainWordlist != "" {
+				words, err := subdomain.LoadWordlist(opts.Config.SubdomainWordlist)
+				if err != nil {
+					return nil, err
+				}
+				source.words = words
+			}
+			if len(opts.Config.Resolvers) > 0 {
+				if _, err := subdomain.NewPool(opts.Config.Resolvers, opts.Config.ResolverRate); err != nil {
+					return nil, err
+				}
+				source.resolvers = opts.Config.Resolvers
+			}
+			source.resolverRate = opts.Config.ResolverRate
+			source.permutations = opts.Config.SubdomainPermutations
+			return source, nil
+		},
+	})
+}
+
+// Source implements passive.Source using live DNS lookups
+type Source struct {
+	opts         passive.Options
+	words        []string // Subdomain wordlist (nil = subdomain.CommonSubdomains)
+	resolvers    []string // Brute force resolvers (nil = the shared resolver)
+	resolverRate int      // Queries per second per resolver (0 = unlimited)
+	permutations bool     // Also try permutations of found subdomains
+}
+
+// NewSource creates a DNS source
+func NewSource(opts passive.Options) *Source {
+	return &Source{opts: opts}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns nil; DNS lookups need no API key
+func (s *Source) RequiredCredentials() []string { return nil }
+
+// Search enumerates common subdomains and MX hosts, attempts zone transfers,
+// mines SPF, DMARC and TXT records, and returns the IPs found. IPs from
+// records carry the chain of records that produced them in their
+// "record_path" metadata.
+// Subdomain brute-forcing gets its own budget (see budget); the later phases
+// run on ctx with the source timeout per lookup, so a slow brute force
+// cannot starve them.
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	t := s.opts.Timeout
+	words := s.words
+	if words == nil {
+		words = subdomain.CommonSubdomains
+	}
+
+	now := time.Now()
+	index := make(map[string]int)
+	var records []core.PassiveIP
+	add := func(ip, key, value string) {
+		if i, ok := index[ip]; ok {
+			// Keep the first value, but record every kind of evidence
+			if _, exists := records[i].Metadata[key]; !exists {
+				records[i].Metadata[key] = value
+			}
+			return
+		}
+		index[ip] = len(records)
+		records = append(records, core.PassiveIP{
+			IP:        ip,
+			Source:    SourceName,
+			FirstSeen: now,
+			LastSeen:  now,
+			Metadata:  map[string]interface{}{key: value},
+		})
+	}
+
+	// Phase 1: Subdomain enumeration
+	s.opts.Printf("  → Enumerating subdomains...\n")
+	r := s.opts.Resolver()
+	subScanner := subdomain.NewScanner(domain, subdomainWorkers, t)
+	if len(s.resolvers) > 0 {
+		if err := subScanner.SetResolvers(s.resolvers, s.resolverRate); err != nil {
+			return nil, err
+		}
+	} else {
+		subScanner.SetResolver(r)
+	}
+	subScanner.SetPermutations(s.permutations)
+	bruteCtx, cancel := context.WithTimeout(ctx, s.budget())
+	subResults, err := subScanner.Scan(bruteCtx, words)
+	cancel()
+	if wildcard := subScanner.WildcardIPs(); len(wildcard) > 0 {
+		s.opts.Printf("  → Wildcard DNS resolves to %s; filtered %d answers\n", strings.Join(wildcard, ", "), subScanner.Filtered())
+	}
+	if err == nil && len(subResults) > 0 {
+		count := len(records)
+		for host, ips := range subResults {
+			for _, ip := range ips {
+				add(ip, "hostname", host)
+			}
+		}
+		s.opts.Printf("  → Found %d IPs from %d subdomains\n", len(records)-count, len(subResults))
+	}
+
+	// Phase 2: MX record analysis
+	s.opts.Printf("  → Analyzing MX records...\n")
+	mxRecords, err := lookupMX(ctx, r, domain, t)
+	if err == nil && len(mxRecords) > 0 {
+		count := len(records)
+		for _, mx := range mxRecords {
+			for _, ip := range mx.IPs {
+				add(ip, "mx_host", mx.Host)
+			}
+		}
+		s.opts.Printf("  → Found %d IPs from %d MX records\n", len(records)-count, len(mxRecords))
+	}
+
+	// Phase 3: Zone transfers
+	s.opts.Printf("  → Attempting zone transfers...\n")
+	axfrResults, err := AttemptAXFR(ctx, r, domain, t)
+	if err == nil {
+		allowed := 0
+		for _, result := range axfrResults {
+			if !result.Allowed {
+				continue
+			}
+			allowed++
+			count := len(records)
+			for _, rec := range result.Records {
+				add(rec.IP, "hostname", rec.Name)
+				add(rec.IP, "axfr_nameserver", result.Nameserver)
+			}
+			s.opts.Printf("  → Zone transfer allowed by %s (%s): %d new IPs from %d address records\n",
+				result.Nameserver, result.Addr, len(records)-count, len(result.Records))
+		}
+		if allowed == 0 {
+			s.opts.Printf("  → Zone transfer refused by all %d nameservers\n", len(axfrResults))
+		}
+	}
+
+	// Phase 4: SPF, DMARC and TXT records
+	s.opts.Printf("  → Mining SPF, DMARC and TXT records...\n")
+	before := len(records)
+	s.mineRecords(ctx, r, domain, t, func(rec RecordIP) {
+		add(rec.IP, "record_path", rec.PathString())
+	})
+	if n := len(records) - before; n > 0 {
+		s.opts.Printf("  → Found %d IPs from SPF, DMARC and TXT records\n", n)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no IPs discovered from DNS enumeration")
+	}
+
+	return records, nil
+}
+
+// budget returns the time allowed for subdomain brute-forcing: 4x the source
+// timeout, or for a custom wordlist enough for every batch of workers to time
+// out once, or for the rate-limited resolvers to get through it, whichever is
+// longest
+func (s *Source) budget() time.Duration {
+	t := s.opts.Timeout
+	n := len(s.words)
+	budget := t * 4
+	if batches := time.Duration(n/subdomainWorkers+1) * t; batches > budget {
+		budget = batches
+	}
+	if s.resolverRate > 0 && len(s.resolvers) > 0 {
+		qps := s.resolverRate * len(s.resolvers)
+		if limited := time.Duration(n/qps+1)*time.Second + t*4; limited > budget {
+			budget = limited
+		}
+	}
+	return budget
+}
+
+// mineRecords expands SPF and reads the DMARC and TXT records of the domain,
+// passing every IP found to emit. Each record family gets its own timeout,
+// derived from the caller's context rather than the brute force budget.
+func (s *Source) mineRecords(ctx context.Context, r Resolver, domain string, timeout time.Duration, emit func(RecordIP)) {
+	spfCtx, cancel := context.WithTimeout(ctx, timeout)
+	spf, err := ExpandSPF(spfCtx, r, domain)
+	cancel()
+	if spf != nil {
+		for _, rec := range spf.IPs {
+			emit(rec)
+		}
+		if len(spf.Ranges) > 0 {
+			cidrs := make([]string, 0, len(spf.Ranges))
+			for _, r := range spf.Ranges {
+				cidrs = append(cidrs, r.CIDR)
+			}
+			s.opts.Printf("  → SPF authorizes %d ranges too large to list: %s\n", len(cidrs), strings.Join(cidrs, ", "))
+		}
+	}
+	if errors.Is(err, ErrSPFLookupLimit) {
+		s.opts.Printf("  → SPF record exceeds %d lookups; results are partial\n", MaxSPFLookups)
+	}
+
+	dmarcCtx, cancel := context.WithTimeout(ctx, timeout)
+	dmarcIPs, _, _ := MineDMARC(dmarcCtx, r, domain)
+	cancel()
+	for _, rec := range dmarcIPs {
+		emit(rec)
+	}
+
+	txtCtx, cancel := context.WithTimeout(ctx, timeout)
+	txtIPs, info, _ := MineTXT(txtCtx, r, domain)
+	cancel()
+	for _, rec := range txtIPs {
+		emit(rec)
+	}
+	if info != nil && len(info.Verifications) > 0 {
+		s.opts.Printf("  → TXT verification tokens: %s\n", strings.Join(info.Verifications, ", "))
+	}
+}
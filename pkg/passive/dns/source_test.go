@@ -0,0 +1,167 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/resolver"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestFactory_SubdomainOptions(t *testing.T) {
+	reg, ok := passive.Lookup(SourceName)
+	if !ok {
+		t.Fatal("dns source not registered")
+	}
+
+	wordlist := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(wordlist, []byte("www\norigin\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := reg.Factory(passive.Options{Config: &core.Config{
+		SubdomainWordlist:     wordlist,
+		Resolvers:             []string{"9.9.9.9", "system"},
+		ResolverRate:          5,
+		SubdomainPermutations: true,
+	}})
+	if err != nil {
+		t.Fatalf("Factory() error = %v", err)
+	}
+	source := src.(*Source)
+	if len(source.words) != 2 || len(source.resolvers) != 2 || source.resolverRate != 5 || !source.permutations {
+		t.Errorf("source = %+v", source)
+	}
+
+	if _, err := reg.Factory(passive.Options{Config: &core.Config{SubdomainWordlist: filepath.Join(t.TempDir(), "missing")}}); err == nil {
+		t.Error("Factory() should fail for a missing wordlist")
+	}
+	if _, err := reg.Factory(passive.Options{Config: &core.Config{Resolvers: []string{"dns.example"}}}); err == nil {
+		t.Error("Factory() should fail for an invalid resolver")
+	}
+	if _, err := reg.Factory(passive.Options{}); err != nil {
+		t.Errorf("Factory() without config error = %v", err)
+	}
+}
+
+// Without --resolvers, brute force goes through the shared resolver, so its
+// lookups use the configured server and show in its stats
+func TestSource_BruteForceUsesSharedResolver(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			header, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+			found := strings.EqualFold(q.Name.String(), "www.example.test.")
+			rcode := dnsmessage.RCodeNameError
+			if found {
+				rcode = dnsmessage.RCodeSuccess
+			}
+			b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, Authoritative: true, RCode: rcode})
+			b.StartQuestions()
+			b.Question(q)
+			b.StartAnswers()
+			if found && q.Type == dnsmessage.TypeA {
+				b.AResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.AResource{A: [4]byte{192, 0, 2, 80}})
+			}
+			msg, _ := b.Finish()
+			conn.WriteTo(msg, addr)
+		}
+	}()
+
+	r, err := resolver.New(resolver.Config{Server: conn.LocalAddr().String(), Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSource(passive.Options{Timeout: time.Second, DNS: r})
+	s.words = []string{"www", "nothere"}
+
+	records, err := s.Search(context.Background(), "example.test")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(records) != 1 || records[0].IP != "192.0.2.80" || records[0].Metadata["hostname"] != "www" {
+		t.Errorf("Search() = %+v, want www's address", records)
+	}
+	if stats := r.Stats(); stats.Queries == 0 || stats.NotFound == 0 {
+		t.Errorf("Stats() = %+v, want the brute-force lookups counted", stats)
+	}
+}
+
+func TestSource_Budget(t *testing.T) {
+	s := NewSource(passive.Options{Timeout: time.Second})
+	if got := s.budget(); got != 4*time.Second {
+		t.Errorf("budget() = %v, want 4s for the built-in list", got)
+	}
+
+	s.words = make([]string, 200) // 11 batches of 20 workers
+	if got := s.budget(); got != 11*time.Second {
+		t.Errorf("budget() = %v, want 11s", got)
+	}
+
+	s.resolvers = []string{"9.9.9.9"}
+	s.resolverRate = 10 // 200 names at 10 qps
+	if got := s.budget(); got != 25*time.Second {
+		t.Errorf("budget() = %v, want 25s", got)
+	}
+}
+
+// slowResolver never answers TXT lookups for the names in slow
+type slowResolver struct {
+	*fakeResolver
+	slow map[string]bool
+}
+
+func (r *slowResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.slow[name] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return r.fakeResolver.LookupTXT(ctx, name)
+}
+
+func TestSource_MineRecordsTimeouts(t *testing.T) {
+	r := &slowResolver{
+		fakeResolver: &fakeResolver{txt: map[string][]string{
+			"example.com": {"v=spf1 include:_spf.slow.example -all", "origin=203.0.113.7"},
+		}},
+		slow: map[string]bool{"_spf.slow.example": true, "_dmarc.example.com": true},
+	}
+
+	// SPF and DMARC use up their timeouts; TXT mining still gets its own
+	s := NewSource(passive.Options{})
+	var found []string
+	start := time.Now()
+	s.mineRecords(context.Background(), r, "example.com", 50*time.Millisecond, func(rec RecordIP) {
+		found = append(found, rec.IP)
+	})
+	if len(found) != 1 || found[0] != "203.0.113.7" {
+		t.Errorf("mineRecords() found %v, want [203.0.113.7]", found)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("mineRecords() took %v", elapsed)
+	}
+}
@@ -0,0 +1,291 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MaxSPFLookups is the RFC 7208 limit on DNS-querying SPF terms
+// (include, a, mx, ptr, exists and redirect) per evaluation
+const MaxSPFLookups = 10
+
+const (
+	// maxSPFMXHosts caps the MX hosts resolved for one mx mechanism (RFC 7208 4.6.4)
+	maxSPFMXHosts = 10
+
+	// minExpandPrefix is the shortest ip4 prefix expanded into addresses;
+	// larger networks (e.g. a provider's /16) are reported as ranges
+	minExpandPrefix = 28
+)
+
+// ErrSPFLookupLimit is returned with partial results when expansion needs
+// more than MaxSPFLookups lookups
+var ErrSPFLookupLimit = errors.New("SPF lookup limit exceeded")
+
+// Resolver is the subset of *net.Resolver used by the record walkers
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// RecordIP is an IP found in DNS records, with the chain of records that led to it
+type RecordIP struct {
+	IP   string
+	Path []string // e.g., ["example.com TXT", "include:_spf.example.net", "ip4:192.0.2.1"]
+}
+
+// PathString returns the record path in display form
+func (r RecordIP) PathString() string {
+	return strings.Join(r.Path, " → ")
+}
+
+// RecordRange is an SPF ip4 network too large to expand into addresses
+type RecordRange struct {
+	CIDR string
+	Path []string
+}
+
+// SPFResult holds everything an SPF expansion found
+type SPFResult struct {
+	IPs     []RecordIP
+	Ranges  []RecordRange
+	Lookups int // DNS-querying terms evaluated
+}
+
+// ExpandSPF follows the domain's SPF record through include, redirect, a
+// and mx terms and collects every IPv4 address it authorizes. Fail-qualified
+// ("-") terms and macros are skipped. When the record needs more than
+// MaxSPFLookups lookups, expansion stops and the results so far are
+// returned with ErrSPFLookupLimit.
+func ExpandSPF(ctx context.Context, r Resolver, domain string) (*SPFResult, error) {
+	record, err := lookupSPF(ctx, r, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &spfWalker{
+		r:       r,
+		result:  &SPFResult{},
+		visited: map[string]bool{strings.ToLower(domain): true},
+		seen:    make(map[string]bool),
+	}
+	err = w.walk(ctx, domain, record, []string{domain + " TXT"})
+	return w.result, err
+}
+
+// lookupSPF returns the domain's "v=spf1" TXT record
+func lookupSPF(ctx context.Context, r Resolver, domain string) (string, error) {
+	records, err := r.LookupTXT(ctx, domain)
+	if err != nil {
+		return "", fmt.Errorf("TXT lookup failed for %s: %w", domain, err)
+	}
+	for _, record := range records {
+		if isSPF(record) {
+			return record, nil
+		}
+	}
+	return "", fmt.Errorf("no SPF record for %s", domain)
+}
+
+// isSPF reports whether a TXT record is an SPF version 1 record
+func isSPF(record string) bool {
+	record = strings.ToLower(strings.TrimSpace(record))
+	return record == "v=spf1" || strings.HasPrefix(record, "v=spf1 ")
+}
+
+// spfWalker carries the lookup budget and results across nested records
+type spfWalker struct {
+	r       Resolver
+	result  *SPFResult
+	visited map[string]bool // Domains already expanded (include loops)
+	seen    map[string]bool // IPs already recorded
+}
+
+// walk evaluates the terms of one SPF record
+func (w *spfWalker) walk(ctx context.Context, domain, record string, path []string) error {
+	var redirect string
+	hasAll := false
+
+	for _, term := range strings.Fields(record)[1:] {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		qualifier := byte('+')
+		if strings.IndexByte("+-~?", term[0]) >= 0 {
+			qualifier, term = term[0], term[1:]
+		}
+		name, arg := splitTerm(term)
+
+		if name == "all" {
+			hasAll = true
+			continue
+		}
+		if strings.Contains(arg, "%") {
+			continue // Macros depend on the sender; nothing to expand offline
+		}
+
+		switch name {
+		case "redirect":
+			redirect = arg
+			continue
+		case "include", "a", "mx", "ptr", "exists":
+			if !w.count() {
+				return ErrSPFLookupLimit
+			}
+		case "ip4":
+		default:
+			continue // ip6, exp= and unknown modifiers
+		}
+
+		if qualifier == '-' {
+			continue // Listed as not allowed to send for the domain
+		}
+
+		switch name {
+		case "ip4":
+			w.addNetwork(arg, extend(path, "ip4:"+arg))
+		case "include":
+			if err := w.follow(ctx, arg, extend(path, "include:"+arg)); err != nil {
+				return err
+			}
+		case "a":
+			host := hostArg(arg, domain)
+			w.addHost(ctx, host, extend(path, "a:"+host))
+		case "mx":
+			host := hostArg(arg, domain)
+			mxs, err := w.r.LookupMX(ctx, host)
+			if err != nil {
+				continue
+			}
+			for i, mx := range mxs {
+				if i == maxSPFMXHosts {
+					break
+				}
+				mxHost := strings.TrimSuffix(mx.Host, ".")
+				w.addHost(ctx, mxHost, extend(path, "mx:"+host, mxHost))
+			}
+		}
+	}
+
+	// redirect= applies only when the record has no "all" term
+	if redirect != "" && !hasAll {
+		if !w.count() {
+			return ErrSPFLookupLimit
+		}
+		return w.follow(ctx, redirect, extend(path, "redirect="+redirect))
+	}
+	return nil
+}
+
+// follow expands the SPF record of an included or redirected domain
+// Missing records and loops are skipped, as a receiver would treat them as no match
+func (w *spfWalker) follow(ctx context.Context, domain string, path []string) error {
+	key := strings.ToLower(strings.TrimSuffix(domain, "."))
+	if w.visited[key] {
+		return nil
+	}
+	w.visited[key] = true
+
+	record, err := lookupSPF(ctx, w.r, domain)
+	if err != nil {
+		return nil
+	}
+	return w.walk(ctx, domain, record, extend(path, domain+" TXT"))
+}
+
+// count spends one lookup from the budget, reporting false when exhausted
+func (w *spfWalker) count() bool {
+	if w.result.Lookups >= MaxSPFLookups {
+		return false
+	}
+	w.result.Lookups++
+	return true
+}
+
+// addHost records the IPv4 addresses a hostname resolves to
+func (w *spfWalker) addHost(ctx context.Context, host string, path []string) {
+	addrs, err := w.r.LookupHost(ctx, host)
+	if err != nil {
+		return
+	}
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			w.addIP(ip.To4().String(), path)
+		}
+	}
+}
+
+// addNetwork records an ip4 term: single addresses and small networks are
+// expanded, larger networks are kept as ranges
+func (w *spfWalker) addNetwork(arg string, path []string) {
+	if !strings.Contains(arg, "/") {
+		if ip := net.ParseIP(arg); ip != nil && ip.To4() != nil {
+			w.addIP(ip.To4().String(), path)
+		}
+		return
+	}
+
+	_, network, err := net.ParseCIDR(arg)
+	if err != nil || network.IP.To4() == nil {
+		return
+	}
+	ones, _ := network.Mask.Size()
+	if ones < minExpandPrefix {
+		w.result.Ranges = append(w.result.Ranges, RecordRange{CIDR: network.String(), Path: path})
+		return
+	}
+
+	start := binary.BigEndian.Uint32(network.IP.To4())
+	for i := uint32(0); i < 1<<(32-ones); i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, start+i)
+		w.addIP(ip.String(), path)
+	}
+}
+
+// addIP records an address the first time it is found
+func (w *spfWalker) addIP(ip string, path []string) {
+	if w.seen[ip] {
+		return
+	}
+	w.seen[ip] = true
+	w.result.IPs = append(w.result.IPs, RecordIP{IP: ip, Path: path})
+}
+
+// splitTerm splits "name:arg", "name=arg" and "name/cidr" SPF terms
+func splitTerm(term string) (name, arg string) {
+	i := strings.IndexAny(term, ":=/")
+	if i < 0 {
+		return strings.ToLower(term), ""
+	}
+	name = strings.ToLower(term[:i])
+	if term[i] == '/' {
+		return name, "" // a/24, mx/24: dual-cidr on the current domain
+	}
+	return name, term[i+1:]
+}
+
+// hostArg returns the target of an a or mx term, defaulting to the current domain
+// A trailing dual-cidr length ("a:host/24") is dropped
+func hostArg(arg, domain string) string {
+	if i := strings.IndexByte(arg, '/'); i >= 0 {
+		arg = arg[:i]
+	}
+	if arg == "" {
+		return domain
+	}
+	return arg
+}
+
+// extend returns a copy of path with elems appended
+func extend(path []string, elems ...string) []string {
+	out := make([]string, 0, len(path)+len(elems))
+	out = append(out, path...)
+	return append(out, elems...)
+}
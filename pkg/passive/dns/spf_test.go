@@ -0,0 +1,222 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeResolver answers lookups from fixed tables and counts TXT queries
+type fakeResolver struct {
+	txt     map[string][]string
+	hosts   map[string][]string
+	mx      map[string][]string
+	txtHits map[string]int
+}
+
+func (f *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if f.txtHits == nil {
+		f.txtHits = make(map[string]int)
+	}
+	f.txtHits[name]++
+	if records, ok := f.txt[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (f *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := f.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (f *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	hosts, ok := f.mx[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	var mxs []*net.MX
+	for i, h := range hosts {
+		mxs = append(mxs, &net.MX{Host: h + ".", Pref: uint16(10 * (i + 1))})
+	}
+	return mxs, nil
+}
+
+// paths maps each found IP to its display path
+func paths(recs []RecordIP) map[string]string {
+	out := make(map[string]string)
+	for _, rec := range recs {
+		out[rec.IP] = rec.PathString()
+	}
+	return out
+}
+
+func TestExpandSPF_Mechanisms(t *testing.T) {
+	r := &fakeResolver{
+		txt: map[string][]string{
+			"example.com": {
+				"google-site-verification=abc",
+				"v=spf1 ip4:192.0.2.10 ip4:198.51.100.0/30 a mx:example.com include:_spf.mail.example.net ip6:2001:db8::1 ~all",
+			},
+			"_spf.mail.example.net": {"v=spf1 a:smtp.mail.example.net/24 ip4:203.0.113.0/16 -ip4:203.0.113.99 ?all"},
+		},
+		hosts: map[string][]string{
+			"example.com":           {"192.0.2.1", "2001:db8::2"},
+			"mx1.example.com":       {"192.0.2.25"},
+			"smtp.mail.example.net": {"192.0.2.50"},
+		},
+		mx: map[string][]string{"example.com": {"mx1.example.com"}},
+	}
+
+	result, err := ExpandSPF(context.Background(), r, "example.com")
+	if err != nil {
+		t.Fatalf("ExpandSPF() error = %v", err)
+	}
+
+	want := map[string]string{
+		"192.0.2.10":   "example.com TXT → ip4:192.0.2.10",
+		"198.51.100.0": "example.com TXT → ip4:198.51.100.0/30",
+		"198.51.100.1": "example.com TXT → ip4:198.51.100.0/30",
+		"198.51.100.2": "example.com TXT → ip4:198.51.100.0/30",
+		"198.51.100.3": "example.com TXT → ip4:198.51.100.0/30",
+		"192.0.2.1":    "example.com TXT → a:example.com",
+		"192.0.2.25":   "example.com TXT → mx:example.com → mx1.example.com",
+		"192.0.2.50":   "example.com TXT → include:_spf.mail.example.net → _spf.mail.example.net TXT → a:smtp.mail.example.net",
+	}
+	if got := paths(result.IPs); !reflect.DeepEqual(got, want) {
+		t.Errorf("IPs =\n%v\nwant\n%v", got, want)
+	}
+
+	if len(result.Ranges) != 1 || result.Ranges[0].CIDR != "203.0.0.0/16" {
+		t.Errorf("Ranges = %+v, want 203.0.0.0/16", result.Ranges)
+	}
+	if result.Lookups != 4 { // a, mx, include, a
+		t.Errorf("Lookups = %d, want 4", result.Lookups)
+	}
+}
+
+func TestExpandSPF_Redirect(t *testing.T) {
+	r := &fakeResolver{
+		txt: map[string][]string{
+			"example.com":      {"v=spf1 redirect=_spf.example.com"},
+			"_spf.example.com": {"v=spf1 ip4:192.0.2.7 -all"},
+			"other.example":    {"v=spf1 ip4:192.0.2.8 redirect=_spf.example.com ~all"},
+		},
+	}
+
+	result, err := ExpandSPF(context.Background(), r, "example.com")
+	if err != nil {
+		t.Fatalf("ExpandSPF() error = %v", err)
+	}
+	want := map[string]string{"192.0.2.7": "example.com TXT → redirect=_spf.example.com → _spf.example.com TXT → ip4:192.0.2.7"}
+	if got := paths(result.IPs); !reflect.DeepEqual(got, want) {
+		t.Errorf("IPs = %v, want %v", got, want)
+	}
+
+	// redirect= is ignored when the record ends in "all"
+	result, _ = ExpandSPF(context.Background(), r, "other.example")
+	if got := paths(result.IPs); len(got) != 1 || got["192.0.2.8"] == "" {
+		t.Errorf("IPs = %v, want only 192.0.2.8", got)
+	}
+}
+
+func TestExpandSPF_LookupLimit(t *testing.T) {
+	r := &fakeResolver{txt: map[string][]string{}}
+	// A chain of 12 includes, each authorizing one address
+	for i := 0; i < 12; i++ {
+		name := "example.com"
+		if i > 0 {
+			name = fmt.Sprintf("spf%d.example.com", i)
+		}
+		r.txt[name] = []string{fmt.Sprintf("v=spf1 ip4:192.0.2.%d include:spf%d.example.com ~all", i, i+1)}
+	}
+
+	result, err := ExpandSPF(context.Background(), r, "example.com")
+	if !errors.Is(err, ErrSPFLookupLimit) {
+		t.Fatalf("ExpandSPF() error = %v, want ErrSPFLookupLimit", err)
+	}
+	if result.Lookups != MaxSPFLookups {
+		t.Errorf("Lookups = %d, want %d", result.Lookups, MaxSPFLookups)
+	}
+	// The apex record plus the 10 includes the budget allows
+	if len(result.IPs) != MaxSPFLookups+1 {
+		t.Errorf("IPs = %d, want %d partial results", len(result.IPs), MaxSPFLookups+1)
+	}
+	if r.txtHits["spf11.example.com"] != 0 {
+		t.Error("include past the lookup limit was queried")
+	}
+}
+
+func TestExpandSPF_IncludeLoop(t *testing.T) {
+	r := &fakeResolver{
+		txt: map[string][]string{
+			"example.com":   {"v=spf1 include:a.example.com ~all"},
+			"a.example.com": {"v=spf1 ip4:192.0.2.1 include:example.com include:a.example.com ~all"},
+		},
+	}
+
+	result, err := ExpandSPF(context.Background(), r, "example.com")
+	if err != nil {
+		t.Fatalf("ExpandSPF() error = %v", err)
+	}
+	if len(result.IPs) != 1 {
+		t.Errorf("IPs = %v, want one", result.IPs)
+	}
+	if r.txtHits["example.com"] != 1 || r.txtHits["a.example.com"] != 1 {
+		t.Errorf("TXT queries = %v, want each domain once", r.txtHits)
+	}
+}
+
+func TestExpandSPF_SkipsMacrosAndMissingIncludes(t *testing.T) {
+	r := &fakeResolver{
+		txt: map[string][]string{
+			"example.com": {"v=spf1 exists:%{i}._spf.example.com include:missing.example.com ip4:192.0.2.1 ~all"},
+		},
+	}
+
+	result, err := ExpandSPF(context.Background(), r, "example.com")
+	if err != nil {
+		t.Fatalf("ExpandSPF() error = %v", err)
+	}
+	if got := paths(result.IPs); len(got) != 1 || got["192.0.2.1"] == "" {
+		t.Errorf("IPs = %v, want only 192.0.2.1", got)
+	}
+	if r.txtHits["missing.example.com"] != 1 {
+		t.Error("missing include was not looked up")
+	}
+}
+
+func TestExpandSPF_NoRecord(t *testing.T) {
+	r := &fakeResolver{txt: map[string][]string{"example.com": {"v=spf10 not spf", "hello"}}}
+	if _, err := ExpandSPF(context.Background(), r, "example.com"); err == nil || !strings.Contains(err.Error(), "no SPF record") {
+		t.Errorf("ExpandSPF() error = %v, want no SPF record", err)
+	}
+	if _, err := ExpandSPF(context.Background(), r, "missing.example.com"); err == nil {
+		t.Error("ExpandSPF() should fail when the TXT lookup fails")
+	}
+}
+
+func TestSplitTerm(t *testing.T) {
+	tests := []struct {
+		term, name, arg string
+	}{
+		{"ip4:192.0.2.0/24", "ip4", "192.0.2.0/24"},
+		{"include:_spf.example.com", "include", "_spf.example.com"},
+		{"redirect=_spf.example.com", "redirect", "_spf.example.com"},
+		{"a", "a", ""},
+		{"a/24", "a", ""},
+		{"MX:mail.example.com/24", "mx", "mail.example.com/24"},
+	}
+	for _, tt := range tests {
+		name, arg := splitTerm(tt.term)
+		if name != tt.name || arg != tt.arg {
+			t.Errorf("splitTerm(%q) = %q, %q; want %q, %q", tt.term, name, arg, tt.name, tt.arg)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DMARCRecord is a parsed _dmarc TXT record
+type DMARCRecord struct {
+	Policy string   // p= tag (none, quarantine, reject)
+	RUA    []string // Aggregate report addresses, without "mailto:"
+	RUF    []string // Forensic report addresses, without "mailto:"
+}
+
+// ParseDMARC parses a "v=DMARC1" record, reporting false for other records
+func ParseDMARC(record string) (*DMARCRecord, bool) {
+	tags := strings.Split(record, ";")
+	if !strings.EqualFold(strings.ReplaceAll(strings.TrimSpace(tags[0]), " ", ""), "v=DMARC1") {
+		return nil, false
+	}
+
+	dmarc := &DMARCRecord{}
+	for _, tag := range tags[1:] {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "p":
+			dmarc.Policy = strings.ToLower(strings.TrimSpace(value))
+		case "rua":
+			dmarc.RUA = reportAddresses(value)
+		case "ruf":
+			dmarc.RUF = reportAddresses(value)
+		}
+	}
+	return dmarc, true
+}
+
+// reportAddresses extracts the mail addresses of a rua/ruf tag
+// ("mailto:a@example.com!10m,mailto:b@example.net")
+func reportAddresses(value string) []string {
+	var addrs []string
+	for _, uri := range strings.Split(value, ",") {
+		uri = strings.TrimSpace(uri)
+		if len(uri) < 7 || !strings.EqualFold(uri[:7], "mailto:") {
+			continue
+		}
+		addr := uri[7:]
+		if i := strings.IndexByte(addr, '!'); i >= 0 {
+			addr = addr[:i] // Size limit suffix
+		}
+		if strings.Contains(addr, "@") {
+			addrs = append(addrs, strings.ToLower(addr))
+		}
+	}
+	return addrs
+}
+
+// MineDMARC resolves the mail servers of DMARC report mailboxes hosted under
+// the domain itself. Third-party report processors are skipped: their
+// servers say nothing about the target's infrastructure.
+func MineDMARC(ctx context.Context, r Resolver, domain string) ([]RecordIP, *DMARCRecord, error) {
+	name := "_dmarc." + domain
+	records, err := r.LookupTXT(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("TXT lookup failed for %s: %w", name, err)
+	}
+
+	var dmarc *DMARCRecord
+	for _, record := range records {
+		if d, ok := ParseDMARC(record); ok {
+			dmarc = d
+			break
+		}
+	}
+	if dmarc == nil {
+		return nil, nil, fmt.Errorf("no DMARC record for %s", domain)
+	}
+
+	var ips []RecordIP
+	seen := make(map[string]bool)
+	add := func(ip string, path []string) {
+		if !seen[ip] {
+			seen[ip] = true
+			ips = append(ips, RecordIP{IP: ip, Path: path})
+		}
+	}
+
+	tags := []struct {
+		name  string
+		addrs []string
+	}{{"rua", dmarc.RUA}, {"ruf", dmarc.RUF}}
+	for _, tag := range tags {
+		for _, addr := range tag.addrs {
+			mailDomain := addr[strings.LastIndexByte(addr, '@')+1:]
+			if !underDomain(mailDomain, domain) {
+				continue
+			}
+			path := []string{name + " TXT", tag.name + ":" + addr}
+			for _, found := range mailServers(ctx, r, mailDomain) {
+				add(found.IP, extend(path, found.Path...))
+			}
+		}
+	}
+	return ips, dmarc, nil
+}
+
+// mailServers resolves a mail domain's MX hosts, or the domain itself when
+// it has no MX records (RFC 5321 implicit MX)
+func mailServers(ctx context.Context, r Resolver, domain string) []RecordIP {
+	var hosts []string
+	if mxs, err := r.LookupMX(ctx, domain); err == nil && len(mxs) > 0 {
+		for _, mx := range mxs {
+			hosts = append(hosts, strings.TrimSuffix(mx.Host, "."))
+		}
+	} else {
+		hosts = []string{domain}
+	}
+
+	var ips []RecordIP
+	for _, host := range hosts {
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+				ips = append(ips, RecordIP{IP: ip.To4().String(), Path: []string{"mx:" + host}})
+			}
+		}
+	}
+	return ips
+}
+
+// TXTInfo is what the non-SPF TXT records of a domain reveal
+type TXTInfo struct {
+	Verifications []string // Services with domain verification tokens (e.g., "google", "microsoft")
+	IPs           []string // IPv4 literals found in the records
+	Hosts         []string // Hostnames under the domain found in the records
+}
+
+var (
+	// verificationRegex matches "<service>-site-verification=" style tokens
+	verificationRegex = regexp.MustCompile(`(?i)^([a-z0-9][a-z0-9._-]*?)[-_](?:site[-_]|domain[-_])?verification[=:]`)
+
+	// verificationPrefixes are tokens that do not follow the common pattern
+	verificationPrefixes = map[string]string{
+		"ms=":                             "microsoft",
+		"docusign=":                       "docusign",
+		"_globalsign-domain-verification": "globalsign",
+		"webexdomainverification":         "webex",
+		"amazonses:":                      "amazonses",
+		"mandrill_verify.":                "mandrill",
+		"pardot":                          "pardot",
+	}
+
+	ipv4Regex = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	hostRegex = regexp.MustCompile(`(?i)\b[a-z0-9](?:[a-z0-9-]*[a-z0-9])?(?:\.[a-z0-9](?:[a-z0-9-]*[a-z0-9])?)+\b`)
+)
+
+// ParseTXT extracts verification services, IPv4 literals and hostnames under
+// domain from a domain's TXT records. SPF and DMARC records are ignored;
+// ExpandSPF and MineDMARC handle those.
+func ParseTXT(domain string, records []string) *TXTInfo {
+	info := &TXTInfo{}
+	services := make(map[string]bool)
+	ips := make(map[string]bool)
+	hosts := make(map[string]bool)
+
+	for _, record := range records {
+		record = strings.TrimSpace(record)
+		if isSPF(record) {
+			continue
+		}
+		if _, ok := ParseDMARC(record); ok {
+			continue
+		}
+
+		if service := verificationService(record); service != "" {
+			services[service] = true
+			continue // Tokens are opaque; their contents are not hosts
+		}
+
+		for _, m := range ipv4Regex.FindAllString(record, -1) {
+			if ip := net.ParseIP(m); ip != nil && ip.To4() != nil {
+				ips[ip.To4().String()] = true
+			}
+		}
+		for _, m := range hostRegex.FindAllString(record, -1) {
+			if host := strings.ToLower(m); host != strings.ToLower(domain) && underDomain(host, domain) {
+				hosts[host] = true
+			}
+		}
+	}
+
+	info.Verifications = sortedSet(services)
+	info.IPs = sortedSet(ips)
+	info.Hosts = sortedSet(hosts)
+	return info
+}
+
+// verificationService names the service a verification token belongs to,
+// or "" if the record is not one
+func verificationService(record string) string {
+	lower := strings.ToLower(record)
+	for prefix, service := range verificationPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return service
+		}
+	}
+	if m := verificationRegex.FindStringSubmatch(lower); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// MineTXT looks up the domain's TXT records and resolves what they reveal:
+// IPv4 literals directly, and hostnames under the domain through DNS
+func MineTXT(ctx context.Context, r Resolver, domain string) ([]RecordIP, *TXTInfo, error) {
+	records, err := r.LookupTXT(ctx, domain)
+	if err != nil {
+		return nil, nil, fmt.Errorf("TXT lookup failed for %s: %w", domain, err)
+	}
+
+	info := ParseTXT(domain, records)
+	root := domain + " TXT"
+
+	var ips []RecordIP
+	seen := make(map[string]bool)
+	for _, ip := range info.IPs {
+		seen[ip] = true
+		ips = append(ips, RecordIP{IP: ip, Path: []string{root, ip}})
+	}
+	for _, host := range info.Hosts {
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ip := net.ParseIP(addr)
+			if ip == nil || ip.To4() == nil || seen[ip.To4().String()] {
+				continue
+			}
+			seen[ip.To4().String()] = true
+			ips = append(ips, RecordIP{IP: ip.To4().String(), Path: []string{root, "host:" + host}})
+		}
+	}
+	return ips, info, nil
+}
+
+// underDomain reports whether host is domain or one of its subdomains
+func underDomain(host, domain string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// sortedSet returns the members of a string set in order
+func sortedSet(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
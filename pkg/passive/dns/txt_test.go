@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseDMARC(t *testing.T) {
+	d, ok := ParseDMARC("v=DMARC1; p=Reject; rua=mailto:dmarc@example.com!10m, mailto:agg@reports.example.net; ruf=mailto:Forensic@Mail.example.com; pct=100")
+	if !ok {
+		t.Fatal("ParseDMARC() rejected a DMARC record")
+	}
+	if d.Policy != "reject" {
+		t.Errorf("Policy = %q, want reject", d.Policy)
+	}
+	if want := []string{"dmarc@example.com", "agg@reports.example.net"}; !reflect.DeepEqual(d.RUA, want) {
+		t.Errorf("RUA = %v, want %v", d.RUA, want)
+	}
+	if want := []string{"forensic@mail.example.com"}; !reflect.DeepEqual(d.RUF, want) {
+		t.Errorf("RUF = %v, want %v", d.RUF, want)
+	}
+
+	for _, record := range []string{"v=spf1 -all", "google-site-verification=abc", ""} {
+		if _, ok := ParseDMARC(record); ok {
+			t.Errorf("ParseDMARC(%q) accepted a non-DMARC record", record)
+		}
+	}
+}
+
+func TestMineDMARC(t *testing.T) {
+	r := &fakeResolver{
+		txt: map[string][]string{
+			"_dmarc.example.com": {"v=DMARC1; p=none; rua=mailto:dmarc@example.com,mailto:x@dmarc-vendor.example; ruf=mailto:f@mail.example.com"},
+		},
+		mx: map[string][]string{"example.com": {"mx1.example.com"}},
+		hosts: map[string][]string{
+			"mx1.example.com":      {"192.0.2.25"},
+			"mail.example.com":     {"192.0.2.26"},
+			"dmarc-vendor.example": {"198.51.100.1"},
+		},
+	}
+
+	ips, dmarc, err := MineDMARC(context.Background(), r, "example.com")
+	if err != nil {
+		t.Fatalf("MineDMARC() error = %v", err)
+	}
+	if dmarc.Policy != "none" {
+		t.Errorf("Policy = %q, want none", dmarc.Policy)
+	}
+
+	want := map[string]string{
+		"192.0.2.25": "_dmarc.example.com TXT → rua:dmarc@example.com → mx:mx1.example.com",
+		// mail.example.com has no MX: its own address receives mail
+		"192.0.2.26": "_dmarc.example.com TXT → ruf:f@mail.example.com → mx:mail.example.com",
+	}
+	if got := paths(ips); !reflect.DeepEqual(got, want) {
+		t.Errorf("IPs = %v, want %v (third-party receivers skipped)", got, want)
+	}
+
+	if _, _, err := MineDMARC(context.Background(), r, "example.net"); err == nil {
+		t.Error("MineDMARC() should fail without a DMARC record")
+	}
+}
+
+func TestParseTXT(t *testing.T) {
+	records := []string{
+		"v=spf1 ip4:192.0.2.99 -all",
+		"google-site-verification=rXOxyZounnZasA8Z7oaD3c14JdjS9aKSWvsR1EbUSIQ",
+		"MS=ms12345678",
+		"atlassian-domain-verification=abc",
+		"facebook-domain-verification=xyz",
+		"_globalsign-domain-verification=123",
+		"origin=203.0.113.7 backup=203.0.113.8 bad=999.1.1.1",
+		"cdn-bypass origin.example.com staging.EXAMPLE.com other.example.net example.com",
+	}
+
+	info := ParseTXT("example.com", records)
+	if want := []string{"atlassian", "facebook", "globalsign", "google", "microsoft"}; !reflect.DeepEqual(info.Verifications, want) {
+		t.Errorf("Verifications = %v, want %v", info.Verifications, want)
+	}
+	if want := []string{"203.0.113.7", "203.0.113.8"}; !reflect.DeepEqual(info.IPs, want) {
+		t.Errorf("IPs = %v, want %v (SPF record ignored)", info.IPs, want)
+	}
+	if want := []string{"origin.example.com", "staging.example.com"}; !reflect.DeepEqual(info.Hosts, want) {
+		t.Errorf("Hosts = %v, want %v", info.Hosts, want)
+	}
+}
+
+func TestMineTXT(t *testing.T) {
+	r := &fakeResolver{
+		txt: map[string][]string{
+			"example.com": {"stripe-verification=abc", "origin=203.0.113.7", "legacy host origin.example.com"},
+		},
+		hosts: map[string][]string{"origin.example.com": {"203.0.113.7", "203.0.113.9"}},
+	}
+
+	ips, info, err := MineTXT(context.Background(), r, "example.com")
+	if err != nil {
+		t.Fatalf("MineTXT() error = %v", err)
+	}
+	want := map[string]string{
+		"203.0.113.7": "example.com TXT → 203.0.113.7",
+		"203.0.113.9": "example.com TXT → host:origin.example.com",
+	}
+	if got := paths(ips); !reflect.DeepEqual(got, want) {
+		t.Errorf("IPs = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(info.Verifications, []string{"stripe"}) {
+		t.Errorf("Verifications = %v, want [stripe]", info.Verifications)
+	}
+}
+
+func TestUnderDomain(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"Mail.Example.com.", true},
+		{"a.b.example.com", true},
+		{"notexample.com", false},
+		{"example.com.evil.net", false},
+	}
+	for _, tt := range tests {
+		if got := underDomain(tt.host, "example.com"); got != tt.want {
+			t.Errorf("underDomain(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
@@ -5,13 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
 )
 
+// DefaultBaseURL is the DNSDumpster API base URL
+const DefaultBaseURL = "https://api.dnsdumpster.com"
+
 // DNSDumpsterResponse represents the API response structure
 type DNSDumpsterResponse struct {
 	A      []DNSRecord `json:"a"`
@@ -47,43 +52,32 @@ func SearchDomain(ctx context.Context, domain string, apiKeys []string, timeout
 		return []string{}, fmt.Errorf("no DNSDumpster API keys provided")
 	}
 
-	// Try each API key until one works
-	var lastErr error
-	for i, apiKey := range apiKeys {
-		apiKey = strings.TrimSpace(apiKey)
-		if apiKey == "" {
-			continue
-		}
-
-		ips, err := searchWithKey(ctx, domain, apiKey, timeout)
-		if err == nil {
-			return ips, nil
-		}
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceDNSDumpster, apiKeys)
 
-		// Check for rate limit errors
-		if strings.Contains(err.Error(), "rate limit") || strings.Contains(err.Error(), "429") {
-			lastErr = fmt.Errorf("key %d/%d rate limited: %w", i+1, len(apiKeys), err)
-			continue // Try next key
-		}
-
-		// For other errors, return immediately
-		return []string{}, fmt.Errorf("key %d/%d failed: %w", i+1, len(apiKeys), err)
-	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return search(ctx, manager, httpclient.Default(), DefaultBaseURL, domain)
+}
 
-	if lastErr != nil {
-		return []string{}, fmt.Errorf("all %d API keys exhausted: %w", len(apiKeys), lastErr)
+// search runs the query through the manager's key rotation
+// Rate limited keys are cooled down and the next key is tried
+func search(ctx context.Context, manager *api.Manager, client *httpclient.Client, baseURL, domain string) ([]string, error) {
+	var ips []string
+	err := manager.Do(ctx, api.SourceDNSDumpster, func(apiKey string) error {
+		var err error
+		ips, err = searchWithKey(ctx, client, baseURL, domain, apiKey)
+		return err
+	})
+	if err != nil {
+		return []string{}, err
 	}
-
-	return []string{}, fmt.Errorf("no valid API keys found")
+	return ips, nil
 }
 
 // searchWithKey performs the search with a single API key
-func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Duration) ([]string, error) {
-	url := fmt.Sprintf("https://api.dnsdumpster.com/domain/%s", domain)
-
-	client := &http.Client{
-		Timeout: timeout,
-	}
+func searchWithKey(ctx context.Context, client *httpclient.Client, baseURL, domain, apiKey string) ([]string, error) {
+	url := fmt.Sprintf("%s/domain/%s", baseURL, domain)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -92,24 +86,23 @@ func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Dura
 
 	// DNSDumpster uses X-API-Key header
 	req.Header.Set("X-API-Key", apiKey)
-	req.Header.Set("User-Agent", "origindive/1.0")
 
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("DNSDumpster request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
 		// Try to parse error from JSON
 		var errResp DNSDumpsterResponse
-		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		json.Unmarshal(body, &errResp)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, api.NewRateLimitError(resp.Response, errResp.Error)
+		}
+		if errResp.Error != "" {
 			return nil, fmt.Errorf("DNSDumpster API error (HTTP %d): %s", resp.StatusCode, errResp.Error)
 		}
 
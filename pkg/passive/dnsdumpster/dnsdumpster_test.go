@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
 )
 
 func TestSearchDomain_NoAPIKeys(t *testing.T) {
@@ -95,7 +97,7 @@ func TestSearchWithKey_HTTPError(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "invalid_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), server.URL, "example.com", "invalid_key")
 	if err == nil {
 		t.Log("Expected error for invalid key")
 	}
@@ -109,7 +111,7 @@ func TestSearchWithKey_InvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Expected JSON parsing error")
 	}
@@ -125,7 +127,7 @@ func TestSearchWithKey_APIError(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Expected API error")
 	}
@@ -232,7 +234,7 @@ func TestSearchWithKey_MultipleRecordTypes(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Multiple record types test completed")
 	}
@@ -252,7 +254,7 @@ func TestSearchWithKey_DuplicateIPs(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Duplicate IP deduplication test completed")
 	}
@@ -289,7 +291,7 @@ func TestSearchWithKey_LongErrorBody(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Expected error for bad request")
 	}
@@ -328,7 +330,7 @@ func TestSearchWithKey_EmptyIPLists(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Empty IP lists test completed")
 	}
@@ -354,7 +356,7 @@ func TestSearchWithKey_IPv6InResults(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("IPv6 AAAA records test completed")
 	}
@@ -0,0 +1,71 @@
+package dnsdumpster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+// SourceName is the registry name of the DNSDumpster source
+const SourceName = "dnsdumpster"
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "DNSDumpster DNS records",
+		Credentials: []string{"dnsdumpster_keys"},
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			if len(opts.Config.DNSDumpsterKeys) == 0 {
+				return nil, passive.MissingCredentials(SourceName, "dnsdumpster_keys")
+			}
+			return &Source{
+				manager: opts.KeyManager(api.SourceDNSDumpster, opts.Config.DNSDumpsterKeys),
+				client:  opts.HTTPClient(),
+				baseURL: opts.BaseURL(SourceName, DefaultBaseURL),
+				timeout: opts.Timeout,
+			}, nil
+		},
+	})
+}
+
+// Source implements passive.Source for DNSDumpster
+type Source struct {
+	manager *api.Manager
+	client  *httpclient.Client
+	baseURL string
+	timeout time.Duration
+}
+
+// NewSource creates a DNSDumpster source using the given API keys
+// An empty baseURL uses DefaultBaseURL
+func NewSource(keys []string, baseURL string, timeout time.Duration) *Source {
+	return &Source{
+		manager: passive.Options{}.KeyManager(api.SourceDNSDumpster, keys),
+		client:  httpclient.Default(),
+		baseURL: passive.Endpoint(baseURL, DefaultBaseURL),
+		timeout: timeout,
+	}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns the config keys the source needs
+func (s *Source) RequiredCredentials() []string { return []string{"dnsdumpster_keys"} }
+
+// Search queries DNSDumpster for IPs related to the domain
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ips, err := search(ctx, s.manager, s.client, s.baseURL, domain)
+	if err != nil {
+		return nil, fmt.Errorf("DNSDumpster search failed: %w", err)
+	}
+	return passive.NewIPs(SourceName, ips, time.Now()), nil
+}
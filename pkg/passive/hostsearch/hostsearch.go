@@ -0,0 +1,250 @@
+// Package hostsearch holds what the host search engine sources (Shodan,
+// Hunter.how, Censys) share: paging through queries, merging hits by IP
+// into passive results, and telling real files from HTML fallback pages
+package hostsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+)
+
+// Query is one search derived from the target
+type Query struct {
+	Name  string // Short label recorded in result metadata
+	Query string
+}
+
+// Limits bounds the requests Run makes
+type Limits struct {
+	MaxPages int // Pages per query (0 = no limit)
+	Credits  int // Pages across all queries (0 = no limit)
+}
+
+// PageFunc fetches page (1-based) of q and adds its hits to a HostSet
+// more reports whether another page follows.
+type PageFunc func(ctx context.Context, q Query, page int) (more bool, err error)
+
+// Run pages through every query breadth-first (page 1 of every query before
+// page 2 of any), so a small budget still covers all queries. A query that
+// fails is dropped; a cancelled context, a rate limit or an error for which
+// fatal returns true ends the run. The first error is returned only when no
+// page succeeded, so partial results are kept.
+func Run(ctx context.Context, queries []Query, limits Limits, fetch PageFunc, fatal func(error) bool) error {
+	next := make([]int, len(queries)) // Next page per query (0 = done)
+	for i := range next {
+		next[i] = 1
+	}
+	credits := limits.Credits
+
+	var firstErr error
+	succeeded := false
+	for {
+		progressed := false
+		for i, q := range queries {
+			if next[i] == 0 || (limits.Credits > 0 && credits == 0) {
+				continue
+			}
+			progressed = true
+			credits--
+
+			more, err := fetch(ctx, q, next[i])
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", q.Name, err)
+				}
+				if ctx.Err() != nil || api.IsRateLimitError(err) || (fatal != nil && fatal(err)) {
+					return result(succeeded, firstErr)
+				}
+				next[i] = 0
+				continue
+			}
+
+			succeeded = true
+			if !more || (limits.MaxPages > 0 && next[i] >= limits.MaxPages) {
+				next[i] = 0
+			} else {
+				next[i]++
+			}
+		}
+		if !progressed {
+			return result(succeeded, firstErr)
+		}
+	}
+}
+
+// result returns err when no page succeeded
+func result(succeeded bool, err error) error {
+	if succeeded {
+		return nil
+	}
+	return err
+}
+
+// IsFatal returns a fatal func for Run that matches any of errs
+func IsFatal(errs ...error) func(error) bool {
+	return func(err error) bool {
+		for _, target := range errs {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Host aggregates every hit a search engine returned for one IP
+type Host struct {
+	ports     map[int]bool
+	queries   map[string]bool
+	lists     map[string]map[string]bool // Metadata key -> set of values
+	fields    map[string]string          // Metadata key -> first non-empty value
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// AddPort records an open port
+func (h *Host) AddPort(port int) {
+	if port > 0 {
+		h.ports[port] = true
+	}
+}
+
+// AddValue adds value to the sorted list stored under key (e.g. "hostnames")
+func (h *Host) AddValue(key, value string) {
+	if value == "" {
+		return
+	}
+	if h.lists[key] == nil {
+		h.lists[key] = make(map[string]bool)
+	}
+	h.lists[key][value] = true
+}
+
+// SetField stores value under key unless an earlier hit already set it
+func (h *Host) SetField(key, value string) {
+	if _, set := h.fields[key]; !set && value != "" {
+		h.fields[key] = value
+	}
+}
+
+// Seen widens the host's first/last seen window to include t
+// The zero time is ignored.
+func (h *Host) Seen(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if h.firstSeen.IsZero() || t.Before(h.firstSeen) {
+		h.firstSeen = t
+	}
+	if t.After(h.lastSeen) {
+		h.lastSeen = t
+	}
+}
+
+// HostSet collects hits by IP across queries and pages
+type HostSet struct {
+	source     string // Source name recorded on every result
+	queriesKey string // Metadata key listing the queries that found a host
+	hosts      map[string]*Host
+}
+
+// NewHostSet creates an empty set for the named source
+// queriesKey is the metadata key of the query labels, e.g. "shodan_queries".
+func NewHostSet(source, queriesKey string) *HostSet {
+	return &HostSet{source: source, queriesKey: queriesKey, hosts: make(map[string]*Host)}
+}
+
+// Add returns the host for ip, noting that the labeled query found it
+// Returns nil for anything but a valid IPv4 address.
+func (s *HostSet) Add(ip, query string) *Host {
+	ip = strings.TrimSpace(ip)
+
+	// Validate and filter IPv4 only (To4() returns nil for IPv6)
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil || parsedIP.To4() == nil {
+		return nil
+	}
+
+	h, ok := s.hosts[ip]
+	if !ok {
+		h = &Host{
+			ports:   make(map[int]bool),
+			queries: make(map[string]bool),
+			lists:   make(map[string]map[string]bool),
+			fields:  make(map[string]string),
+		}
+		s.hosts[ip] = h
+	}
+	h.queries[query] = true
+	return h
+}
+
+// Len returns the number of hosts collected
+func (s *HostSet) Len() int {
+	return len(s.hosts)
+}
+
+// Records converts the collected hosts into passive IPs sorted by address
+// Hosts never given a seen time are stamped with now.
+func (s *HostSet) Records(now time.Time) []core.PassiveIP {
+	records := make([]core.PassiveIP, 0, len(s.hosts))
+	for ip, h := range s.hosts {
+		rec := core.PassiveIP{
+			IP:        ip,
+			Source:    s.source,
+			FirstSeen: h.firstSeen,
+			LastSeen:  h.lastSeen,
+			Metadata: map[string]interface{}{
+				s.queriesKey: sortedKeys(h.queries),
+			},
+		}
+		if rec.FirstSeen.IsZero() {
+			rec.FirstSeen, rec.LastSeen = now, now
+		}
+
+		if len(h.ports) > 0 {
+			ports := make([]int, 0, len(h.ports))
+			for port := range h.ports {
+				ports = append(ports, port)
+			}
+			sort.Ints(ports)
+			rec.Metadata["ports"] = ports
+		}
+		for key, set := range h.lists {
+			rec.Metadata[key] = sortedKeys(set)
+		}
+		for key, value := range h.fields {
+			rec.Metadata[key] = value
+		}
+		records = append(records, rec)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].IP < records[j].IP })
+	return records
+}
+
+// sortedKeys returns the keys of a string set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LooksLikeHTML reports whether a file response (e.g. /favicon.ico) is
+// really an HTML page, as served by sites that answer every path with
+// their front page
+func LooksLikeHTML(data []byte) bool {
+	head := strings.ToLower(strings.TrimSpace(string(data[:min(len(data), 512)])))
+	return strings.HasPrefix(head, "<!doctype html") || strings.HasPrefix(head, "<html")
+}
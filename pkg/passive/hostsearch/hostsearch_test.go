@@ -0,0 +1,147 @@
+package hostsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/api"
+)
+
+var testQueries = []Query{{Name: "a", Query: "qa"}, {Name: "b", Query: "qb"}}
+
+func TestRun_BreadthFirstWithinCredits(t *testing.T) {
+	var pages []string
+	fetch := func(ctx context.Context, q Query, page int) (bool, error) {
+		pages = append(pages, fmt.Sprintf("%s#%d", q.Name, page))
+		return true, nil
+	}
+
+	if err := Run(context.Background(), testQueries, Limits{Credits: 3}, fetch, nil); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := []string{"a#1", "b#1", "a#2"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestRun_MaxPagesAndLastPage(t *testing.T) {
+	var pages []string
+	fetch := func(ctx context.Context, q Query, page int) (bool, error) {
+		pages = append(pages, fmt.Sprintf("%s#%d", q.Name, page))
+		return q.Name == "a", nil // b has a single page
+	}
+
+	if err := Run(context.Background(), testQueries, Limits{MaxPages: 3}, fetch, nil); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := []string{"a#1", "b#1", "a#2", "a#3"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	errDenied := errors.New("denied")
+	errBadFilter := errors.New("bad filter")
+
+	// A failed query is dropped; the others keep their results
+	var calls int
+	err := Run(context.Background(), testQueries, Limits{MaxPages: 2}, func(ctx context.Context, q Query, page int) (bool, error) {
+		calls++
+		if q.Name == "a" {
+			return false, errBadFilter
+		}
+		return true, nil
+	}, nil)
+	if err != nil || calls != 3 {
+		t.Errorf("Run() = %v after %d calls, want partial results after 3", err, calls)
+	}
+
+	// A fatal error ends the run and is returned when nothing succeeded
+	calls = 0
+	err = Run(context.Background(), testQueries, Limits{MaxPages: 2}, func(ctx context.Context, q Query, page int) (bool, error) {
+		calls++
+		return false, errDenied
+	}, IsFatal(errDenied))
+	if !errors.Is(err, errDenied) || calls != 1 {
+		t.Errorf("Run() = %v after %d calls, want errDenied after 1", err, calls)
+	}
+
+	// So does a rate limit
+	calls = 0
+	err = Run(context.Background(), testQueries, Limits{MaxPages: 2}, func(ctx context.Context, q Query, page int) (bool, error) {
+		calls++
+		return false, &api.RateLimitError{StatusCode: 429, Message: "slow down"}
+	}, nil)
+	if err == nil || calls != 1 {
+		t.Errorf("Run() = %v after %d calls, want a rate limit error after 1", err, calls)
+	}
+}
+
+func TestHostSet_Records(t *testing.T) {
+	set := NewHostSet("engine", "engine_queries")
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	h := set.Add(" 192.0.2.1 ", "cert")
+	h.AddPort(443)
+	h.AddValue("hostnames", "www.example.com")
+	h.SetField("asn", "AS64500")
+	h.Seen(now.AddDate(0, -2, 0))
+
+	h = set.Add("192.0.2.1", "hostname")
+	h.AddPort(80)
+	h.AddPort(443)
+	h.AddValue("hostnames", "api.example.com")
+	h.SetField("asn", "AS64999") // First value wins
+	h.SetField("organization", "")
+	h.Seen(now.AddDate(0, -1, 0))
+	h.Seen(time.Time{})
+
+	set.Add("192.0.2.9", "cert")
+	for _, ip := range []string{"2001:db8::1", "not-an-ip", ""} {
+		if set.Add(ip, "cert") != nil {
+			t.Errorf("Add(%q) should return nil", ip)
+		}
+	}
+	if set.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", set.Len())
+	}
+
+	records := set.Records(now)
+	if len(records) != 2 || records[0].IP != "192.0.2.1" || records[1].IP != "192.0.2.9" {
+		t.Fatalf("Records() = %+v", records)
+	}
+
+	want := map[string]interface{}{
+		"engine_queries": []string{"cert", "hostname"},
+		"ports":          []int{80, 443},
+		"hostnames":      []string{"api.example.com", "www.example.com"},
+		"asn":            "AS64500",
+	}
+	if rec := records[0]; rec.Source != "engine" || !reflect.DeepEqual(rec.Metadata, want) {
+		t.Errorf("Records()[0] = %s %v, want %v", rec.Source, rec.Metadata, want)
+	}
+	if rec := records[0]; !rec.FirstSeen.Equal(now.AddDate(0, -2, 0)) || !rec.LastSeen.Equal(now.AddDate(0, -1, 0)) {
+		t.Errorf("seen = %v..%v", rec.FirstSeen, rec.LastSeen)
+	}
+	if rec := records[1]; !rec.FirstSeen.Equal(now) || !rec.LastSeen.Equal(now) {
+		t.Errorf("unseen host = %v..%v, want now", rec.FirstSeen, rec.LastSeen)
+	}
+}
+
+func TestLooksLikeHTML(t *testing.T) {
+	tests := map[string]bool{
+		"\n  <!DOCTYPE html><html>": true,
+		"<html lang=en>":            true,
+		"\x00\x00\x01\x00":          false,
+		"":                          false,
+	}
+	for data, want := range tests {
+		if got := LooksLikeHTML([]byte(data)); got != want {
+			t.Errorf("LooksLikeHTML(%q) = %v, want %v", data, got, want)
+		}
+	}
+}
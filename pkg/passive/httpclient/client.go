@@ -0,0 +1,305 @@
+// Package httpclient provides the HTTP layer shared by passive sources:
+// retries with jitter on 5xx/429 (honoring Retry-After), per-source
+// concurrency limits, a configurable User-Agent, optional proxy routing
+// and response size caps
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jhaxce/origindive/internal/version"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/proxy"
+)
+
+const (
+	// DefaultTimeout bounds a single request attempt
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultMaxRetries is how many times a 5xx/429 response is retried
+	DefaultMaxRetries = 2
+
+	// DefaultRetryWait is the base backoff before the first retry
+	// It doubles on every attempt and gets up to 50% jitter added
+	DefaultRetryWait = 1 * time.Second
+
+	// DefaultMaxRetryAfter is the longest Retry-After that is waited out
+	// Longer waits are returned to the caller (e.g., to rotate API keys)
+	DefaultMaxRetryAfter = 30 * time.Second
+
+	// DefaultMaxBodySize caps how much of a response body is read
+	DefaultMaxBodySize = 32 << 20 // 32 MiB (crt.sh answers can be large)
+
+	// DefaultConcurrency is the in-flight request limit per source
+	DefaultConcurrency = 4
+)
+
+// DefaultUserAgent identifies origindive to passive APIs
+var DefaultUserAgent = version.AppName + "/" + version.Version
+
+// SourceConcurrency holds per-source in-flight limits that differ from
+// DefaultConcurrency (free tiers that reject parallel requests)
+var SourceConcurrency = map[string]int{
+	"virustotal":  1, // 4 requests/minute on free keys
+	"dnsdumpster": 1, // 1 request every 2 seconds
+	"viewdns":     1,
+	"ct":          2, // crt.sh drops aggressive clients
+}
+
+// ErrBodyTooLarge is returned when a response exceeds MaxBodySize
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// Config configures a Client; zero values use the package defaults
+type Config struct {
+	UserAgent     string         // User-Agent header (default: DefaultUserAgent)
+	ProxyURL      string         // Optional proxy (http://, https://, socks5://)
+	Timeout       time.Duration  // Per-attempt timeout
+	MaxRetries    int            // Retries on 5xx/429 (negative = none)
+	RetryWait     time.Duration  // Base backoff between retries
+	MaxRetryAfter time.Duration  // Longest Retry-After honored by retrying
+	MaxBodySize   int64          // Response body cap in bytes
+	Concurrency   map[string]int // Per-source in-flight limits (merged over SourceConcurrency)
+}
+
+// Response is an HTTP response whose body has already been read
+// The embedded Response's Body is closed; use Data instead
+type Response struct {
+	*http.Response
+	Data []byte
+}
+
+// Client sends passive source requests
+// It is safe for concurrent use by multiple sources
+type Client struct {
+	http          *http.Client
+	userAgent     string
+	timeout       time.Duration
+	maxRetries    int
+	retryWait     time.Duration
+	maxRetryAfter time.Duration
+	maxBodySize   int64
+	limits        map[string]int
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+
+	sleep func(ctx context.Context, d time.Duration) bool // Replaced in tests
+}
+
+// New creates a client from cfg
+// Returns an error if the proxy URL is invalid
+func New(cfg Config) (*Client, error) {
+	c := &Client{
+		http:          &http.Client{},
+		userAgent:     cfg.UserAgent,
+		timeout:       cfg.Timeout,
+		maxRetries:    cfg.MaxRetries,
+		retryWait:     cfg.RetryWait,
+		maxRetryAfter: cfg.MaxRetryAfter,
+		maxBodySize:   cfg.MaxBodySize,
+		limits:        make(map[string]int),
+		sems:          make(map[string]chan struct{}),
+		sleep:         sleepContext,
+	}
+
+	if c.userAgent == "" {
+		c.userAgent = DefaultUserAgent
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = DefaultMaxRetries
+	}
+	if c.maxRetries < 0 {
+		c.maxRetries = 0
+	}
+	if c.retryWait <= 0 {
+		c.retryWait = DefaultRetryWait
+	}
+	if c.maxRetryAfter <= 0 {
+		c.maxRetryAfter = DefaultMaxRetryAfter
+	}
+	if c.maxBodySize <= 0 {
+		c.maxBodySize = DefaultMaxBodySize
+	}
+	for source, n := range SourceConcurrency {
+		c.limits[source] = n
+	}
+	for source, n := range cfg.Concurrency {
+		c.limits[strings.ToLower(source)] = n
+	}
+
+	if cfg.ProxyURL != "" {
+		p, err := proxy.ParseProxy(cfg.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		proxied, err := p.GetHTTPClient(c.timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.http.Transport = proxied.Transport
+	}
+
+	return c, nil
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultClient *Client
+)
+
+// Default returns a shared direct client with the package defaults
+// Used by sources created without an explicit client
+func Default() *Client {
+	defaultOnce.Do(func() {
+		defaultClient, _ = New(Config{}) // No proxy: cannot fail
+	})
+	return defaultClient
+}
+
+// UserAgent returns the User-Agent sent with every request
+func (c *Client) UserAgent() string {
+	return c.userAgent
+}
+
+// Do sends req on behalf of source and reads the response body
+// 5xx and 429 responses are retried with exponential backoff and jitter;
+// a Retry-After header is honored when it is no longer than MaxRetryAfter,
+// otherwise the response is returned so the caller can rotate keys. The
+// last response is returned (not an error) when retries are exhausted.
+// req must be replayable: requests built with a bytes.Buffer, bytes.Reader
+// or strings.Reader body (or no body) are.
+func (c *Client) Do(ctx context.Context, source string, req *http.Request) (*Response, error) {
+	release, err := c.acquire(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !retryable(resp.StatusCode) || attempt >= c.maxRetries {
+			return resp, nil
+		}
+
+		wait, ok := c.backoff(resp, attempt)
+		if !ok {
+			return resp, nil // Retry-After too long: let the caller decide
+		}
+		if !c.sleep(ctx, wait) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt sends one copy of req bounded by the per-attempt timeout
+func (c *Client) attempt(ctx context.Context, req *http.Request) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	r := req.Clone(ctx)
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay request body: %w", err)
+		}
+		r.Body = body
+	}
+
+	resp, err := c.http.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(data)) > c.maxBodySize {
+		return nil, fmt.Errorf("%w: more than %d bytes from %s", ErrBodyTooLarge, c.maxBodySize, req.URL.Host)
+	}
+
+	return &Response{Response: resp, Data: data}, nil
+}
+
+// backoff returns how long to wait before retrying after resp
+// ok is false when the server asks for a longer wait than MaxRetryAfter
+func (c *Client) backoff(resp *Response, attempt int) (wait time.Duration, ok bool) {
+	if after := api.RetryAfter(resp.Response); after > 0 {
+		if after > c.maxRetryAfter {
+			return 0, false
+		}
+		return after, true
+	}
+
+	wait = c.retryWait << attempt
+	if half := int64(wait / 2); half > 0 {
+		wait += time.Duration(rand.Int63n(half))
+	}
+	return wait, true
+}
+
+// acquire takes a slot in the source's concurrency limit
+func (c *Client) acquire(ctx context.Context, source string) (func(), error) {
+	sem := c.semaphore(strings.ToLower(source))
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// semaphore returns the source's concurrency semaphore, creating it once
+func (c *Client) semaphore(source string) chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sem, ok := c.sems[source]
+	if !ok {
+		limit := c.limits[source]
+		if limit <= 0 {
+			limit = DefaultConcurrency
+		}
+		sem = make(chan struct{}, limit)
+		c.sems[source] = sem
+	}
+	return sem
+}
+
+// retryable reports whether a status code is worth retrying
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// sleepContext waits for d or until ctx is done
+// Returns false if the context ended first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,275 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client whose retry sleeps are recorded, not slept
+func newTestClient(t *testing.T, cfg Config) (*Client, *[]time.Duration) {
+	t.Helper()
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) bool {
+		waits = append(waits, d)
+		return ctx.Err() == nil
+	}
+	return c, &waits
+}
+
+func get(t *testing.T, url string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestDo_DefaultUserAgent(t *testing.T) {
+	var ua string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua = r.Header.Get("User-Agent")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c, _ := newTestClient(t, Config{})
+	resp, err := c.Do(context.Background(), "ct", get(t, server.URL))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if ua != DefaultUserAgent || !strings.HasPrefix(ua, "origindive/") {
+		t.Errorf("User-Agent = %q, want %q", ua, DefaultUserAgent)
+	}
+	if string(resp.Data) != "ok" {
+		t.Errorf("Data = %q, want ok", resp.Data)
+	}
+
+	// A configured User-Agent replaces the default
+	c, _ = newTestClient(t, Config{UserAgent: "recon-team/2"})
+	c.Do(context.Background(), "ct", get(t, server.URL))
+	if ua != "recon-team/2" {
+		t.Errorf("User-Agent = %q, want recon-team/2", ua)
+	}
+}
+
+func TestDo_RetriesServerErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("recovered"))
+	}))
+	defer server.Close()
+
+	c, waits := newTestClient(t, Config{RetryWait: 100 * time.Millisecond})
+	resp, err := c.Do(context.Background(), "ct", get(t, server.URL))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Errorf("status = %d after %d calls, want 200 after 3", resp.StatusCode, calls)
+	}
+
+	// Exponential backoff with up to 50% jitter
+	if len(*waits) != 2 {
+		t.Fatalf("waits = %v, want 2", *waits)
+	}
+	for i, base := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+		if w := (*waits)[i]; w < base || w >= base+base/2 {
+			t.Errorf("wait[%d] = %v, want [%v, %v)", i, w, base, base+base/2)
+		}
+	}
+}
+
+func TestDo_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c, _ := newTestClient(t, Config{MaxRetries: 1})
+	resp, err := c.Do(context.Background(), "ct", get(t, server.URL))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || calls != 2 {
+		t.Errorf("status = %d after %d calls, want 503 after 2", resp.StatusCode, calls)
+	}
+
+	// Negative MaxRetries disables retries
+	calls = 0
+	c, _ = newTestClient(t, Config{MaxRetries: -1})
+	c.Do(context.Background(), "ct", get(t, server.URL))
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 without retries", calls)
+	}
+}
+
+func TestDo_RetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c, waits := newTestClient(t, Config{})
+	resp, err := c.Do(context.Background(), "shodan", get(t, server.URL))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Do() = %v, %v; want 200", resp, err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 3*time.Second {
+		t.Errorf("waits = %v, want [3s]", *waits)
+	}
+}
+
+func TestDo_LongRetryAfterReturned(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	c, waits := newTestClient(t, Config{})
+	resp, err := c.Do(context.Background(), "shodan", get(t, server.URL))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || calls != 1 || len(*waits) != 0 {
+		t.Errorf("status = %d, calls = %d, waits = %v; want 429 returned without retry", resp.StatusCode, calls, *waits)
+	}
+}
+
+func TestDo_ReplaysBody(t *testing.T) {
+	var bodies []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(data))
+		n := len(bodies)
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, bytes.NewBufferString(`{"q":"x"}`))
+	c, _ := newTestClient(t, Config{})
+	if _, err := c.Do(context.Background(), "censys", req); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if len(bodies) != 2 || bodies[0] != `{"q":"x"}` || bodies[1] != bodies[0] {
+		t.Errorf("bodies = %q, want the same body twice", bodies)
+	}
+}
+
+func TestDo_BodyCap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("a"), 2048))
+	}))
+	defer server.Close()
+
+	c, _ := newTestClient(t, Config{MaxBodySize: 1024})
+	_, err := c.Do(context.Background(), "ct", get(t, server.URL))
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Do() error = %v, want ErrBodyTooLarge", err)
+	}
+
+	c, _ = newTestClient(t, Config{MaxBodySize: 2048})
+	if resp, err := c.Do(context.Background(), "ct", get(t, server.URL)); err != nil || len(resp.Data) != 2048 {
+		t.Errorf("Do() at the cap = %v, want full body", err)
+	}
+}
+
+func TestDo_ConcurrencyLimit(t *testing.T) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer server.Close()
+
+	c, _ := newTestClient(t, Config{Concurrency: map[string]int{"Shodan": 2}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Do(context.Background(), "shodan", get(t, server.URL))
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("peak in-flight = %d, want <= 2", peak)
+	}
+}
+
+func TestDo_ContextCancelledWhileWaiting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c, err := New(Config{RetryWait: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.Do(ctx, "ct", get(t, server.URL)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Do() did not stop waiting when the context ended")
+	}
+}
+
+func TestNew_Proxy(t *testing.T) {
+	if _, err := New(Config{ProxyURL: "ftp://127.0.0.1"}); err == nil {
+		t.Error("New() with unsupported proxy scheme should fail")
+	}
+	c, err := New(Config{ProxyURL: "http://127.0.0.1:8080"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if c.http.Transport == nil {
+		t.Error("Expected proxy transport")
+	}
+}
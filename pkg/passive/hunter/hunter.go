@@ -0,0 +1,261 @@
+// Package hunter provides Hunter.how API integration for passive reconnaissance
+package hunter
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/hostsearch"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+// DefaultBaseURL is the Hunter.how API base URL
+const DefaultBaseURL = "https://api.hunter.how"
+
+const (
+	// DefaultMaxPages is how many result pages are fetched per query
+	// Hunter.how bills API pulls per returned row, so this caps the cost
+	DefaultMaxPages = 3
+
+	// pageSize is the number of rows requested per page (API maximum)
+	pageSize = 100
+
+	// lookback is the search window; Hunter.how requires start/end dates
+	lookback = 365 * 24 * time.Hour
+)
+
+// errUnauthorized stops the search: no other query can succeed with the key
+var errUnauthorized = errors.New("Hunter rejected the API key")
+
+// HunterResponse represents the JSON response from the Hunter.how search API
+type HunterResponse struct {
+	Code    int        `json:"code"` // 200 on success; errors mirror HTTP codes
+	Message string     `json:"message"`
+	Data    HunterData `json:"data"`
+}
+
+// HunterData contains the search results
+type HunterData struct {
+	Total int           `json:"total"`
+	List  []HunterAsset `json:"list"`
+}
+
+// HunterAsset represents a single service result
+type HunterAsset struct {
+	IP        string      `json:"ip"`
+	Port      int         `json:"port"`
+	Domain    string      `json:"domain"`
+	Protocol  string      `json:"protocol"`
+	WebTitle  string      `json:"web_title"`
+	Country   string      `json:"country"`
+	Province  string      `json:"province"`
+	City      string      `json:"city"`
+	ASN       interface{} `json:"asn"`    // Number or "AS<n>" string, when present
+	ASOrg     string      `json:"as_org"` // When present
+	UpdatedAt string      `json:"updated_at"`
+}
+
+// Time returns when the service was last seen, or the zero time if unknown
+func (a HunterAsset) Time() time.Time {
+	t, err := time.Parse("2006-01-02", a.UpdatedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ASNString returns the ASN as "AS<n>", or "" when absent
+func (a HunterAsset) ASNString() string {
+	switch v := a.ASN.(type) {
+	case float64:
+		if v > 0 {
+			return fmt.Sprintf("AS%d", int64(v))
+		}
+	case string:
+		v = strings.TrimSpace(v)
+		if v == "" || v == "0" {
+			return ""
+		}
+		if !strings.HasPrefix(strings.ToUpper(v), "AS") {
+			v = "AS" + v
+		}
+		return strings.ToUpper(v[:2]) + v[2:]
+	}
+	return ""
+}
+
+// Query is one Hunter.how search derived from the target
+type Query = hostsearch.Query
+
+// DomainQueries returns the queries built from the domain name alone
+func DomainQueries(domain string) []Query {
+	domain = strings.ReplaceAll(domain, `"`, "")
+	return []Query{
+		{Name: "domain", Query: fmt.Sprintf(`domain.suffix="%s"`, domain)},
+		{Name: "cert", Query: fmt.Sprintf(`cert.subject.suffix="%s"`, domain)},
+	}
+}
+
+// FaviconQuery returns the favicon query for a favicon's contents
+// Hunter.how indexes favicons by the MD5 of the file
+func FaviconQuery(icon []byte) Query {
+	sum := md5.Sum(icon)
+	return Query{Name: "favicon", Query: fmt.Sprintf(`favicon_hash=="%s"`, hex.EncodeToString(sum[:]))}
+}
+
+// SiteQueries returns the favicon query for https://<domain>/favicon.ico
+// Nothing is returned when the site is unreachable or serves no real icon
+func SiteQueries(ctx context.Context, client *httpclient.Client, siteURL string) []Query {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(siteURL, "/")+"/favicon.ico", nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := client.Do(ctx, SourceName, req)
+	if err != nil || resp.StatusCode != http.StatusOK || len(resp.Data) == 0 || hostsearch.LooksLikeHTML(resp.Data) {
+		return nil
+	}
+	return []Query{FaviconQuery(resp.Data)}
+}
+
+// SearchDomain queries Hunter.how for hosts matching the domain and its certificates
+func SearchDomain(ctx context.Context, domain string, apiKeys []string, timeout time.Duration) ([]string, error) {
+	if len(apiKeys) == 0 {
+		return []string{}, fmt.Errorf("no Hunter API keys provided")
+	}
+
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceHunter, apiKeys)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	records, err := search(ctx, manager, httpclient.Default(), DefaultBaseURL, DomainQueries(domain), time.Now())
+	if err != nil {
+		return []string{}, err
+	}
+
+	ips := make([]string, 0, len(records))
+	for _, rec := range records {
+		ips = append(ips, rec.IP)
+	}
+	return ips, nil
+}
+
+// search runs every query through the manager's key rotation, reading up to
+// DefaultMaxPages pages each, over the lookback window ending at now.
+// A query that fails is dropped; rate limits and rejected keys end the
+// search. Partial results are returned without error when at least one
+// page succeeded.
+func search(ctx context.Context, manager *api.Manager, client *httpclient.Client, baseURL string, queries []Query, now time.Time) ([]core.PassiveIP, error) {
+	if !manager.HasKeys(api.SourceHunter) {
+		return nil, fmt.Errorf("no valid API keys found")
+	}
+
+	hosts := hostsearch.NewHostSet(SourceName, "hunter_queries")
+	fetch := func(ctx context.Context, q hostsearch.Query, page int) (bool, error) {
+		var resp *HunterResponse
+		err := manager.Do(ctx, api.SourceHunter, func(apiKey string) error {
+			var err error
+			resp, err = queryPage(ctx, client, baseURL, apiKey, q.Query, page, now)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		addAssets(hosts, q.Name, resp.Data.List)
+		return len(resp.Data.List) == pageSize && page*pageSize < resp.Data.Total, nil
+	}
+
+	err := hostsearch.Run(ctx, queries, hostsearch.Limits{MaxPages: DefaultMaxPages}, fetch, hostsearch.IsFatal(errUnauthorized))
+	if err != nil {
+		return nil, err
+	}
+	return hosts.Records(time.Now()), nil
+}
+
+// queryPage fetches one page of search results with a single API key
+func queryPage(ctx context.Context, client *httpclient.Client, baseURL, apiKey, query string, page int, now time.Time) (*HunterResponse, error) {
+	params := url.Values{}
+	params.Set("api-key", apiKey)
+	params.Set("query", base64.URLEncoding.EncodeToString([]byte(query)))
+	params.Set("page", strconv.Itoa(page))
+	params.Set("page_size", strconv.Itoa(pageSize))
+	params.Set("start_time", now.Add(-lookback).Format("2006-01-02"))
+	params.Set("end_time", now.Format("2006-01-02"))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(ctx, SourceName, req)
+	if err != nil {
+		return nil, fmt.Errorf("Hunter request failed: %w", err)
+	}
+
+	body := resp.Data
+
+	// Hunter.how reports most errors in the JSON code with HTTP 200
+	var hunterResp HunterResponse
+	parseErr := json.Unmarshal(body, &hunterResp)
+
+	code := resp.StatusCode
+	if code == http.StatusOK && parseErr == nil && hunterResp.Code != 0 {
+		code = hunterResp.Code
+	}
+
+	switch {
+	case code == http.StatusTooManyRequests || (code != http.StatusOK && isQuotaMessage(hunterResp.Message)):
+		return nil, api.NewRateLimitError(resp.Response, hunterResp.Message)
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return nil, fmt.Errorf("%w (code %d): %s", errUnauthorized, code, hunterResp.Message)
+	case code != http.StatusOK:
+		if hunterResp.Message != "" {
+			return nil, fmt.Errorf("Hunter API error (code %d): %s", code, hunterResp.Message)
+		}
+		bodyStr := string(body)
+		if len(bodyStr) > 200 {
+			bodyStr = bodyStr[:200] + "..."
+		}
+		return nil, fmt.Errorf("Hunter returned status %d: %s", code, strings.TrimSpace(bodyStr))
+	case parseErr != nil:
+		return nil, fmt.Errorf("failed to parse Hunter response: %w", parseErr)
+	}
+
+	return &hunterResp, nil
+}
+
+// isQuotaMessage reports whether an error message describes exhausted credits
+func isQuotaMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "limit") || strings.Contains(msg, "quota") || strings.Contains(msg, "credit")
+}
+
+// addAssets records the IPv4 assets found by the named query
+func addAssets(hosts *hostsearch.HostSet, query string, assets []HunterAsset) {
+	for _, asset := range assets {
+		h := hosts.Add(asset.IP, query)
+		if h == nil {
+			continue
+		}
+
+		h.AddPort(asset.Port)
+		h.AddValue("hostnames", strings.ToLower(strings.TrimSpace(asset.Domain)))
+		h.SetField("asn", asset.ASNString())
+		h.SetField("organization", asset.ASOrg)
+		h.SetField("country", asset.Country)
+		h.SetField("city", asset.City)
+		h.Seen(asset.Time())
+	}
+}
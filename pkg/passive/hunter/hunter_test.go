@@ -0,0 +1,352 @@
+package hunter
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+var testNow = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// runSearch runs queries against a mock API with the given keys
+func runSearch(t *testing.T, baseURL string, keys []string, queries []Query) ([]core.PassiveIP, error) {
+	t.Helper()
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceHunter, keys)
+	return search(context.Background(), manager, httpclient.Default(), baseURL, queries, testNow)
+}
+
+func assertIPs(t *testing.T, records []core.PassiveIP, want []string) {
+	t.Helper()
+	got := make([]string, 0, len(records))
+	for _, rec := range records {
+		got = append(got, rec.IP)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IPs = %v, want %v", got, want)
+	}
+}
+
+// decodeQuery returns the plain query text of a request
+func decodeQuery(t *testing.T, r *http.Request) string {
+	t.Helper()
+	q, err := base64.URLEncoding.DecodeString(r.URL.Query().Get("query"))
+	if err != nil {
+		t.Errorf("query is not base64url: %v", err)
+	}
+	return string(q)
+}
+
+// writeAssets writes a successful response
+func writeAssets(w http.ResponseWriter, total int, assets []HunterAsset) {
+	json.NewEncoder(w).Encode(HunterResponse{Code: 200, Data: HunterData{Total: total, List: assets}})
+}
+
+func TestSearchDomain_NoAPIKeys(t *testing.T) {
+	_, err := SearchDomain(context.Background(), "example.com", []string{}, 5*time.Second)
+	if err == nil || err.Error() != "no Hunter API keys provided" {
+		t.Errorf("SearchDomain() error = %v, want no Hunter API keys provided", err)
+	}
+}
+
+func TestSearch_EmptyAPIKeys(t *testing.T) {
+	_, err := runSearch(t, "http://127.0.0.1:0", []string{"", "  "}, DomainQueries("example.com"))
+	if err == nil || err.Error() != "no valid API keys found" {
+		t.Errorf("search() error = %v, want no valid API keys found", err)
+	}
+}
+
+func TestQueryPage_Request(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/search" {
+			t.Errorf("path = %s, want /search", r.URL.Path)
+		}
+		if q.Get("api-key") != "test_key" {
+			t.Errorf("api-key = %q", q.Get("api-key"))
+		}
+		if got := decodeQuery(t, r); got != `domain.suffix="example.com"` {
+			t.Errorf("query = %q", got)
+		}
+		if q.Get("page") != "2" || q.Get("page_size") != "100" {
+			t.Errorf("page = %s, page_size = %s", q.Get("page"), q.Get("page_size"))
+		}
+		if q.Get("start_time") != "2023-06-02" || q.Get("end_time") != "2024-06-01" {
+			t.Errorf("window = %s..%s, want 2023-06-02..2024-06-01", q.Get("start_time"), q.Get("end_time"))
+		}
+		writeAssets(w, 1, []HunterAsset{{IP: "192.0.2.1"}})
+	}))
+	defer server.Close()
+
+	resp, err := queryPage(context.Background(), httpclient.Default(), server.URL, "test_key", `domain.suffix="example.com"`, 2, testNow)
+	if err != nil {
+		t.Fatalf("queryPage() error = %v", err)
+	}
+	if len(resp.Data.List) != 1 || resp.Data.List[0].IP != "192.0.2.1" {
+		t.Errorf("List = %+v", resp.Data.List)
+	}
+}
+
+func TestQueryPage_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		rateLimit bool
+		unauth    bool
+	}{
+		{"http 429", 429, `{"code":429,"message":"too many requests"}`, true, false},
+		{"quota in JSON", 200, `{"code":40001,"message":"Your daily API pull limit has been reached"}`, true, false},
+		{"invalid key in JSON", 200, `{"code":401,"message":"api-key is invalid"}`, false, true},
+		{"http 403", 403, `{}`, false, true},
+		{"other JSON error", 200, `{"code":400,"message":"syntax error"}`, false, false},
+		{"server error", 500, `oops`, false, false},
+		{"bad JSON", 200, `not json`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client, err := httpclient.New(httpclient.Config{MaxRetries: -1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = queryPage(context.Background(), client, server.URL, "k", "q", 1, testNow)
+			if err == nil {
+				t.Fatal("queryPage() should fail")
+			}
+			if got := api.IsRateLimitError(err); got != tt.rateLimit {
+				t.Errorf("IsRateLimitError(%v) = %v, want %v", err, got, tt.rateLimit)
+			}
+			if got := errors.Is(err, errUnauthorized); got != tt.unauth {
+				t.Errorf("errors.Is(%v, errUnauthorized) = %v, want %v", err, got, tt.unauth)
+			}
+		})
+	}
+}
+
+func TestSearch_Pagination(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		mu.Lock()
+		requests = append(requests, fmt.Sprintf("%s#%d", decodeQuery(t, r), page))
+		mu.Unlock()
+
+		// 1000 matches: more than DefaultMaxPages pages
+		var assets []HunterAsset
+		for i := 0; i < pageSize; i++ {
+			n := (page-1)*pageSize + i
+			assets = append(assets, HunterAsset{IP: fmt.Sprintf("10.0.%d.%d", n/256, n%256)})
+		}
+		writeAssets(w, 1000, assets)
+	}))
+	defer server.Close()
+
+	records, err := runSearch(t, server.URL, []string{"k"}, DomainQueries("example.com")[:1])
+	if err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+	if len(requests) != DefaultMaxPages {
+		t.Errorf("requests = %v, want %d pages", requests, DefaultMaxPages)
+	}
+	if len(records) != DefaultMaxPages*pageSize {
+		t.Errorf("records = %d, want %d", len(records), DefaultMaxPages*pageSize)
+	}
+}
+
+func TestSearch_StopsOnShortPage(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeAssets(w, 250, []HunterAsset{{IP: "192.0.2.1"}})
+	}))
+	defer server.Close()
+
+	if _, err := runSearch(t, server.URL, []string{"k"}, DomainQueries("example.com")[:1]); err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 for a short page", calls)
+	}
+}
+
+func TestSearch_Metadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch q := decodeQuery(t, r); {
+		case strings.HasPrefix(q, "domain.suffix"):
+			writeAssets(w, 3, []HunterAsset{
+				{IP: "192.0.2.1", Port: 443, Domain: "WWW.example.com", Country: "United States", City: "Ashburn", ASN: float64(14618), ASOrg: "Amazon", UpdatedAt: "2024-03-01"},
+				{IP: "192.0.2.1", Port: 80, Domain: "api.example.com", UpdatedAt: "2024-01-15"},
+				{IP: "2001:db8::1", Port: 443},
+			})
+		case strings.HasPrefix(q, "cert.subject"):
+			writeAssets(w, 1, []HunterAsset{{IP: "192.0.2.1", Port: 8443, ASN: "AS14618"}, {IP: "192.0.2.9"}})
+		}
+	}))
+	defer server.Close()
+
+	records, err := runSearch(t, server.URL, []string{"k"}, DomainQueries("example.com"))
+	if err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+	assertIPs(t, records, []string{"192.0.2.1", "192.0.2.9"})
+
+	rec := records[0]
+	if rec.Source != SourceName {
+		t.Errorf("Source = %q, want %q", rec.Source, SourceName)
+	}
+	want := map[string]interface{}{
+		"hunter_queries": []string{"cert", "domain"},
+		"ports":          []int{80, 443, 8443},
+		"hostnames":      []string{"api.example.com", "www.example.com"},
+		"asn":            "AS14618",
+		"organization":   "Amazon",
+		"country":        "United States",
+		"city":           "Ashburn",
+	}
+	if !reflect.DeepEqual(rec.Metadata, want) {
+		t.Errorf("Metadata = %v, want %v", rec.Metadata, want)
+	}
+	if rec.FirstSeen.Format("2006-01-02") != "2024-01-15" || rec.LastSeen.Format("2006-01-02") != "2024-03-01" {
+		t.Errorf("seen = %v..%v, want 2024-01-15..2024-03-01", rec.FirstSeen, rec.LastSeen)
+	}
+}
+
+func TestSearch_FailedQueryDropped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(decodeQuery(t, r), "cert") {
+			w.Write([]byte(`{"code":400,"message":"unsupported field"}`))
+			return
+		}
+		writeAssets(w, 1, []HunterAsset{{IP: "192.0.2.1"}})
+	}))
+	defer server.Close()
+
+	records, err := runSearch(t, server.URL, []string{"k"}, DomainQueries("example.com"))
+	if err != nil {
+		t.Fatalf("search() error = %v, want partial results", err)
+	}
+	assertIPs(t, records, []string{"192.0.2.1"})
+}
+
+func TestSearch_UnauthorizedStops(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"code":401,"message":"api-key is invalid"}`))
+	}))
+	defer server.Close()
+
+	_, err := runSearch(t, server.URL, []string{"k"}, DomainQueries("example.com"))
+	if !errors.Is(err, errUnauthorized) {
+		t.Errorf("search() error = %v, want errUnauthorized", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want search to stop after the first rejection", calls)
+	}
+}
+
+func TestSearch_KeyRotationOnQuota(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("api-key")
+		keys = append(keys, key)
+		if key == "spent" {
+			w.Write([]byte(`{"code":40001,"message":"daily API pull limit reached"}`))
+			return
+		}
+		writeAssets(w, 1, []HunterAsset{{IP: "192.0.2.1"}})
+	}))
+	defer server.Close()
+
+	records, err := runSearch(t, server.URL, []string{"spent", "fresh"}, DomainQueries("example.com")[:1])
+	if err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+	assertIPs(t, records, []string{"192.0.2.1"})
+	if !reflect.DeepEqual(keys, []string{"spent", "fresh"}) {
+		t.Errorf("keys used = %v, want [spent fresh]", keys)
+	}
+}
+
+func TestDomainQueries(t *testing.T) {
+	got := DomainQueries(`exa"mple.com`)
+	want := []Query{
+		{Name: "domain", Query: `domain.suffix="example.com"`},
+		{Name: "cert", Query: `cert.subject.suffix="example.com"`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DomainQueries() = %v, want %v", got, want)
+	}
+}
+
+func TestSiteQueries(t *testing.T) {
+	icon := []byte("\x00\x00\x01\x00icon")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/favicon.ico" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		w.Write(icon)
+	}))
+	defer server.Close()
+
+	got := SiteQueries(context.Background(), httpclient.Default(), server.URL+"/")
+	want := []Query{FaviconQuery(icon)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SiteQueries() = %v, want %v", got, want)
+	}
+	if want[0].Query != `favicon_hash=="`+fmt.Sprintf("%x", md5.Sum(icon))+`"` {
+		t.Errorf("FaviconQuery() = %q", want[0].Query)
+	}
+
+	// An HTML page served for every path is not a favicon
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<!DOCTYPE html><html></html>"))
+	}))
+	defer html.Close()
+	if got := SiteQueries(context.Background(), httpclient.Default(), html.URL); len(got) != 0 {
+		t.Errorf("SiteQueries() = %v, want none for HTML", got)
+	}
+}
+
+func TestASNString(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{float64(13335), "AS13335"},
+		{"AS13335", "AS13335"},
+		{"as13335", "AS13335"},
+		{"13335", "AS13335"},
+		{"", ""},
+		{float64(0), ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := (HunterAsset{ASN: tt.in}).ASNString(); got != tt.want {
+			t.Errorf("ASNString(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
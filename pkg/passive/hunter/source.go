@@ -0,0 +1,91 @@
+package hunter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+// SourceName is the registry name of the Hunter.how source
+const SourceName = "hunter"
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "Hunter.how asset search by domain, certificate subject and favicon",
+		Credentials: []string{"hunter_keys"},
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			if len(opts.Config.HunterKeys) == 0 {
+				return nil, passive.MissingCredentials(SourceName, "hunter_keys")
+			}
+			return &Source{
+				manager:   opts.KeyManager(api.SourceHunter, opts.Config.HunterKeys),
+				client:    opts.HTTPClient(),
+				baseURL:   opts.BaseURL(SourceName, DefaultBaseURL),
+				siteFetch: opts.Config.PassiveSiteFetch,
+				timeout:   opts.Timeout,
+			}, nil
+		},
+	})
+}
+
+// Source implements passive.Source for Hunter.how
+type Source struct {
+	manager   *api.Manager
+	client    *httpclient.Client
+	baseURL   string
+	siteFetch bool // Fetch the target site's favicon for the favicon query
+	timeout   time.Duration
+}
+
+// NewSource creates a Hunter.how source using the given API keys
+// An empty baseURL uses DefaultBaseURL
+func NewSource(keys []string, baseURL string, timeout time.Duration) *Source {
+	return &Source{
+		manager: passive.Options{}.KeyManager(api.SourceHunter, keys),
+		client:  httpclient.Default(),
+		baseURL: passive.Endpoint(baseURL, DefaultBaseURL),
+		timeout: timeout,
+	}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns the config keys the source needs
+func (s *Source) RequiredCredentials() []string { return []string{"hunter_keys"} }
+
+// SetSiteFetch lets Search request https://<domain>/favicon.ico
+func (s *Source) SetSiteFetch(enabled bool) { s.siteFetch = enabled }
+
+// CacheVariant is "site" when the target site is fetched for pivots
+func (s *Source) CacheVariant() string {
+	if s.siteFetch {
+		return "site"
+	}
+	return ""
+}
+
+// Search queries Hunter.how for IPs related to the domain
+// The domain and certificate queries run first. With site fetching on, a
+// favicon query taken from https://<domain>/favicon.ico follows when the
+// site answers.
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	queries := DomainQueries(domain)
+	if s.siteFetch {
+		queries = append(queries, SiteQueries(ctx, s.client, "https://"+domain)...)
+	}
+	records, err := search(ctx, s.manager, s.client, s.baseURL, queries, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("Hunter search failed: %w", err)
+	}
+	return records, nil
+}
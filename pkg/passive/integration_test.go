@@ -0,0 +1,322 @@
+package passive_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	_ "github.com/jhaxce/origindive/pkg/passive/all"
+)
+
+// integrationDomain resolves offline via /etc/hosts, so sources that
+// resolve discovered hostnames still produce IPs without network access
+const integrationDomain = "localhost"
+
+// offlineSources are registered sources that do not talk HTTP
+var offlineSources = map[string]bool{
+	"dns": true, // Queries the system resolver directly
+}
+
+// fixture serves one source's API and lists the IPs the source must return
+type fixture struct {
+	path    string
+	handler http.HandlerFunc
+	want    []string
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// requireHeader rejects requests without the expected credential header
+func requireHeader(t *testing.T, name, value string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(name); got != value {
+			t.Errorf("%s %s: header %s = %q, want %q", r.Method, r.URL.Path, name, got, value)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireQuery rejects requests without the expected query parameter
+func requireQuery(t *testing.T, name, value string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get(name); got != value {
+			t.Errorf("%s %s: query %s = %q, want %q", r.Method, r.URL.Path, name, got, value)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func sourceFixtures(t *testing.T) map[string][]fixture {
+	return map[string][]fixture{
+		"shodan": {{
+			path: "/shodan/host/search",
+			handler: requireQuery(t, "key", "shodan-key", func(w http.ResponseWriter, r *http.Request) {
+				if q := r.URL.Query().Get("query"); !strings.Contains(q, integrationDomain) {
+					t.Errorf("shodan: query = %q, want domain", q)
+				}
+				writeJSON(w, map[string]interface{}{
+					"total": 3,
+					"matches": []map[string]interface{}{
+						{"ip_str": "192.0.2.10"},
+						{"ip_str": "192.0.2.10"},
+						{"ip_str": "2001:db8::10"},
+					},
+				})
+			}),
+			want: []string{"192.0.2.10"},
+		}},
+		"censys": {{
+			path: "/v3/global/search/query",
+			handler: requireHeader(t, "Authorization", "Bearer censys-token", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("censys: method = %s, want POST", r.Method)
+				}
+				if org := r.URL.Query().Get("organization_id"); org != "censys-org" {
+					t.Errorf("censys: organization_id = %q", org)
+				}
+				writeJSON(w, map[string]interface{}{
+					"result": map[string]interface{}{
+						"hits": []map[string]interface{}{{
+							"host_v1": map[string]interface{}{
+								"resource": map[string]interface{}{"ip": "192.0.2.20"},
+							},
+						}},
+					},
+				})
+			}),
+			want: []string{"192.0.2.20"},
+		}},
+		"securitytrails": {
+			{
+				path: "/v1/domain/" + integrationDomain + "/subdomains",
+				handler: requireHeader(t, "APIKEY", "st-key", func(w http.ResponseWriter, r *http.Request) {
+					writeJSON(w, map[string]interface{}{"subdomains": []string{}})
+				}),
+			},
+			{
+				path: "/v1/history/" + integrationDomain + "/dns/a",
+				handler: requireHeader(t, "APIKEY", "st-key", func(w http.ResponseWriter, r *http.Request) {
+					writeJSON(w, map[string]interface{}{
+						"records": []map[string]interface{}{{
+							"type":       "a",
+							"values":     []map[string]interface{}{{"ip": "192.0.2.30", "asn_organization": "Example Hosting"}},
+							"first_seen": "2023-01-01",
+							"last_seen":  "2024-01-01",
+						}},
+					})
+				}),
+				want: []string{"192.0.2.30"},
+			},
+		},
+		"zoomeye": {{
+			path: "/v2/search",
+			handler: requireHeader(t, "API-KEY", "zoomeye-key", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, map[string]interface{}{
+					"code": 60000,
+					"data": []map[string]interface{}{{"ip": "192.0.2.40"}},
+				})
+			}),
+			want: []string{"192.0.2.40"},
+		}},
+		"hunter": {{
+			path: "/search",
+			handler: requireQuery(t, "api-key", "hunter-key", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, map[string]interface{}{
+					"code": 200,
+					"data": map[string]interface{}{
+						"total": 1,
+						"list":  []map[string]interface{}{{"ip": "192.0.2.45", "port": 443, "domain": integrationDomain}},
+					},
+				})
+			}),
+			want: []string{"192.0.2.45"},
+		}},
+		"virustotal": {{
+			path: "/api/v3/domains/" + integrationDomain + "/subdomains",
+			handler: requireHeader(t, "x-apikey", "vt-key", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, map[string]interface{}{
+					"data": []map[string]interface{}{{
+						"attributes": map[string]interface{}{
+							"last_dns_records": []map[string]string{
+								{"type": "A", "value": "192.0.2.50"},
+								{"type": "AAAA", "value": "2001:db8::50"},
+							},
+						},
+					}},
+				})
+			}),
+			want: []string{"192.0.2.50"},
+		}},
+		"viewdns": {{
+			path: "/reverseip/",
+			handler: requireQuery(t, "apikey", "viewdns-key", func(w http.ResponseWriter, r *http.Request) {
+				if host := r.URL.Query().Get("host"); host != "127.0.0.1" {
+					t.Errorf("viewdns: host = %q, want resolved IP", host)
+				}
+				writeJSON(w, map[string]interface{}{
+					"response": map[string]interface{}{
+						"domains": []map[string]string{{"name": integrationDomain}},
+					},
+				})
+			}),
+			want: []string{"127.0.0.1"},
+		}},
+		"dnsdumpster": {{
+			path: "/domain/" + integrationDomain,
+			handler: requireHeader(t, "X-API-Key", "dnsdumpster-key", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, map[string]interface{}{
+					"a":  []map[string]interface{}{{"host": integrationDomain, "ips": []map[string]string{{"ip": "192.0.2.60"}}}},
+					"mx": []map[string]interface{}{{"host": "mx." + integrationDomain, "ips": []map[string]string{{"ip": "192.0.2.61"}}}},
+				})
+			}),
+			want: []string{"192.0.2.60", "192.0.2.61"},
+		}},
+		"ct": {{
+			path: "/json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if q := r.URL.Query().Get("q"); q != integrationDomain {
+					t.Errorf("ct: q = %q, want %s", q, integrationDomain)
+				}
+				writeJSON(w, []map[string]interface{}{
+					{"name_value": integrationDomain + "\n*." + integrationDomain},
+				})
+			},
+			want: []string{"127.0.0.1"},
+		}},
+		"wayback": {{
+			path: "/cdx/search/cdx",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if u := r.URL.Query().Get("url"); u != "*."+integrationDomain {
+					t.Errorf("wayback: url = %q", u)
+				}
+				writeJSON(w, [][]string{
+					{"original"},
+					{"http://" + integrationDomain + ":80/index.html"},
+				})
+			},
+			want: []string{"127.0.0.1"},
+		}},
+	}
+}
+
+// integrationConfig points every source at baseURL and configures one key each
+func integrationConfig(baseURL string) *core.Config {
+	config := core.DefaultConfig()
+	config.ShodanKeys = []string{"shodan-key"}
+	config.CensysTokens = []string{"censys-token"}
+	config.CensysOrgID = "censys-org"
+	config.SecurityTrailsKeys = []string{"st-key"}
+	config.ZoomEyeKeys = []string{"zoomeye-key"}
+	config.HunterKeys = []string{"hunter-key"}
+	config.VirusTotalKeys = []string{"vt-key"}
+	config.ViewDNSKeys = []string{"viewdns-key"}
+	config.DNSDumpsterKeys = []string{"dnsdumpster-key"}
+
+	config.PassiveEndpoints = make(map[string]string)
+	for _, name := range passive.Names() {
+		config.PassiveEndpoints[name] = baseURL + "/" // Trailing slash is trimmed
+	}
+	return config
+}
+
+func TestIntegration_AllSourcesAgainstMockServers(t *testing.T) {
+	fixtures := sourceFixtures(t)
+
+	for _, name := range passive.Names() {
+		if offlineSources[name] {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			fx, ok := fixtures[name]
+			if !ok {
+				t.Fatalf("no integration fixture for source %s", name)
+			}
+
+			mux := http.NewServeMux()
+			var want []string
+			for _, f := range fx {
+				mux.HandleFunc(f.path, f.handler)
+				want = append(want, f.want...)
+			}
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+				http.NotFound(w, r)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			src, err := passive.New(name, passive.Options{
+				Config:  integrationConfig(server.URL),
+				Timeout: 10 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+
+			records, err := src.Search(context.Background(), integrationDomain)
+			if err != nil {
+				t.Fatalf("Search() error: %v", err)
+			}
+
+			got := make(map[string]bool)
+			for _, r := range records {
+				if r.Source != name {
+					t.Errorf("record %s has Source %q, want %q", r.IP, r.Source, name)
+				}
+				got[r.IP] = true
+			}
+			for _, ip := range want {
+				if !got[ip] {
+					t.Errorf("Search() missing %s, got %v", ip, sortedKeys(got))
+				}
+			}
+		})
+	}
+}
+
+func TestIntegration_RateLimitSurfaces(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	config := integrationConfig(server.URL)
+	for _, name := range []string{"shodan", "censys", "zoomeye", "hunter", "virustotal", "dnsdumpster"} {
+		t.Run(name, func(t *testing.T) {
+			src, err := passive.New(name, passive.Options{Config: config, Timeout: 5 * time.Second})
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			_, err = src.Search(context.Background(), integrationDomain)
+			if err == nil || !strings.Contains(err.Error(), "rate limit") {
+				t.Errorf("Search() error = %v, want rate limit error", err)
+			}
+		})
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
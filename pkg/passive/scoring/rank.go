@@ -0,0 +1,203 @@
+package scoring
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+// ptrWorkers bounds concurrent reverse DNS lookups during Rank
+const ptrWorkers = 20
+
+// ptrTimeout bounds a single reverse DNS lookup during Rank
+const ptrTimeout = 3 * time.Second
+
+// Rank scores every passive record with ScoreAll and merges records for the
+// same IP into one entry carrying all contributing sources, the widest
+// first/last-seen window and the best score. IPs that have no record at or
+// above MinConfidence are dropped. Results are sorted by confidence
+// (highest first), then IP.
+func (s *Scorer) Rank(ctx context.Context, ips []core.PassiveIP) []core.PassiveIP {
+	if len(ips) == 0 {
+		return nil
+	}
+
+	// Reverse DNS once per unique IP instead of once per record
+	s.resolvePTRs(ctx, ips)
+
+	merged := MergeByIP(ips)
+	byIP := make(map[string]*core.PassiveIP, len(merged))
+	for i := range merged {
+		merged[i].Confidence = -1 // Not yet scored
+		byIP[merged[i].IP] = &merged[i]
+	}
+
+	for _, scored := range s.ScoreAll(ips) {
+		m := byIP[scored.IP]
+		if scored.Confidence > m.Confidence {
+			m.Confidence = scored.Confidence
+			m.Source = scored.Source // Source of the strongest record
+		}
+	}
+
+	ranked := make([]core.PassiveIP, 0, len(merged))
+	for _, m := range merged {
+		if m.Confidence >= 0 {
+			ranked = append(ranked, m)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Confidence != ranked[j].Confidence {
+			return ranked[i].Confidence > ranked[j].Confidence
+		}
+		return ranked[i].IP < ranked[j].IP
+	})
+
+	return ranked
+}
+
+// MergeByIP collapses records for the same IP into one entry
+// Sources lists every contributing source (sorted), FirstSeen is the
+// earliest and LastSeen the latest non-zero timestamp, and metadata keys,
+// ASN and location are combined (first value wins). Order of first appearance
+// is preserved.
+func MergeByIP(ips []core.PassiveIP) []core.PassiveIP {
+	merged := make([]core.PassiveIP, 0, len(ips))
+	index := make(map[string]int, len(ips))
+
+	for _, rec := range ips {
+		i, ok := index[rec.IP]
+		if !ok {
+			index[rec.IP] = len(merged)
+			merged = append(merged, core.PassiveIP{
+				IP:         rec.IP,
+				Source:     rec.Source,
+				Confidence: rec.Confidence,
+				FirstSeen:  rec.FirstSeen,
+				LastSeen:   rec.LastSeen,
+			})
+			i = len(merged) - 1
+		}
+
+		m := &merged[i]
+		m.Sources = addSource(m.Sources, rec.Source)
+		m.Sources = addSource(m.Sources, rec.Sources...)
+		if !rec.FirstSeen.IsZero() && (m.FirstSeen.IsZero() || rec.FirstSeen.Before(m.FirstSeen)) {
+			m.FirstSeen = rec.FirstSeen
+		}
+		if rec.LastSeen.After(m.LastSeen) {
+			m.LastSeen = rec.LastSeen
+		}
+		if rec.Confidence > m.Confidence {
+			m.Confidence = rec.Confidence
+		}
+		if m.ASN == 0 {
+			m.ASN, m.ASOrg = rec.ASN, rec.ASOrg
+		}
+		if m.Country == "" {
+			m.Country, m.City = rec.Country, rec.City
+		}
+		for k, v := range rec.Metadata {
+			if m.Metadata == nil {
+				m.Metadata = make(map[string]interface{})
+			}
+			if _, exists := m.Metadata[k]; !exists {
+				m.Metadata[k] = v
+			}
+		}
+	}
+
+	return merged
+}
+
+// addSource adds source names to a sorted set
+func addSource(sources []string, names ...string) []string {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		i := sort.SearchStrings(sources, name)
+		if i < len(sources) && sources[i] == name {
+			continue
+		}
+		sources = append(sources, "")
+		copy(sources[i+1:], sources[i:])
+		sources[i] = name
+	}
+	return sources
+}
+
+// resolvePTRs performs one reverse DNS lookup per unique IP and stores the
+// result as "reverse_dns" metadata on every record, so ScoreIP does not
+// repeat the lookup for each record. Records that already carry reverse
+// DNS or PTR metadata are left untouched.
+func (s *Scorer) resolvePTRs(ctx context.Context, ips []core.PassiveIP) {
+	lookup := s.lookup()
+
+	pending := make(map[string]bool)
+	for _, rec := range ips {
+		if !hasPTRMetadata(rec) {
+			pending[rec.IP] = true
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]string, len(pending))
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < ptrWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range jobs {
+				lookupCtx, cancel := context.WithTimeout(ctx, ptrTimeout)
+				names, err := lookup(lookupCtx, ip)
+				cancel()
+
+				name := ""
+				if err == nil && len(names) > 0 {
+					name = strings.TrimSuffix(names[0], ".")
+				}
+				mu.Lock()
+				results[ip] = name
+				mu.Unlock()
+			}
+		}()
+	}
+	for ip := range pending {
+		jobs <- ip
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := range ips {
+		name, ok := results[ips[i].IP]
+		if !ok || hasPTRMetadata(ips[i]) {
+			continue
+		}
+		if ips[i].Metadata == nil {
+			ips[i].Metadata = make(map[string]interface{})
+		}
+		ips[i].Metadata["reverse_dns"] = name
+	}
+}
+
+// hasPTRMetadata reports whether a record already carries reverse DNS data
+func hasPTRMetadata(ip core.PassiveIP) bool {
+	if ip.Metadata == nil {
+		return false
+	}
+	if _, ok := ip.Metadata["reverse_dns"].(string); ok {
+		return true
+	}
+	_, ok := ip.Metadata["ptr_record"].(string)
+	return ok
+}
@@ -0,0 +1,164 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+// stubLookup returns a PTR lookup that answers from a map and counts calls
+func stubLookup(ptrs map[string]string) (func(context.Context, string) ([]string, error), *map[string]int) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	return func(ctx context.Context, addr string) ([]string, error) {
+		mu.Lock()
+		calls[addr]++
+		mu.Unlock()
+		if name, ok := ptrs[addr]; ok {
+			return []string{name + "."}, nil
+		}
+		return nil, errors.New("no PTR")
+	}, &calls
+}
+
+func TestRank_MergesSourcesAndTimestamps(t *testing.T) {
+	scorer := NewScorer("example.com", DefaultScoringConfig())
+	lookup, calls := stubLookup(map[string]string{"192.0.2.1": "origin.example.com"})
+	scorer.lookupAddr = lookup
+
+	now := time.Now()
+	old := now.Add(-90 * 24 * time.Hour)
+
+	ips := []core.PassiveIP{
+		{IP: "192.0.2.1", Source: "shodan", FirstSeen: now, LastSeen: now},
+		{IP: "192.0.2.2", Source: "ct", FirstSeen: now, LastSeen: now},
+		{IP: "192.0.2.1", Source: "securitytrails", FirstSeen: old, LastSeen: old},
+		{IP: "192.0.2.1", Source: "shodan", FirstSeen: now, LastSeen: now},
+	}
+
+	ranked := scorer.Rank(context.Background(), ips)
+	if len(ranked) != 2 {
+		t.Fatalf("Expected 2 ranked IPs, got %d", len(ranked))
+	}
+
+	top := ranked[0]
+	if top.IP != "192.0.2.1" {
+		t.Fatalf("Expected multi-source IP first, got %s", top.IP)
+	}
+	if want := []string{"securitytrails", "shodan"}; !reflect.DeepEqual(top.Sources, want) {
+		t.Errorf("Sources = %v, want %v", top.Sources, want)
+	}
+	if !top.FirstSeen.Equal(old) {
+		t.Errorf("FirstSeen = %v, want earliest %v", top.FirstSeen, old)
+	}
+	if !top.LastSeen.Equal(now) {
+		t.Errorf("LastSeen = %v, want latest %v", top.LastSeen, now)
+	}
+	if top.Metadata["reverse_dns"] != "origin.example.com" {
+		t.Errorf("reverse_dns = %v, want origin.example.com", top.Metadata["reverse_dns"])
+	}
+	if top.Confidence <= ranked[1].Confidence {
+		t.Errorf("Expected %s (%.2f) to outrank %s (%.2f)",
+			top.IP, top.Confidence, ranked[1].IP, ranked[1].Confidence)
+	}
+
+	// One PTR lookup per unique IP, not per record
+	for ip, n := range *calls {
+		if n != 1 {
+			t.Errorf("Expected 1 PTR lookup for %s, got %d", ip, n)
+		}
+	}
+}
+
+func TestRank_MinConfidence(t *testing.T) {
+	config := DefaultScoringConfig()
+	config.MinConfidence = 0.7
+
+	scorer := NewScorer("example.com", config)
+	scorer.lookupAddr, _ = stubLookup(nil)
+
+	now := time.Now()
+	ips := []core.PassiveIP{
+		{IP: "192.0.2.1", Source: "shodan", LastSeen: now},
+		{IP: "192.0.2.1", Source: "censys", LastSeen: now},
+		{IP: "192.0.2.1", Source: "securitytrails", LastSeen: now},
+		{IP: "192.0.2.2", Source: "wayback", LastSeen: now.Add(-400 * 24 * time.Hour)},
+	}
+
+	ranked := scorer.Rank(context.Background(), ips)
+	if len(ranked) != 1 || ranked[0].IP != "192.0.2.1" {
+		t.Fatalf("Expected only 192.0.2.1 above threshold, got %+v", ranked)
+	}
+	if ranked[0].Confidence < config.MinConfidence {
+		t.Errorf("Confidence %.2f below threshold %.2f", ranked[0].Confidence, config.MinConfidence)
+	}
+	if len(ranked[0].Sources) != 3 {
+		t.Errorf("Expected 3 sources, got %v", ranked[0].Sources)
+	}
+}
+
+func TestRank_KeepsExistingPTRMetadata(t *testing.T) {
+	scorer := NewScorer("example.com", DefaultScoringConfig())
+	lookup, calls := stubLookup(map[string]string{"192.0.2.1": "other.net"})
+	scorer.lookupAddr = lookup
+
+	ips := []core.PassiveIP{
+		{IP: "192.0.2.1", Source: "dns", Metadata: map[string]interface{}{"ptr_record": "www.example.com"}},
+	}
+
+	ranked := scorer.Rank(context.Background(), ips)
+	if len(ranked) != 1 {
+		t.Fatalf("Expected 1 ranked IP, got %d", len(ranked))
+	}
+	if len(*calls) != 0 {
+		t.Errorf("Expected no PTR lookups, got %v", *calls)
+	}
+	if _, ok := ranked[0].Metadata["reverse_dns"]; ok {
+		t.Error("Expected reverse_dns to stay unset when ptr_record exists")
+	}
+}
+
+func TestRank_Empty(t *testing.T) {
+	scorer := NewScorer("example.com", nil)
+	if ranked := scorer.Rank(context.Background(), nil); ranked != nil {
+		t.Errorf("Expected nil for empty input, got %v", ranked)
+	}
+}
+
+func TestMergeByIP(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	merged := MergeByIP([]core.PassiveIP{
+		{IP: "192.0.2.5", Source: "ct", LastSeen: t1, Metadata: map[string]interface{}{"hostname": "a.example.com"}},
+		{IP: "192.0.2.4", Source: "dns"},
+		{IP: "192.0.2.5", Source: "wayback", FirstSeen: t1, LastSeen: t2, Metadata: map[string]interface{}{"hostname": "b.example.com", "asn": "AS64500"}},
+		{IP: "192.0.2.5", Sources: []string{"ct", "shodan"}, ASN: 64500, ASOrg: "EXAMPLE-NET"},
+	})
+
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 merged IPs, got %d", len(merged))
+	}
+	if merged[0].IP != "192.0.2.5" || merged[1].IP != "192.0.2.4" {
+		t.Errorf("Expected first-appearance order, got %s, %s", merged[0].IP, merged[1].IP)
+	}
+
+	m := merged[0]
+	if want := []string{"ct", "shodan", "wayback"}; !reflect.DeepEqual(m.Sources, want) {
+		t.Errorf("Sources = %v, want %v", m.Sources, want)
+	}
+	if !m.FirstSeen.Equal(t1) || !m.LastSeen.Equal(t2) {
+		t.Errorf("Seen window = %v..%v, want %v..%v", m.FirstSeen, m.LastSeen, t1, t2)
+	}
+	if m.Metadata["hostname"] != "a.example.com" || m.Metadata["asn"] != "AS64500" {
+		t.Errorf("Unexpected merged metadata: %v", m.Metadata)
+	}
+	if m.ASN != 64500 || m.ASOrg != "EXAMPLE-NET" {
+		t.Errorf("ASN = %d %q, want the enriched record's", m.ASN, m.ASOrg)
+	}
+}
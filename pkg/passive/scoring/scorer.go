@@ -2,17 +2,21 @@
 package scoring
 
 import (
-	"net"
+	"context"
 	"strings"
 	"time"
 
 	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
 // Scorer calculates confidence scores for passive IPs
 type Scorer struct {
 	domain string
 	config *ScoringConfig
+
+	// lookupAddr resolves PTR records (nil = resolver.Default())
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
 }
 
 // ScoringConfig holds scoring configuration
@@ -90,6 +94,19 @@ func NewScorer(domain string, config *ScoringConfig) *Scorer {
 	}
 }
 
+// SetResolver sends reverse DNS lookups to r
+func (s *Scorer) SetResolver(r *resolver.Resolver) {
+	s.lookupAddr = r.LookupAddr
+}
+
+// lookup returns the PTR lookup function in use
+func (s *Scorer) lookup() func(ctx context.Context, addr string) ([]string, error) {
+	if s.lookupAddr == nil {
+		return resolver.Default().LookupAddr
+	}
+	return s.lookupAddr
+}
+
 // ScoreIP calculates confidence score for a single IP
 func (s *Scorer) ScoreIP(ip *core.PassiveIP, allIPs []core.PassiveIP) float64 {
 	score := s.config.BaseScore
@@ -233,7 +250,9 @@ func (s *Scorer) hasReverseDNSMatch(ip *core.PassiveIP) bool {
 
 // performReverseDNS performs actual reverse DNS lookup
 func (s *Scorer) performReverseDNS(ip string) string {
-	names, err := net.LookupAddr(ip)
+	ctx, cancel := context.WithTimeout(context.Background(), ptrTimeout)
+	defer cancel()
+	names, err := s.lookup()(ctx, ip)
 	if err != nil || len(names) == 0 {
 		return ""
 	}
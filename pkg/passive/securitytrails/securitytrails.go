@@ -5,13 +5,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
+// DefaultBaseURL is the SecurityTrails API base URL
+const DefaultBaseURL = "https://api.securitytrails.com"
+
 // SubdomainResponse represents the JSON response from SecurityTrails subdomains API
 type SubdomainResponse struct {
 	Subdomains []string `json:"subdomains"`
@@ -44,97 +51,105 @@ type Value struct {
 	ASNOrganization string `json:"asn_organization"`
 }
 
+// historyDateLayout is the date format of first_seen/last_seen in history records
+const historyDateLayout = "2006-01-02"
+
 // SearchSubdomainsAndHistory queries SecurityTrails for subdomains and historical IPs
 func SearchSubdomainsAndHistory(ctx context.Context, domain string, apiKeys []string, timeout time.Duration) ([]string, error) {
-	if len(apiKeys) == 0 {
-		return []string{}, fmt.Errorf("no SecurityTrails API keys provided")
+	records, err := SearchRecords(ctx, domain, apiKeys, timeout)
+	if err != nil {
+		return []string{}, err
 	}
 
-	// Try each API key until one works
-	var lastErr error
-	for i, apiKey := range apiKeys {
-		apiKey = strings.TrimSpace(apiKey)
-		if apiKey == "" {
-			continue
-		}
-
-		ips, err := searchWithKey(ctx, domain, apiKey, timeout)
-		if err == nil {
-			return ips, nil
+	seen := make(map[string]bool, len(records))
+	ips := make([]string, 0, len(records))
+	for _, r := range records {
+		if !seen[r.IP] {
+			seen[r.IP] = true
+			ips = append(ips, r.IP)
 		}
-
-		// Check for rate limit errors
-		if strings.Contains(err.Error(), "rate limit") || strings.Contains(err.Error(), "429") {
-			lastErr = fmt.Errorf("key %d/%d rate limited: %w", i+1, len(apiKeys), err)
-			continue // Try next key
-		}
-
-		// For other errors, return immediately
-		return []string{}, fmt.Errorf("key %d/%d failed: %w", i+1, len(apiKeys), err)
 	}
+	return ips, nil
+}
 
-	if lastErr != nil {
-		return []string{}, fmt.Errorf("all %d API keys exhausted: %w", len(apiKeys), lastErr)
+// SearchRecords queries SecurityTrails for subdomains and historical IPs
+// and returns one record per sighting. Historical A records carry their
+// first/last-seen dates and ASN organization; resolved subdomains carry
+// the hostname and are stamped with the current time.
+func SearchRecords(ctx context.Context, domain string, apiKeys []string, timeout time.Duration) ([]core.PassiveIP, error) {
+	if len(apiKeys) == 0 {
+		return nil, fmt.Errorf("no SecurityTrails API keys provided")
 	}
 
-	return []string{}, fmt.Errorf("no valid API keys found")
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceSecurityTrails, apiKeys)
+	return search(ctx, manager, httpclient.Default(), resolver.Default(), DefaultBaseURL, domain, timeout)
+}
+
+// search runs the query through the manager's key rotation
+// Rate limited keys are cooled down and the next key is tried
+func search(ctx context.Context, manager *api.Manager, client *httpclient.Client, dns *resolver.Resolver, baseURL, domain string, timeout time.Duration) ([]core.PassiveIP, error) {
+	var records []core.PassiveIP
+	err := manager.Do(ctx, api.SourceSecurityTrails, func(apiKey string) error {
+		var err error
+		records, err = searchWithKey(ctx, client, dns, baseURL, domain, apiKey, timeout)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
 }
 
 // searchWithKey performs the search with a single API key
-func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Duration) ([]string, error) {
-	ipSet := make(map[string]bool)
+func searchWithKey(ctx context.Context, client *httpclient.Client, dns *resolver.Resolver, baseURL, domain, apiKey string, timeout time.Duration) ([]core.PassiveIP, error) {
+	var records []core.PassiveIP
 
 	// Step 1: Get subdomains
-	subdomains, err := getSubdomains(ctx, domain, apiKey, timeout)
+	subdomains, err := getSubdomains(ctx, client, baseURL, domain, apiKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get subdomains: %w", err)
 	}
 
 	// Step 2: Get historical IPs for main domain
-	histIPs, err := getHistoricalIPs(ctx, domain, apiKey, timeout)
-	if err != nil {
-		// Non-fatal: log but continue
-		// Some domains may not have history
-	} else {
-		for _, ip := range histIPs {
-			ipSet[ip] = true
-		}
+	// Non-fatal: some domains may not have history
+	if history, err := getHistoricalRecords(ctx, client, baseURL, domain, apiKey); err == nil {
+		records = append(records, history...)
 	}
 
 	// Step 3: Resolve subdomains to IPs (limit to first 50 to avoid rate limits)
 	resolveCount := 0
 	maxResolve := 50
+	now := time.Now()
 	for _, subdomain := range subdomains {
 		if resolveCount >= maxResolve {
 			break
 		}
 
 		fullDomain := subdomain + "." + domain
-		ips, err := resolveToIPv4(ctx, fullDomain, 5*time.Second)
+		ips, err := resolveToIPv4(ctx, dns, fullDomain, 5*time.Second)
 		if err != nil {
 			continue // Skip failed resolutions
 		}
 
 		for _, ip := range ips {
-			ipSet[ip] = true
+			records = append(records, core.PassiveIP{
+				IP:        ip,
+				Source:    "securitytrails",
+				FirstSeen: now,
+				LastSeen:  now,
+				Metadata:  map[string]interface{}{"hostname": fullDomain},
+			})
 		}
 		resolveCount++
 	}
 
-	// Convert to slice
-	ips := make([]string, 0, len(ipSet))
-	for ip := range ipSet {
-		ips = append(ips, ip)
-	}
-
-	return ips, nil
+	return records, nil
 }
 
 // getSubdomains fetches subdomains from SecurityTrails API
-func getSubdomains(ctx context.Context, domain, apiKey string, timeout time.Duration) ([]string, error) {
-	url := fmt.Sprintf("https://api.securitytrails.com/v1/domain/%s/subdomains", domain)
-
-	client := &http.Client{Timeout: timeout}
+func getSubdomains(ctx context.Context, client *httpclient.Client, baseURL, domain, apiKey string) ([]string, error) {
+	url := fmt.Sprintf("%s/v1/domain/%s/subdomains", baseURL, domain)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -142,23 +157,22 @@ func getSubdomains(ctx context.Context, domain, apiKey string, timeout time.Dura
 	}
 
 	req.Header.Set("APIKEY", apiKey)
-	req.Header.Set("User-Agent", "origindive/1.0")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("SecurityTrails request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	if resp.StatusCode != http.StatusOK {
 		var errResp SubdomainResponse
-		if json.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
+		json.Unmarshal(body, &errResp)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, api.NewRateLimitError(resp.Response, errResp.Message)
+		}
+		if errResp.Message != "" {
 			return nil, fmt.Errorf("SecurityTrails API error (HTTP %d): %s", resp.StatusCode, errResp.Message)
 		}
 		return nil, fmt.Errorf("SecurityTrails returned status %d", resp.StatusCode)
@@ -176,11 +190,9 @@ func getSubdomains(ctx context.Context, domain, apiKey string, timeout time.Dura
 	return subResp.Subdomains, nil
 }
 
-// getHistoricalIPs fetches historical A records from SecurityTrails API
-func getHistoricalIPs(ctx context.Context, domain, apiKey string, timeout time.Duration) ([]string, error) {
-	url := fmt.Sprintf("https://api.securitytrails.com/v1/history/%s/dns/a", domain)
-
-	client := &http.Client{Timeout: timeout}
+// getHistoricalRecords fetches historical A records from SecurityTrails API
+func getHistoricalRecords(ctx context.Context, client *httpclient.Client, baseURL, domain, apiKey string) ([]core.PassiveIP, error) {
+	url := fmt.Sprintf("%s/v1/history/%s/dns/a", baseURL, domain)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -188,72 +200,66 @@ func getHistoricalIPs(ctx context.Context, domain, apiKey string, timeout time.D
 	}
 
 	req.Header.Set("APIKEY", apiKey)
-	req.Header.Set("User-Agent", "origindive/1.0")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("SecurityTrails request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	if resp.StatusCode != http.StatusOK {
-		return []string{}, nil // Non-fatal: no history available
+		return nil, nil // Non-fatal: no history available
 	}
 
 	var histResp HistoryResponse
 	if err := json.Unmarshal(body, &histResp); err != nil {
-		return []string{}, nil // Non-fatal: parsing error
+		return nil, nil // Non-fatal: parsing error
 	}
 
-	// Extract IPs from history records
-	ipSet := make(map[string]bool)
+	return historyRecords(histResp), nil
+}
+
+// historyRecords converts history API records into PassiveIP records
+// One record is emitted per IPv4 value, with the period's first/last-seen
+// dates and the ASN organization as metadata
+func historyRecords(histResp HistoryResponse) []core.PassiveIP {
+	var records []core.PassiveIP
 	for _, record := range histResp.Records {
+		firstSeen, _ := time.Parse(historyDateLayout, record.FirstSeen)
+		lastSeen, _ := time.Parse(historyDateLayout, record.LastSeen)
+
 		for _, value := range record.Values {
 			ip := strings.TrimSpace(value.IP)
-			if ip != "" {
-				// Validate IPv4
-				parsedIP := net.ParseIP(ip)
-				if parsedIP != nil && parsedIP.To4() != nil {
-					ipSet[ip] = true
-				}
+			if ip == "" {
+				continue
+			}
+			// Validate IPv4
+			parsedIP := net.ParseIP(ip)
+			if parsedIP == nil || parsedIP.To4() == nil {
+				continue
 			}
-		}
-	}
 
-	ips := make([]string, 0, len(ipSet))
-	for ip := range ipSet {
-		ips = append(ips, ip)
+			rec := core.PassiveIP{
+				IP:        ip,
+				Source:    "securitytrails",
+				FirstSeen: firstSeen,
+				LastSeen:  lastSeen,
+			}
+			if value.ASNOrganization != "" {
+				rec.Metadata = map[string]interface{}{"organization": value.ASNOrganization}
+			}
+			records = append(records, rec)
+		}
 	}
-
-	return ips, nil
+	return records
 }
 
 // resolveToIPv4 resolves a domain to IPv4 addresses
-func resolveToIPv4(ctx context.Context, domain string, timeout time.Duration) ([]string, error) {
-	resolver := &net.Resolver{}
-
+func resolveToIPv4(ctx context.Context, dns *resolver.Resolver, domain string, timeout time.Duration) ([]string, error) {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	addrs, err := resolver.LookupHost(ctxWithTimeout, domain)
-	if err != nil {
-		return nil, err
-	}
-
-	// Filter IPv4 only
-	var ipv4s []string
-	for _, addr := range addrs {
-		ip := net.ParseIP(addr)
-		if ip != nil && ip.To4() != nil {
-			ipv4s = append(ipv4s, addr)
-		}
-	}
-
-	return ipv4s, nil
+	return dns.LookupIPv4(ctxWithTimeout, domain)
 }
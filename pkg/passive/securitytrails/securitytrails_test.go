@@ -2,8 +2,11 @@ package securitytrails
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
 func TestSearchSubdomainsAndHistory_NoAPIKeys(t *testing.T) {
@@ -50,8 +53,49 @@ func TestSearchSubdomainsAndHistory_ContextCancellation(t *testing.T) {
 
 func TestResolveToIPv4(t *testing.T) {
 	ctx := context.Background()
-	_, err := resolveToIPv4(ctx, "localhost", 2*time.Second)
+	_, err := resolveToIPv4(ctx, resolver.Default(), "localhost", 2*time.Second)
 	if err != nil {
 		t.Logf("Resolve failed: %v", err)
 	}
 }
+
+func TestHistoryRecords(t *testing.T) {
+	body := `{"records":[
+		{"type":"a","first_seen":"2021-03-01","last_seen":"2023-06-15",
+		 "values":[{"ip":"192.0.2.10","asn_organization":"Example Hosting"},{"ip":"2001:db8::1"}]},
+		{"type":"a","first_seen":"2023-06-16","last_seen":"bad-date",
+		 "values":[{"ip":"192.0.2.20"},{"ip":""}]}
+	]}`
+
+	var resp HistoryResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	records := historyRecords(resp)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 IPv4 records, got %d", len(records))
+	}
+
+	first := records[0]
+	if first.IP != "192.0.2.10" || first.Source != "securitytrails" {
+		t.Errorf("Unexpected record: %+v", first)
+	}
+	if got := first.FirstSeen.Format(historyDateLayout); got != "2021-03-01" {
+		t.Errorf("FirstSeen = %s, want 2021-03-01", got)
+	}
+	if got := first.LastSeen.Format(historyDateLayout); got != "2023-06-15" {
+		t.Errorf("LastSeen = %s, want 2023-06-15", got)
+	}
+	if first.Metadata["organization"] != "Example Hosting" {
+		t.Errorf("organization = %v, want Example Hosting", first.Metadata["organization"])
+	}
+
+	// Unparseable dates leave the timestamp zero
+	if !records[1].LastSeen.IsZero() {
+		t.Errorf("Expected zero LastSeen for bad date, got %v", records[1].LastSeen)
+	}
+	if records[1].Metadata != nil {
+		t.Errorf("Expected no metadata without ASN organization, got %v", records[1].Metadata)
+	}
+}
@@ -0,0 +1,75 @@
+package securitytrails
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
+)
+
+// SourceName is the registry name of the SecurityTrails source
+const SourceName = "securitytrails"
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "SecurityTrails subdomains and historical A records",
+		Credentials: []string{"securitytrails_keys"},
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			if len(opts.Config.SecurityTrailsKeys) == 0 {
+				return nil, passive.MissingCredentials(SourceName, "securitytrails_keys")
+			}
+			return &Source{
+				manager: opts.KeyManager(api.SourceSecurityTrails, opts.Config.SecurityTrailsKeys),
+				client:  opts.HTTPClient(),
+				dns:     opts.Resolver(),
+				baseURL: opts.BaseURL(SourceName, DefaultBaseURL),
+				timeout: opts.Timeout,
+			}, nil
+		},
+	})
+}
+
+// Source implements passive.Source for SecurityTrails
+type Source struct {
+	manager *api.Manager
+	client  *httpclient.Client
+	dns     *resolver.Resolver
+	baseURL string
+	timeout time.Duration
+}
+
+// NewSource creates a SecurityTrails source using the given API keys
+// An empty baseURL uses DefaultBaseURL
+func NewSource(keys []string, baseURL string, timeout time.Duration) *Source {
+	return &Source{
+		manager: passive.Options{}.KeyManager(api.SourceSecurityTrails, keys),
+		client:  httpclient.Default(),
+		dns:     resolver.Default(),
+		baseURL: passive.Endpoint(baseURL, DefaultBaseURL),
+		timeout: timeout,
+	}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns the config keys the source needs
+func (s *Source) RequiredCredentials() []string { return []string{"securitytrails_keys"} }
+
+// Search queries SecurityTrails for IPs related to the domain
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	records, err := search(ctx, s.manager, s.client, s.dns, s.baseURL, domain, s.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("SecurityTrails search failed: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,171 @@
+package shodan
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"math/bits"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jhaxce/origindive/pkg/passive/hostsearch"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+// Pivot is one Shodan search query derived from the target
+type Pivot = hostsearch.Query
+
+// DomainPivots returns the queries built from the domain name alone
+func DomainPivots(domain string) []Pivot {
+	domain = strings.ReplaceAll(domain, `"`, "")
+	return []Pivot{
+		{Name: "ssl.cert.subject.cn", Query: fmt.Sprintf(`ssl.cert.subject.cn:"%s"`, domain)},
+		{Name: "ssl", Query: fmt.Sprintf(`ssl:"%s"`, domain)},
+		{Name: "hostname", Query: fmt.Sprintf(`hostname:"%s"`, domain)},
+	}
+}
+
+// SitePivots returns http.title and http.favicon.hash queries built from the
+// site's front page. Origins usually serve the same page as the CDN edge, so
+// these find hosts whose certificates and PTR records never name the domain.
+// The site is fetched like a browser visit; pivots that cannot be built
+// (unreachable site, challenge page, no favicon) are left out.
+func SitePivots(ctx context.Context, client *httpclient.Client, siteURL string) []Pivot {
+	var pivots []Pivot
+	siteURL = strings.TrimRight(siteURL, "/")
+
+	if page, ok := fetch(ctx, client, siteURL+"/"); ok {
+		if title := pageTitle(page); title != "" {
+			pivots = append(pivots, Pivot{Name: "http.title", Query: fmt.Sprintf(`http.title:"%s"`, title)})
+		}
+	}
+
+	if icon, ok := fetch(ctx, client, siteURL+"/favicon.ico"); ok && len(icon) > 0 && !hostsearch.LooksLikeHTML(icon) {
+		pivots = append(pivots, Pivot{Name: "http.favicon.hash", Query: fmt.Sprintf("http.favicon.hash:%d", FaviconHash(icon))})
+	}
+
+	return pivots
+}
+
+// fetch returns the body of a 200 response
+func fetch(ctx context.Context, client *httpclient.Client, url string) ([]byte, bool) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, false
+	}
+	resp, err := client.Do(ctx, SourceName, req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	return resp.Data, true
+}
+
+var titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// genericTitles are titles shared by unrelated sites (CDN challenges,
+// error pages, server defaults); searching for them finds noise
+var genericTitles = []string{
+	"just a moment",
+	"attention required",
+	"access denied",
+	"ddos-guard",
+	"welcome to nginx",
+	"apache2 ubuntu default page",
+	"iis windows server",
+	"301 moved permanently",
+	"302 found",
+	"403 forbidden",
+	"404 not found",
+	"502 bad gateway",
+	"503 service",
+}
+
+// pageTitle extracts a searchable title from an HTML page
+// Returns "" for missing or generic titles
+func pageTitle(page []byte) string {
+	m := titleRegex.FindSubmatch(page)
+	if m == nil {
+		return ""
+	}
+
+	title := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	title = strings.ReplaceAll(title, `"`, "")
+	if len(title) < 4 {
+		return ""
+	}
+
+	lower := strings.ToLower(title)
+	for _, generic := range genericTitles {
+		if strings.HasPrefix(lower, generic) {
+			return ""
+		}
+	}
+	return title
+}
+
+// FaviconHash returns Shodan's http.favicon.hash for a favicon: the signed
+// 32-bit MurmurHash3 of its base64 encoding, wrapped at 76 characters with a
+// trailing newline (Python's base64.encodebytes)
+func FaviconHash(data []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	var sb strings.Builder
+	for len(encoded) > 76 {
+		sb.WriteString(encoded[:76])
+		sb.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	sb.WriteString(encoded)
+	sb.WriteByte('\n')
+
+	return int32(murmur3([]byte(sb.String()), 0))
+}
+
+// murmur3 is MurmurHash3 x86 32-bit
+func murmur3(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	h := seed
+	blocks := len(data) / 4
+	for i := 0; i < blocks; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := data[blocks*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,160 @@
+package shodan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+func TestDomainPivots(t *testing.T) {
+	var queries []string
+	for _, p := range DomainPivots("example.com") {
+		queries = append(queries, p.Query)
+	}
+	want := []string{`ssl.cert.subject.cn:"example.com"`, `ssl:"example.com"`, `hostname:"example.com"`}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("queries = %v, want %v", queries, want)
+	}
+}
+
+func TestMurmur3(t *testing.T) {
+	// Reference values from the mmh3 Python package (signed)
+	tests := []struct {
+		in   string
+		want int32
+	}{
+		{"", 0},
+		{"foo", -156908512},
+		{"hello", 613153351},
+	}
+	for _, tt := range tests {
+		if got := int32(murmur3([]byte(tt.in), 0)); got != tt.want {
+			t.Errorf("murmur3(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFaviconHash_WrapsBase64(t *testing.T) {
+	// 60 bytes encode to 80 base64 characters: one full line plus 4
+	data := bytes.Repeat([]byte{0xfa}, 60)
+	want := int32(murmur3([]byte(strings.Repeat("+vr6", 19)+"\n"+"+vr6"+"\n"), 0))
+	if got := FaviconHash(data); got != want {
+		t.Errorf("FaviconHash() = %d, want %d", got, want)
+	}
+}
+
+func TestSitePivots(t *testing.T) {
+	icon := []byte("\x00\x00\x01\x00fake-icon-bytes")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte("<html><head><title>\n  Example &amp; Co \"Store\"\n</title></head></html>"))
+		case "/favicon.ico":
+			w.Write(icon)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	pivots := SitePivots(context.Background(), httpclient.Default(), server.URL+"/")
+	want := []Pivot{
+		{Name: "http.title", Query: `http.title:"Example & Co Store"`},
+		{Name: "http.favicon.hash", Query: fmt.Sprintf("http.favicon.hash:%d", FaviconHash(icon))},
+	}
+	if !reflect.DeepEqual(pivots, want) {
+		t.Errorf("SitePivots() = %v, want %v", pivots, want)
+	}
+}
+
+func TestSitePivots_SkipsNoise(t *testing.T) {
+	// A CDN challenge page answered for every path yields no pivots
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<!DOCTYPE html><html><title>Just a moment...</title></html>"))
+	}))
+	defer server.Close()
+
+	if pivots := SitePivots(context.Background(), httpclient.Default(), server.URL); len(pivots) != 0 {
+		t.Errorf("SitePivots() = %v, want none", pivots)
+	}
+}
+
+func TestPageTitle(t *testing.T) {
+	tests := []struct {
+		page string
+		want string
+	}{
+		{"<title>Acme Portal</title>", "Acme Portal"},
+		{"<TITLE lang=en>Acme</TITLE>", "Acme"},
+		{"<title>404 Not Found</title>", ""},
+		{"<title>Hi</title>", ""},
+		{"<p>no title</p>", ""},
+	}
+	for _, tt := range tests {
+		if got := pageTitle([]byte(tt.page)); got != tt.want {
+			t.Errorf("pageTitle(%q) = %q, want %q", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestSource_SiteFetchOptIn(t *testing.T) {
+	// The proxy answers API requests and records tunnels to the target site
+	var mu sync.Mutex
+	var tunnels []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			mu.Lock()
+			tunnels = append(tunnels, r.Host)
+			mu.Unlock()
+			http.Error(w, "no tunnels", http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(ShodanResponse{Total: 1, Matches: []ShodanMatch{{IPStr: "192.0.2.1"}}})
+	}))
+	defer proxy.Close()
+
+	client, err := httpclient.New(httpclient.Config{ProxyURL: proxy.URL, MaxRetries: -1})
+	if err != nil {
+		t.Fatalf("httpclient.New() error = %v", err)
+	}
+	reg, _ := passive.Lookup(SourceName)
+
+	for _, siteFetch := range []bool{false, true} {
+		tunnels = nil
+		src, err := reg.Factory(passive.Options{
+			Config: &core.Config{
+				ShodanKeys:       []string{"k"},
+				PassiveSiteFetch: siteFetch,
+				PassiveEndpoints: map[string]string{SourceName: "http://shodan.test"},
+			},
+			HTTP:    client,
+			Timeout: 5 * time.Second,
+		})
+		if err != nil {
+			t.Fatalf("Factory() error = %v", err)
+		}
+		if _, err := src.Search(context.Background(), "example.com"); err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+
+		var want []string
+		if siteFetch {
+			want = []string{"example.com:443", "example.com:443"} // Front page and favicon
+		}
+		if !reflect.DeepEqual(tunnels, want) {
+			t.Errorf("passive_site_fetch=%v: site requests = %v, want %v", siteFetch, tunnels, want)
+		}
+	}
+}
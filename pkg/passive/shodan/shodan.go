@@ -4,14 +4,34 @@ package shodan
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/hostsearch"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
 )
 
+// DefaultBaseURL is the Shodan REST API base URL
+// Override it with passive_endpoints.shodan (e.g., an enterprise mirror)
+const DefaultBaseURL = "https://api.shodan.io"
+
+// DefaultMaxCredits is how many query credits one search may spend
+// Every filtered search page costs one credit, so this caps the requests
+const DefaultMaxCredits = 10
+
+// pageSize is the number of matches Shodan returns per search page
+const pageSize = 100
+
+// errUnauthorized stops the search: no other pivot can succeed with the key
+var errUnauthorized = errors.New("Shodan rejected the API key")
+
 // ShodanResponse represents the JSON response from Shodan API
 type ShodanResponse struct {
 	Total   int           `json:"total"`
@@ -19,85 +39,120 @@ type ShodanResponse struct {
 	Error   string        `json:"error,omitempty"`
 }
 
-// ShodanMatch represents a single host result
+// ShodanMatch represents a single host result (one service banner)
 type ShodanMatch struct {
 	IPStr     string   `json:"ip_str"`
 	Hostnames []string `json:"hostnames"`
 	Domains   []string `json:"domains"`
 	Port      int      `json:"port"`
 	Transport string   `json:"transport"`
+	Org       string   `json:"org"`
+	ISP       string   `json:"isp"`
+	ASN       string   `json:"asn"`       // e.g., "AS13335"
+	Timestamp string   `json:"timestamp"` // When the banner was collected (UTC, no zone)
+}
+
+// Time returns when the banner was collected, or the zero time if unknown
+func (m ShodanMatch) Time() time.Time {
+	t, err := time.Parse("2006-01-02T15:04:05.999999", m.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
 }
 
-// SearchHostname queries Shodan for hosts matching the domain using hostname filter
+// SearchHostname queries Shodan for hosts matching the domain's certificate and hostname pivots
 func SearchHostname(ctx context.Context, domain string, apiKeys []string, timeout time.Duration) ([]string, error) {
 	if len(apiKeys) == 0 {
 		return []string{}, fmt.Errorf("no Shodan API keys provided")
 	}
 
-	// Try each API key until one works (rotation for rate limits)
-	var lastErr error
-	for i, apiKey := range apiKeys {
-		apiKey = strings.TrimSpace(apiKey)
-		if apiKey == "" {
-			continue
-		}
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceShodan, apiKeys)
 
-		ips, err := searchWithKey(ctx, domain, apiKey, timeout)
-		if err == nil {
-			return ips, nil
-		}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	records, err := search(ctx, manager, httpclient.Default(), DefaultBaseURL, DomainPivots(domain), DefaultMaxCredits)
+	if err != nil {
+		return []string{}, err
+	}
 
-		// Check for rate limit errors
-		if strings.Contains(err.Error(), "rate limit") || strings.Contains(err.Error(), "429") {
-			lastErr = fmt.Errorf("key %d/%d rate limited: %w", i+1, len(apiKeys), err)
-			continue // Try next key
-		}
+	ips := make([]string, 0, len(records))
+	for _, rec := range records {
+		ips = append(ips, rec.IP)
+	}
+	return ips, nil
+}
 
-		// For other errors, return immediately (invalid key, network issue, etc.)
-		return []string{}, fmt.Errorf("key %d/%d failed: %w", i+1, len(apiKeys), err)
+// search runs every pivot through the manager's key rotation, spending at
+// most credits requests. Pages are fetched breadth-first (page 1 of every
+// pivot before page 2 of any) so a small budget still covers all pivots.
+// A pivot that fails (e.g., a filter the plan does not include) is dropped;
+// rate limits and rejected keys end the search. Partial results are returned
+// without error when at least one page succeeded.
+func search(ctx context.Context, manager *api.Manager, client *httpclient.Client, baseURL string, pivots []Pivot, credits int) ([]core.PassiveIP, error) {
+	if !manager.HasKeys(api.SourceShodan) {
+		return nil, fmt.Errorf("no valid API keys found")
+	}
+	if credits <= 0 {
+		credits = DefaultMaxCredits
 	}
 
-	if lastErr != nil {
-		return []string{}, fmt.Errorf("all %d API keys exhausted: %w", len(apiKeys), lastErr)
+	hosts := hostsearch.NewHostSet(SourceName, "shodan_queries")
+	fetch := func(ctx context.Context, pivot hostsearch.Query, page int) (bool, error) {
+		var resp *ShodanResponse
+		err := manager.Do(ctx, api.SourceShodan, func(apiKey string) error {
+			var err error
+			resp, err = queryPage(ctx, client, baseURL, apiKey, pivot.Query, page)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		addMatches(hosts, pivot.Name, resp.Matches)
+		return len(resp.Matches) == pageSize && page*pageSize < resp.Total, nil
 	}
 
-	return []string{}, fmt.Errorf("no valid API keys found")
+	err := hostsearch.Run(ctx, pivots, hostsearch.Limits{Credits: credits}, fetch, hostsearch.IsFatal(errUnauthorized))
+	if err != nil {
+		return nil, err
+	}
+	return hosts.Records(time.Now()), nil
 }
 
-// searchWithKey performs the search with a single API key
-func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Duration) ([]string, error) {
-	// Build query: ssl.cert.subject.cn:"domain.com"
-	// This searches for SSL certificates with the domain in the Common Name field
-	query := fmt.Sprintf(`ssl.cert.subject.cn:"%s"`, domain)
-	url := fmt.Sprintf("https://api.shodan.io/shodan/host/search?query=%s&key=%s", query, apiKey)
-
-	client := &http.Client{
-		Timeout: timeout,
+// queryPage fetches one page of search results with a single API key
+func queryPage(ctx context.Context, client *httpclient.Client, baseURL, apiKey, query string, page int) (*ShodanResponse, error) {
+	params := url.Values{}
+	params.Set("key", apiKey)
+	params.Set("query", query)
+	if page > 1 {
+		params.Set("page", strconv.Itoa(page))
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/shodan/host/search?"+params.Encode(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "origindive/1.0")
-
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("Shodan request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
 		// Try to parse error message from JSON
 		var errResp ShodanResponse
-		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		json.Unmarshal(body, &errResp)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, api.NewRateLimitError(resp.Response, errResp.Error)
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("%w (HTTP 401): %s", errUnauthorized, errResp.Error)
+		}
+		if errResp.Error != "" {
 			return nil, fmt.Errorf("Shodan API error (HTTP %d): %s", resp.StatusCode, errResp.Error)
 		}
 		return nil, fmt.Errorf("Shodan returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
@@ -114,31 +169,24 @@ func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Dura
 		return nil, fmt.Errorf("Shodan API error: %s", shodanResp.Error)
 	}
 
-	// Extract unique IPv4 addresses
-	ipSet := make(map[string]bool)
-	for _, match := range shodanResp.Matches {
-		ip := strings.TrimSpace(match.IPStr)
-		if ip == "" {
-			continue
-		}
+	return &shodanResp, nil
+}
 
-		// Validate and filter IPv4 only
-		parsedIP := net.ParseIP(ip)
-		if parsedIP == nil {
+// addMatches records the IPv4 matches found by the named pivot
+func addMatches(hosts *hostsearch.HostSet, pivot string, matches []ShodanMatch) {
+	for _, match := range matches {
+		h := hosts.Add(match.IPStr, pivot)
+		if h == nil {
 			continue
 		}
 
-		// Check if IPv4 (To4() returns nil for IPv6)
-		if parsedIP.To4() != nil {
-			ipSet[ip] = true
+		h.AddPort(match.Port)
+		for _, name := range match.Hostnames {
+			h.AddValue("hostnames", strings.ToLower(name))
 		}
+		h.SetField("organization", match.Org)
+		h.SetField("isp", match.ISP)
+		h.SetField("asn", match.ASN)
+		h.Seen(match.Time())
 	}
-
-	// Convert to slice
-	ips := make([]string, 0, len(ipSet))
-	for ip := range ipSet {
-		ips = append(ips, ip)
-	}
-
-	return ips, nil
 }
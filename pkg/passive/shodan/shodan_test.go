@@ -3,12 +3,73 @@ package shodan
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
 )
 
+// searchServer runs the domain pivots for example.com against a mock API
+func searchServer(t *testing.T, baseURL string, credits int) []core.PassiveIP {
+	t.Helper()
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceShodan, []string{"test_key"})
+
+	records, err := search(context.Background(), manager, httpclient.Default(), baseURL, DomainPivots("example.com")[:1], credits)
+	if err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+	return records
+}
+
+func assertIPs(t *testing.T, records []core.PassiveIP, want []string) {
+	t.Helper()
+	got := make([]string, 0, len(records))
+	for _, rec := range records {
+		got = append(got, rec.IP)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IPs = %v, want %v", got, want)
+	}
+}
+
+// pagedServer serves total matches per query in pages of pageSize and
+// records every (query, page) request
+func pagedServer(t *testing.T, total int) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+		mu.Lock()
+		requests = append(requests, fmt.Sprintf("%s#%d", query, page))
+		mu.Unlock()
+
+		resp := ShodanResponse{Total: total}
+		for i := (page - 1) * pageSize; i < total && i < page*pageSize; i++ {
+			resp.Matches = append(resp.Matches, ShodanMatch{IPStr: fmt.Sprintf("10.%d.%d.%d", len(query)%256, i/256, i%256)})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests...)
+	}
+}
+
 func TestSearchHostname_NoAPIKeys(t *testing.T) {
 	ctx := context.Background()
 	_, err := SearchHostname(ctx, "example.com", []string{}, 5*time.Second)
@@ -120,7 +181,7 @@ func TestSearchHostname_AllKeysRateLimited(t *testing.T) {
 	}
 }
 
-func TestSearchWithKey_HTTPError(t *testing.T) {
+func TestQueryPage_HTTPError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(ShodanResponse{
@@ -130,13 +191,13 @@ func TestSearchWithKey_HTTPError(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "invalid_key", 1*time.Second)
-	if err == nil {
-		t.Log("Expected error for invalid key")
+	_, err := queryPage(ctx, httpclient.Default(), server.URL, "invalid_key", `hostname:"example.com"`, 1)
+	if !errors.Is(err, errUnauthorized) {
+		t.Errorf("queryPage() error = %v, want errUnauthorized", err)
 	}
 }
 
-func TestSearchWithKey_InvalidJSON(t *testing.T) {
+func TestQueryPage_InvalidJSON(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte("{invalid json"))
@@ -144,13 +205,13 @@ func TestSearchWithKey_InvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := queryPage(ctx, httpclient.Default(), server.URL, "test_key", `hostname:"example.com"`, 1)
 	if err == nil {
 		t.Log("Expected JSON parsing error")
 	}
 }
 
-func TestSearchWithKey_APIErrorInJSON(t *testing.T) {
+func TestQueryPage_APIErrorInJSON(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(ShodanResponse{
@@ -160,7 +221,7 @@ func TestSearchWithKey_APIErrorInJSON(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := queryPage(ctx, httpclient.Default(), server.URL, "test_key", `hostname:"example.com"`, 1)
 	if err == nil {
 		t.Log("Expected API error from JSON")
 	}
@@ -205,7 +266,7 @@ func TestShodanStructures(t *testing.T) {
 	}
 }
 
-func TestSearchWithKey_IPv6Filtering(t *testing.T) {
+func TestSearch_IPv6Filtering(t *testing.T) {
 	// Test that IPv6 addresses are filtered out
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := ShodanResponse{
@@ -220,14 +281,10 @@ func TestSearchWithKey_IPv6Filtering(t *testing.T) {
 	}))
 	defer server.Close()
 
-	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
-	if err == nil {
-		t.Log("IPv6 filtering test completed")
-	}
+	assertIPs(t, searchServer(t, server.URL, 1), []string{"192.168.1.1"})
 }
 
-func TestSearchWithKey_EmptyIPStr(t *testing.T) {
+func TestSearch_EmptyIPStr(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := ShodanResponse{
 			Total: 2,
@@ -241,14 +298,10 @@ func TestSearchWithKey_EmptyIPStr(t *testing.T) {
 	}))
 	defer server.Close()
 
-	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
-	if err == nil {
-		t.Log("Empty IP filtering test completed")
-	}
+	assertIPs(t, searchServer(t, server.URL, 1), []string{"192.168.1.1"})
 }
 
-func TestSearchWithKey_InvalidIPFormat(t *testing.T) {
+func TestSearch_InvalidIPFormat(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := ShodanResponse{
 			Total: 2,
@@ -262,11 +315,7 @@ func TestSearchWithKey_InvalidIPFormat(t *testing.T) {
 	}))
 	defer server.Close()
 
-	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
-	if err == nil {
-		t.Log("Invalid IP filtering test completed")
-	}
+	assertIPs(t, searchServer(t, server.URL, 1), []string{"192.168.1.1"})
 }
 
 func TestSearchHostname_ContextCancellation(t *testing.T) {
@@ -279,7 +328,7 @@ func TestSearchHostname_ContextCancellation(t *testing.T) {
 	}
 }
 
-func TestSearchWithKey_DuplicateIPs(t *testing.T) {
+func TestSearch_DuplicateIPs(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := ShodanResponse{
 			Total: 3,
@@ -294,11 +343,7 @@ func TestSearchWithKey_DuplicateIPs(t *testing.T) {
 	}))
 	defer server.Close()
 
-	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
-	if err == nil {
-		t.Log("Duplicate IP deduplication test completed")
-	}
+	assertIPs(t, searchServer(t, server.URL, 1), []string{"192.168.1.1", "192.168.1.2"})
 }
 
 func TestSearchHostname_WhitespaceKeys(t *testing.T) {
@@ -310,9 +355,9 @@ func TestSearchHostname_WhitespaceKeys(t *testing.T) {
 	}
 }
 
-func TestSearchWithKey_EmptyDomain(t *testing.T) {
+func TestQueryPage_EmptyDomain(t *testing.T) {
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "", "test_key", 1*time.Second)
+	_, err := queryPage(ctx, httpclient.Default(), DefaultBaseURL, "test_key", `hostname:""`, 1)
 
 	if err == nil {
 		t.Log("Expected error for empty domain")
@@ -341,3 +386,149 @@ func TestShodanMatch_AllFields(t *testing.T) {
 		t.Errorf("Port = %d, want 25", match.Port)
 	}
 }
+
+func TestSearch_PaginatesWithinBudget(t *testing.T) {
+	server, requests := pagedServer(t, 350)
+	defer server.Close()
+
+	// 350 matches span 4 pages; a budget of 3 stops after page 3
+	records := searchServer(t, server.URL, 3)
+	if len(records) != 300 {
+		t.Errorf("records = %d, want 300", len(records))
+	}
+	if got := requests(); len(got) != 3 || got[2] != `ssl.cert.subject.cn:"example.com"#3` {
+		t.Errorf("requests = %v, want pages 1-3", got)
+	}
+
+	// A larger budget stops at the last page
+	server2, requests2 := pagedServer(t, 350)
+	defer server2.Close()
+	if records := searchServer(t, server2.URL, 10); len(records) != 350 {
+		t.Errorf("records = %d, want 350", len(records))
+	}
+	if got := requests2(); len(got) != 4 {
+		t.Errorf("requests = %v, want 4 pages", got)
+	}
+}
+
+func TestSearch_BreadthFirstAcrossPivots(t *testing.T) {
+	server, requests := pagedServer(t, 1000)
+	defer server.Close()
+
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceShodan, []string{"test_key"})
+	if _, err := search(context.Background(), manager, httpclient.Default(), server.URL, DomainPivots("example.com"), 4); err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+
+	want := []string{
+		`ssl.cert.subject.cn:"example.com"#1`,
+		`ssl:"example.com"#1`,
+		`hostname:"example.com"#1`,
+		`ssl.cert.subject.cn:"example.com"#2`,
+	}
+	if got := requests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestSearch_MatchMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := ShodanResponse{Total: 1}
+		switch r.URL.Query().Get("query") {
+		case `ssl.cert.subject.cn:"example.com"`:
+			resp.Matches = []ShodanMatch{{
+				IPStr: "198.51.100.7", Port: 443, Hostnames: []string{"Origin.example.com"},
+				Org: "Example Hosting", ISP: "Example ISP", ASN: "AS64500", Timestamp: "2024-03-01T10:00:00.000000",
+			}}
+		case `hostname:"example.com"`:
+			resp.Matches = []ShodanMatch{{
+				IPStr: "198.51.100.7", Port: 22, Hostnames: []string{"origin.example.com", "ssh.example.com"},
+				Timestamp: "2024-01-15T08:30:00.123456",
+			}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceShodan, []string{"test_key"})
+	records, err := search(context.Background(), manager, httpclient.Default(), server.URL, DomainPivots("example.com"), 10)
+	if err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1 merged host", len(records))
+	}
+
+	rec := records[0]
+	if rec.Source != SourceName {
+		t.Errorf("Source = %q", rec.Source)
+	}
+	if !rec.FirstSeen.Equal(time.Date(2024, 1, 15, 8, 30, 0, 123456000, time.UTC)) ||
+		!rec.LastSeen.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("FirstSeen/LastSeen = %v/%v, want banner timestamps", rec.FirstSeen, rec.LastSeen)
+	}
+
+	want := map[string]interface{}{
+		"ports":          []int{22, 443},
+		"hostnames":      []string{"origin.example.com", "ssh.example.com"},
+		"organization":   "Example Hosting",
+		"isp":            "Example ISP",
+		"asn":            "AS64500",
+		"shodan_queries": []string{"hostname", "ssl.cert.subject.cn"},
+	}
+	if !reflect.DeepEqual(rec.Metadata, want) {
+		t.Errorf("Metadata = %v, want %v", rec.Metadata, want)
+	}
+}
+
+func TestSearch_FailedPivotSkipped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") == `ssl.cert.subject.cn:"example.com"` {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(ShodanResponse{Error: "Access denied (403 Forbidden)"})
+			return
+		}
+		json.NewEncoder(w).Encode(ShodanResponse{Total: 1, Matches: []ShodanMatch{{IPStr: "198.51.100.8"}}})
+	}))
+	defer server.Close()
+
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceShodan, []string{"test_key"})
+	records, err := search(context.Background(), manager, httpclient.Default(), server.URL, DomainPivots("example.com"), 10)
+	if err != nil {
+		t.Fatalf("search() error = %v, want partial results", err)
+	}
+	assertIPs(t, records, []string{"198.51.100.8"})
+}
+
+func TestSearch_UnauthorizedStops(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ShodanResponse{Error: "Invalid API key"})
+	}))
+	defer server.Close()
+
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceShodan, []string{"bad_key"})
+	_, err := search(context.Background(), manager, httpclient.Default(), server.URL, DomainPivots("example.com"), 10)
+	if !errors.Is(err, errUnauthorized) {
+		t.Errorf("search() error = %v, want errUnauthorized", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (no further pivots with a rejected key)", calls)
+	}
+}
+
+func TestShodanMatch_Time(t *testing.T) {
+	m := ShodanMatch{Timestamp: "2024-05-06T07:08:09.500000"}
+	if want := time.Date(2024, 5, 6, 7, 8, 9, 500000000, time.UTC); !m.Time().Equal(want) {
+		t.Errorf("Time() = %v, want %v", m.Time(), want)
+	}
+	if !(ShodanMatch{Timestamp: "yesterday"}).Time().IsZero() {
+		t.Error("Time() should be zero for unparseable timestamps")
+	}
+}
@@ -0,0 +1,92 @@
+package shodan
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+// SourceName is the registry name of the Shodan source
+const SourceName = "shodan"
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "Shodan host search by certificate, hostname, page title and favicon pivots",
+		Credentials: []string{"shodan_keys"},
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			if len(opts.Config.ShodanKeys) == 0 {
+				return nil, passive.MissingCredentials(SourceName, "shodan_keys")
+			}
+			return &Source{
+				manager:   opts.KeyManager(api.SourceShodan, opts.Config.ShodanKeys),
+				client:    opts.HTTPClient(),
+				baseURL:   opts.BaseURL(SourceName, DefaultBaseURL),
+				credits:   opts.Config.ShodanMaxCredits,
+				siteFetch: opts.Config.PassiveSiteFetch,
+				timeout:   opts.Timeout,
+			}, nil
+		},
+	})
+}
+
+// Source implements passive.Source for Shodan
+type Source struct {
+	manager   *api.Manager
+	client    *httpclient.Client
+	baseURL   string
+	credits   int  // Query credits per search (0 = DefaultMaxCredits)
+	siteFetch bool // Fetch the target site for title and favicon pivots
+	timeout   time.Duration
+}
+
+// NewSource creates a Shodan source using the given API keys
+// An empty baseURL uses DefaultBaseURL
+func NewSource(keys []string, baseURL string, timeout time.Duration) *Source {
+	return &Source{
+		manager: passive.Options{}.KeyManager(api.SourceShodan, keys),
+		client:  httpclient.Default(),
+		baseURL: passive.Endpoint(baseURL, DefaultBaseURL),
+		timeout: timeout,
+	}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns the config keys the source needs
+func (s *Source) RequiredCredentials() []string { return []string{"shodan_keys"} }
+
+// SetSiteFetch lets Search request https://<domain>/ and its favicon
+func (s *Source) SetSiteFetch(enabled bool) { s.siteFetch = enabled }
+
+// CacheVariant is "site" when the target site is fetched for pivots
+func (s *Source) CacheVariant() string {
+	if s.siteFetch {
+		return "site"
+	}
+	return ""
+}
+
+// Search queries Shodan for IPs related to the domain
+// The domain pivots run first. With site fetching on, title and favicon
+// pivots taken from https://<domain>/ follow when the site answers.
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	pivots := DomainPivots(domain)
+	if s.siteFetch {
+		pivots = append(pivots, SitePivots(ctx, s.client, "https://"+domain)...)
+	}
+	records, err := search(ctx, s.manager, s.client, s.baseURL, pivots, s.credits)
+	if err != nil {
+		return nil, fmt.Errorf("Shodan search failed: %w", err)
+	}
+	return records, nil
+}
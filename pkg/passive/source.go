@@ -0,0 +1,229 @@
+// Package passive defines the common interface implemented by passive
+// reconnaissance sources and the registry they register into
+package passive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
+)
+
+// ErrMissingCredentials is returned by a Factory when the credentials a
+// source requires are not configured
+var ErrMissingCredentials = errors.New("missing credentials")
+
+// Source is a passive intelligence source that discovers IPs for a domain
+type Source interface {
+	// Name returns the registry name of the source (e.g., "shodan")
+	Name() string
+
+	// RequiredCredentials returns the config keys the source needs
+	// (e.g., "shodan_keys"). Empty for free sources.
+	RequiredCredentials() []string
+
+	// Search queries the source for IPs related to the domain
+	Search(ctx context.Context, domain string) ([]core.PassiveIP, error)
+}
+
+// Variant is implemented by sources whose results depend on options, such
+// as a search mode, so results are cached per option set
+type Variant interface {
+	// CacheVariant describes the options in effect ("" for the defaults)
+	CacheVariant() string
+}
+
+// CacheKey returns the name a source's results are cached under: its name,
+// followed by its variant when it has one (e.g., "censys+cert+site")
+func CacheKey(src Source) string {
+	if v, ok := src.(Variant); ok {
+		if variant := v.CacheVariant(); variant != "" {
+			return src.Name() + "+" + variant
+		}
+	}
+	return src.Name()
+}
+
+// Options are passed to a Factory when a source is created
+type Options struct {
+	Config  *core.Config                             // Scan configuration (API keys, settings)
+	Timeout time.Duration                            // Per-source timeout
+	Logf    func(format string, args ...interface{}) // Optional progress logger (nil = silent)
+	API     *api.Manager                             // Shared key rotation and status (nil = per-source)
+	HTTP    *httpclient.Client                       // Shared HTTP client (nil = httpclient.Default())
+	DNS     *resolver.Resolver                       // Shared DNS resolver (nil = resolver.Default())
+}
+
+// Printf writes a progress line through Logf if one is set
+func (o Options) Printf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+// KeyManager registers a source's API keys with the shared manager and
+// returns it. Without a shared manager, a private one is created so key
+// rotation still applies within the source.
+func (o Options) KeyManager(source api.Source, keys []string) *api.Manager {
+	m := o.API
+	if m == nil {
+		m = api.NewManager(true)
+	}
+	m.SetKeys(source, keys)
+	return m
+}
+
+// HTTPClient returns the shared HTTP client, or the package default
+// (direct, default User-Agent) when none is configured
+func (o Options) HTTPClient() *httpclient.Client {
+	if o.HTTP != nil {
+		return o.HTTP
+	}
+	return httpclient.Default()
+}
+
+// Resolver returns the shared DNS resolver, or the package default
+// (caching system resolver) when none is configured
+func (o Options) Resolver() *resolver.Resolver {
+	if o.DNS != nil {
+		return o.DNS
+	}
+	return resolver.Default()
+}
+
+// BaseURL returns the base URL configured for a source in
+// passive_endpoints, or def when no override is set
+func (o Options) BaseURL(source, def string) string {
+	if o.Config == nil {
+		return def
+	}
+	return Endpoint(o.Config.PassiveEndpoints[source], def)
+}
+
+// Endpoint normalizes a base URL override, falling back to def when empty
+// Trailing slashes are trimmed so sources can append their paths directly
+func Endpoint(baseURL, def string) string {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		return def
+	}
+	return baseURL
+}
+
+// Factory creates a configured Source
+// Returns an error wrapping ErrMissingCredentials if credentials are absent
+type Factory func(opts Options) (Source, error)
+
+// Registration describes a source in the registry
+type Registration struct {
+	Name        string   // Unique lowercase name used by --passive-sources
+	Description string   // One-line description for listings
+	Credentials []string // Config keys the source requires (empty = free)
+	Factory     Factory  // Creates the source
+}
+
+// RequiresCredentials reports whether the source needs API credentials
+func (r Registration) RequiresCredentials() bool {
+	return len(r.Credentials) > 0
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Registration)
+)
+
+// Register adds a source to the registry
+// It panics if the name is empty, the factory is nil, or the name is
+// already registered, since these are programming errors in init()
+func Register(r Registration) {
+	name := strings.ToLower(strings.TrimSpace(r.Name))
+	if name == "" {
+		panic("passive: Register called with empty source name")
+	}
+	if r.Factory == nil {
+		panic("passive: Register called with nil factory for " + name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic("passive: Register called twice for source " + name)
+	}
+	r.Name = name
+	registry[name] = r
+}
+
+// Lookup returns the registration for a source name (case-insensitive)
+func Lookup(name string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	r, ok := registry[strings.ToLower(strings.TrimSpace(name))]
+	return r, ok
+}
+
+// Names returns the sorted names of all registered sources
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// All returns all registrations sorted by name
+func All() []Registration {
+	names := Names()
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	regs := make([]Registration, 0, len(names))
+	for _, name := range names {
+		regs = append(regs, registry[name])
+	}
+	return regs
+}
+
+// New creates a registered source by name
+func New(name string, opts Options) (Source, error) {
+	r, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown passive source: %s", name)
+	}
+	return r.Factory(opts)
+}
+
+// MissingCredentials returns an error wrapping ErrMissingCredentials
+// that names the config key a source is missing
+func MissingCredentials(source, key string) error {
+	return fmt.Errorf("%w: %s requires %s", ErrMissingCredentials, source, key)
+}
+
+// NewIPs converts bare IP strings from a source into PassiveIP records
+// observed at the given time
+func NewIPs(source string, ips []string, seen time.Time) []core.PassiveIP {
+	records := make([]core.PassiveIP, 0, len(ips))
+	for _, ip := range ips {
+		records = append(records, core.PassiveIP{
+			IP:        ip,
+			Source:    source,
+			FirstSeen: seen,
+			LastSeen:  seen,
+		})
+	}
+	return records
+}
@@ -0,0 +1,207 @@
+package passive
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+type stubSource struct{ name string }
+
+func (s *stubSource) Name() string                  { return s.name }
+func (s *stubSource) RequiredCredentials() []string { return nil }
+func (s *stubSource) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	return NewIPs(s.name, []string{"192.0.2.1"}, time.Now()), nil
+}
+
+// withRegistry runs fn against an empty registry and restores the original
+func withRegistry(t *testing.T, fn func()) {
+	t.Helper()
+	registryMu.Lock()
+	saved := registry
+	registry = make(map[string]Registration)
+	registryMu.Unlock()
+
+	defer func() {
+		registryMu.Lock()
+		registry = saved
+		registryMu.Unlock()
+	}()
+	fn()
+}
+
+func stubRegistration(name string) Registration {
+	return Registration{
+		Name:        name,
+		Description: "stub " + name,
+		Factory: func(opts Options) (Source, error) {
+			return &stubSource{name: strings.ToLower(name)}, nil
+		},
+	}
+}
+
+func TestRegister_LookupAndNames(t *testing.T) {
+	withRegistry(t, func() {
+		Register(stubRegistration("zeta"))
+		Register(stubRegistration("Alpha"))
+
+		names := Names()
+		if len(names) != 2 || names[0] != "alpha" || names[1] != "zeta" {
+			t.Errorf("Names() = %v, want [alpha zeta]", names)
+		}
+
+		reg, ok := Lookup("ALPHA")
+		if !ok {
+			t.Fatal("Lookup(ALPHA) not found")
+		}
+		if reg.Name != "alpha" {
+			t.Errorf("Registration.Name = %s, want alpha", reg.Name)
+		}
+
+		all := All()
+		if len(all) != 2 || all[0].Name != "alpha" {
+			t.Errorf("All() = %v, want sorted registrations", all)
+		}
+	})
+}
+
+func TestRegister_Panics(t *testing.T) {
+	tests := []struct {
+		name string
+		reg  Registration
+	}{
+		{"empty name", Registration{Factory: stubRegistration("x").Factory}},
+		{"nil factory", Registration{Name: "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withRegistry(t, func() {
+				defer func() {
+					if recover() == nil {
+						t.Error("Register() expected panic")
+					}
+				}()
+				Register(tt.reg)
+			})
+		})
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		withRegistry(t, func() {
+			Register(stubRegistration("dup"))
+			defer func() {
+				if recover() == nil {
+					t.Error("Register() expected panic on duplicate")
+				}
+			}()
+			Register(stubRegistration("dup"))
+		})
+	})
+}
+
+func TestNew(t *testing.T) {
+	withRegistry(t, func() {
+		Register(stubRegistration("stub"))
+
+		src, err := New("stub", Options{})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		ips, err := src.Search(context.Background(), "example.com")
+		if err != nil || len(ips) != 1 || ips[0].Source != "stub" {
+			t.Errorf("Search() = %v, %v", ips, err)
+		}
+
+		if _, err := New("missing", Options{}); err == nil {
+			t.Error("New(missing) expected error")
+		}
+	})
+}
+
+func TestMissingCredentials(t *testing.T) {
+	err := MissingCredentials("shodan", "shodan_keys")
+	if !errors.Is(err, ErrMissingCredentials) {
+		t.Error("MissingCredentials() should wrap ErrMissingCredentials")
+	}
+	if !strings.Contains(err.Error(), "shodan_keys") {
+		t.Errorf("MissingCredentials() = %q, should name the config key", err)
+	}
+}
+
+func TestNewIPs(t *testing.T) {
+	seen := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	ips := NewIPs("ct", []string{"192.0.2.1", "192.0.2.2"}, seen)
+
+	if len(ips) != 2 {
+		t.Fatalf("NewIPs() returned %d records, want 2", len(ips))
+	}
+	for _, ip := range ips {
+		if ip.Source != "ct" || !ip.FirstSeen.Equal(seen) || !ip.LastSeen.Equal(seen) {
+			t.Errorf("NewIPs() record = %+v", ip)
+		}
+	}
+}
+
+func TestOptions_Printf(t *testing.T) {
+	// Nil logger must be safe
+	Options{}.Printf("ignored %d", 1)
+
+	var got string
+	opts := Options{Logf: func(format string, args ...interface{}) {
+		got = format
+	}}
+	opts.Printf("hello")
+	if got != "hello" {
+		t.Errorf("Printf() forwarded %q, want hello", got)
+	}
+}
+
+func TestOptions_BaseURL(t *testing.T) {
+	const def = "https://api.example.com"
+
+	if got := (Options{}).BaseURL("shodan", def); got != def {
+		t.Errorf("BaseURL() without config = %q, want default", got)
+	}
+
+	config := core.DefaultConfig()
+	config.PassiveEndpoints = map[string]string{"shodan": " http://127.0.0.1:9000/ ", "ct": ""}
+	opts := Options{Config: config}
+
+	if got := opts.BaseURL("shodan", def); got != "http://127.0.0.1:9000" {
+		t.Errorf("BaseURL(shodan) = %q, want trimmed override", got)
+	}
+	if got := opts.BaseURL("ct", def); got != def {
+		t.Errorf("BaseURL(ct) with empty override = %q, want default", got)
+	}
+	if got := opts.BaseURL("censys", def); got != def {
+		t.Errorf("BaseURL(censys) = %q, want default", got)
+	}
+}
+
+type variantSource struct {
+	stubSource
+	variant string
+}
+
+func (s *variantSource) CacheVariant() string { return s.variant }
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		src  Source
+		want string
+	}{
+		{&stubSource{name: "ct"}, "ct"},
+		{&variantSource{stubSource{name: "censys"}, ""}, "censys"},
+		{&variantSource{stubSource{name: "censys"}, "cert+site"}, "censys+cert+site"},
+	}
+	for _, tt := range tests {
+		if got := CacheKey(tt.src); got != tt.want {
+			t.Errorf("CacheKey(%s) = %q, want %q", tt.src.Name(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package subdomain
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxPermutations caps the names one permutation pass generates
+const maxPermutations = 5000
+
+// permutationWords are environment and lifecycle words joined to found
+// names with a hyphen (dev-api, api-staging)
+var permutationWords = []string{
+	"dev", "staging", "stage", "test", "qa", "uat",
+	"prod", "old", "new", "origin", "backup", "internal",
+}
+
+// Permutations returns variations of found subdomain names: each word of
+// permutationWords as prefix and suffix, and numeric neighbours (api2 →
+// api1, api3; api → api1, api2, api01). Only the first label of multi-label
+// names is varied. Found names themselves are not included.
+func Permutations(found []string) []string {
+	seen := make(map[string]bool, len(found))
+	for _, name := range found {
+		seen[name] = true
+	}
+
+	var out []string
+	add := func(name string) {
+		if !seen[name] && len(out) < maxPermutations {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+
+	for _, name := range found {
+		label, rest, _ := strings.Cut(name, ".")
+		if rest != "" {
+			rest = "." + rest
+		}
+
+		for _, word := range permutationWords {
+			if label == word {
+				continue
+			}
+			add(word + "-" + label + rest)
+			add(label + "-" + word + rest)
+		}
+		for _, v := range numericVariants(label) {
+			add(v + rest)
+		}
+	}
+	return out
+}
+
+// numericVariants returns the numeric neighbours of a label
+func numericVariants(label string) []string {
+	i := len(label)
+	for i > 0 && label[i-1] >= '0' && label[i-1] <= '9' {
+		i--
+	}
+	base, digits := label[:i], label[i:]
+
+	if digits == "" {
+		return []string{label + "1", label + "2", label + "01"}
+	}
+	if base == "" {
+		return nil // Purely numeric labels are usually not a series
+	}
+
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return nil
+	}
+	var variants []string
+	for _, m := range []int{n - 1, n + 1} {
+		if m >= 0 {
+			variants = append(variants, fmt.Sprintf("%s%0*d", base, len(digits), m))
+		}
+	}
+	return variants
+}
+
+// LoadWordlist reads subdomain names from a file, one per line. Blank lines
+// and # comments are skipped, names are lowercased and de-duplicated, and a
+// trailing dot is removed.
+func LoadWordlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wordlist: %w", err)
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word := strings.TrimSuffix(strings.ToLower(strings.Fields(line)[0]), ".")
+		if word == "" || strings.ContainsAny(word, "*/:") || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read wordlist: %w", err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("wordlist %s has no entries", path)
+	}
+	return words, nil
+}
@@ -0,0 +1,89 @@
+package subdomain
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPermutations(t *testing.T) {
+	got := Permutations([]string{"api", "web2", "dev", "mail.eu"})
+	set := make(map[string]bool)
+	for _, name := range got {
+		if set[name] {
+			t.Errorf("duplicate permutation %q", name)
+		}
+		set[name] = true
+	}
+
+	for _, want := range []string{
+		"dev-api", "api-staging", "api1", "api2", "api01",
+		"web1", "web3", "staging-web2",
+		"dev-staging", "dev1",
+		"dev-mail.eu", "mail-staging.eu", "mail1.eu",
+	} {
+		if !set[want] {
+			t.Errorf("Permutations() missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"api", "dev-dev", "dev", "web2"} {
+		if set[unwanted] {
+			t.Errorf("Permutations() should not contain %q", unwanted)
+		}
+	}
+}
+
+func TestPermutations_Cap(t *testing.T) {
+	var found []string
+	for i := 0; i < 1000; i++ {
+		found = append(found, "host"+string(rune('a'+i%26))+string(rune('a'+i/26)))
+	}
+	if got := Permutations(found); len(got) != maxPermutations {
+		t.Errorf("len(Permutations()) = %d, want cap %d", len(got), maxPermutations)
+	}
+}
+
+func TestNumericVariants(t *testing.T) {
+	tests := []struct {
+		label string
+		want  []string
+	}{
+		{"api", []string{"api1", "api2", "api01"}},
+		{"web2", []string{"web1", "web3"}},
+		{"node09", []string{"node08", "node10"}},
+		{"db0", []string{"db1"}},
+		{"404", nil},
+	}
+	for _, tt := range tests {
+		if got := numericVariants(tt.label); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("numericVariants(%q) = %v, want %v", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestLoadWordlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	content := "# comment\nwww\n\nAPI\napi\nmail.eu.\n*.wild\nstaging extra columns\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := LoadWordlist(path)
+	if err != nil {
+		t.Fatalf("LoadWordlist() error = %v", err)
+	}
+	want := []string{"www", "api", "mail.eu", "staging"}
+	if !reflect.DeepEqual(words, want) {
+		t.Errorf("LoadWordlist() = %v, want %v", words, want)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	os.WriteFile(empty, []byte("# nothing\n"), 0644)
+	if _, err := LoadWordlist(empty); err == nil {
+		t.Error("LoadWordlist() should fail for a list without entries")
+	}
+	if _, err := LoadWordlist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadWordlist() should fail for a missing file")
+	}
+}
@@ -0,0 +1,186 @@
+package subdomain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/resolver"
+)
+
+const (
+	// maxResolverFailures is how many consecutive failures take a resolver out of rotation
+	maxResolverFailures = 3
+
+	// resolverCooldown is how long a failing resolver stays out of rotation
+	resolverCooldown = 30 * time.Second
+)
+
+// ErrNoResolvers is returned when every resolver in the pool is cooling down
+var ErrNoResolvers = errors.New("no DNS resolvers available")
+
+// hostLookuper resolves hostnames; *resolver.Resolver implements it
+type hostLookuper interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Pool spreads lookups across DNS resolvers round-robin. Each resolver has
+// its own rate limit; a resolver that keeps failing (timeouts, refused) is
+// taken out of rotation for a while and the lookup moves on to the next.
+// NXDOMAIN answers are final and do not count as failures.
+type Pool struct {
+	resolvers []*poolResolver
+	next      uint32
+	now       func() time.Time
+}
+
+// poolResolver is one resolver with its rate limit and health
+type poolResolver struct {
+	addr     string
+	lookup   hostLookuper
+	interval time.Duration // Minimum gap between queries (0 = unlimited)
+
+	mu        sync.Mutex
+	nextSlot  time.Time // Earliest time the next query may start
+	failures  int       // Consecutive failures
+	coolUntil time.Time
+}
+
+// NewPool creates a pool of the given resolvers, each limited to rate
+// queries per second (0 = unlimited). Servers are IP addresses ("8.8.8.8",
+// "[2606:4700:4700::1111]:53"), "system", or any resolver.Config server
+// such as "tls://dns.google" or "https://cloudflare-dns.com/dns-query".
+func NewPool(servers []string, rate int) (*Pool, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no DNS resolvers given")
+	}
+
+	var interval time.Duration
+	if rate > 0 {
+		interval = time.Second / time.Duration(rate)
+	}
+
+	p := &Pool{now: time.Now}
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		// Brute-forced names are looked up once, so answers are not cached
+		r, err := resolver.New(resolver.Config{Server: server, CacheTTL: -1})
+		if err != nil {
+			return nil, err
+		}
+		p.resolvers = append(p.resolvers, &poolResolver{addr: r.String(), lookup: r, interval: interval})
+	}
+	if len(p.resolvers) == 0 {
+		return nil, fmt.Errorf("no DNS resolvers given")
+	}
+	return p, nil
+}
+
+// singlePool returns a pool of one resolver
+func singlePool(r *resolver.Resolver) *Pool {
+	return &Pool{now: time.Now, resolvers: []*poolResolver{{addr: r.String(), lookup: r}}}
+}
+
+// Servers returns the resolver addresses in the pool
+func (p *Pool) Servers() []string {
+	servers := make([]string, len(p.resolvers))
+	for i, r := range p.resolvers {
+		servers[i] = r.addr
+	}
+	return servers
+}
+
+// LookupHost resolves host on the next available resolver, failing over to
+// the others on errors other than NXDOMAIN
+func (p *Pool) LookupHost(ctx context.Context, host string) ([]string, error) {
+	start := int(atomic.AddUint32(&p.next, 1) - 1)
+	var lastErr error
+	tried := 0
+
+	for i := 0; i < len(p.resolvers); i++ {
+		r := p.resolvers[(start+i)%len(p.resolvers)]
+		if !r.available(p.now()) {
+			continue
+		}
+		tried++
+
+		if err := r.wait(ctx, p.now); err != nil {
+			return nil, err
+		}
+		addrs, err := r.lookup.LookupHost(ctx, host)
+		if err == nil || resolver.IsNotFound(err) {
+			r.succeeded()
+			return addrs, err
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		r.failed(p.now())
+		lastErr = err
+	}
+
+	if tried == 0 {
+		return nil, ErrNoResolvers
+	}
+	return nil, lastErr
+}
+
+// available reports whether the resolver is in rotation at now
+func (r *poolResolver) available(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !now.Before(r.coolUntil)
+}
+
+// wait blocks until the resolver's rate limit allows another query
+func (r *poolResolver) wait(ctx context.Context, now func() time.Time) error {
+	if r.interval <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	t := now()
+	slot := r.nextSlot
+	if slot.Before(t) {
+		slot = t
+	}
+	r.nextSlot = slot.Add(r.interval)
+	r.mu.Unlock()
+
+	delay := slot.Sub(t)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (r *poolResolver) succeeded() {
+	r.mu.Lock()
+	r.failures = 0
+	r.mu.Unlock()
+}
+
+// failed records a failure, cooling the resolver down after too many in a row
+func (r *poolResolver) failed(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures++
+	if r.failures >= maxResolverFailures {
+		r.failures = 0
+		r.coolUntil = now.Add(resolverCooldown)
+	}
+}
@@ -0,0 +1,176 @@
+package subdomain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/resolver"
+)
+
+// fakeLookuper answers from a table; names missing from it are NXDOMAIN
+// unless err is set, which every lookup then returns
+type fakeLookuper struct {
+	mu    sync.Mutex
+	hosts map[string][]string
+	err   error
+	calls int
+}
+
+func (f *fakeLookuper) LookupHost(ctx context.Context, host string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if addrs, ok := f.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (f *fakeLookuper) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// testPool builds a pool over fake resolvers with a controllable clock
+func testPool(now *time.Time, lookupers ...*fakeLookuper) *Pool {
+	p := &Pool{now: func() time.Time { return *now }}
+	for i, l := range lookupers {
+		p.resolvers = append(p.resolvers, &poolResolver{addr: string(rune('a' + i)), lookup: l})
+	}
+	return p
+}
+
+var errTimeout = &net.DNSError{Err: "i/o timeout", IsTimeout: true}
+
+func TestNewPool(t *testing.T) {
+	p, err := NewPool([]string{"8.8.8.8", " 1.1.1.1:5353 ", "2606:4700:4700::1111", "[2001:db8::1]:53", "system", ""}, 10)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	want := []string{"8.8.8.8:53", "1.1.1.1:5353", "[2606:4700:4700::1111]:53", "[2001:db8::1]:53", "system"}
+	if got := p.Servers(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Servers() = %v, want %v", got, want)
+	}
+	if p.resolvers[0].interval != 100*time.Millisecond {
+		t.Errorf("interval = %v, want 100ms for 10 qps", p.resolvers[0].interval)
+	}
+
+	for _, bad := range [][]string{nil, {""}, {"dns.google"}, {"8.8.8.8:53:53"}} {
+		if _, err := NewPool(bad, 0); err == nil {
+			t.Errorf("NewPool(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	now := time.Now()
+	a := &fakeLookuper{hosts: map[string][]string{"x": {"192.0.2.1"}}}
+	b := &fakeLookuper{hosts: map[string][]string{"x": {"192.0.2.1"}}}
+	p := testPool(&now, a, b)
+
+	for i := 0; i < 4; i++ {
+		if _, err := p.LookupHost(context.Background(), "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.count() != 2 || b.count() != 2 {
+		t.Errorf("calls = %d/%d, want 2/2", a.count(), b.count())
+	}
+}
+
+func TestPool_Failover(t *testing.T) {
+	now := time.Now()
+	broken := &fakeLookuper{err: errTimeout}
+	good := &fakeLookuper{hosts: map[string][]string{"x": {"192.0.2.1"}}}
+	p := testPool(&now, broken, good)
+
+	// Round-robin starts on the broken resolver every other lookup
+	for i := 0; i < 2*maxResolverFailures; i++ {
+		addrs, err := p.LookupHost(context.Background(), "x")
+		if err != nil || len(addrs) != 1 {
+			t.Fatalf("LookupHost() = %v, %v; want failover to the good resolver", addrs, err)
+		}
+	}
+
+	// The broken resolver is now cooling down and is skipped
+	before := broken.count()
+	for i := 0; i < 4; i++ {
+		p.LookupHost(context.Background(), "x")
+	}
+	if broken.count() != before {
+		t.Errorf("cooling resolver queried %d more times", broken.count()-before)
+	}
+
+	// It returns to rotation after the cooldown
+	now = now.Add(resolverCooldown)
+	p.LookupHost(context.Background(), "x")
+	p.LookupHost(context.Background(), "x")
+	if broken.count() == before {
+		t.Error("resolver not back in rotation after cooldown")
+	}
+}
+
+func TestPool_NXDOMAINIsFinal(t *testing.T) {
+	now := time.Now()
+	a := &fakeLookuper{}
+	b := &fakeLookuper{}
+	p := testPool(&now, a, b)
+
+	_, err := p.LookupHost(context.Background(), "missing")
+	if !resolver.IsNotFound(err) {
+		t.Fatalf("LookupHost() error = %v, want NXDOMAIN", err)
+	}
+	if a.count()+b.count() != 1 {
+		t.Errorf("calls = %d, want NXDOMAIN from one resolver only", a.count()+b.count())
+	}
+}
+
+func TestPool_AllFailing(t *testing.T) {
+	now := time.Now()
+	p := testPool(&now, &fakeLookuper{err: errTimeout})
+
+	if _, err := p.LookupHost(context.Background(), "x"); !errors.Is(err, errTimeout) {
+		t.Errorf("LookupHost() error = %v, want the resolver error", err)
+	}
+	for i := 0; i < maxResolverFailures; i++ {
+		p.LookupHost(context.Background(), "x")
+	}
+	if _, err := p.LookupHost(context.Background(), "x"); !errors.Is(err, ErrNoResolvers) {
+		t.Errorf("LookupHost() error = %v, want ErrNoResolvers", err)
+	}
+}
+
+func TestPool_RateLimit(t *testing.T) {
+	p := &Pool{now: time.Now, resolvers: []*poolResolver{{
+		addr:     "a",
+		lookup:   &fakeLookuper{hosts: map[string][]string{"x": {"192.0.2.1"}}},
+		interval: 20 * time.Millisecond,
+	}}}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		p.LookupHost(context.Background(), "x")
+	}
+	// The first query is immediate; the next four wait one interval each
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 queries took %v, want >= 80ms at 50 qps", elapsed)
+	}
+
+	// Waiting for a slot respects the context
+	p.resolvers[0].interval = time.Hour
+	p.resolvers[0].nextSlot = time.Now().Add(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.LookupHost(ctx, "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LookupHost() error = %v, want deadline exceeded", err)
+	}
+}
@@ -3,12 +3,20 @@ package subdomain
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
+// wildcardProbes is how many random names are resolved to detect wildcard DNS
+const wildcardProbes = 3
+
 // CommonSubdomains is a list of frequently used subdomains
 var CommonSubdomains = []string{
 	// Mail & Communication
@@ -50,25 +58,60 @@ var CommonSubdomains = []string{
 
 // Scanner performs subdomain enumeration
 type Scanner struct {
-	domain     string
-	workers    int
-	timeout    time.Duration
-	dnsServers []string
-	mu         sync.Mutex
-	discovered map[string][]string // subdomain -> IPs
+	domain       string
+	workers      int
+	timeout      time.Duration
+	dnsServers   []string
+	pool         *Pool
+	permutations bool
+	mu           sync.Mutex
+	discovered   map[string][]string // subdomain -> IPs
+	tried        map[string]bool     // Names already resolved
+	wildcard     map[string]bool     // IPs random names resolve to (nil = not probed yet)
+	filtered     int                 // Names dropped as wildcard answers
 }
 
 // NewScanner creates a new subdomain scanner
+// Lookups go to the system resolver until SetResolver or SetResolvers
+// replaces it
 func NewScanner(domain string, workers int, timeout time.Duration) *Scanner {
+	pool := singlePool(resolver.Default())
 	return &Scanner{
 		domain:     domain,
 		workers:    workers,
 		timeout:    timeout,
-		dnsServers: []string{"8.8.8.8:53", "1.1.1.1:53"}, // Google, Cloudflare DNS
+		dnsServers: pool.Servers(),
+		pool:       pool,
 		discovered: make(map[string][]string),
+		tried:      make(map[string]bool),
 	}
 }
 
+// SetResolvers replaces the resolver pool, limiting each resolver to rate
+// queries per second (0 = unlimited). The given resolvers are the only
+// ones queried.
+func (s *Scanner) SetResolvers(servers []string, rate int) error {
+	pool, err := NewPool(servers, rate)
+	if err != nil {
+		return err
+	}
+	s.pool = pool
+	s.dnsServers = pool.Servers()
+	return nil
+}
+
+// SetResolver sends every lookup to r, replacing the resolver pool
+func (s *Scanner) SetResolver(r *resolver.Resolver) {
+	s.pool = singlePool(r)
+	s.dnsServers = s.pool.Servers()
+}
+
+// SetPermutations enables a second pass over variations (dev-api,
+// api-staging, api2, ...) of the names the first pass found
+func (s *Scanner) SetPermutations(enabled bool) {
+	s.permutations = enabled
+}
+
 // Result represents a subdomain scan result
 type Result struct {
 	Subdomain string
@@ -77,11 +120,41 @@ type Result struct {
 }
 
 // Scan performs subdomain enumeration
+// On wildcard zones, names resolving only to the wildcard IPs are dropped
+// and wildcard IPs are removed from the rest
 func (s *Scanner) Scan(ctx context.Context, subdomains []string) (map[string][]string, error) {
 	if len(subdomains) == 0 {
 		subdomains = CommonSubdomains
 	}
 
+	if s.wildcard == nil {
+		s.detectWildcard(ctx)
+	}
+
+	found := s.resolveAll(ctx, subdomains)
+	if s.permutations && len(found) > 0 && ctx.Err() == nil {
+		s.resolveAll(ctx, Permutations(found))
+	}
+
+	return s.discovered, nil
+}
+
+// resolveAll resolves the names not tried yet with the worker pool and
+// records the ones that resolve to non-wildcard IPs, which it returns
+func (s *Scanner) resolveAll(ctx context.Context, names []string) []string {
+	var subdomains []string
+	s.mu.Lock()
+	for _, name := range names {
+		if !s.tried[name] {
+			s.tried[name] = true
+			subdomains = append(subdomains, name)
+		}
+	}
+	s.mu.Unlock()
+	if len(subdomains) == 0 {
+		return nil
+	}
+
 	// Create work channel
 	jobs := make(chan string, len(subdomains))
 	results := make(chan Result, len(subdomains))
@@ -97,7 +170,7 @@ func (s *Scanner) Scan(ctx context.Context, subdomains []string) (map[string][]s
 				case <-ctx.Done():
 					return
 				default:
-					ips, err := s.resolveSubdomain(subdomain)
+					ips, err := s.resolveSubdomain(ctx, subdomain)
 					results <- Result{
 						Subdomain: subdomain,
 						IPs:       ips,
@@ -121,31 +194,92 @@ func (s *Scanner) Scan(ctx context.Context, subdomains []string) (map[string][]s
 	}()
 
 	// Collect results
+	var found []string
 	for result := range results {
-		if result.Error == nil && len(result.IPs) > 0 {
-			s.mu.Lock()
-			s.discovered[result.Subdomain] = result.IPs
-			s.mu.Unlock()
+		if result.Error != nil || len(result.IPs) == 0 {
+			continue
+		}
+		ips := s.withoutWildcard(result.IPs)
+		s.mu.Lock()
+		if len(ips) == 0 {
+			s.filtered++
+		} else {
+			s.discovered[result.Subdomain] = ips
+			found = append(found, result.Subdomain)
 		}
+		s.mu.Unlock()
 	}
 
-	return s.discovered, nil
+	sort.Strings(found)
+	return found
 }
 
-// resolveSubdomain resolves a subdomain to IP addresses
-func (s *Scanner) resolveSubdomain(subdomain string) ([]string, error) {
-	target := fmt.Sprintf("%s.%s", subdomain, s.domain)
+// detectWildcard resolves random names under the domain; any IPs they
+// return are wildcard answers
+func (s *Scanner) detectWildcard(ctx context.Context) {
+	s.wildcard = make(map[string]bool)
+	for i := 0; i < wildcardProbes; i++ {
+		ips, err := s.resolveSubdomain(ctx, randomLabel())
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			s.wildcard[ip] = true
+		}
+	}
+}
+
+// randomLabel returns a label no real zone is likely to contain
+func randomLabel() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "odw" + hex.EncodeToString(b)
+}
 
-	// Create custom resolver with timeout
-	resolver := &net.Resolver{
-		PreferGo: true,
+// withoutWildcard returns ips minus the detected wildcard IPs
+func (s *Scanner) withoutWildcard(ips []string) []string {
+	if len(s.wildcard) == 0 {
+		return ips
 	}
+	var kept []string
+	for _, ip := range ips {
+		if !s.wildcard[ip] {
+			kept = append(kept, ip)
+		}
+	}
+	return kept
+}
+
+// WildcardIPs returns the IPs the domain's wildcard record resolves to,
+// or nil when the zone has no wildcard
+func (s *Scanner) WildcardIPs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ips []string
+	for ip := range s.wildcard {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// Filtered returns how many names were dropped as wildcard answers
+func (s *Scanner) Filtered() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filtered
+}
+
+// resolveSubdomain resolves a subdomain to IP addresses through the resolver pool
+func (s *Scanner) resolveSubdomain(ctx context.Context, subdomain string) ([]string, error) {
+	target := fmt.Sprintf("%s.%s", subdomain, s.domain)
 
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
 	// Resolve A records
-	ips, err := resolver.LookupHost(ctx, target)
+	ips, err := s.pool.LookupHost(ctx, target)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package subdomain
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -21,8 +22,8 @@ func TestNewScanner(t *testing.T) {
 	if scanner.timeout != 3*time.Second {
 		t.Errorf("timeout = %v, want 3s", scanner.timeout)
 	}
-	if len(scanner.dnsServers) != 2 {
-		t.Errorf("dnsServers count = %d, want 2", len(scanner.dnsServers))
+	if !reflect.DeepEqual(scanner.dnsServers, []string{"system"}) {
+		t.Errorf("dnsServers = %v, want the system resolver", scanner.dnsServers)
 	}
 	if scanner.discovered == nil {
 		t.Error("discovered map should be initialized")
@@ -212,7 +213,7 @@ func TestCommonSubdomains_NotEmpty(t *testing.T) {
 func TestScanner_ResolveSubdomain_ValidDomain(t *testing.T) {
 	scanner := NewScanner("google.com", 5, 5*time.Second)
 
-	ips, err := scanner.resolveSubdomain("www")
+	ips, err := scanner.resolveSubdomain(context.Background(), "www")
 	if err != nil {
 		t.Logf("Resolution failed (expected in test env): %v", err)
 		return
@@ -233,7 +234,7 @@ func TestScanner_ResolveSubdomain_ValidDomain(t *testing.T) {
 func TestScanner_ResolveSubdomain_InvalidDomain(t *testing.T) {
 	scanner := NewScanner("example.com", 5, 2*time.Second)
 
-	ips, err := scanner.resolveSubdomain("nonexistent99999")
+	ips, err := scanner.resolveSubdomain(context.Background(), "nonexistent99999")
 	if err == nil {
 		t.Log("Resolution succeeded unexpectedly")
 	}
@@ -246,7 +247,7 @@ func TestScanner_ResolveSubdomain_InvalidDomain(t *testing.T) {
 func TestScanner_ResolveSubdomain_Timeout(t *testing.T) {
 	scanner := NewScanner("example.com", 5, 1*time.Nanosecond)
 
-	_, err := scanner.resolveSubdomain("www")
+	_, err := scanner.resolveSubdomain(context.Background(), "www")
 	if err == nil {
 		t.Log("Resolution succeeded despite timeout (might be cached)")
 	}
@@ -317,3 +318,107 @@ func TestScanner_MultipleScanCalls(t *testing.T) {
 		t.Logf("Accumulated results: %d subdomains", len(results2))
 	}
 }
+
+// fakeScanner returns a scanner whose lookups are answered by hosts
+func fakeScanner(domain string, hosts map[string][]string) (*Scanner, *fakeLookuper) {
+	lookup := &fakeLookuper{hosts: hosts}
+	scanner := NewScanner(domain, 4, time.Second)
+	scanner.pool = &Pool{now: time.Now, resolvers: []*poolResolver{{addr: "fake", lookup: lookup}}}
+	return scanner, lookup
+}
+
+func TestScanner_WildcardFiltering(t *testing.T) {
+	scanner, _ := fakeScanner("example.com", map[string][]string{
+		"www.example.com":    {"192.0.2.1", "198.51.100.7"},
+		"origin.example.com": {"203.0.113.5"},
+	})
+	// Every other name resolves to the wildcard IPs
+	scanner.pool.resolvers[0].lookup = wildcardLookuper{
+		fakeLookuper: scanner.pool.resolvers[0].lookup.(*fakeLookuper),
+		wildcard:     []string{"192.0.2.1", "192.0.2.2"},
+	}
+
+	results, err := scanner.Scan(context.Background(), []string{"www", "origin", "nothere", "api"})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	want := map[string][]string{
+		"www":    {"198.51.100.7"},
+		"origin": {"203.0.113.5"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("Scan() = %v, want %v", results, want)
+	}
+	if got := scanner.WildcardIPs(); !reflect.DeepEqual(got, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Errorf("WildcardIPs() = %v", got)
+	}
+	if scanner.Filtered() != 2 {
+		t.Errorf("Filtered() = %d, want 2", scanner.Filtered())
+	}
+}
+
+// wildcardLookuper answers names missing from the table with the wildcard IPs
+type wildcardLookuper struct {
+	*fakeLookuper
+	wildcard []string
+}
+
+func (w wildcardLookuper) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, err := w.fakeLookuper.LookupHost(ctx, host); err == nil {
+		return addrs, nil
+	}
+	return w.wildcard, nil
+}
+
+func TestScanner_NoWildcard(t *testing.T) {
+	scanner, lookup := fakeScanner("example.com", map[string][]string{"www.example.com": {"192.0.2.1"}})
+
+	results, _ := scanner.Scan(context.Background(), []string{"www"})
+	if len(results) != 1 || scanner.WildcardIPs() != nil || scanner.Filtered() != 0 {
+		t.Errorf("Scan() = %v, wildcard = %v", results, scanner.WildcardIPs())
+	}
+
+	// Wildcard probes run once per scanner; names are not re-resolved
+	calls := lookup.count()
+	scanner.Scan(context.Background(), []string{"www"})
+	if lookup.count() != calls {
+		t.Errorf("second Scan() made %d lookups, want 0", lookup.count()-calls)
+	}
+}
+
+func TestScanner_Permutations(t *testing.T) {
+	hosts := map[string][]string{
+		"api.example.com":     {"192.0.2.1"},
+		"dev-api.example.com": {"192.0.2.2"},
+		"api2.example.com":    {"192.0.2.3"},
+	}
+
+	scanner, _ := fakeScanner("example.com", hosts)
+	results, _ := scanner.Scan(context.Background(), []string{"api"})
+	if len(results) != 1 {
+		t.Errorf("Scan() without permutations = %v, want only api", results)
+	}
+
+	scanner, _ = fakeScanner("example.com", hosts)
+	scanner.SetPermutations(true)
+	results, _ = scanner.Scan(context.Background(), []string{"api"})
+	for _, name := range []string{"api", "dev-api", "api2"} {
+		if _, ok := results[name]; !ok {
+			t.Errorf("Scan() with permutations missing %s: %v", name, results)
+		}
+	}
+}
+
+func TestScanner_SetResolvers(t *testing.T) {
+	scanner := NewScanner("example.com", 4, time.Second)
+	if err := scanner.SetResolvers([]string{"9.9.9.9", "149.112.112.112:53"}, 5); err != nil {
+		t.Fatalf("SetResolvers() error = %v", err)
+	}
+	if !reflect.DeepEqual(scanner.dnsServers, []string{"9.9.9.9:53", "149.112.112.112:53"}) {
+		t.Errorf("dnsServers = %v", scanner.dnsServers)
+	}
+	if err := scanner.SetResolvers([]string{"not-an-ip"}, 0); err == nil {
+		t.Error("SetResolvers() should reject hostnames")
+	}
+}
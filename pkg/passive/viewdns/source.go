@@ -0,0 +1,75 @@
+package viewdns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
+)
+
+// SourceName is the registry name of the ViewDNS source
+const SourceName = "viewdns"
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "ViewDNS reverse IP lookup",
+		Credentials: []string{"viewdns_keys"},
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			if len(opts.Config.ViewDNSKeys) == 0 {
+				return nil, passive.MissingCredentials(SourceName, "viewdns_keys")
+			}
+			return &Source{
+				manager: opts.KeyManager(api.SourceViewDNS, opts.Config.ViewDNSKeys),
+				client:  opts.HTTPClient(),
+				dns:     opts.Resolver(),
+				baseURL: opts.BaseURL(SourceName, DefaultBaseURL),
+				timeout: opts.Timeout,
+			}, nil
+		},
+	})
+}
+
+// Source implements passive.Source for ViewDNS
+type Source struct {
+	manager *api.Manager
+	client  *httpclient.Client
+	dns     *resolver.Resolver
+	baseURL string
+	timeout time.Duration
+}
+
+// NewSource creates a ViewDNS source using the given API keys
+// An empty baseURL uses DefaultBaseURL
+func NewSource(keys []string, baseURL string, timeout time.Duration) *Source {
+	return &Source{
+		manager: passive.Options{}.KeyManager(api.SourceViewDNS, keys),
+		client:  httpclient.Default(),
+		dns:     resolver.Default(),
+		baseURL: passive.Endpoint(baseURL, DefaultBaseURL),
+		timeout: timeout,
+	}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns the config keys the source needs
+func (s *Source) RequiredCredentials() []string { return []string{"viewdns_keys"} }
+
+// Search queries ViewDNS for IPs related to the domain
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ips, err := search(ctx, s.manager, s.client, s.dns, s.baseURL, domain)
+	if err != nil {
+		return nil, fmt.Errorf("ViewDNS search failed: %w", err)
+	}
+	return passive.NewIPs(SourceName, ips, time.Now()), nil
+}
@@ -5,14 +5,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
+// DefaultBaseURL is the ViewDNS API base URL
+const DefaultBaseURL = "https://api.viewdns.info"
+
 // ViewDNSResponse represents the ViewDNS API response for reverse IP lookup
 type ViewDNSResponse struct {
 	Query    ViewDNSQuery   `json:"query"`
@@ -43,88 +48,61 @@ func SearchReverseIP(ctx context.Context, domain string, apiKeys []string, timeo
 		return []string{}, fmt.Errorf("no ViewDNS API keys provided")
 	}
 
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceViewDNS, apiKeys)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return search(ctx, manager, httpclient.Default(), resolver.Default(), DefaultBaseURL, domain)
+}
+
+// search resolves the domain and runs the reverse IP lookup through the
+// manager's key rotation
+func search(ctx context.Context, manager *api.Manager, client *httpclient.Client, dns *resolver.Resolver, baseURL, domain string) ([]string, error) {
 	// First resolve the domain to get its current IP
-	resolver := &net.Resolver{}
-	addrs, err := resolver.LookupHost(ctx, domain)
+	addrs, err := dns.LookupIPv4(ctx, domain)
 	if err != nil {
 		return []string{}, fmt.Errorf("failed to resolve domain: %w", err)
 	}
 
-	if len(addrs) == 0 {
-		return []string{}, fmt.Errorf("no IP addresses found for domain")
-	}
-
 	// Use the first IPv4 address
-	var targetIP string
-	for _, addr := range addrs {
-		ip := net.ParseIP(addr)
-		if ip != nil && ip.To4() != nil {
-			targetIP = addr
-			break
-		}
-	}
-
-	if targetIP == "" {
+	if len(addrs) == 0 {
 		return []string{}, fmt.Errorf("no IPv4 address found for domain")
 	}
+	targetIP := addrs[0]
 
-	// Try each API key until one works
-	var lastErr error
-	for i, apiKey := range apiKeys {
-		apiKey = strings.TrimSpace(apiKey)
-		if apiKey == "" {
-			continue
-		}
-
-		ips, err := reverseIPWithKey(ctx, targetIP, apiKey, timeout)
-		if err == nil {
-			return ips, nil
-		}
-
-		// Check for rate limit errors
-		if strings.Contains(err.Error(), "rate limit") || strings.Contains(err.Error(), "429") {
-			lastErr = fmt.Errorf("key %d/%d rate limited: %w", i+1, len(apiKeys), err)
-			continue // Try next key
-		}
-
-		// For other errors, return immediately
-		return []string{}, fmt.Errorf("key %d/%d failed: %w", i+1, len(apiKeys), err)
-	}
-
-	if lastErr != nil {
-		return []string{}, fmt.Errorf("all %d API keys exhausted: %w", len(apiKeys), lastErr)
+	var ips []string
+	err = manager.Do(ctx, api.SourceViewDNS, func(apiKey string) error {
+		var err error
+		ips, err = reverseIPWithKey(ctx, client, dns, baseURL, targetIP, apiKey)
+		return err
+	})
+	if err != nil {
+		return []string{}, err
 	}
-
-	return []string{}, fmt.Errorf("no valid API keys found")
+	return ips, nil
 }
 
 // reverseIPWithKey performs reverse IP lookup with a single API key
-func reverseIPWithKey(ctx context.Context, ipAddr, apiKey string, timeout time.Duration) ([]string, error) {
-	apiURL := fmt.Sprintf("https://api.viewdns.info/reverseip/?host=%s&apikey=%s&output=json",
+func reverseIPWithKey(ctx context.Context, client *httpclient.Client, dns *resolver.Resolver, baseURL, ipAddr, apiKey string) ([]string, error) {
+	apiURL := fmt.Sprintf("%s/reverseip/?host=%s&apikey=%s&output=json", baseURL,
 		url.QueryEscape(ipAddr), url.QueryEscape(apiKey))
 
-	client := &http.Client{
-		Timeout: timeout,
-	}
-
 	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "origindive/1.0")
-
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("ViewDNS request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, api.NewRateLimitError(resp.Response, "")
+	}
 	if resp.StatusCode != http.StatusOK {
 		bodyStr := string(body)
 		if len(bodyStr) > 200 {
@@ -146,7 +124,6 @@ func reverseIPWithKey(ctx context.Context, ipAddr, apiKey string, timeout time.D
 
 	// Resolve discovered domains to IPs
 	ipSet := make(map[string]bool)
-	resolver := &net.Resolver{}
 
 	for _, domainEntry := range vdnsResp.Response.Domains {
 		domainName := strings.TrimSpace(domainEntry.Name)
@@ -156,19 +133,15 @@ func reverseIPWithKey(ctx context.Context, ipAddr, apiKey string, timeout time.D
 
 		// Resolve with short timeout
 		resolveCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
-		addrs, err := resolver.LookupHost(resolveCtx, domainName)
+		addrs, err := dns.LookupIPv4(resolveCtx, domainName)
 		cancel()
 
 		if err != nil {
 			continue // Skip failed resolutions
 		}
 
-		// Filter IPv4 only
 		for _, addr := range addrs {
-			ip := net.ParseIP(addr)
-			if ip != nil && ip.To4() != nil {
-				ipSet[addr] = true
-			}
+			ipSet[addr] = true
 		}
 	}
 
@@ -7,6 +7,9 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
 func TestSearchReverseIP_NoAPIKeys(t *testing.T) {
@@ -95,7 +98,7 @@ func TestReverseIPWithKey_HTTPError(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := reverseIPWithKey(ctx, "192.168.1.1", "invalid_key", 1*time.Second)
+	_, err := reverseIPWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "192.168.1.1", "invalid_key")
 	if err == nil {
 		t.Log("Expected error for HTTP error")
 	}
@@ -114,7 +117,7 @@ func TestReverseIPWithKey_APIError(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := reverseIPWithKey(ctx, "192.168.1.1", "test_key", 1*time.Second)
+	_, err := reverseIPWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "192.168.1.1", "test_key")
 	if err == nil {
 		t.Log("Expected API error")
 	}
@@ -128,7 +131,7 @@ func TestReverseIPWithKey_InvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := reverseIPWithKey(ctx, "192.168.1.1", "test_key", 1*time.Second)
+	_, err := reverseIPWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "192.168.1.1", "test_key")
 	if err == nil {
 		t.Log("Expected JSON parsing error")
 	}
@@ -198,7 +201,7 @@ func TestReverseIPWithKey_EmptyDomainList(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := reverseIPWithKey(ctx, "192.168.1.1", "test_key", 1*time.Second)
+	_, err := reverseIPWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "192.168.1.1", "test_key")
 	if err == nil {
 		t.Log("Empty domain list handled")
 	}
@@ -208,7 +211,7 @@ func TestReverseIPWithKey_ContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // Cancel immediately
 
-	_, err := reverseIPWithKey(ctx, "192.168.1.1", "test_key", 5*time.Second)
+	_, err := reverseIPWithKey(ctx, httpclient.Default(), resolver.Default(), DefaultBaseURL, "192.168.1.1", "test_key")
 	if err == nil {
 		t.Log("Expected error for cancelled context")
 	}
@@ -236,7 +239,7 @@ func TestReverseIPWithKey_LongErrorBody(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := reverseIPWithKey(ctx, "192.168.1.1", "test_key", 1*time.Second)
+	_, err := reverseIPWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "192.168.1.1", "test_key")
 	if err == nil {
 		t.Log("Expected error for bad request")
 	} else if len(err.Error()) > 300 {
@@ -259,7 +262,7 @@ func TestReverseIPWithKey_URLEscaping(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 	defer cancel()
 
-	_, err := reverseIPWithKey(ctx, "192.168.1.1", "key&special=chars", 1*time.Second)
+	_, err := reverseIPWithKey(ctx, httpclient.Default(), resolver.Default(), DefaultBaseURL, "192.168.1.1", "key&special=chars")
 	if err == nil {
 		t.Log("URL escaping test completed")
 	}
@@ -5,13 +5,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
+// DefaultBaseURL is the VirusTotal API base URL
+const DefaultBaseURL = "https://www.virustotal.com"
+
 // VTSubdomainResponse represents the VirusTotal subdomains API response
 type VTSubdomainResponse struct {
 	Data  []VTDomainData `json:"data"`
@@ -55,43 +61,32 @@ func SearchSubdomains(ctx context.Context, domain string, apiKeys []string, time
 		return []string{}, fmt.Errorf("no VirusTotal API keys provided")
 	}
 
-	// Try each API key until one works
-	var lastErr error
-	for i, apiKey := range apiKeys {
-		apiKey = strings.TrimSpace(apiKey)
-		if apiKey == "" {
-			continue
-		}
-
-		ips, err := searchWithKey(ctx, domain, apiKey, timeout)
-		if err == nil {
-			return ips, nil
-		}
-
-		// Check for rate limit errors (204 or 429)
-		if strings.Contains(err.Error(), "rate limit") || strings.Contains(err.Error(), "429") || strings.Contains(err.Error(), "204") {
-			lastErr = fmt.Errorf("key %d/%d rate limited: %w", i+1, len(apiKeys), err)
-			continue // Try next key
-		}
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceVirusTotal, apiKeys)
 
-		// For other errors, return immediately
-		return []string{}, fmt.Errorf("key %d/%d failed: %w", i+1, len(apiKeys), err)
-	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return search(ctx, manager, httpclient.Default(), resolver.Default(), DefaultBaseURL, domain)
+}
 
-	if lastErr != nil {
-		return []string{}, fmt.Errorf("all %d API keys exhausted: %w", len(apiKeys), lastErr)
+// search runs the query through the manager's key rotation
+// Rate limited keys are cooled down and the next key is tried
+func search(ctx context.Context, manager *api.Manager, client *httpclient.Client, dns *resolver.Resolver, baseURL, domain string) ([]string, error) {
+	var ips []string
+	err := manager.Do(ctx, api.SourceVirusTotal, func(apiKey string) error {
+		var err error
+		ips, err = searchWithKey(ctx, client, dns, baseURL, domain, apiKey)
+		return err
+	})
+	if err != nil {
+		return []string{}, err
 	}
-
-	return []string{}, fmt.Errorf("no valid API keys found")
+	return ips, nil
 }
 
 // searchWithKey performs the search with a single API key
-func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Duration) ([]string, error) {
-	url := fmt.Sprintf("https://www.virustotal.com/api/v3/domains/%s/subdomains?limit=40", domain)
-
-	client := &http.Client{
-		Timeout: timeout,
-	}
+func searchWithKey(ctx context.Context, client *httpclient.Client, dns *resolver.Resolver, baseURL, domain, apiKey string) ([]string, error) {
+	url := fmt.Sprintf("%s/api/v3/domains/%s/subdomains?limit=40", baseURL, domain)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -100,23 +95,18 @@ func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Dura
 
 	// VirusTotal uses x-apikey header
 	req.Header.Set("x-apikey", apiKey)
-	req.Header.Set("User-Agent", "origindive/1.0")
 
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("VirusTotal request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
 	// Check for rate limiting (204 No Content or 429)
 	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == 429 {
-		return nil, fmt.Errorf("rate limit exceeded (HTTP %d)", resp.StatusCode)
+		return nil, api.NewRateLimitError(resp.Response, "")
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
@@ -171,7 +161,7 @@ func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Dura
 		if subdomain != "" {
 			// Resolve with short timeout to avoid delays
 			resolveCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
-			addrs, err := net.DefaultResolver.LookupHost(resolveCtx, subdomain)
+			addrs, err := dns.LookupHost(resolveCtx, subdomain)
 			cancel()
 
 			if err == nil {
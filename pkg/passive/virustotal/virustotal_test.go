@@ -7,6 +7,9 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
 func TestSearchSubdomains_NoAPIKeys(t *testing.T) {
@@ -136,7 +139,7 @@ func TestSearchWithKey_HTTPError(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "invalid_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "example.com", "invalid_key")
 	if err == nil {
 		t.Log("Expected error for invalid key")
 	}
@@ -149,7 +152,7 @@ func TestSearchWithKey_NoContent(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Expected rate limit error for 204")
 	}
@@ -163,7 +166,7 @@ func TestSearchWithKey_InvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Expected JSON parsing error")
 	}
@@ -256,7 +259,7 @@ func TestSearchWithKey_LongResponseBody(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "example.com", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), resolver.Default(), server.URL, "example.com", "test_key")
 	if err == nil {
 		t.Log("Expected error for bad request")
 	} else if len(err.Error()) > 300 {
@@ -306,7 +309,7 @@ func TestSearchSubdomains_WhitespaceKeys(t *testing.T) {
 
 func TestSearchWithKey_EmptyDomain(t *testing.T) {
 	ctx := context.Background()
-	_, err := searchWithKey(ctx, "", "test_key", 1*time.Second)
+	_, err := searchWithKey(ctx, httpclient.Default(), resolver.Default(), DefaultBaseURL, "", "test_key")
 
 	if err == nil {
 		t.Log("Expected error for empty domain")
@@ -0,0 +1,67 @@
+package wayback
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
+)
+
+// SourceName is the registry name of the Wayback Machine source
+const SourceName = "wayback"
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "Wayback Machine CDX archive subdomains",
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			return &Source{
+				client:  opts.HTTPClient(),
+				dns:     opts.Resolver(),
+				baseURL: opts.BaseURL(SourceName, DefaultBaseURL),
+				timeout: opts.Timeout,
+			}, nil
+		},
+	})
+}
+
+// Source implements passive.Source for the Wayback Machine
+type Source struct {
+	client  *httpclient.Client
+	dns     *resolver.Resolver
+	baseURL string
+	timeout time.Duration
+}
+
+// NewSource creates a Wayback Machine source
+// An empty baseURL uses DefaultBaseURL
+func NewSource(baseURL string, timeout time.Duration) *Source {
+	return &Source{
+		client:  httpclient.Default(),
+		dns:     resolver.Default(),
+		baseURL: passive.Endpoint(baseURL, DefaultBaseURL),
+		timeout: timeout,
+	}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns nil; the CDX API needs no API key
+func (s *Source) RequiredCredentials() []string { return nil }
+
+// Search finds archived subdomains and resolves them to IPs
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ips, err := search(ctx, s.client, s.dns, s.baseURL, domain, s.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Wayback Machine search failed: %w", err)
+	}
+	return passive.NewIPs(SourceName, ips, time.Now()), nil
+}
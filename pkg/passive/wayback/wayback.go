@@ -5,56 +5,59 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
+// DefaultBaseURL is the Wayback Machine base URL
+const DefaultBaseURL = "http://web.archive.org"
+
 // CDXRecord represents a single record from the Wayback Machine CDX API
 type CDXRecord []string
 
 // SearchSubdomains queries the Wayback Machine CDX API for historical subdomains
 func SearchSubdomains(ctx context.Context, domain string, timeout time.Duration) ([]string, error) {
-	// Build URL: query for *.domain.com with JSON output and collapse by urlkey
-	url := fmt.Sprintf("http://web.archive.org/cdx/search/cdx?url=*.%s&output=json&collapse=urlkey&fl=original", domain)
+	return search(ctx, httpclient.Default(), resolver.Default(), DefaultBaseURL, domain, timeout)
+}
 
-	client := &http.Client{
-		Timeout: timeout,
-	}
+// search queries the CDX API at baseURL
+func search(ctx context.Context, client *httpclient.Client, dns *resolver.Resolver, baseURL, domain string, timeout time.Duration) ([]string, error) {
+	// Build URL: query for *.domain.com with JSON output and collapse by urlkey
+	url := fmt.Sprintf("%s/cdx/search/cdx?url=*.%s&output=json&collapse=urlkey&fl=original", baseURL, domain)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "origindive/1.0")
-
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("Wayback Machine request failed: %w (archive.org may be temporarily unavailable)", err)
 	}
-	defer resp.Body.Close()
 
-	// Check for service unavailability
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, api.NewRateLimitError(resp.Response, "archive.org")
+	}
+
+	// Check for service unavailability (still failing after retries)
 	if resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout || resp.StatusCode == http.StatusBadGateway {
 		return nil, fmt.Errorf("Wayback Machine API is temporarily unavailable (status %d). Please try again later", resp.StatusCode)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		bodyStr := string(body)
+		bodyStr := string(resp.Data)
 		if len(bodyStr) > 200 {
 			bodyStr = bodyStr[:200] + "..."
 		}
 		return nil, fmt.Errorf("Wayback Machine returned status %d: %s", resp.StatusCode, strings.TrimSpace(bodyStr))
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	// Parse JSON array of arrays
 	var records []CDXRecord
@@ -91,7 +94,7 @@ func SearchSubdomains(ctx context.Context, domain string, timeout time.Duration)
 	}
 
 	// Resolve subdomains to IPs (limit to first 100 to avoid long delays)
-	return resolveSubdomainsToIPs(ctx, subdomains, domain, 100, 3*time.Second)
+	return resolveSubdomainsToIPs(ctx, dns, subdomains, domain, 100, 3*time.Second)
 }
 
 // extractSubdomain extracts the subdomain from a URL
@@ -128,9 +131,8 @@ func extractSubdomain(urlStr, baseDomain string) string {
 }
 
 // resolveSubdomainsToIPs resolves a list of subdomains to IPv4 addresses
-func resolveSubdomainsToIPs(ctx context.Context, subdomains []string, baseDomain string, maxResolve int, timeout time.Duration) ([]string, error) {
+func resolveSubdomainsToIPs(ctx context.Context, dns *resolver.Resolver, subdomains []string, baseDomain string, maxResolve int, timeout time.Duration) ([]string, error) {
 	ipSet := make(map[string]bool)
-	resolver := &net.Resolver{}
 
 	resolveCount := 0
 	for _, subdomain := range subdomains {
@@ -139,19 +141,15 @@ func resolveSubdomainsToIPs(ctx context.Context, subdomains []string, baseDomain
 		}
 
 		ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
-		addrs, err := resolver.LookupHost(ctxWithTimeout, subdomain)
+		addrs, err := dns.LookupIPv4(ctxWithTimeout, subdomain)
 		cancel()
 
 		if err != nil {
 			continue // Skip failed resolutions
 		}
 
-		// Filter IPv4 only
 		for _, addr := range addrs {
-			ip := net.ParseIP(addr)
-			if ip != nil && ip.To4() != nil {
-				ipSet[addr] = true
-			}
+			ipSet[addr] = true
 		}
 
 		resolveCount++
@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/resolver"
 )
 
 func TestSearchSubdomains_ValidResponse(t *testing.T) {
@@ -157,7 +159,7 @@ func TestExtractSubdomain_CaseInsensitive(t *testing.T) {
 func TestResolveSubdomainsToIPs_Empty(t *testing.T) {
 	ctx := context.Background()
 
-	ips, err := resolveSubdomainsToIPs(ctx, []string{}, "example.com", 100, 3*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{}, "example.com", 100, 3*time.Second)
 	if err != nil {
 		t.Errorf("Should not error on empty list: %v", err)
 	}
@@ -176,7 +178,7 @@ func TestResolveSubdomainsToIPs_MaxResolveLimit(t *testing.T) {
 		"facebook.com", "twitter.com", "youtube.com", "netflix.com",
 	}
 
-	_, err := resolveSubdomainsToIPs(ctx, subdomains, "example.com", 5, 2*time.Second)
+	_, err := resolveSubdomainsToIPs(ctx, resolver.Default(), subdomains, "example.com", 5, 2*time.Second)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -187,7 +189,7 @@ func TestResolveSubdomainsToIPs_MaxResolveLimit(t *testing.T) {
 func TestResolveSubdomainsToIPs_InvalidDomain(t *testing.T) {
 	ctx := context.Background()
 
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"invalid-99999.test"}, "test", 10, 2*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"invalid-99999.test"}, "test", 10, 2*time.Second)
 	if err != nil {
 		t.Errorf("Should not error, just skip: %v", err)
 	}
@@ -200,7 +202,7 @@ func TestResolveSubdomainsToIPs_InvalidDomain(t *testing.T) {
 func TestResolveSubdomainsToIPs_ValidDomain(t *testing.T) {
 	ctx := context.Background()
 
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com"}, "google.com", 10, 5*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com"}, "google.com", 10, 5*time.Second)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -215,7 +217,7 @@ func TestResolveSubdomainsToIPs_ValidDomain(t *testing.T) {
 func TestResolveSubdomainsToIPs_IPv4Only(t *testing.T) {
 	ctx := context.Background()
 
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com"}, "google.com", 10, 5*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com"}, "google.com", 10, 5*time.Second)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -232,7 +234,7 @@ func TestResolveSubdomainsToIPs_Deduplication(t *testing.T) {
 	ctx := context.Background()
 
 	// Same domain twice should deduplicate IPs
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com", "google.com"}, "google.com", 10, 5*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com", "google.com"}, "google.com", 10, 5*time.Second)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -250,7 +252,7 @@ func TestResolveSubdomainsToIPs_ContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com"}, "google.com", 10, 5*time.Second)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com"}, "google.com", 10, 5*time.Second)
 	if err != nil {
 		t.Errorf("Should not error on cancellation: %v", err)
 	}
@@ -262,7 +264,7 @@ func TestResolveSubdomainsToIPs_Timeout(t *testing.T) {
 	ctx := context.Background()
 
 	// Very short timeout for each lookup
-	ips, err := resolveSubdomainsToIPs(ctx, []string{"google.com"}, "google.com", 10, 1*time.Nanosecond)
+	ips, err := resolveSubdomainsToIPs(ctx, resolver.Default(), []string{"google.com"}, "google.com", 10, 1*time.Nanosecond)
 	if err != nil {
 		t.Errorf("Should not error on timeout: %v", err)
 	}
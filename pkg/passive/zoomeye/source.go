@@ -0,0 +1,71 @@
+package zoomeye
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/passive"
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
+)
+
+// SourceName is the registry name of the ZoomEye source
+const SourceName = "zoomeye"
+
+func init() {
+	passive.Register(passive.Registration{
+		Name:        SourceName,
+		Description: "ZoomEye host search",
+		Credentials: []string{"zoomeye_keys"},
+		Factory: func(opts passive.Options) (passive.Source, error) {
+			if len(opts.Config.ZoomEyeKeys) == 0 {
+				return nil, passive.MissingCredentials(SourceName, "zoomeye_keys")
+			}
+			return &Source{
+				manager: opts.KeyManager(api.SourceZoomEye, opts.Config.ZoomEyeKeys),
+				client:  opts.HTTPClient(),
+				baseURL: opts.BaseURL(SourceName, DefaultBaseURL),
+				timeout: opts.Timeout,
+			}, nil
+		},
+	})
+}
+
+// Source implements passive.Source for ZoomEye
+type Source struct {
+	manager *api.Manager
+	client  *httpclient.Client
+	baseURL string
+	timeout time.Duration
+}
+
+// NewSource creates a ZoomEye source using the given API keys
+// An empty baseURL uses DefaultBaseURL
+func NewSource(keys []string, baseURL string, timeout time.Duration) *Source {
+	return &Source{
+		manager: passive.Options{}.KeyManager(api.SourceZoomEye, keys),
+		client:  httpclient.Default(),
+		baseURL: passive.Endpoint(baseURL, DefaultBaseURL),
+		timeout: timeout,
+	}
+}
+
+// Name returns the registry name of the source
+func (s *Source) Name() string { return SourceName }
+
+// RequiredCredentials returns the config keys the source needs
+func (s *Source) RequiredCredentials() []string { return []string{"zoomeye_keys"} }
+
+// Search queries ZoomEye for IPs related to the domain
+func (s *Source) Search(ctx context.Context, domain string) ([]core.PassiveIP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ips, err := search(ctx, s.manager, s.client, s.baseURL, domain)
+	if err != nil {
+		return nil, fmt.Errorf("ZoomEye search failed: %w", err)
+	}
+	return passive.NewIPs(SourceName, ips, time.Now()), nil
+}
@@ -7,13 +7,18 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/jhaxce/origindive/pkg/passive/api"
+	"github.com/jhaxce/origindive/pkg/passive/httpclient"
 )
 
+// DefaultBaseURL is the ZoomEye API base URL
+const DefaultBaseURL = "https://api.zoomeye.ai"
+
 // ZoomEyeV2Request represents the request body for ZoomEye v2 POST API
 type ZoomEyeV2Request struct {
 	QBase64  string `json:"qbase64"`
@@ -47,38 +52,31 @@ func SearchHost(ctx context.Context, domain string, apiKeys []string, timeout ti
 		return []string{}, fmt.Errorf("no ZoomEye API keys provided")
 	}
 
-	// Try each API key until one works
-	var lastErr error
-	for i, apiKey := range apiKeys {
-		apiKey = strings.TrimSpace(apiKey)
-		if apiKey == "" {
-			continue
-		}
+	manager := api.NewManager(true)
+	manager.SetKeys(api.SourceZoomEye, apiKeys)
 
-		ips, err := searchWithKey(ctx, domain, apiKey, timeout)
-		if err == nil {
-			return ips, nil
-		}
-
-		// Check for rate limit errors
-		if strings.Contains(err.Error(), "rate limit") || strings.Contains(err.Error(), "429") {
-			lastErr = fmt.Errorf("key %d/%d rate limited: %w", i+1, len(apiKeys), err)
-			continue // Try next key
-		}
-
-		// For other errors, return immediately
-		return []string{}, fmt.Errorf("key %d/%d failed: %w", i+1, len(apiKeys), err)
-	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return search(ctx, manager, httpclient.Default(), DefaultBaseURL, domain)
+}
 
-	if lastErr != nil {
-		return []string{}, fmt.Errorf("all %d API keys exhausted: %w", len(apiKeys), lastErr)
+// search runs the query through the manager's key rotation
+// Rate limited keys are cooled down and the next key is tried
+func search(ctx context.Context, manager *api.Manager, client *httpclient.Client, baseURL, domain string) ([]string, error) {
+	var ips []string
+	err := manager.Do(ctx, api.SourceZoomEye, func(apiKey string) error {
+		var err error
+		ips, err = searchWithKey(ctx, client, baseURL, domain, apiKey)
+		return err
+	})
+	if err != nil {
+		return []string{}, err
 	}
-
-	return []string{}, fmt.Errorf("no valid API keys found")
+	return ips, nil
 }
 
 // searchWithKey performs the search with a single API key
-func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Duration) ([]string, error) {
+func searchWithKey(ctx context.Context, client *httpclient.Client, baseURL, domain, apiKey string) ([]string, error) {
 	// Build query: ssl.cert.subject.cn="domain.com" and encode with base64
 	query := fmt.Sprintf(`ssl.cert.subject.cn="%s"`, domain)
 	encodedQuery := base64.StdEncoding.EncodeToString([]byte(query))
@@ -97,11 +95,7 @@ func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Dura
 	}
 
 	// Use v2 POST API endpoint
-	url := "https://api.zoomeye.ai/v2/search"
-
-	client := &http.Client{
-		Timeout: timeout,
-	}
+	url := baseURL + "/v2/search"
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -111,24 +105,23 @@ func searchWithKey(ctx context.Context, domain, apiKey string, timeout time.Dura
 	// ZoomEye uses API-KEY header
 	req.Header.Set("API-KEY", apiKey)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "origindive/1.0")
 
-	resp, err := client.Do(req)
+	resp, err := client.Do(ctx, SourceName, req)
 	if err != nil {
 		return nil, fmt.Errorf("ZoomEye request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	body := resp.Data
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
 		// Try to parse error from JSON
 		var errResp ZoomEyeV2Response
-		if json.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
+		json.Unmarshal(body, &errResp)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, api.NewRateLimitError(resp.Response, errResp.Message)
+		}
+		if errResp.Message != "" {
 			return nil, fmt.Errorf("ZoomEye API error (HTTP %d): %s", resp.StatusCode, errResp.Message)
 		}
 
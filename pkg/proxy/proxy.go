@@ -34,6 +34,12 @@ type Proxy struct {
 	Port     string
 	Username string
 	Password string
+	ExitIP   string // Public IP observed through the proxy (set by TestProxy)
+}
+
+// Redacted returns the proxy as scheme://host:port without credentials
+func (p *Proxy) Redacted() string {
+	return fmt.Sprintf("%s://%s", p.Type, net.JoinHostPort(p.Host, p.Port))
 }
 
 // DetectCountryCode detects the user's country from Cloudflare CDN trace
@@ -309,9 +315,14 @@ func isValidIP(s string) bool {
 
 // TestProxy tests if the proxy is working by making a request to test endpoints
 // Uses multiple fallback endpoints for reliability
+// On success the detected public IP is recorded in ExitIP
 func (p *Proxy) TestProxy(timeout time.Duration) error {
-	_, err := ValidateProxy(p.URL, timeout)
-	return err
+	ip, err := ValidateProxy(p.URL, timeout)
+	if err != nil {
+		return err
+	}
+	p.ExitIP = ip
+	return nil
 }
 
 // FetchProxyList fetches a list of proxies from public sources
@@ -502,8 +513,17 @@ func ValidateProxies(ctx context.Context, proxies []*Proxy, timeout time.Duratio
 		maxWorkers = 10
 	}
 
+	// A tested proxy and its exit IP; only the collector writes ExitIP,
+	// as workers may still run after it returns and a proxy can be listed
+	// twice
+	type result struct {
+		proxy  *Proxy
+		exitIP string
+		err    error
+	}
+
 	jobs := make(chan *Proxy, len(proxies))
-	results := make(chan *Proxy, len(proxies))
+	results := make(chan result, len(proxies))
 
 	// Worker pool for testing proxies
 	for i := 0; i < maxWorkers; i++ {
@@ -513,9 +533,8 @@ func ValidateProxies(ctx context.Context, proxies []*Proxy, timeout time.Duratio
 				case <-ctx.Done():
 					return
 				default:
-					if err := proxy.TestProxy(timeout); err == nil {
-						results <- proxy
-					}
+					ip, err := ValidateProxy(proxy.URL, timeout)
+					results <- result{proxy, ip, err}
 				}
 			}
 		}()
@@ -535,8 +554,12 @@ func ValidateProxies(ctx context.Context, proxies []*Proxy, timeout time.Duratio
 collectLoop:
 	for i := 0; i < len(proxies); i++ {
 		select {
-		case proxy := <-results:
-			validated = append(validated, proxy)
+		case r := <-results:
+			if r.err != nil {
+				continue
+			}
+			r.proxy.ExitIP = r.exitIP
+			validated = append(validated, r.proxy)
 			// Early exit if we have enough working proxies (>100)
 			if len(validated) >= 100 && i > len(proxies)/2 {
 				break collectLoop
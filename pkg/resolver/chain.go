@@ -0,0 +1,177 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxChainHops bounds the CNAME chains LookupCNAMEChain follows
+const maxChainHops = 16
+
+// LookupCNAMEChain returns every name host's CNAME chain passes through, in
+// order and ending with the canonical name; it is empty when host has no
+// CNAME.
+// LookupCNAME reports a single name, so hops such as "x.edgekey.net" in
+// front of "e1.akamaiedge.net" are read from the raw answers. If those
+// cannot be captured, the chain is just the name LookupCNAME reports.
+func (r *Resolver) LookupCNAMEChain(ctx context.Context, host string) ([]string, error) {
+	v, err := r.lookup(ctx, "cname-chain", host, func(ctx context.Context) (interface{}, error) {
+		return r.cnameChain(ctx, host)
+	})
+	chain, _ := v.([]string)
+	return chain, err
+}
+
+// cnameChain resolves host through a Go resolver whose connections record
+// the answers, then follows the CNAME records they contain from host
+func (r *Resolver) cnameChain(ctx context.Context, host string) ([]string, error) {
+	rec := &answerRecorder{}
+	res := &net.Resolver{PreferGo: true, Dial: rec.wrap(r.dial)}
+	canonical, err := res.LookupCNAME(ctx, host)
+	if err != nil && !IsNotFound(err) {
+		// The Go resolver cannot reach the server (e.g. no usable system
+		// configuration); the backend still knows the canonical name
+		canonical, err = r.backend.LookupCNAME(ctx, host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	canonical = strings.TrimSuffix(canonical, ".")
+	if canonical == "" || strings.EqualFold(canonical, strings.TrimSuffix(host, ".")) {
+		return nil, nil
+	}
+	// The Go resolver reports the first or last hop depending on which
+	// answer it reads first; either way it must be on the recorded chain
+	chain := rec.chain(host)
+	for _, hop := range chain {
+		if strings.EqualFold(hop, canonical) {
+			return chain, nil
+		}
+	}
+	return []string{canonical}, nil
+}
+
+// answerRecorder keeps a copy of every DNS message read through the
+// connections it wraps
+type answerRecorder struct {
+	mu       sync.Mutex
+	messages [][]byte
+	streams  []*bytes.Buffer // Length-prefixed stream data, split by chain
+}
+
+// wrap returns a Dial function whose connections are recorded
+func (a *answerRecorder) wrap(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		// The Go resolver frames messages by connection type, so a packet
+		// connection must stay one
+		if pc, ok := c.(net.PacketConn); ok {
+			return &recordedPacketConn{Conn: c, pc: pc, rec: a}, nil
+		}
+		buf := &bytes.Buffer{}
+		a.mu.Lock()
+		a.streams = append(a.streams, buf)
+		a.mu.Unlock()
+		return &recordedConn{Conn: c, buf: buf, rec: a}, nil
+	}
+}
+
+// chain follows the recorded CNAME records from host
+func (a *answerRecorder) chain(host string) []string {
+	a.mu.Lock()
+	messages := append([][]byte(nil), a.messages...)
+	for _, buf := range a.streams {
+		data := buf.Bytes()
+		for len(data) >= 2 {
+			n := int(binary.BigEndian.Uint16(data))
+			if len(data) < 2+n {
+				break
+			}
+			messages = append(messages, data[2:2+n])
+			data = data[2+n:]
+		}
+	}
+	a.mu.Unlock()
+
+	targets := make(map[string]string) // Owner name -> CNAME target
+	for _, msg := range messages {
+		var p dnsmessage.Parser
+		if _, err := p.Start(msg); err != nil || p.SkipAllQuestions() != nil {
+			continue
+		}
+		for {
+			h, err := p.AnswerHeader()
+			if err != nil {
+				break
+			}
+			if h.Type != dnsmessage.TypeCNAME {
+				p.SkipAnswer()
+				continue
+			}
+			cname, err := p.CNAMEResource()
+			if err != nil {
+				break
+			}
+			targets[strings.ToLower(h.Name.String())] = cname.CNAME.String()
+		}
+	}
+
+	var chain []string
+	name := strings.ToLower(strings.TrimSuffix(host, ".") + ".")
+	for len(chain) < maxChainHops {
+		next, ok := targets[name]
+		if !ok {
+			break
+		}
+		chain = append(chain, strings.TrimSuffix(next, "."))
+		name = strings.ToLower(next)
+	}
+	return chain
+}
+
+// recordedConn records the stream a connection reads
+type recordedConn struct {
+	net.Conn
+	buf *bytes.Buffer
+	rec *answerRecorder
+}
+
+func (c *recordedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.rec.mu.Lock()
+	c.buf.Write(b[:n])
+	c.rec.mu.Unlock()
+	return n, err
+}
+
+// recordedPacketConn records each packet a connection reads
+type recordedPacketConn struct {
+	net.Conn
+	pc  net.PacketConn
+	rec *answerRecorder
+}
+
+func (c *recordedPacketConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.rec.mu.Lock()
+		c.rec.messages = append(c.rec.messages, append([]byte(nil), b[:n]...))
+		c.rec.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *recordedPacketConn) ReadFrom(b []byte) (int, net.Addr, error) { return c.pc.ReadFrom(b) }
+func (c *recordedPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.pc.WriteTo(b, addr)
+}
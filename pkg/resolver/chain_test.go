@@ -0,0 +1,76 @@
+package resolver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResolver_LookupCNAMEChainUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	srv := &answer{}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(srv.reply(buf[:n]), addr)
+		}
+	}()
+
+	r, err := New(Config{Server: conn.LocalAddr().String(), Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testChainLookups(t, r)
+}
+
+// DoH connections are streams, so this covers length-prefixed answers
+func TestResolver_LookupCNAMEChainDoH(t *testing.T) {
+	srv := &answer{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query, _ := io.ReadAll(req.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(srv.reply(query))
+	}))
+	defer server.Close()
+
+	r, err := New(Config{Server: server.URL + "/dns-query", HTTPClient: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testChainLookups(t, r)
+}
+
+// testChainLookups checks LookupCNAMEChain against the answer server
+func testChainLookups(t *testing.T, r *Resolver) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	chain, err := r.LookupCNAMEChain(ctx, "cdn.example.com")
+	if err != nil {
+		t.Fatalf("LookupCNAMEChain() error = %v", err)
+	}
+	if !reflect.DeepEqual(chain, testChain) {
+		t.Errorf("LookupCNAMEChain() = %v, want %v", chain, testChain)
+	}
+
+	if chain, err := r.LookupCNAMEChain(ctx, "www.example.com"); err != nil || len(chain) != 0 {
+		t.Errorf("LookupCNAMEChain(no CNAME) = %v, %v, want empty", chain, err)
+	}
+	if _, err := r.LookupCNAMEChain(ctx, "nothere.example.com"); !IsNotFound(err) {
+		t.Errorf("LookupCNAMEChain() error = %v, want NXDOMAIN", err)
+	}
+}
@@ -0,0 +1,319 @@
+// Package resolver provides the DNS resolver shared by origindive's lookups:
+// the system resolver or a chosen server over UDP, TCP, DNS-over-TLS or
+// DNS-over-HTTPS, with an in-memory answer cache and query statistics
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds one query exchange with a configured server
+	DefaultTimeout = 5 * time.Second
+
+	// DefaultCacheTTL is how long answers are reused within a run
+	DefaultCacheTTL = 5 * time.Minute
+)
+
+// Config configures a Resolver; zero values use the package defaults
+type Config struct {
+	// Server selects the resolver:
+	//   "" or "system"                    operating system resolver
+	//   "8.8.8.8", "udp://8.8.8.8:53"     UDP (TCP on truncated answers)
+	//   "tcp://1.1.1.1"                   TCP
+	//   "tls://dns.google", "tls://1.1.1.1:853"      DNS-over-TLS
+	//   "https://cloudflare-dns.com/dns-query"       DNS-over-HTTPS
+	Server string
+
+	Timeout    time.Duration // Per-exchange timeout (0 = DefaultTimeout)
+	CacheTTL   time.Duration // Answer cache lifetime (0 = DefaultCacheTTL, < 0 = no cache)
+	HTTPClient *http.Client  // Client for DNS-over-HTTPS (nil = a client with Timeout)
+}
+
+// Stats counts the lookups made through a Resolver
+type Stats struct {
+	Queries   uint64 // Lookups sent to the server
+	CacheHits uint64 // Lookups answered from the cache
+	NotFound  uint64 // Lookups answered with NXDOMAIN
+	Errors    uint64 // Lookups that failed (timeouts, refused, ...)
+}
+
+// lookuper is the subset of *net.Resolver a Resolver forwards to
+type lookuper interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+}
+
+// Resolver resolves names through one configured server
+// It is safe for concurrent use
+type Resolver struct {
+	name     string
+	system   bool
+	backend  lookuper
+	dial     dialFunc // Connects to the server for LookupCNAMEChain
+	cacheTTL time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+
+	queries, cacheHits, notFound, failures atomic.Uint64
+}
+
+// cacheEntry is a cached answer or NXDOMAIN
+type cacheEntry struct {
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+// New creates a resolver from cfg
+// Returns an error if the server is not a valid resolver address or URL
+func New(cfg Config) (*Resolver, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+
+	r := &Resolver{
+		cacheTTL: cfg.CacheTTL,
+		now:      time.Now,
+		cache:    make(map[string]cacheEntry),
+	}
+
+	server := strings.TrimSpace(cfg.Server)
+	if server == "" || strings.EqualFold(server, "system") {
+		r.name = "system"
+		r.system = true
+		r.backend = &net.Resolver{}
+		r.dial = (&net.Dialer{Timeout: cfg.Timeout}).DialContext
+		return r, nil
+	}
+
+	scheme, rest, ok := strings.Cut(server, "://")
+	if !ok {
+		scheme, rest = "udp", server
+	}
+	scheme = strings.ToLower(scheme)
+
+	var dial dialFunc
+	switch scheme {
+	case "udp", "tcp":
+		addr, err := hostPort(rest, "53", !ok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNS resolver %q: %w", server, err)
+		}
+		dial = dialServer(scheme, addr, cfg.Timeout)
+		r.name = addr
+		if scheme == "tcp" {
+			r.name = "tcp://" + addr
+		}
+	case "tls":
+		addr, err := hostPort(rest, "853", false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNS resolver %q: %w", server, err)
+		}
+		dial = dialTLS(addr, cfg.Timeout)
+		r.name = "tls://" + addr
+	case "https":
+		u, err := url.Parse(server)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid DNS-over-HTTPS URL %q", server)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		client := cfg.HTTPClient
+		if client == nil {
+			client = &http.Client{Timeout: cfg.Timeout}
+		}
+		dial = dialHTTPS(client, u.String())
+		r.name = u.String()
+	default:
+		return nil, fmt.Errorf("invalid DNS resolver %q: unsupported scheme %q (use udp, tcp, tls or https)", server, scheme)
+	}
+
+	r.backend = &net.Resolver{PreferGo: true, Dial: dial}
+	r.dial = dial
+	return r, nil
+}
+
+// hostPort validates a resolver address and adds the default port
+// Bare addresses (no scheme) must be IP addresses
+func hostPort(server, port string, requireIP bool) (string, error) {
+	host, p, err := net.SplitHostPort(server)
+	if err != nil {
+		if strings.Count(server, ":") == 1 {
+			return "", err // host:port with a bad port
+		}
+		host, p = strings.Trim(server, "[]"), port
+	}
+	if host == "" {
+		return "", fmt.Errorf("missing host")
+	}
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid address %q", server)
+	}
+	if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid port %q", p)
+	}
+	if requireIP && net.ParseIP(host) == nil {
+		return "", fmt.Errorf("must be an IP address or a URL such as tls://%s", host)
+	}
+	return net.JoinHostPort(host, p), nil
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultResolver *Resolver
+)
+
+// Default returns a shared caching system resolver
+// Used by lookups made without an explicit resolver
+func Default() *Resolver {
+	defaultOnce.Do(func() {
+		defaultResolver, _ = New(Config{})
+	})
+	return defaultResolver
+}
+
+// String returns the server the resolver queries ("system" for the OS resolver)
+func (r *Resolver) String() string { return r.name }
+
+// IsSystem reports whether the resolver is the operating system's
+func (r *Resolver) IsSystem() bool { return r.system }
+
+// Stats returns the lookup counters
+func (r *Resolver) Stats() Stats {
+	return Stats{
+		Queries:   r.queries.Load(),
+		CacheHits: r.cacheHits.Load(),
+		NotFound:  r.notFound.Load(),
+		Errors:    r.failures.Load(),
+	}
+}
+
+// LookupHost returns the IPv4 and IPv6 addresses of host
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	v, err := r.lookup(ctx, "host", host, func(ctx context.Context) (interface{}, error) {
+		return r.backend.LookupHost(ctx, host)
+	})
+	addrs, _ := v.([]string)
+	return addrs, err
+}
+
+// LookupIPv4 returns the IPv4 addresses of host
+func (r *Resolver) LookupIPv4(ctx context.Context, host string) ([]string, error) {
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var ipv4s []string
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			ipv4s = append(ipv4s, addr)
+		}
+	}
+	return ipv4s, nil
+}
+
+// LookupAddr returns the PTR names of an IP address
+func (r *Resolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	v, err := r.lookup(ctx, "ptr", addr, func(ctx context.Context) (interface{}, error) {
+		return r.backend.LookupAddr(ctx, addr)
+	})
+	names, _ := v.([]string)
+	return names, err
+}
+
+// LookupCNAME returns the canonical name host's CNAME chain ends at
+// (host itself when it has no CNAME)
+func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	v, err := r.lookup(ctx, "cname", host, func(ctx context.Context) (interface{}, error) {
+		return r.backend.LookupCNAME(ctx, host)
+	})
+	cname, _ := v.(string)
+	return cname, err
+}
+
+// LookupTXT returns the TXT records of name
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	v, err := r.lookup(ctx, "txt", name, func(ctx context.Context) (interface{}, error) {
+		return r.backend.LookupTXT(ctx, name)
+	})
+	records, _ := v.([]string)
+	return records, err
+}
+
+// LookupMX returns the MX records of name
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	v, err := r.lookup(ctx, "mx", name, func(ctx context.Context) (interface{}, error) {
+		return r.backend.LookupMX(ctx, name)
+	})
+	mxs, _ := v.([]*net.MX)
+	return mxs, err
+}
+
+// LookupNS returns the NS records of name
+func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	v, err := r.lookup(ctx, "ns", name, func(ctx context.Context) (interface{}, error) {
+		return r.backend.LookupNS(ctx, name)
+	})
+	nss, _ := v.([]*net.NS)
+	return nss, err
+}
+
+// lookup answers from the cache or runs query, caching answers and NXDOMAIN
+func (r *Resolver) lookup(ctx context.Context, kind, name string, query func(context.Context) (interface{}, error)) (interface{}, error) {
+	key := kind + " " + strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if r.cacheTTL > 0 {
+		r.mu.Lock()
+		entry, ok := r.cache[key]
+		r.mu.Unlock()
+		if ok && r.now().Before(entry.expires) {
+			r.cacheHits.Add(1)
+			return entry.value, entry.err
+		}
+	}
+
+	r.queries.Add(1)
+	value, err := query(ctx)
+	switch {
+	case err == nil:
+	case IsNotFound(err):
+		r.notFound.Add(1)
+	default:
+		r.failures.Add(1)
+		return value, err // Transient failures are not cached
+	}
+
+	if r.cacheTTL > 0 {
+		r.mu.Lock()
+		r.cache[key] = cacheEntry{value: value, err: err, expires: r.now().Add(r.cacheTTL)}
+		r.mu.Unlock()
+	}
+	return value, err
+}
+
+// IsNotFound reports whether err is an authoritative "no such host" answer
+func IsNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,346 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// answer is a tiny authoritative server for example.com: www has an A
+// record, cdn is a CNAME chain through testChain, every other name is
+// NXDOMAIN. It counts the queries it answers.
+type answer struct {
+	mu      sync.Mutex
+	queries int
+}
+
+func (a *answer) reply(query []byte) []byte {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+	a.mu.Lock()
+	a.queries++
+	a.mu.Unlock()
+
+	name := strings.ToLower(q.Name.String())
+	rcode := dnsmessage.RCodeNameError
+	if name == "www.example.com." || name == "cdn.example.com." {
+		rcode = dnsmessage.RCodeSuccess
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID: header.ID, Response: true, Authoritative: true, RecursionAvailable: true, RCode: rcode,
+	})
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	owner := q.Name
+	if name == "cdn.example.com." {
+		for _, hop := range testChain {
+			target := dnsmessage.MustNewName(hop + ".")
+			b.CNAMEResource(dnsmessage.ResourceHeader{Name: owner, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.CNAMEResource{CNAME: target})
+			owner = target
+		}
+	}
+	if rcode == dnsmessage.RCodeSuccess && q.Type == dnsmessage.TypeA {
+		b.AResource(dnsmessage.ResourceHeader{Name: owner, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}})
+	}
+	msg, _ := b.Finish()
+	return msg
+}
+
+// testChain is the CNAME chain cdn.example.com resolves through
+var testChain = []string{"cdn.example.com.edgekey.net", "e1.a.akamaiedge.net"}
+
+func (a *answer) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.queries
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		server string
+		want   string
+	}{
+		{"", "system"},
+		{"System", "system"},
+		{"8.8.8.8", "8.8.8.8:53"},
+		{"1.1.1.1:5353", "1.1.1.1:5353"},
+		{"2606:4700:4700::1111", "[2606:4700:4700::1111]:53"},
+		{"udp://9.9.9.9", "9.9.9.9:53"},
+		{"udp://dns.example:53", "dns.example:53"},
+		{"tcp://1.1.1.1", "tcp://1.1.1.1:53"},
+		{"tls://dns.google", "tls://dns.google:853"},
+		{"TLS://[2001:db8::1]:8853", "tls://[2001:db8::1]:8853"},
+		{"https://cloudflare-dns.com/dns-query", "https://cloudflare-dns.com/dns-query"},
+		{"https://dns.google", "https://dns.google/dns-query"},
+	}
+	for _, tt := range tests {
+		r, err := New(Config{Server: tt.server})
+		if err != nil {
+			t.Errorf("New(%q) error = %v", tt.server, err)
+			continue
+		}
+		if r.String() != tt.want {
+			t.Errorf("New(%q).String() = %q, want %q", tt.server, r.String(), tt.want)
+		}
+		if r.IsSystem() != (tt.want == "system") {
+			t.Errorf("New(%q).IsSystem() = %v", tt.server, r.IsSystem())
+		}
+	}
+
+	for _, bad := range []string{"dns.google", "8.8.8.8:53:53", "8.8.8.8:dns", "quic://dns.adguard.com", "https://", "tls://"} {
+		if _, err := New(Config{Server: bad}); err == nil {
+			t.Errorf("New(%q) should fail", bad)
+		}
+	}
+}
+
+// fakeBackend answers LookupHost from a table; other lookups fail
+type fakeBackend struct {
+	hosts map[string][]string
+	err   error
+	calls int
+}
+
+func (f *fakeBackend) LookupHost(ctx context.Context, host string) ([]string, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if addrs, ok := f.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (f *fakeBackend) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	f.calls++
+	return []string{"host.example.com."}, nil
+}
+
+func (f *fakeBackend) LookupCNAME(context.Context, string) (string, error) { return "", f.err }
+func (f *fakeBackend) LookupTXT(context.Context, string) ([]string, error) { return nil, f.err }
+func (f *fakeBackend) LookupMX(context.Context, string) ([]*net.MX, error) { return nil, f.err }
+func (f *fakeBackend) LookupNS(context.Context, string) ([]*net.NS, error) { return nil, f.err }
+
+func TestResolver_CacheAndStats(t *testing.T) {
+	now := time.Now()
+	backend := &fakeBackend{hosts: map[string][]string{"www.example.com": {"192.0.2.1", "2001:db8::1"}}}
+	r := &Resolver{backend: backend, cacheTTL: time.Minute, now: func() time.Time { return now }, cache: make(map[string]cacheEntry)}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		addrs, err := r.LookupHost(ctx, "www.example.com")
+		if err != nil || len(addrs) != 2 {
+			t.Fatalf("LookupHost() = %v, %v", addrs, err)
+		}
+	}
+	// Names are cached case-insensitively and without the trailing dot
+	if ipv4, _ := r.LookupIPv4(ctx, "WWW.example.com."); !reflect.DeepEqual(ipv4, []string{"192.0.2.1"}) {
+		t.Errorf("LookupIPv4() = %v", ipv4)
+	}
+	// NXDOMAIN is cached too
+	for i := 0; i < 2; i++ {
+		if _, err := r.LookupHost(ctx, "missing.example.com"); !IsNotFound(err) {
+			t.Fatalf("LookupHost() error = %v, want NXDOMAIN", err)
+		}
+	}
+	// Lookup kinds have separate entries
+	r.LookupAddr(ctx, "192.0.2.1")
+
+	if backend.calls != 3 {
+		t.Errorf("backend calls = %d, want 3", backend.calls)
+	}
+	want := Stats{Queries: 3, CacheHits: 4, NotFound: 1}
+	if got := r.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// Entries expire
+	now = now.Add(time.Minute)
+	r.LookupHost(ctx, "www.example.com")
+	if backend.calls != 4 {
+		t.Errorf("backend calls = %d, want a fresh query after expiry", backend.calls)
+	}
+}
+
+func TestResolver_ErrorsNotCached(t *testing.T) {
+	backend := &fakeBackend{err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}
+	r := &Resolver{backend: backend, cacheTTL: time.Minute, now: time.Now, cache: make(map[string]cacheEntry)}
+
+	r.LookupHost(context.Background(), "www.example.com")
+	r.LookupHost(context.Background(), "www.example.com")
+	if backend.calls != 2 {
+		t.Errorf("backend calls = %d, want failures retried", backend.calls)
+	}
+	if got := r.Stats(); got.Errors != 2 || got.CacheHits != 0 {
+		t.Errorf("Stats() = %+v", got)
+	}
+
+	// A negative TTL disables the cache
+	backend.err = nil
+	r.cacheTTL = -1
+	r.LookupAddr(context.Background(), "192.0.2.1")
+	r.LookupAddr(context.Background(), "192.0.2.1")
+	if backend.calls != 4 {
+		t.Errorf("backend calls = %d, want no caching", backend.calls)
+	}
+}
+
+func TestResolver_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	srv := &answer{}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(srv.reply(buf[:n]), addr)
+		}
+	}()
+
+	r, err := New(Config{Server: conn.LocalAddr().String(), Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookups(t, r, srv)
+}
+
+func TestResolver_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	srv := &answer{}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				for {
+					var length [2]byte
+					if _, err := io.ReadFull(c, length[:]); err != nil {
+						return
+					}
+					query := make([]byte, int(length[0])<<8|int(length[1]))
+					if _, err := io.ReadFull(c, query); err != nil {
+						return
+					}
+					msg := srv.reply(query)
+					c.Write(append([]byte{byte(len(msg) >> 8), byte(len(msg))}, msg...))
+				}
+			}()
+		}
+	}()
+
+	r, err := New(Config{Server: "tcp://" + ln.Addr().String(), Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookups(t, r, srv)
+}
+
+func TestResolver_DoH(t *testing.T) {
+	srv := &answer{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(req.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(srv.reply(query))
+	}))
+	defer server.Close()
+
+	r, err := New(Config{Server: server.URL + "/dns-query", HTTPClient: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookups(t, r, srv)
+}
+
+func TestResolver_DoHStatusError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	r, _ := New(Config{Server: server.URL, HTTPClient: server.Client()})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.LookupHost(ctx, "www.example.com")
+	if err == nil || IsNotFound(err) {
+		t.Errorf("LookupHost() error = %v, want a server failure", err)
+	}
+	if r.Stats().Errors != 1 {
+		t.Errorf("Stats() = %+v, want one error", r.Stats())
+	}
+}
+
+// testLookups checks a resolver against the answer server
+func testLookups(t *testing.T, r *Resolver, srv *answer) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, err := r.LookupHost(ctx, "www.example.com")
+	if err != nil {
+		t.Fatalf("LookupHost() error = %v", err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"192.0.2.10"}) {
+		t.Errorf("LookupHost() = %v, want [192.0.2.10]", addrs)
+	}
+
+	if _, err := r.LookupHost(ctx, "nothere.example.com"); !IsNotFound(err) {
+		t.Errorf("LookupHost() error = %v, want NXDOMAIN", err)
+	}
+
+	queries := srv.count()
+	if queries == 0 {
+		t.Fatal("server received no queries")
+	}
+	r.LookupHost(ctx, "www.example.com")
+	if srv.count() != queries {
+		t.Error("cached answer was queried again")
+	}
+	if s := r.Stats(); s.Queries != 2 || s.CacheHits != 1 || s.NotFound != 1 {
+		t.Errorf("Stats() = %+v", s)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !IsNotFound(&net.DNSError{IsNotFound: true}) {
+		t.Error("IsNotFound() = false for NXDOMAIN")
+	}
+	if IsNotFound(&net.DNSError{IsTimeout: true}) || IsNotFound(errors.New("x")) || IsNotFound(nil) {
+		t.Error("IsNotFound() = true for other errors")
+	}
+}
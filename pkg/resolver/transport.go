@@ -0,0 +1,148 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// The Go resolver sends every query through its Dial function and speaks
+// DNS over whatever connection it gets back: packet framing for a
+// net.PacketConn, TCP length-prefixed framing for anything else. Each
+// transport below is a Dial function that ignores the system nameserver
+// the Go resolver asks for and connects to the configured server instead.
+
+// dialFunc is the Dial function of a net.Resolver
+type dialFunc = func(ctx context.Context, network, address string) (net.Conn, error)
+
+// maxDoHResponse caps a DNS-over-HTTPS response body
+const maxDoHResponse = 65535
+
+// dialServer connects to addr over UDP or TCP. In UDP mode the Go resolver
+// retries truncated answers over TCP, so the network it asks for is used.
+func dialServer(scheme, addr string, timeout time.Duration) func(ctx context.Context, network, address string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: timeout}
+	return func(ctx context.Context, network, _ string) (net.Conn, error) {
+		if scheme == "tcp" {
+			network = "tcp"
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// dialTLS connects to addr with DNS-over-TLS (RFC 7858)
+func dialTLS(addr string, timeout time.Duration) func(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	d := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12},
+	}
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+}
+
+// dialHTTPS returns connections that carry each query to a DNS-over-HTTPS
+// endpoint (RFC 8484) as a POST
+func dialHTTPS(client *http.Client, endpoint string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return &dohConn{ctx: ctx, client: client, endpoint: endpoint}, nil
+	}
+}
+
+// dohConn is a stream connection to a DoH endpoint: every length-prefixed
+// query written is sent as one HTTPS request, and the length-prefixed
+// answer becomes readable
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	endpoint string
+	deadline time.Time
+
+	out bytes.Buffer // Query bytes not yet forming a whole message
+	in  bytes.Buffer // Answers waiting to be read
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.out.Write(b)
+	for c.out.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.out.Bytes()))
+		if c.out.Len() < 2+n {
+			break
+		}
+		query := make([]byte, n)
+		c.out.Next(2)
+		c.out.Read(query)
+
+		answer, err := c.exchange(query)
+		if err != nil {
+			return 0, err
+		}
+		var length [2]byte
+		binary.BigEndian.PutUint16(length[:], uint16(len(answer)))
+		c.in.Write(length[:])
+		c.in.Write(answer)
+	}
+	return len(b), nil
+}
+
+// exchange posts one DNS message and returns the answer
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS server returned status %d", resp.StatusCode)
+	}
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, maxDoHResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(answer) > maxDoHResponse {
+		return nil, fmt.Errorf("DNS-over-HTTPS answer too large")
+	}
+	return answer, nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.in.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.in.Read(b)
+}
+
+func (c *dohConn) Close() error                     { return nil }
+func (c *dohConn) LocalAddr() net.Addr              { return dohAddr(c.endpoint) }
+func (c *dohConn) RemoteAddr() net.Addr             { return dohAddr(c.endpoint) }
+func (c *dohConn) SetDeadline(t time.Time) error    { c.deadline = t; return nil }
+func (c *dohConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(time.Time) error { return nil }
+
+// dohAddr is the net.Addr of a DoH endpoint
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
@@ -0,0 +1,25 @@
+package scanner
+
+import (
+	"fmt"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/waf"
+)
+
+// applyPageClass records the class of a CDN/WAF block, challenge or error
+// page in result's body, from the body matches of the edge signatures.
+// Such a page also shows the IP is an edge of that provider, unless a
+// header signature already said so.
+func applyPageClass(result *core.IPResult, sigs *waf.SignatureDatabase, body []byte) {
+	sig, m, ok := sigs.MatchBody(body)
+	if !ok || m.Class == "" {
+		return
+	}
+
+	result.PageClass = m.Class
+	if result.EdgeProvider == "" {
+		result.EdgeProvider = sig.ID
+		result.EdgeEvidence = fmt.Sprintf("%s page %q", m.Class, m.Markers[0])
+	}
+}
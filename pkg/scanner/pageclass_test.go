@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/waf"
+)
+
+func TestApplyPageClass(t *testing.T) {
+	sigs, err := waf.EmbeddedSignatureDatabase()
+	if err != nil {
+		t.Fatalf("EmbeddedSignatureDatabase() error: %v", err)
+	}
+
+	// A page marks the IP as an edge of its provider
+	r := &core.IPResult{IP: "192.0.2.1", Status: "4xx"}
+	applyPageClass(r, sigs, []byte("Request unsuccessful. Incapsula incident ID: 1"))
+	if r.PageClass != core.PageClassBlock || r.EdgeProvider != "incapsula" || r.EdgeEvidence != `block page "request unsuccessful"` {
+		t.Errorf("applyPageClass() = %+v", r)
+	}
+
+	// A header signature's provider and evidence are kept
+	r = &core.IPResult{IP: "192.0.2.2", EdgeProvider: "cloudflare", EdgeEvidence: "cf-ray: 1-LAX"}
+	applyPageClass(r, sigs, []byte("<title>Just a moment...</title> /cdn-cgi/challenge-platform/"))
+	if r.PageClass != core.PageClassChallenge || r.EdgeEvidence != "cf-ray: 1-LAX" {
+		t.Errorf("applyPageClass() = %+v", r)
+	}
+
+	r = &core.IPResult{IP: "192.0.2.3"}
+	applyPageClass(r, sigs, []byte("<title>Shop</title>"))
+	if r.PageClass != "" || r.IsCDNEdge() {
+		t.Errorf("applyPageClass() on an origin page = %+v", r)
+	}
+
+	// Markers without a class show an edge but not a CDN/WAF page
+	r = &core.IPResult{IP: "192.0.2.4"}
+	applyPageClass(r, sigs, []byte(`<script src="/_Incapsula_Resource?SWJIYLWA=1"></script>`))
+	if r.PageClass != "" || r.IsCDNEdge() {
+		t.Errorf("applyPageClass() on a proxied page = %+v", r)
+	}
+
+	// Without signatures nothing is classified
+	r = &core.IPResult{IP: "192.0.2.5"}
+	applyPageClass(r, nil, []byte("Request unsuccessful. Incapsula incident ID: 1"))
+	if r.PageClass != "" || r.IsCDNEdge() {
+		t.Errorf("applyPageClass() without signatures = %+v", r)
+	}
+}
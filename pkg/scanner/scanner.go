@@ -12,6 +12,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
@@ -20,10 +21,13 @@ import (
 
 	"github.com/jhaxce/origindive/internal/colors"
 	"github.com/jhaxce/origindive/internal/version"
+	"github.com/jhaxce/origindive/pkg/asn"
 	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/geoip"
 	"github.com/jhaxce/origindive/pkg/ip"
 	"github.com/jhaxce/origindive/pkg/output"
 	"github.com/jhaxce/origindive/pkg/proxy"
+	"github.com/jhaxce/origindive/pkg/resolver"
 	"github.com/jhaxce/origindive/pkg/waf"
 )
 
@@ -32,13 +36,23 @@ type Scanner struct {
 	config           *core.Config
 	client           *http.Client
 	wafFilter        *waf.Filter
-	proxyList        []*proxy.Proxy // List of proxies for rotation
-	proxyIndex       uint64         // Atomic counter for proxy rotation
+	classifier       *waf.Classifier        // Provider/category of every result's range
+	signatures       *waf.SignatureDatabase // CDN edge response signatures
+	asnDB            *asn.Database          // Offline IP-to-ASN table (nil = no enrichment)
+	geoDB            *geoip.Database        // GeoIP MMDB files (nil = no enrichment or country filter)
+	geoFilter        *geoip.Filter          // --geo-include/--geo-exclude (nil = every country)
+	geoSkipped       uint64                 // Atomic count of IPs outside geoFilter
+	proxy            *proxy.Proxy           // Proxy behind the default client (nil for direct)
+	proxyList        []*proxy.Proxy         // List of proxies for rotation
+	proxyIndex       uint64                 // Atomic counter for proxy rotation
+	egressMu         sync.Mutex             // Guards egressCounts
+	egressCounts     map[string]uint64      // Exit IP -> requests sent through it
 	mu               sync.Mutex
 	cancelFunc       context.CancelFunc
 	progressCallback func(scanned, total uint64) // Progress update callback
 	resultCallback   func(result *core.IPResult) // Real-time result callback
 	progressStopper  func()                      // Function to stop progress display
+	resolver         *resolver.Resolver          // PTR lookups (nil = resolver.Default())
 }
 
 // New creates a new scanner with the given configuration
@@ -48,6 +62,7 @@ func New(config *core.Config) (*Scanner, error) {
 	}
 
 	var proxyClient *http.Client
+	var proxyObj *proxy.Proxy
 	var proxyList []*proxy.Proxy
 
 	// Handle proxy configuration
@@ -121,7 +136,8 @@ func New(config *core.Config) (*Scanner, error) {
 
 		proxyList = proxies
 		// Use first proxy for initial client
-		proxyClient, _ = proxies[0].GetHTTPClient(config.Timeout)
+		proxyObj = proxies[0]
+		proxyClient, _ = proxyObj.GetHTTPClient(config.Timeout)
 		if config.ProxyRotate {
 			fmt.Println("[*] Proxy rotation enabled")
 		}
@@ -129,7 +145,8 @@ func New(config *core.Config) (*Scanner, error) {
 	} else if config.ProxyURL != "" {
 		// Use specified proxy
 		fmt.Printf("[*] Using proxy: %s\n", config.ProxyURL)
-		proxyObj, err := proxy.ParseProxy(config.ProxyURL)
+		var err error
+		proxyObj, err = proxy.ParseProxy(config.ProxyURL)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse proxy URL: %w", err)
 		}
@@ -140,7 +157,7 @@ func New(config *core.Config) (*Scanner, error) {
 			if err := proxyObj.TestProxy(5 * time.Second); err != nil {
 				return nil, fmt.Errorf("proxy test failed: %w", err)
 			}
-			fmt.Println("[+] Proxy test successful")
+			fmt.Printf("[+] Proxy test successful (exit IP: %s)\n", proxyObj.ExitIP)
 		}
 
 		proxyClient, err = proxyObj.GetHTTPClient(config.Timeout)
@@ -159,6 +176,7 @@ func New(config *core.Config) (*Scanner, error) {
 	if proxyClient != nil {
 		client = proxyClient
 	} else {
+		proxyObj = nil
 		// Standard client without proxy
 		client = &http.Client{
 			Timeout: config.Timeout,
@@ -180,35 +198,71 @@ func New(config *core.Config) (*Scanner, error) {
 	}
 
 	s := &Scanner{
-		config:    config,
-		client:    client,
-		proxyList: proxyList,
+		config:       config,
+		client:       client,
+		proxy:        proxyObj,
+		proxyList:    proxyList,
+		egressCounts: make(map[string]uint64),
 	}
 
-	// Load WAF filter if enabled and database path is set
-	if config.SkipWAF {
-		wafPath := config.WAFDatabasePath
-		if wafPath == "" {
-			wafPath = "data/waf_ranges.json" // Default path
+	// Custom ranges files are always skipped, each as its own provider(s).
+	// They are added first, so they take precedence over the database in
+	// both the filter and the classifier.
+	custom, err := waf.LoadCustomProviderFiles(config.CustomWAFPaths())
+	if err != nil {
+		return nil, err
+	}
+	rangeSet := waf.NewRangeSet()
+	for i := range custom {
+		if err := rangeSet.AddProvider(&custom[i]); err != nil {
+			return nil, fmt.Errorf("failed to load custom WAF ranges: %w", err)
 		}
+	}
 
-		// Only load if we can access the file (handles test case with empty path)
-		db, err := waf.LoadWAFDatabase(wafPath)
-		if err != nil {
-			// If using default path and it doesn't exist, silently skip
-			// If using custom path, this is an error
-			if config.WAFDatabasePath != "" {
-				return nil, fmt.Errorf("failed to load WAF database from %s: %w", wafPath, err)
+	// Load the WAF database: every provider classifies results, and with
+	// --skip-waf the providers in the skip categories are filtered. Without
+	// an on-disk copy the database embedded in the binary is used.
+	var providers []waf.Provider
+	db, info, err := waf.OpenWAFDatabase(config.WAFDatabasePath)
+	if err != nil {
+		// A database that exists but cannot be loaded is only fatal when
+		// it is needed for filtering
+		if config.SkipWAF {
+			return nil, fmt.Errorf("failed to load WAF database from %s: %w", info.Source(), err)
+		}
+	} else {
+		providers = db.Providers
+		if config.SkipWAF {
+			skip, err := waf.SkipProviders(db, config.SkipProviders, config.SkipCategories)
+			if err == nil {
+				err = rangeSet.AddProviders(skip)
 			}
-			// Default path not found - continue without WAF filtering
-		} else {
-			filter, err := waf.NewFilterFromDatabase(db, config.SkipProviders, config.ShowSkipped)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create WAF filter: %w", err)
 			}
-			s.wafFilter = filter
 		}
 	}
+	s.classifier = waf.NewClassifier(append(custom, providers...))
+
+	// CDN edge signatures are read from next to the WAF database, or the
+	// embedded table; without them responses are not checked
+	sigPath := ""
+	if config.WAFDatabasePath != "" {
+		sigPath = filepath.Join(filepath.Dir(config.WAFDatabasePath), "cdn_signatures.json")
+	}
+	if sigs, err := waf.OpenSignatureDatabase(sigPath); err == nil {
+		s.signatures = sigs
+	}
+
+	if rangeSet.Count() > 0 {
+		s.wafFilter = waf.NewFilter(rangeSet, true)
+	}
+
+	geoFilter, err := geoip.NewFilter(config.GeoInclude, config.GeoExclude)
+	if err != nil {
+		return nil, err
+	}
+	s.geoFilter = geoFilter
 
 	return s, nil
 }
@@ -232,13 +286,26 @@ func (s *Scanner) Scan(ctx context.Context) (*core.ScanResult, error) {
 	for i, r := range s.config.IPRanges {
 		ranges[i] = ip.IPRange{Start: r[0], End: r[1]}
 	}
-
-	iterator := ip.NewIterator(ranges)
-	totalIPs := iterator.TotalIPs()
+	totalIPs := ip.NewIterator(ranges).TotalIPs()
 	if totalIPs == 0 {
 		return nil, fmt.Errorf("no IP ranges to scan")
 	}
 
+	// Drop WAF-covered addresses up front unless each one is reported
+	var preSkipped uint64
+	if s.wafFilter != nil && !s.config.ShowSkipped {
+		remaining := s.wafFilter.SubtractRanges(s.config.IPRanges)
+		ranges = make([]ip.IPRange, len(remaining))
+		for i, r := range remaining {
+			ranges[i] = ip.IPRange{Start: r[0], End: r[1]}
+		}
+		preSkipped = totalIPs - ip.NewIterator(ranges).TotalIPs()
+		if preSkipped > 0 && s.progressCallback != nil {
+			s.progressCallback(preSkipped, 0)
+		}
+	}
+	iterator := ip.NewIterator(ranges)
+
 	// Create channels
 	jobs := make(chan uint32, s.config.Workers*2)
 	results := make(chan *core.IPResult, s.config.Workers*2)
@@ -246,8 +313,9 @@ func (s *Scanner) Scan(ctx context.Context) (*core.ScanResult, error) {
 	// Atomic counters
 	var (
 		scanned uint64
-		skipped uint64
+		skipped = preSkipped
 	)
+	atomic.StoreUint64(&s.geoSkipped, 0)
 
 	// Start workers
 	var wg sync.WaitGroup
@@ -304,7 +372,7 @@ func (s *Scanner) Scan(ctx context.Context) (*core.ScanResult, error) {
 		if !s.config.NoColor {
 			fmt.Println(colors.YELLOW + "[*] Verifying redirect behavior" + colors.NC + "\n")
 		} else {
-			fmt.Println("[*] Verifying redirect behavior\n")
+			fmt.Print("[*] Verifying redirect behavior\n\n")
 		}
 		falsePositiveIPs := s.validateSuccessfulIPs(ctx, result.Success)
 		// Additionally perform PTR reverse lookup checks
@@ -358,7 +426,8 @@ func (s *Scanner) Scan(ctx context.Context) (*core.ScanResult, error) {
 	result.EndTime = time.Now()
 	result.Summary.TotalIPs = totalIPs
 	result.Summary.ScannedIPs = scanned
-	result.Summary.SkippedIPs = skipped
+	result.Summary.GeoSkippedIPs = atomic.LoadUint64(&s.geoSkipped)
+	result.Summary.SkippedIPs = skipped - result.Summary.GeoSkippedIPs
 	result.Summary.SuccessCount = uint64(len(result.Success))
 	result.Summary.Duration = result.EndTime.Sub(result.StartTime)
 
@@ -368,12 +437,42 @@ func (s *Scanner) Scan(ctx context.Context) (*core.ScanResult, error) {
 		result.Summary.SuccessIPs = append(result.Summary.SuccessIPs, ipResult.IP)
 	}
 
+	// Count CDN edges per provider, and the block/challenge/error pages
+	// among them per class
+	result.Summary.CDNEdgeCount = uint64(len(result.CDNEdges))
+	if len(result.CDNEdges) > 0 {
+		result.Summary.EdgeStats = make(map[string]uint64)
+		for _, r := range result.CDNEdges {
+			result.Summary.EdgeStats[r.EdgeProvider]++
+			if r.PageClass != "" {
+				if result.Summary.PageStats == nil {
+					result.Summary.PageStats = make(map[string]uint64)
+				}
+				result.Summary.PageStats[r.PageClass]++
+			}
+		}
+	}
+
+	// Group 200 OK hits by the AS announcing them
+	for _, r := range result.Success {
+		if r.ASN == 0 {
+			continue
+		}
+		if result.Summary.ASNStats == nil {
+			result.Summary.ASNStats = make(map[string]uint64)
+		}
+		result.Summary.ASNStats[asn.Record{ASN: r.ASN, Org: r.ASOrg}.String()]++
+	}
+
 	// Add WAF stats if filter was used
 	if s.wafFilter != nil {
 		stats := s.wafFilter.GetStats()
 		result.Summary.WAFStats = stats.ByProvider
 	}
 
+	// Add per-exit-IP request counts if proxies were used
+	result.Summary.EgressStats = s.GetEgressStats()
+
 	return result, nil
 }
 
@@ -409,14 +508,28 @@ func (s *Scanner) worker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan ui
 							IP:       ipAddr.String(),
 							Status:   "skipped",
 							Provider: provider,
+							Category: s.classifier.Category(provider),
 						}
 					}
 					continue
 				}
 			}
 
+			// Skip IPs outside the --geo-include/--geo-exclude countries
+			if !s.geoAllowed(ipAddr) {
+				atomic.AddUint64(&s.geoSkipped, 1)
+				newSkipped := atomic.AddUint64(skipped, 1)
+				if s.progressCallback != nil {
+					s.progressCallback(atomic.LoadUint64(scanned)+newSkipped, 0)
+				}
+				continue
+			}
+
 			// Scan the IP
 			result := s.scanIP(ctx, ipAddr)
+			result.Provider, result.Category, _ = s.classifier.Classify(ipAddr)
+			s.asnDB.EnrichResult(result)
+			s.geoDB.EnrichResult(result)
 			newScanned := atomic.AddUint64(scanned, 1)
 
 			// Update progress
@@ -473,7 +586,14 @@ func (s *Scanner) scanIP(ctx context.Context, ipAddr net.IP) *core.IPResult {
 
 	// Perform request
 	startTime := time.Now()
-	client := s.getClient() // Use proxy-aware client
+	client, proxyObj := s.getClient() // Use proxy-aware client
+
+	// Record which proxy and exit IP carry this request
+	if proxyObj != nil {
+		result.Proxy = proxyObj.Redacted()
+		result.ExitIP = proxyObj.ExitIP
+		s.recordEgress(proxyObj)
+	}
 
 	// If redirect following is enabled, first check natural redirect (without Host header)
 	// This helps detect shared hosting where Host header influences redirect destination
@@ -608,21 +728,8 @@ func (s *Scanner) scanIP(ctx context.Context, ipAddr net.IP) *core.IPResult {
 		}
 		result.RedirectChain = redirectChain
 
-		// Extract content if enabled and status is 200
-		if s.config.VerifyContent && resp.StatusCode == 200 {
-			// Read response body (limit to 64KB for safety)
-			body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
-			if err == nil {
-				// Calculate SHA256 hash
-				hash := sha256.Sum256(body)
-				result.BodyHash = hex.EncodeToString(hash[:])[:16] // First 16 chars
-
-				// Extract HTML title if Content-Type is HTML
-				if strings.Contains(strings.ToLower(result.ContentType), "html") {
-					result.Title = extractTitle(string(body))
-				}
-			}
-		}
+		// Hash the body and check for CDN edge signatures
+		s.inspectResponse(resp, result)
 
 		switch {
 		case resp.StatusCode == 200:
@@ -661,21 +768,8 @@ func (s *Scanner) scanIP(ctx context.Context, ipAddr net.IP) *core.IPResult {
 	result.Server = resp.Header.Get("Server")
 	result.ContentType = resp.Header.Get("Content-Type")
 
-	// Extract content if enabled and status is 200
-	if s.config.VerifyContent && resp.StatusCode == 200 {
-		// Read response body (limit to 64KB for safety)
-		body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
-		if err == nil {
-			// Calculate SHA256 hash
-			hash := sha256.Sum256(body)
-			result.BodyHash = hex.EncodeToString(hash[:])[:16] // First 16 chars
-
-			// Extract HTML title if Content-Type is HTML
-			if strings.Contains(strings.ToLower(result.ContentType), "html") {
-				result.Title = extractTitle(string(body))
-			}
-		}
-	}
+	// Hash the body and check for CDN edge signatures
+	s.inspectResponse(resp, result)
 
 	switch {
 	case resp.StatusCode == 200:
@@ -693,6 +787,33 @@ func (s *Scanner) scanIP(ctx context.Context, ipAddr net.IP) *core.IPResult {
 	return result
 }
 
+// inspectResponse reads the response body (up to 64KB) to record its hash
+// and title when --verify is set for 200 responses, matches headers and
+// body against the CDN edge signatures, and classifies CDN/WAF block,
+// challenge and error pages
+func (s *Scanner) inspectResponse(resp *http.Response, result *core.IPResult) {
+	verify := s.config.VerifyContent && resp.StatusCode == 200
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	if verify && err == nil {
+		// Calculate SHA256 hash
+		hash := sha256.Sum256(body)
+		result.BodyHash = hex.EncodeToString(hash[:])[:16] // First 16 chars
+
+		// Extract HTML title if Content-Type is HTML
+		if strings.Contains(strings.ToLower(result.ContentType), "html") {
+			result.Title = extractTitle(string(body))
+		}
+	}
+
+	// An edge answering for the domain is a CDN in front of it, not an origin
+	if sig, evidence, ok := s.signatures.Match(resp.Header, body); ok {
+		result.EdgeProvider = sig.ID
+		result.EdgeEvidence = evidence
+	}
+	applyPageClass(result, s.signatures, body)
+}
+
 // extractTitle extracts the <title> tag content from HTML
 func extractTitle(html string) string {
 	// Simple regex to extract title (not perfect but good enough)
@@ -734,6 +855,32 @@ func (s *Scanner) SetResultCallback(callback func(result *core.IPResult)) {
 	s.resultCallback = callback
 }
 
+// SetResolver sets the DNS resolver used for PTR validation
+func (s *Scanner) SetResolver(r *resolver.Resolver) {
+	s.resolver = r
+}
+
+// SetASNDatabase sets the offline IP-to-ASN table used to enrich results
+func (s *Scanner) SetASNDatabase(db *asn.Database) {
+	s.asnDB = db
+}
+
+// SetGeoDatabase sets the GeoIP databases used to enrich results and
+// apply the country filter
+func (s *Scanner) SetGeoDatabase(db *geoip.Database) {
+	s.geoDB = db
+}
+
+// geoAllowed reports whether ip passes the country filter. Without a
+// GeoIP database every IP passes.
+func (s *Scanner) geoAllowed(ip net.IP) bool {
+	if s.geoFilter == nil || s.geoDB == nil {
+		return true
+	}
+	loc, _ := s.geoDB.Lookup(ip)
+	return s.geoFilter.Allow(loc.CountryCode)
+}
+
 // validateSuccessfulIPs checks if successful IPs behave the same without Host header
 // This helps detect shared hosting where the Host header influences the response
 // Returns list of IPs flagged as potential false positives
@@ -939,11 +1086,15 @@ func normalizeURLForDisplay(u string) string {
 // when the PTR record clearly points to a different host (not containing the target domain).
 func (s *Scanner) validatePTRs(ctx context.Context, successIPs []*core.IPResult) []string {
 	falsePositiveIPs := make([]string, 0)
+	r := s.resolver
+	if r == nil {
+		r = resolver.Default()
+	}
 
 	for _, ipResult := range successIPs {
 		// perform lookup with per-lookup timeout
 		lookupCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
-		names, err := net.DefaultResolver.LookupAddr(lookupCtx, ipResult.IP)
+		names, err := r.LookupAddr(lookupCtx, ipResult.IP)
 		cancel()
 		if err != nil || len(names) == 0 {
 			// No PTR found; record empty PTR
@@ -1036,10 +1187,11 @@ func (s *Scanner) getUserAgent() string {
 }
 
 // getClient returns the HTTP client to use (with proxy rotation if enabled)
-func (s *Scanner) getClient() *http.Client {
+// along with the proxy it sends through (nil for direct connections)
+func (s *Scanner) getClient() (*http.Client, *proxy.Proxy) {
 	// No proxy rotation needed
 	if !s.config.ProxyRotate || len(s.proxyList) <= 1 {
-		return s.client
+		return s.client, s.proxy
 	}
 
 	// Rotate through proxy list
@@ -1050,8 +1202,42 @@ func (s *Scanner) getClient() *http.Client {
 	client, err := proxyObj.GetHTTPClient(s.config.Timeout)
 	if err != nil {
 		// Fallback to default client on error
-		return s.client
+		return s.client, s.proxy
 	}
 
-	return client
+	return client, proxyObj
+}
+
+// egressKey returns the summary key for a proxy: its exit IP when known,
+// otherwise the redacted proxy address marked as unverified
+func egressKey(p *proxy.Proxy) string {
+	if p.ExitIP != "" {
+		return p.ExitIP
+	}
+	return "unverified " + p.Redacted()
+}
+
+// recordEgress counts one request sent through the given proxy
+func (s *Scanner) recordEgress(p *proxy.Proxy) {
+	s.egressMu.Lock()
+	defer s.egressMu.Unlock()
+	if s.egressCounts == nil {
+		s.egressCounts = make(map[string]uint64)
+	}
+	s.egressCounts[egressKey(p)]++
+}
+
+// GetEgressStats returns a copy of the per-exit-IP request counts
+// Returns nil when no request went through a proxy
+func (s *Scanner) GetEgressStats() map[string]uint64 {
+	s.egressMu.Lock()
+	defer s.egressMu.Unlock()
+	if len(s.egressCounts) == 0 {
+		return nil
+	}
+	stats := make(map[string]uint64, len(s.egressCounts))
+	for k, v := range s.egressCounts {
+		stats[k] = v
+	}
+	return stats
 }
@@ -2,13 +2,23 @@ package scanner
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/jhaxce/origindive/pkg/asn"
 	"github.com/jhaxce/origindive/pkg/core"
+	"github.com/jhaxce/origindive/pkg/geoip"
+	"github.com/jhaxce/origindive/pkg/proxy"
+	"github.com/jhaxce/origindive/pkg/waf"
 )
 
 func TestNew(t *testing.T) {
@@ -164,9 +174,9 @@ func TestScanner_WAFIntegration(t *testing.T) {
 		t.Fatalf("New() with SkipWAF=true error: %v", err)
 	}
 
-	// Filter should still be nil because WAFDatabasePath is empty
-	if scanner2.wafFilter != nil {
-		t.Error("WAF filter should be nil when WAFDatabasePath is empty")
+	// Without a database path the embedded database is filtered
+	if scanner2.wafFilter == nil {
+		t.Error("WAF filter should use the embedded database when WAFDatabasePath is empty")
 	}
 }
 
@@ -412,9 +422,12 @@ func TestScanner_SkipWAF(t *testing.T) {
 		t.Fatalf("New() error: %v", err)
 	}
 
-	// WAF filter is nil when database path is empty
-	if scanner1.wafFilter != nil {
-		t.Error("WAF filter should be nil when WAFDatabasePath is empty")
+	// Without a database path the embedded database is filtered
+	if scanner1.wafFilter == nil {
+		t.Error("WAF filter should use the embedded database when WAFDatabasePath is empty")
+	}
+	if skip, _ := scanner1.wafFilter.ShouldSkip(net.ParseIP("104.16.0.1")); !skip {
+		t.Error("embedded filter should skip Cloudflare addresses")
 	}
 
 	// Test with SkipWAF = false (should NOT have WAF filter)
@@ -435,3 +448,442 @@ func TestScanner_SkipWAF(t *testing.T) {
 		t.Error("WAF filter should be nil when SkipWAF is false")
 	}
 }
+
+func TestScanner_CustomWAF(t *testing.T) {
+	dir := t.TempDir()
+	office := filepath.Join(dir, "office.txt")
+	os.WriteFile(office, []byte("# Office egress\n192.0.2.0/25\n"), 0644)
+	partners := filepath.Join(dir, "partners.json")
+	os.WriteFile(partners, []byte(`{"providers":[{"id":"partner-cdn","name":"Partner CDN","ranges":["192.0.2.128/26"]}]}`), 0644)
+
+	config := &core.Config{
+		Domain:         "example.com",
+		Timeout:        time.Second,
+		Workers:        2,
+		CustomWAFFile:  office,
+		CustomWAFFiles: []string{partners},
+		IPRanges:       [][2]uint32{{0xc0000200, 0xc00002bf}}, // 192.0.2.0 - 192.0.2.191
+	}
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if s.wafFilter == nil {
+		t.Fatal("custom ranges should enable the WAF filter without --skip-waf")
+	}
+
+	// Every address is covered, so nothing is scanned
+	result, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	if result.Summary.ScannedIPs != 0 || result.Summary.SkippedIPs != 192 {
+		t.Errorf("scanned/skipped = %d/%d, want 0/192", result.Summary.ScannedIPs, result.Summary.SkippedIPs)
+	}
+	if result.Summary.WAFStats["custom-office"] != 128 || result.Summary.WAFStats["partner-cdn"] != 64 {
+		t.Errorf("WAFStats = %v", result.Summary.WAFStats)
+	}
+
+	// A bad file is an error, not silently ignored
+	config.CustomWAFFiles = []string{filepath.Join(dir, "missing.txt")}
+	if _, err := New(config); err == nil {
+		t.Error("New() should fail for a missing custom WAF file")
+	}
+}
+
+func TestScanner_CustomWAFPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "waf_ranges.json")
+	os.WriteFile(dbPath, []byte(`{"providers":[{"id":"edge","name":"Edge CDN","category":"cdn","ranges":["192.0.2.0/24"]}]}`), 0644)
+	os.Mkdir(filepath.Join(dir, "a"), 0755)
+	os.Mkdir(filepath.Join(dir, "b"), 0755)
+	first := filepath.Join(dir, "a", "office.txt")
+	os.WriteFile(first, []byte("192.0.2.0/25\n"), 0644)
+	second := filepath.Join(dir, "b", "office.txt")
+	os.WriteFile(second, []byte("192.0.2.128/26\n"), 0644)
+
+	config := &core.Config{
+		Domain:          "example.com",
+		Timeout:         time.Second,
+		Workers:         2,
+		SkipWAF:         true,
+		WAFDatabasePath: dbPath,
+		CustomWAFFiles:  []string{first, second},
+		IPRanges:        [][2]uint32{{0xc0000200, 0xc00002ff}}, // 192.0.2.0/24
+	}
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	// Custom ranges win over the database in the filter and the classifier,
+	// and files sharing a name get distinct providers
+	result, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	stats := result.Summary.WAFStats
+	if stats["custom-office"] != 128 || stats["custom-office-2"] != 64 || stats["edge"] != 64 {
+		t.Errorf("WAFStats = %v", stats)
+	}
+	for ip, want := range map[string]string{"192.0.2.1": "custom-office", "192.0.2.129": "custom-office-2", "192.0.2.200": "edge"} {
+		if provider, _, _ := s.classifier.ClassifyString(ip); provider != want {
+			t.Errorf("Classify(%s) = %q, want %q", ip, provider, want)
+		}
+	}
+}
+
+func TestScanner_EgressIdentity(t *testing.T) {
+	// Each test server acts as an HTTP proxy and answers every request itself
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	proxyA := httptest.NewServer(handler)
+	defer proxyA.Close()
+	proxyB := httptest.NewServer(handler)
+	defer proxyB.Close()
+
+	pa, err := proxy.ParseProxy(proxyA.URL)
+	if err != nil {
+		t.Fatalf("ParseProxy() error: %v", err)
+	}
+	pa.ExitIP = "203.0.113.10"
+	pb, err := proxy.ParseProxy(proxyB.URL)
+	if err != nil {
+		t.Fatalf("ParseProxy() error: %v", err)
+	}
+	// pb left unverified (no ExitIP)
+
+	s := &Scanner{
+		config: &core.Config{
+			Domain:      "example.com",
+			HTTPMethod:  "GET",
+			Timeout:     5 * time.Second,
+			ProxyRotate: true,
+		},
+		client:    http.DefaultClient,
+		proxyList: []*proxy.Proxy{pa, pb},
+	}
+
+	exits := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		result := s.scanIP(context.Background(), net.ParseIP("192.0.2.1"))
+		if result.Status != "200" {
+			t.Fatalf("scanIP() status = %s, error = %s", result.Status, result.Error)
+		}
+		if result.Proxy == "" {
+			t.Error("scanIP() did not record the proxy")
+		}
+		if strings.Contains(result.Proxy, "@") {
+			t.Errorf("Proxy %q should not contain credentials", result.Proxy)
+		}
+		exits[result.ExitIP]++
+	}
+
+	if exits["203.0.113.10"] != 2 || exits[""] != 2 {
+		t.Errorf("exit IPs = %v, want 2 verified and 2 unverified", exits)
+	}
+
+	stats := s.GetEgressStats()
+	if stats["203.0.113.10"] != 2 {
+		t.Errorf("EgressStats[203.0.113.10] = %d, want 2", stats["203.0.113.10"])
+	}
+	if stats["unverified "+pb.Redacted()] != 2 {
+		t.Errorf("EgressStats = %v, want 2 unverified requests via %s", stats, pb.Redacted())
+	}
+}
+
+func TestScanner_EgressStatsDirect(t *testing.T) {
+	s, err := New(&core.Config{Timeout: 5 * time.Second, Workers: 1})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if stats := s.GetEgressStats(); stats != nil {
+		t.Errorf("GetEgressStats() = %v, want nil without proxies", stats)
+	}
+}
+
+func TestScanner_Categories(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "waf_ranges.json")
+	os.WriteFile(dbPath, []byte(`{"providers":[
+		{"id":"edge","name":"Edge CDN","category":"cdn","ranges":["127.0.0.0/30"]},
+		{"id":"compute","name":"Compute","category":"cloud","ranges":["127.0.0.4/30"]}
+	]}`), 0644)
+
+	config := &core.Config{
+		Domain:          "example.com",
+		HTTPMethod:      "GET",
+		Timeout:         time.Second,
+		Workers:         2,
+		SkipWAF:         true,
+		ShowAll:         true,
+		WAFDatabasePath: dbPath,
+		IPRanges:        [][2]uint32{{0x7f000000, 0x7f000008}}, // 127.0.0.0 - 127.0.0.8
+	}
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	// Only the CDN range is skipped; cloud addresses are scanned and labeled
+	result, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	if result.Summary.SkippedIPs != 4 || result.Summary.ScannedIPs != 5 {
+		t.Errorf("scanned/skipped = %d/%d, want 5/4", result.Summary.ScannedIPs, result.Summary.SkippedIPs)
+	}
+
+	var all []*core.IPResult
+	all = append(all, result.Success...)
+	all = append(all, result.Redirects...)
+	all = append(all, result.Other...)
+	all = append(all, result.Timeouts...)
+	all = append(all, result.Errors...)
+	labeled := 0
+	for _, r := range all {
+		switch r.IP {
+		case "127.0.0.4", "127.0.0.5", "127.0.0.6", "127.0.0.7":
+			if r.Provider != "compute" || r.Category != "cloud" {
+				t.Errorf("%s: provider/category = %q/%q, want compute/cloud", r.IP, r.Provider, r.Category)
+			}
+			labeled++
+		default:
+			if r.Provider != "" || r.Category != "" {
+				t.Errorf("%s: provider/category = %q/%q, want none", r.IP, r.Provider, r.Category)
+			}
+		}
+	}
+	if labeled != 4 {
+		t.Errorf("%d cloud results, want 4", labeled)
+	}
+
+	// Skipping the cloud category as well leaves only 127.0.0.8
+	config.SkipCategories = []string{"cdn", "cloud"}
+	s, err = New(config)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	result, err = s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	if result.Summary.SkippedIPs != 8 || result.Summary.WAFStats["compute"] != 4 {
+		t.Errorf("skipped = %d, WAFStats = %v", result.Summary.SkippedIPs, result.Summary.WAFStats)
+	}
+}
+
+func TestScanner_EmbeddedWAFDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	// A database path that does not exist yet falls back to the embedded one
+	config := &core.Config{
+		Timeout:         time.Second,
+		Workers:         1,
+		SkipWAF:         true,
+		WAFDatabasePath: filepath.Join(dir, "waf_ranges.json"),
+	}
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if skip, provider := s.wafFilter.ShouldSkip(net.ParseIP("104.16.0.1")); !skip || provider != "cloudflare" {
+		t.Errorf("ShouldSkip(104.16.0.1) = %v, %q", skip, provider)
+	}
+
+	// A corrupt database is an error rather than silently replaced
+	os.WriteFile(config.WAFDatabasePath, []byte("{"), 0644)
+	if _, err := New(config); err == nil {
+		t.Error("New() should fail for a corrupt WAF database with SkipWAF")
+	}
+	config.SkipWAF = false
+	if _, err := New(config); err != nil {
+		t.Errorf("New() without SkipWAF error: %v", err)
+	}
+}
+
+func TestScanner_CDNEdgeSignatures(t *testing.T) {
+	// The test server stands in for every scanned IP
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Scanned-IP") {
+		case "192.0.2.1":
+			w.Header().Set("CF-Ray", "8f1c2d3e4f5a6b7c-LAX")
+		case "192.0.2.2":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("<html><body>Request unsuccessful. Incapsula incident ID: 123</body></html>"))
+			return
+		}
+		w.Write([]byte("<html><title>Origin</title></html>"))
+	}))
+	defer srv.Close()
+	srvURL, _ := url.Parse(srv.URL)
+
+	sigs, err := waf.EmbeddedSignatureDatabase()
+	if err != nil {
+		t.Fatalf("EmbeddedSignatureDatabase() error: %v", err)
+	}
+	s := &Scanner{
+		config: &core.Config{
+			Domain:     "example.com",
+			HTTPMethod: "GET",
+			Timeout:    5 * time.Second,
+			Workers:    2,
+			ShowAll:    true,
+			IPRanges:   [][2]uint32{{0xc0000201, 0xc0000203}}, // 192.0.2.1 - 192.0.2.3
+		},
+		client:     &http.Client{Transport: redirectTransport{srvURL.Host}},
+		signatures: sigs,
+	}
+
+	result := s.scanIP(context.Background(), net.ParseIP("192.0.2.1"))
+	if result.EdgeProvider != "cloudflare" || result.EdgeEvidence != "cf-ray: 8f1c2d3e4f5a6b7c-LAX" {
+		t.Errorf("scanIP(192.0.2.1) edge = %q (%s)", result.EdgeProvider, result.EdgeEvidence)
+	}
+
+	scan, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	if len(scan.Success) != 1 || scan.Success[0].IP != "192.0.2.3" {
+		t.Errorf("Success = %v, want only the origin 192.0.2.3", scan.Summary.SuccessIPs)
+	}
+	if len(scan.CDNEdges) != 2 || scan.Summary.CDNEdgeCount != 2 {
+		t.Errorf("CDNEdges = %d, count %d, want 2", len(scan.CDNEdges), scan.Summary.CDNEdgeCount)
+	}
+	if scan.Summary.EdgeStats["cloudflare"] != 1 || scan.Summary.EdgeStats["incapsula"] != 1 {
+		t.Errorf("EdgeStats = %v", scan.Summary.EdgeStats)
+	}
+
+	// The Incapsula block page is classified by its body match
+	for _, edge := range scan.CDNEdges {
+		if edge.EdgeProvider == "incapsula" && edge.PageClass != core.PageClassBlock {
+			t.Errorf("block page = %+v", edge)
+		}
+	}
+	if scan.Summary.PageStats[core.PageClassBlock] != 1 {
+		t.Errorf("PageStats = %v", scan.Summary.PageStats)
+	}
+
+	// Without signatures neither edge is recognized
+	s.signatures = nil
+	scan, _ = s.Scan(context.Background())
+	if len(scan.Success) != 2 || len(scan.CDNEdges) != 0 {
+		t.Errorf("without signatures success/edges = %d/%d, want 2/0", len(scan.Success), len(scan.CDNEdges))
+	}
+}
+
+// redirectTransport sends every request to addr, passing the scanned IP in
+// X-Scanned-IP
+type redirectTransport struct{ addr string }
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Scanned-IP", req.URL.Hostname())
+	req.URL.Host = rt.addr
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestScanner_ASNStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><title>Origin</title></html>"))
+	}))
+	defer srv.Close()
+	srvURL, _ := url.Parse(srv.URL)
+
+	db, err := asn.ParseDatabase(strings.NewReader("192.0.2.0\t192.0.2.1\t64500\tUS\tEXAMPLE-NET\n192.0.2.2\t192.0.2.2\t64501\tDE\tOTHER-NET\n"))
+	if err != nil {
+		t.Fatalf("ParseDatabase() error: %v", err)
+	}
+	s := &Scanner{
+		config: &core.Config{
+			Domain:     "example.com",
+			HTTPMethod: "GET",
+			Timeout:    5 * time.Second,
+			Workers:    2,
+			IPRanges:   [][2]uint32{{0xc0000201, 0xc0000203}}, // 192.0.2.1 - 192.0.2.3
+		},
+		client: &http.Client{Transport: redirectTransport{srvURL.Host}},
+	}
+	s.SetASNDatabase(db)
+
+	scan, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	for _, r := range scan.Success {
+		if r.IP == "192.0.2.1" && (r.ASN != 64500 || r.ASOrg != "EXAMPLE-NET") {
+			t.Errorf("192.0.2.1 = AS%d %q, want AS64500 EXAMPLE-NET", r.ASN, r.ASOrg)
+		}
+	}
+	want := map[string]uint64{"AS64500 EXAMPLE-NET": 1, "AS64501 OTHER-NET": 1}
+	if len(scan.Summary.ASNStats) != len(want) {
+		t.Errorf("ASNStats = %v, want %v (192.0.2.3 has no ASN)", scan.Summary.ASNStats, want)
+	}
+	for k, v := range want {
+		if scan.Summary.ASNStats[k] != v {
+			t.Errorf("ASNStats[%s] = %d, want %d", k, scan.Summary.ASNStats[k], v)
+		}
+	}
+}
+
+func TestScanner_GeoFilter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><title>Origin</title></html>"))
+	}))
+	defer srv.Close()
+	srvURL, _ := url.Parse(srv.URL)
+
+	// 192.0.2.0-1 are in DE, 192.0.2.2 in US, 192.0.2.3 has no location
+	db, err := geoip.Open("testdata/geoip-country.mmdb")
+	if err != nil {
+		t.Fatalf("geoip.Open() error: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		include, exclude []string
+		wantIPs          []string
+	}{
+		{"include", []string{"DE"}, nil, []string{"192.0.2.1"}},
+		{"exclude", nil, []string{"DE"}, []string{"192.0.2.2", "192.0.2.3"}},
+		{"none", nil, nil, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := geoip.NewFilter(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("NewFilter() error: %v", err)
+			}
+			s := &Scanner{
+				config: &core.Config{
+					Domain:     "example.com",
+					HTTPMethod: "GET",
+					Timeout:    5 * time.Second,
+					Workers:    2,
+					IPRanges:   [][2]uint32{{0xc0000201, 0xc0000203}}, // 192.0.2.1 - 192.0.2.3
+				},
+				client:    &http.Client{Transport: redirectTransport{srvURL.Host}},
+				geoFilter: filter,
+			}
+			s.SetGeoDatabase(db)
+
+			scan, err := s.Scan(context.Background())
+			if err != nil {
+				t.Fatalf("Scan() error: %v", err)
+			}
+			got := append([]string(nil), scan.Summary.SuccessIPs...)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.wantIPs) {
+				t.Errorf("scanned hits = %v, want %v", got, tt.wantIPs)
+			}
+			if skipped := 3 - uint64(len(tt.wantIPs)); scan.Summary.GeoSkippedIPs != skipped || scan.Summary.SkippedIPs != 0 {
+				t.Errorf("GeoSkippedIPs = %d, SkippedIPs = %d; want %d, 0", scan.Summary.GeoSkippedIPs, scan.Summary.SkippedIPs, skipped)
+			}
+			for _, r := range scan.Success {
+				if r.IP == "192.0.2.1" && r.Country != "DE" {
+					t.Errorf("192.0.2.1 country = %q, want DE", r.Country)
+				}
+			}
+		})
+	}
+}
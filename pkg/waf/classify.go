@@ -0,0 +1,76 @@
+package waf
+
+import "net"
+
+// Classifier tells which provider and category an address belongs to,
+// over every provider in the database whether it is skipped or not
+type Classifier struct {
+	rangeSet   *RangeSet
+	categories map[string]string // provider ID -> category
+}
+
+// NewClassifier creates a classifier for providers
+// Where ranges overlap the provider listed first wins
+func NewClassifier(providers []Provider) *Classifier {
+	c := &Classifier{
+		rangeSet:   NewRangeSet(),
+		categories: make(map[string]string, len(providers)),
+	}
+
+	for i := range providers {
+		if err := c.rangeSet.AddProvider(&providers[i]); err != nil {
+			continue // Invalid ranges are reported when the skip list is loaded
+		}
+		if _, ok := c.categories[providers[i].ID]; !ok {
+			c.categories[providers[i].ID] = providers[i].GetCategory()
+		}
+	}
+
+	return c
+}
+
+// LoadClassifier creates a classifier from the WAF database at dbPath and
+// the custom ranges files, which take precedence. A missing database
+// falls back to the embedded one; an unreadable one leaves only the
+// custom providers.
+func LoadClassifier(dbPath string, customPaths []string) (*Classifier, error) {
+	providers, err := LoadCustomProviderFiles(customPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	if db, _, err := OpenWAFDatabase(dbPath); err == nil {
+		providers = append(providers, db.Providers...)
+	}
+
+	return NewClassifier(providers), nil
+}
+
+// Classify returns the provider ID and category of ip's range
+func (c *Classifier) Classify(ip net.IP) (provider, category string, found bool) {
+	if c == nil {
+		return "", "", false
+	}
+	provider, found = c.rangeSet.FindProvider(ip)
+	if !found {
+		return "", "", false
+	}
+	return provider, c.categories[provider], true
+}
+
+// ClassifyString is Classify for a textual address
+func (c *Classifier) ClassifyString(addr string) (provider, category string, found bool) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", "", false
+	}
+	return c.Classify(ip)
+}
+
+// Category returns the category of a provider ("" if unknown)
+func (c *Classifier) Category(provider string) string {
+	if c == nil {
+		return ""
+	}
+	return c.categories[provider]
+}
@@ -0,0 +1,159 @@
+package waf
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testCategoryDB = &WAFDatabase{Providers: []Provider{
+	{ID: "cloudflare", Category: CategoryCDN, Ranges: []string{"104.16.0.0/13"}},
+	{ID: "incapsula", Category: CategoryWAF, Ranges: []string{"45.60.0.0/16"}},
+	{ID: "aws-ec2", Category: CategoryCloud, Ranges: []string{"3.0.0.0/9", "2600:1f18::/33"}},
+	{ID: "hetzner", Category: CategoryHosting, Ranges: []string{"5.9.0.0/16"}},
+	{ID: "legacy", Ranges: []string{"198.51.100.0/24"}}, // Written before categories
+}}
+
+func TestProvider_GetCategory(t *testing.T) {
+	if got := testCategoryDB.GetProvider("legacy").GetCategory(); got != CategoryCDN {
+		t.Errorf("GetCategory() without a category = %q, want cdn", got)
+	}
+	if got := testCategoryDB.GetProvider("hetzner").GetCategory(); got != CategoryHosting {
+		t.Errorf("GetCategory() = %q, want hosting", got)
+	}
+	if IsValidCategory("isp") || !IsValidCategory("cloud") {
+		t.Error("IsValidCategory() mismatch")
+	}
+
+	db := &WAFDatabase{Providers: []Provider{{ID: "x", Category: "isp", Ranges: []string{"192.0.2.0/24"}}}}
+	if err := db.ValidateRanges(); err == nil {
+		t.Error("ValidateRanges() should reject an unknown category")
+	}
+}
+
+func TestLoadSkipRanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		ids        []string
+		categories []string
+		skipped    []string
+		scanned    []string
+	}{
+		{"default categories", nil, nil,
+			[]string{"104.16.0.1", "45.60.1.1", "198.51.100.1"},
+			[]string{"3.1.1.1", "5.9.1.1", "2600:1f18::1"}},
+		{"cloud too", nil, []string{"cdn", "waf", "cloud"},
+			[]string{"104.16.0.1", "3.1.1.1", "2600:1f18::1"},
+			[]string{"5.9.1.1"}},
+		{"hosting only", nil, []string{" Hosting "},
+			[]string{"5.9.1.1"},
+			[]string{"104.16.0.1", "3.1.1.1"}},
+		{"explicit providers override categories", []string{"aws-ec2"}, []string{"cdn"},
+			[]string{"3.1.1.1"},
+			[]string{"104.16.0.1"}},
+	}
+	for _, tt := range tests {
+		rs, err := LoadSkipRanges(testCategoryDB, tt.ids, tt.categories)
+		if err != nil {
+			t.Fatalf("%s: LoadSkipRanges() error: %v", tt.name, err)
+		}
+		for _, ip := range tt.skipped {
+			if !rs.Contains(net.ParseIP(ip)) {
+				t.Errorf("%s: %s should be skipped", tt.name, ip)
+			}
+		}
+		for _, ip := range tt.scanned {
+			if rs.Contains(net.ParseIP(ip)) {
+				t.Errorf("%s: %s should not be skipped", tt.name, ip)
+			}
+		}
+	}
+
+	if _, err := LoadSkipRanges(testCategoryDB, []string{"missing"}, nil); err == nil {
+		t.Error("LoadSkipRanges() should fail for an unknown provider")
+	}
+}
+
+func TestClassifier(t *testing.T) {
+	c := NewClassifier(testCategoryDB.Providers)
+
+	tests := []struct {
+		ip, provider, category string
+	}{
+		{"104.16.0.1", "cloudflare", CategoryCDN},
+		{"45.60.1.1", "incapsula", CategoryWAF},
+		{"3.1.1.1", "aws-ec2", CategoryCloud},
+		{"2600:1f18::1", "aws-ec2", CategoryCloud},
+		{"5.9.1.1", "hetzner", CategoryHosting},
+		{"198.51.100.1", "legacy", CategoryCDN},
+		{"192.0.2.1", "", ""},
+	}
+	for _, tt := range tests {
+		provider, category, found := c.ClassifyString(tt.ip)
+		if provider != tt.provider || category != tt.category || found != (tt.provider != "") {
+			t.Errorf("ClassifyString(%s) = %q, %q, %v; want %q, %q", tt.ip, provider, category, found, tt.provider, tt.category)
+		}
+	}
+	if _, _, found := c.ClassifyString("not-an-ip"); found {
+		t.Error("ClassifyString() found a provider for an invalid address")
+	}
+	if c.Category("aws-ec2") != CategoryCloud || c.Category("missing") != "" {
+		t.Error("Category() mismatch")
+	}
+
+	// A nil classifier (no database) classifies nothing
+	var none *Classifier
+	if _, _, found := none.Classify(net.ParseIP("104.16.0.1")); found || none.Category("cloudflare") != "" {
+		t.Error("nil Classifier should classify nothing")
+	}
+}
+
+func TestLoadClassifier(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "waf_ranges.json")
+	if err := SaveWAFDatabase(dbPath, testCategoryDB); err != nil {
+		t.Fatalf("SaveWAFDatabase() error: %v", err)
+	}
+	office := filepath.Join(dir, "office.txt")
+	os.WriteFile(office, []byte("3.0.0.0/24\n"), 0644)
+
+	c, err := LoadClassifier(dbPath, []string{office})
+	if err != nil {
+		t.Fatalf("LoadClassifier() error: %v", err)
+	}
+	// Custom ranges take precedence over the database
+	if provider, category, _ := c.ClassifyString("3.0.0.1"); provider != "custom-office" || category != CategoryWAF {
+		t.Errorf("ClassifyString(3.0.0.1) = %q, %q", provider, category)
+	}
+	if provider, _, _ := c.ClassifyString("3.1.0.1"); provider != "aws-ec2" {
+		t.Errorf("ClassifyString(3.1.0.1) = %q", provider)
+	}
+
+	// A missing database falls back to the embedded one
+	c, err = LoadClassifier(filepath.Join(dir, "missing.json"), []string{office})
+	if err != nil {
+		t.Fatalf("LoadClassifier() error: %v", err)
+	}
+	if _, _, found := c.ClassifyString("3.1.0.1"); found {
+		t.Error("ClassifyString() found a provider of the missing database")
+	}
+	if provider, _, _ := c.ClassifyString("104.16.0.1"); provider != "cloudflare" {
+		t.Errorf("ClassifyString(104.16.0.1) = %q, want the embedded cloudflare", provider)
+	}
+
+	// A corrupt database is not replaced by the embedded one
+	corrupt := filepath.Join(dir, "corrupt.json")
+	os.WriteFile(corrupt, []byte("{"), 0644)
+	c, err = LoadClassifier(corrupt, []string{office})
+	if err != nil {
+		t.Fatalf("LoadClassifier() error: %v", err)
+	}
+	if _, _, found := c.ClassifyString("104.16.0.1"); found {
+		t.Error("ClassifyString() used the embedded database for a corrupt file")
+	}
+
+	if _, err := LoadClassifier(dbPath, []string{filepath.Join(dir, "missing.txt")}); err == nil {
+		t.Error("LoadClassifier() should fail for a missing custom file")
+	}
+}
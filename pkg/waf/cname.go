@@ -0,0 +1,147 @@
+package waf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// CNAMEPattern maps the hostnames a CDN/WAF points customers' CNAMEs at
+// to the provider
+type CNAMEPattern struct {
+	Name     string   `json:"name"`
+	ID       string   `json:"id"`
+	Suffixes []string `json:"suffixes"` // e.g. "cdn.cloudflare.net", "edgekey.net"
+}
+
+// CNAMEDatabase is the CNAME pattern table (cdn_cnames.json)
+type CNAMEDatabase struct {
+	LastUpdated time.Time      `json:"last_updated"`
+	Patterns    []CNAMEPattern `json:"patterns"`
+}
+
+// LoadCNAMEDatabase loads the CNAME pattern table from a JSON file
+func LoadCNAMEDatabase(filepath string) (*CNAMEDatabase, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CNAME database: %w", err)
+	}
+
+	var db CNAMEDatabase
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("failed to parse CNAME database: %w", err)
+	}
+
+	return &db, nil
+}
+
+// Match returns the pattern whose suffix host ends with
+// Suffixes match whole labels: "cloudfront.net" matches
+// "d111.cloudfront.net" but not "evilcloudfront.net"
+func (db *CNAMEDatabase) Match(host string) (*CNAMEPattern, string, bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for i := range db.Patterns {
+		for _, suffix := range db.Patterns[i].Suffixes {
+			suffix = strings.ToLower(strings.Trim(suffix, "."))
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return &db.Patterns[i], suffix, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// Detection is a CDN/WAF the target domain appears to be behind
+type Detection struct {
+	Provider string // Provider ID
+	Name     string // Provider display name
+	Method   string // "cname" or "ip"
+	Evidence string // e.g. "www.example.com → example.com.cdn.cloudflare.net"
+}
+
+// DomainResolver resolves the names checked by DetectDomain;
+// *net.Resolver and *resolver.Resolver implement it
+type DomainResolver interface {
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// chainResolver is a DomainResolver that reports every hop of a CNAME
+// chain; *resolver.Resolver implements it
+type chainResolver interface {
+	LookupCNAMEChain(ctx context.Context, host string) ([]string, error)
+}
+
+// cnameChain returns the names host's CNAME chain passes through
+// Resolvers without LookupCNAMEChain only give the canonical name.
+func cnameChain(ctx context.Context, r DomainResolver, host string) []string {
+	if cr, ok := r.(chainResolver); ok {
+		chain, _ := cr.LookupCNAMEChain(ctx, host)
+		return chain
+	}
+	canonical, err := r.LookupCNAME(ctx, host)
+	canonical = strings.TrimSuffix(canonical, ".")
+	if err != nil || canonical == "" || strings.EqualFold(canonical, host) {
+		return nil // No CNAME
+	}
+	return []string{canonical}
+}
+
+// DetectDomain reports the CDNs/WAFs in front of domain: every hop of the
+// CNAME chains of domain and www.domain is matched against cnames, and the
+// domain's addresses against the CDN/WAF ranges in db. Either database may
+// be nil.
+// The CNAME checks catch providers without published ranges and IPv6-only
+// edges that the range check misses.
+func DetectDomain(ctx context.Context, r DomainResolver, db *WAFDatabase, cnames *CNAMEDatabase, domain string) []Detection {
+	var detections []Detection
+	seen := make(map[string]bool)
+	add := func(d Detection) {
+		if !seen[d.Provider+" "+d.Method] {
+			seen[d.Provider+" "+d.Method] = true
+			detections = append(detections, d)
+		}
+	}
+
+	if cnames != nil {
+		hosts := []string{domain}
+		if !strings.HasPrefix(domain, "www.") {
+			hosts = append(hosts, "www."+domain)
+		}
+		for _, host := range hosts {
+			chain := cnameChain(ctx, r, host)
+			for i, name := range chain {
+				if p, _, ok := cnames.Match(name); ok {
+					evidence := strings.Join(append([]string{host}, chain[:i+1]...), " → ")
+					add(Detection{Provider: p.ID, Name: p.Name, Method: "cname", Evidence: evidence})
+				}
+			}
+		}
+	}
+
+	if db != nil {
+		addrs, err := r.LookupHost(ctx, domain)
+		if err == nil {
+			rs, _ := LoadSkipRanges(db, nil, nil) // Cloud and hosting ranges are not a CDN in front
+			for _, addr := range addrs {
+				ip := net.ParseIP(addr)
+				if ip == nil || rs == nil {
+					continue
+				}
+				if id, found := rs.FindProvider(ip); found {
+					name := id
+					if p := db.GetProvider(id); p != nil {
+						name = p.Name
+					}
+					add(Detection{Provider: id, Name: name, Method: "ip", Evidence: domain + " → " + addr})
+				}
+			}
+		}
+	}
+
+	return detections
+}
@@ -0,0 +1,195 @@
+package waf
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDomainResolver answers CNAME and host lookups from tables; cnames
+// holds one hop per name, and names without a CNAME are their own
+// canonical name
+type fakeDomainResolver struct {
+	cnames map[string]string
+	hosts  map[string][]string
+}
+
+func (f *fakeDomainResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	chain, err := f.LookupCNAMEChain(ctx, host)
+	if len(chain) > 0 {
+		return chain[len(chain)-1] + ".", nil
+	}
+	return host + ".", err
+}
+
+func (f *fakeDomainResolver) LookupCNAMEChain(ctx context.Context, host string) ([]string, error) {
+	var chain []string
+	for name := host; ; {
+		cname, ok := f.cnames[name]
+		if !ok {
+			break
+		}
+		name = strings.TrimSuffix(cname, ".")
+		chain = append(chain, name)
+	}
+	if _, ok := f.hosts[host]; !ok && len(chain) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return chain, nil
+}
+
+// endOnlyResolver hides LookupCNAMEChain, like *net.Resolver
+type endOnlyResolver struct{ DomainResolver }
+
+func (f *fakeDomainResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := f.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+var testCNAMEs = &CNAMEDatabase{Patterns: []CNAMEPattern{
+	{ID: "cloudflare", Name: "Cloudflare", Suffixes: []string{"cdn.cloudflare.net"}},
+	{ID: "aws-cloudfront", Name: "AWS CloudFront", Suffixes: []string{".cloudfront.net"}},
+	{ID: "akamai", Name: "Akamai", Suffixes: []string{"edgekey.net", "akamaiedge.net"}},
+	{ID: "azure-cdn", Name: "Azure Front Door / CDN", Suffixes: []string{"azureedge.net"}},
+}}
+
+func TestCNAMEDatabase_Match(t *testing.T) {
+	tests := []struct {
+		host   string
+		want   string
+		suffix string
+	}{
+		{"example.com.cdn.cloudflare.net.", "cloudflare", "cdn.cloudflare.net"},
+		{"D111111abcdef8.CloudFront.net", "aws-cloudfront", "cloudfront.net"},
+		{"e1234.a.akamaiedge.net", "akamai", "akamaiedge.net"},
+		{"cloudfront.net", "aws-cloudfront", "cloudfront.net"},
+		{"evilcloudfront.net", "", ""},
+		{"cloudflare.net", "", ""},
+		{"example.com", "", ""},
+	}
+	for _, tt := range tests {
+		p, suffix, ok := testCNAMEs.Match(tt.host)
+		if ok != (tt.want != "") {
+			t.Errorf("Match(%q) ok = %v", tt.host, ok)
+			continue
+		}
+		if ok && (p.ID != tt.want || suffix != tt.suffix) {
+			t.Errorf("Match(%q) = %s/%s, want %s/%s", tt.host, p.ID, suffix, tt.want, tt.suffix)
+		}
+	}
+}
+
+func TestDetectDomain(t *testing.T) {
+	db := &WAFDatabase{Providers: []Provider{
+		{ID: "cloudflare", Name: "Cloudflare", Ranges: []string{"104.16.0.0/13"}},
+		{ID: "aws-ec2", Name: "AWS EC2", Category: CategoryCloud, Ranges: []string{"2001:db8::/32"}}, // Compute is not a CDN in front
+	}}
+	r := &fakeDomainResolver{
+		cnames: map[string]string{
+			"www.example.com":             "www.example.com.edgekey.net.",
+			"www.example.com.edgekey.net": "e1.a.akamaiedge.net.",
+		},
+		hosts: map[string][]string{
+			"example.com":         {"104.16.1.1", "2001:db8::1"},
+			"e1.a.akamaiedge.net": {"2001:db8::2"},
+		},
+	}
+
+	detections := DetectDomain(context.Background(), r, db, testCNAMEs, "example.com")
+	want := []Detection{
+		{Provider: "akamai", Name: "Akamai", Method: "cname", Evidence: "www.example.com → www.example.com.edgekey.net"},
+		{Provider: "cloudflare", Name: "Cloudflare", Method: "ip", Evidence: "example.com → 104.16.1.1"},
+	}
+	if len(detections) != len(want) {
+		t.Fatalf("DetectDomain() = %+v, want %+v", detections, want)
+	}
+	for i := range want {
+		if detections[i] != want[i] {
+			t.Errorf("detection %d = %+v, want %+v", i, detections[i], want[i])
+		}
+	}
+
+	// Without a CNAME table only the ranges are checked
+	if got := DetectDomain(context.Background(), r, db, nil, "example.com"); len(got) != 1 || got[0].Method != "ip" {
+		t.Errorf("DetectDomain() without CNAMEs = %+v", got)
+	}
+
+	// A resolver that only reports the end of the chain still detects it
+	got := DetectDomain(context.Background(), endOnlyResolver{r}, nil, testCNAMEs, "example.com")
+	if len(got) != 1 || got[0].Evidence != "www.example.com → e1.a.akamaiedge.net" {
+		t.Errorf("DetectDomain() with LookupCNAME only = %+v", got)
+	}
+
+	// A domain that is not behind a CDN
+	if got := DetectDomain(context.Background(), r, db, testCNAMEs, "origin.test"); len(got) != 0 {
+		t.Errorf("DetectDomain() = %+v, want none", got)
+	}
+}
+
+// Providers seen only on an intermediate hop are reported with the chain up
+// to that hop
+func TestDetectDomain_IntermediateHops(t *testing.T) {
+	r := &fakeDomainResolver{
+		cnames: map[string]string{
+			"shop.test":               "shop.test.azureedge.net.",
+			"shop.test.azureedge.net": "shop.test.edgekey.net.",
+			"shop.test.edgekey.net":   "e2.b.akamaiedge.net.",
+			"e2.b.akamaiedge.net":     "origin-lb.example.org.",
+		},
+		hosts: map[string][]string{"origin-lb.example.org": {"192.0.2.7"}},
+	}
+
+	detections := DetectDomain(context.Background(), r, nil, testCNAMEs, "shop.test")
+	want := []Detection{
+		{Provider: "azure-cdn", Name: "Azure Front Door / CDN", Method: "cname", Evidence: "shop.test → shop.test.azureedge.net"},
+		{Provider: "akamai", Name: "Akamai", Method: "cname", Evidence: "shop.test → shop.test.azureedge.net → shop.test.edgekey.net"},
+	}
+	if len(detections) != len(want) {
+		t.Fatalf("DetectDomain() = %+v, want %+v", detections, want)
+	}
+	for i := range want {
+		if detections[i] != want[i] {
+			t.Errorf("detection %d = %+v, want %+v", i, detections[i], want[i])
+		}
+	}
+}
+
+func TestLoadCNAMEDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cdn_cnames.json")
+	os.WriteFile(path, []byte(`{"patterns":[{"id":"fastly","name":"Fastly","suffixes":["fastly.net"]}]}`), 0644)
+
+	db, err := LoadCNAMEDatabase(path)
+	if err != nil {
+		t.Fatalf("LoadCNAMEDatabase() error = %v", err)
+	}
+	if p, _, ok := db.Match("example.global.fastly.net"); !ok || p.Name != "Fastly" {
+		t.Errorf("Match() = %v, %v", p, ok)
+	}
+
+	// The shipped table parses and covers the common CDNs
+	shipped, err := LoadCNAMEDatabase("../../data/cdn_cnames.json")
+	if err != nil {
+		t.Fatalf("LoadCNAMEDatabase(data/cdn_cnames.json) error = %v", err)
+	}
+	for _, host := range []string{"x.cdn.cloudflare.net", "x.edgekey.net", "x.cloudfront.net", "x.fastly.net", "x.azureedge.net"} {
+		if _, _, ok := shipped.Match(host); !ok {
+			t.Errorf("shipped table does not match %s", host)
+		}
+	}
+
+	// Traffic managers and Google-hosted content are not CDNs in front
+	for _, host := range []string{"x.trafficmanager.net", "x.googleusercontent.com"} {
+		if p, _, ok := shipped.Match(host); ok {
+			t.Errorf("shipped table matches %s as %s", host, p.ID)
+		}
+	}
+
+	if _, err := LoadCNAMEDatabase(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadCNAMEDatabase() should fail for a missing file")
+	}
+}
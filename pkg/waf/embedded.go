@@ -0,0 +1,135 @@
+package waf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/jhaxce/origindive/data"
+)
+
+// StaleAge is how old a WAF database can get before it is reported as
+// stale. Provider ranges change often enough that a month-old copy
+// misses new edge addresses.
+const StaleAge = 30 * 24 * time.Hour
+
+// DatabaseInfo describes which WAF database is in use
+type DatabaseInfo struct {
+	Path        string    // File the database was loaded from; empty when embedded
+	Embedded    bool      // The copy built into the binary
+	LastUpdated time.Time // The database's last_updated
+}
+
+// Source returns "embedded" or the database file path
+func (i DatabaseInfo) Source() string {
+	if i.Embedded {
+		return "embedded"
+	}
+	return i.Path
+}
+
+// Age returns how long ago the database was updated
+// A database without last_updated is treated as infinitely old
+func (i DatabaseInfo) Age() time.Duration {
+	if i.LastUpdated.IsZero() {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Since(i.LastUpdated)
+}
+
+// IsStale reports whether the database is older than StaleAge
+func (i DatabaseInfo) IsStale() bool {
+	return i.Age() > StaleAge
+}
+
+// String describes the database and its age, e.g.
+// "embedded (updated 2025-12-03, 45 days ago)"
+func (i DatabaseInfo) String() string {
+	if i.LastUpdated.IsZero() {
+		return fmt.Sprintf("%s (update time unknown)", i.Source())
+	}
+	return fmt.Sprintf("%s (updated %s, %d days ago)", i.Source(), i.LastUpdated.Format("2006-01-02"), int(i.Age().Hours()/24))
+}
+
+// EmbeddedWAFDatabase returns the WAF database built into the binary
+func EmbeddedWAFDatabase() (*WAFDatabase, error) {
+	var db WAFDatabase
+	if err := json.Unmarshal(data.WAFRanges, &db); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded WAF database: %w", err)
+	}
+	return &db, nil
+}
+
+// EmbeddedUpdateConfig returns the update sources built into the binary
+func EmbeddedUpdateConfig() (*UpdateConfig, error) {
+	var config UpdateConfig
+	if err := json.Unmarshal(data.WAFSources, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded update config: %w", err)
+	}
+	return &config, nil
+}
+
+// EmbeddedCNAMEDatabase returns the CNAME pattern table built into the binary
+func EmbeddedCNAMEDatabase() (*CNAMEDatabase, error) {
+	var db CNAMEDatabase
+	if err := json.Unmarshal(data.CDNCNAMEs, &db); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded CNAME database: %w", err)
+	}
+	return &db, nil
+}
+
+// EmbeddedSignatureDatabase returns the edge signature table built into the binary
+func EmbeddedSignatureDatabase() (*SignatureDatabase, error) {
+	var db SignatureDatabase
+	if err := json.Unmarshal(data.CDNSignatures, &db); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded signature database: %w", err)
+	}
+	return &db, nil
+}
+
+// OpenWAFDatabase loads the WAF database at path, or the embedded one when
+// path is empty or does not exist. A file that exists but cannot be read
+// or parsed is an error rather than silently replaced.
+func OpenWAFDatabase(path string) (*WAFDatabase, DatabaseInfo, error) {
+	if path != "" {
+		db, err := LoadWAFDatabase(path)
+		if err == nil {
+			return db, DatabaseInfo{Path: path, LastUpdated: db.LastUpdated}, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, DatabaseInfo{Path: path}, err
+		}
+	}
+
+	db, err := EmbeddedWAFDatabase()
+	if err != nil {
+		return nil, DatabaseInfo{Embedded: true}, err
+	}
+	return db, DatabaseInfo{Embedded: true, LastUpdated: db.LastUpdated}, nil
+}
+
+// OpenCNAMEDatabase loads the CNAME pattern table at path, or the embedded
+// one when path is empty or does not exist
+func OpenCNAMEDatabase(path string) (*CNAMEDatabase, error) {
+	if path != "" {
+		db, err := LoadCNAMEDatabase(path)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return db, err
+		}
+	}
+	return EmbeddedCNAMEDatabase()
+}
+
+// OpenSignatureDatabase loads the edge signature table at path, or the
+// embedded one when path is empty or does not exist
+func OpenSignatureDatabase(path string) (*SignatureDatabase, error) {
+	if path != "" {
+		db, err := LoadSignatureDatabase(path)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return db, err
+		}
+	}
+	return EmbeddedSignatureDatabase()
+}
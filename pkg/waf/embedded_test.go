@@ -0,0 +1,129 @@
+package waf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbeddedDatabases(t *testing.T) {
+	db, err := EmbeddedWAFDatabase()
+	if err != nil {
+		t.Fatalf("EmbeddedWAFDatabase() error: %v", err)
+	}
+	if db.GetProvider("cloudflare") == nil || db.LastUpdated.IsZero() {
+		t.Errorf("embedded database = %d providers, last updated %v", len(db.Providers), db.LastUpdated)
+	}
+	if err := db.ValidateRanges(); err != nil {
+		t.Errorf("embedded database is invalid: %v", err)
+	}
+
+	config, err := EmbeddedUpdateConfig()
+	if err != nil || len(config.Sources) == 0 {
+		t.Errorf("EmbeddedUpdateConfig() = %v, %v", config, err)
+	}
+
+	cnames, err := EmbeddedCNAMEDatabase()
+	if err != nil || len(cnames.Patterns) == 0 {
+		t.Errorf("EmbeddedCNAMEDatabase() = %v, %v", cnames, err)
+	}
+}
+
+func TestOpenWAFDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	// No path and a missing file both use the embedded database
+	for _, path := range []string{"", filepath.Join(dir, "missing.json")} {
+		db, info, err := OpenWAFDatabase(path)
+		if err != nil {
+			t.Fatalf("OpenWAFDatabase(%q) error: %v", path, err)
+		}
+		if !info.Embedded || info.Source() != "embedded" || db.GetProvider("cloudflare") == nil {
+			t.Errorf("OpenWAFDatabase(%q) = %+v", path, info)
+		}
+	}
+
+	// A file on disk is preferred
+	path := filepath.Join(dir, "waf_ranges.json")
+	updated := time.Now().Add(-48 * time.Hour).UTC()
+	SaveWAFDatabase(path, &WAFDatabase{LastUpdated: updated, Providers: []Provider{{ID: "user", Ranges: []string{"192.0.2.0/24"}}}})
+	db, info, err := OpenWAFDatabase(path)
+	if err != nil {
+		t.Fatalf("OpenWAFDatabase() error: %v", err)
+	}
+	if info.Embedded || info.Source() != path || db.GetProvider("user") == nil {
+		t.Errorf("OpenWAFDatabase() = %+v", info)
+	}
+	if info.IsStale() || !strings.Contains(info.String(), "2 days ago") {
+		t.Errorf("info = %s, stale %v", info, info.IsStale())
+	}
+
+	// A corrupt file is an error, not a silent fallback
+	os.WriteFile(path, []byte("{"), 0644)
+	if _, _, err := OpenWAFDatabase(path); err == nil {
+		t.Error("OpenWAFDatabase() should fail for a corrupt file")
+	}
+}
+
+func TestDatabaseInfo_Stale(t *testing.T) {
+	old := DatabaseInfo{Embedded: true, LastUpdated: time.Now().Add(-2 * StaleAge)}
+	if !old.IsStale() {
+		t.Error("a database twice StaleAge old should be stale")
+	}
+
+	unknown := DatabaseInfo{Path: "waf_ranges.json"}
+	if !unknown.IsStale() || unknown.String() != "waf_ranges.json (update time unknown)" {
+		t.Errorf("no last_updated: stale %v, %s", unknown.IsStale(), unknown)
+	}
+}
+
+func TestOpenCNAMEDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := OpenCNAMEDatabase(filepath.Join(dir, "cdn_cnames.json"))
+	if err != nil || len(db.Patterns) == 0 {
+		t.Errorf("OpenCNAMEDatabase(missing) = %v, %v", db, err)
+	}
+
+	path := filepath.Join(dir, "cdn_cnames.json")
+	os.WriteFile(path, []byte(`{"patterns":[{"id":"edge","name":"Edge","suffixes":["edge.example"]}]}`), 0644)
+	db, err = OpenCNAMEDatabase(path)
+	if err != nil || len(db.Patterns) != 1 {
+		t.Errorf("OpenCNAMEDatabase() = %v, %v", db, err)
+	}
+}
+
+func TestUpdater_EmbeddedDefaults(t *testing.T) {
+	embedded, _ := EmbeddedUpdateConfig()
+	u, err := NewUpdater("", filepath.Join(t.TempDir(), "waf_ranges.json"))
+	if err != nil {
+		t.Fatalf("NewUpdater(\"\") error: %v", err)
+	}
+	if len(u.config.Sources) != len(embedded.Sources) {
+		t.Errorf("NewUpdater(\"\") has %d sources, want the %d embedded", len(u.config.Sources), len(embedded.Sources))
+	}
+
+	// The first update starts from the embedded database, so providers
+	// without a source keep their ranges
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.0/24\n"))
+	}))
+	defer srv.Close()
+	u.config = &UpdateConfig{Sources: []UpdateSource{{Provider: "edge", URL: srv.URL, Format: "text"}}}
+	u.httpClient = srv.Client()
+	if _, err := u.Update(); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	db, err := LoadWAFDatabase(u.dbPath)
+	if err != nil {
+		t.Fatalf("LoadWAFDatabase() error: %v", err)
+	}
+	if db.GetProvider("edge") == nil || db.GetProvider("sucuri") == nil {
+		t.Errorf("updated providers = %v", db.ListProviders())
+	}
+}
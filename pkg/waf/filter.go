@@ -58,6 +58,29 @@ func (f *Filter) ShouldSkip(ip net.IP) (bool, string) {
 	return false, ""
 }
 
+// SubtractRanges removes WAF-covered addresses from IPv4 ranges before
+// they are iterated, counting them as checked and skipped
+// Returns the ranges left to scan
+func (f *Filter) SubtractRanges(ranges [][2]uint32) [][2]uint32 {
+	if !f.enabled || f.rangeSet == nil {
+		return ranges
+	}
+
+	remaining := make([][2]uint32, 0, len(ranges))
+	for _, r := range ranges {
+		rest, covered := f.rangeSet.Subtract(r[0], r[1])
+		remaining = append(remaining, rest...)
+		for providerID, n := range covered {
+			atomic.AddUint64(&f.totalChecked, n)
+			atomic.AddUint64(&f.totalSkipped, n)
+			if counter, ok := f.skippedByProvider[providerID]; ok {
+				atomic.AddUint64(counter, n)
+			}
+		}
+	}
+	return remaining
+}
+
 // ShouldSkipString checks if an IP string should be skipped
 func (f *Filter) ShouldSkipString(ipStr string) (bool, string) {
 	ip := net.ParseIP(ipStr)
@@ -0,0 +1,316 @@
+package waf
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RangeParser extracts IP ranges from a downloaded provider list
+// source carries the per-source options (e.g. Filter) the parser may use
+type RangeParser func(data []byte, source *UpdateSource) ([]string, error)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[string]RangeParser{
+		"text":               parseTextList,
+		"json":               parseSniffedJSON,
+		"aws":                parseAWSList,
+		"fastly":             parseFastlyList,
+		"google":             parseGoogleList,
+		"azure-service-tags": parseAzureServiceTags,
+		"imperva":            parseImpervaList,
+		"gcore":              parseGcoreList,
+		"ip-list":            parseIPList,
+		"ripestat":           parseRIPEStatPrefixes,
+	}
+)
+
+// RegisterParser makes a range parser available to update sources under name
+// Registering an existing name replaces the parser
+func RegisterParser(name string, p RangeParser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[name] = p
+}
+
+// GetParser returns the range parser registered under name
+func GetParser(name string) (RangeParser, bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	p, ok := parsers[name]
+	return p, ok
+}
+
+// ParserNames returns the registered parser names, sorted
+func ParserNames() []string {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	names := make([]string, 0, len(parsers))
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseTextList parses a plain text list, one CIDR per line
+// Blank lines and # comments are skipped
+func parseTextList(data []byte, source *UpdateSource) ([]string, error) {
+	ranges := make([]string, 0)
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ranges = append(ranges, line)
+		}
+	}
+
+	return ranges, scanner.Err()
+}
+
+// parseSniffedJSON handles the legacy "json" format by recognizing the
+// AWS and Fastly layouts
+func parseSniffedJSON(data []byte, source *UpdateSource) ([]string, error) {
+	if strings.Contains(string(data), `"service"`) {
+		return parseAWSList(data, source)
+	}
+	if strings.Contains(string(data), `"addresses"`) {
+		return parseFastlyList(data, source)
+	}
+	return nil, fmt.Errorf("unsupported JSON format")
+}
+
+// parseAWSList parses AWS ip-ranges.json, keeping the prefixes of the
+// service named by Filter (default CLOUDFRONT)
+func parseAWSList(data []byte, source *UpdateSource) ([]string, error) {
+	var awsData struct {
+		Prefixes []struct {
+			IPPrefix string `json:"ip_prefix"`
+			Service  string `json:"service"`
+		} `json:"prefixes"`
+		IPv6Prefixes []struct {
+			IPv6Prefix string `json:"ipv6_prefix"`
+			Service    string `json:"service"`
+		} `json:"ipv6_prefixes"`
+	}
+
+	if err := json.Unmarshal(data, &awsData); err != nil {
+		return nil, err
+	}
+
+	service := "CLOUDFRONT"
+	if source != nil && source.Filter != "" {
+		service = source.Filter
+	}
+
+	ranges := make([]string, 0)
+	for _, prefix := range awsData.Prefixes {
+		if prefix.Service == service {
+			ranges = append(ranges, prefix.IPPrefix)
+		}
+	}
+	for _, prefix := range awsData.IPv6Prefixes {
+		if prefix.Service == service {
+			ranges = append(ranges, prefix.IPv6Prefix)
+		}
+	}
+
+	return ranges, nil
+}
+
+// parseFastlyList parses Fastly's public-ip-list
+func parseFastlyList(data []byte, source *UpdateSource) ([]string, error) {
+	var fastlyData struct {
+		Addresses     []string `json:"addresses"`
+		IPv6Addresses []string `json:"ipv6_addresses"`
+	}
+
+	if err := json.Unmarshal(data, &fastlyData); err != nil {
+		return nil, err
+	}
+
+	return append(fastlyData.Addresses, fastlyData.IPv6Addresses...), nil
+}
+
+// parseGoogleList parses Google's goog.json/cloud.json
+// With a Filter only prefixes of that scope are kept (e.g. "global" for the
+// anycast load balancer addresses Cloud CDN is served from)
+func parseGoogleList(data []byte, source *UpdateSource) ([]string, error) {
+	var googleData struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+			Scope      string `json:"scope"`
+		} `json:"prefixes"`
+	}
+
+	if err := json.Unmarshal(data, &googleData); err != nil {
+		return nil, err
+	}
+
+	ranges := make([]string, 0)
+	for _, prefix := range googleData.Prefixes {
+		if source != nil && source.Filter != "" && prefix.Scope != source.Filter {
+			continue
+		}
+		if prefix.IPv4Prefix != "" {
+			ranges = append(ranges, prefix.IPv4Prefix)
+		}
+		if prefix.IPv6Prefix != "" {
+			ranges = append(ranges, prefix.IPv6Prefix)
+		}
+	}
+
+	return ranges, nil
+}
+
+// parseAzureServiceTags parses Azure's ServiceTags_Public JSON, keeping the
+// prefixes of the service tag named by Filter (default AzureFrontDoor.Frontend)
+func parseAzureServiceTags(data []byte, source *UpdateSource) ([]string, error) {
+	var azureData struct {
+		Values []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+
+	if err := json.Unmarshal(data, &azureData); err != nil {
+		return nil, err
+	}
+
+	tag := "AzureFrontDoor.Frontend"
+	if source != nil && source.Filter != "" {
+		tag = source.Filter
+	}
+
+	for _, value := range azureData.Values {
+		if value.Name == tag {
+			return value.Properties.AddressPrefixes, nil
+		}
+	}
+
+	return nil, fmt.Errorf("service tag %s not found", tag)
+}
+
+// parseImpervaList parses the Imperva (Incapsula) IP ranges API response
+func parseImpervaList(data []byte, source *UpdateSource) ([]string, error) {
+	var impervaData struct {
+		IPRanges   []string `json:"ipRanges"`
+		IPv6Ranges []string `json:"ipv6Ranges"`
+		Res        int      `json:"res"`
+		ResMessage string   `json:"res_message"`
+	}
+
+	if err := json.Unmarshal(data, &impervaData); err != nil {
+		return nil, err
+	}
+	if impervaData.Res != 0 {
+		return nil, fmt.Errorf("imperva API error %d: %s", impervaData.Res, impervaData.ResMessage)
+	}
+
+	return append(impervaData.IPRanges, impervaData.IPv6Ranges...), nil
+}
+
+// parseGcoreList parses Gcore's CDN public-ip-list
+func parseGcoreList(data []byte, source *UpdateSource) ([]string, error) {
+	var gcoreData struct {
+		Addresses   []string `json:"addresses"`
+		AddressesV6 []string `json:"addresses_v6"`
+	}
+
+	if err := json.Unmarshal(data, &gcoreData); err != nil {
+		return nil, err
+	}
+
+	return append(gcoreData.Addresses, gcoreData.AddressesV6...), nil
+}
+
+// ipListPattern matches the IPv4/IPv6 addresses and CIDRs in a list
+// that is neither JSON nor one-per-line (e.g. an XML string array)
+var ipListPattern = regexp.MustCompile(`[0-9A-Fa-f:.]+(?:/[0-9]{1,3})?`)
+
+// parseIPList parses a list of addresses or CIDRs: a JSON string array
+// (Bunny's edge server list) or any text containing them. Bare addresses
+// are kept as host ranges.
+func parseIPList(data []byte, source *UpdateSource) ([]string, error) {
+	var entries []string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		entries = ipListPattern.FindAllString(string(data), -1)
+	}
+
+	ranges := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if ip := net.ParseIP(entry); ip != nil {
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		} else if _, _, err := net.ParseCIDR(entry); err != nil {
+			continue // A hex word or version string caught by the pattern
+		}
+		ranges = append(ranges, entry)
+	}
+
+	return ranges, nil
+}
+
+// parseRIPEStatPrefixes parses the RIPEstat announced-prefixes API, used for
+// providers that publish their ASNs rather than a range list
+func parseRIPEStatPrefixes(data []byte, source *UpdateSource) ([]string, error) {
+	var ripeData struct {
+		Status string `json:"status"`
+		Data   struct {
+			Prefixes []struct {
+				Prefix string `json:"prefix"`
+			} `json:"prefixes"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(data, &ripeData); err != nil {
+		return nil, err
+	}
+	if ripeData.Status != "" && ripeData.Status != "ok" {
+		return nil, fmt.Errorf("RIPEstat status %q", ripeData.Status)
+	}
+
+	ranges := make([]string, 0, len(ripeData.Data.Prefixes))
+	for _, prefix := range ripeData.Data.Prefixes {
+		ranges = append(ranges, prefix.Prefix)
+	}
+
+	return ranges, nil
+}
+
+// normalizeRanges validates parsed ranges: entries that are not CIDRs are
+// dropped and duplicates removed. It returns the number dropped.
+func normalizeRanges(ranges []string) ([]string, int) {
+	seen := make(map[string]bool, len(ranges))
+	valid := make([]string, 0, len(ranges))
+	dropped := 0
+
+	for _, r := range ranges {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(r))
+		if err != nil {
+			dropped++
+			continue
+		}
+		cidr := network.String()
+		if !seen[cidr] {
+			seen[cidr] = true
+			valid = append(valid, cidr)
+		}
+	}
+
+	return valid, dropped
+}
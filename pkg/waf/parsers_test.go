@@ -0,0 +1,298 @@
+package waf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
+
+// Each parser against a recorded sample of its provider's list
+func TestParsers_Fixtures(t *testing.T) {
+	tests := []struct {
+		parser  string
+		fixture string
+		filter  string
+		count   int
+		first   string
+		last    string
+	}{
+		{"text", "cloudflare-ips-v4.txt", "", 15, "173.245.48.0/20", "131.0.72.0/22"},
+		{"text", "cloudflare-ips-v6.txt", "", 7, "2400:cb00::/32", "2c0f:f248::/32"},
+		{"aws", "aws-ip-ranges.json", "", 5, "13.32.0.0/15", "2600:9000::/28"},
+		{"aws", "aws-ip-ranges.json", "EC2", 2, "18.208.0.0/13", "2600:1f18::/33"},
+		{"json", "aws-ip-ranges.json", "", 5, "13.32.0.0/15", "2600:9000::/28"},
+		{"fastly", "fastly-public-ip-list.json", "", 18, "23.235.32.0/20", "2a04:4e42::/32"},
+		{"json", "fastly-public-ip-list.json", "", 18, "23.235.32.0/20", "2a04:4e42::/32"},
+		{"google", "google-cloud.json", "global", 4, "34.36.0.0/16", "2600:1901::/48"},
+		{"google", "google-cloud.json", "", 7, "34.1.208.0/20", "2600:1900:4000::/44"},
+		{"azure-service-tags", "azure-service-tags.json", "", 10, "13.107.208.0/24", "2620:1ec:bdf::/48"},
+		{"azure-service-tags", "azure-service-tags.json", "AzureFrontDoor.Backend", 3, "13.73.248.16/29", "2603:1000:4::5e0/123"},
+		{"imperva", "imperva-ips.json", "", 12, "199.83.128.0/21", "2a02:e980::/29"},
+		{"text", "stackpath-ipblocks.txt", "", 7, "69.16.128.0/18", "2001:4de0::/32"},
+		{"ip-list", "bunny-edgeserverlist.json", "", 10, "89.187.188.227/32", "143.244.56.49/32"},
+		{"ip-list", "bunny-edgeserverlist-ipv6.xml", "", 3, "2400:52e0:1a00::1029:1/128", "2a02:6ea0:c020::2/128"},
+		{"gcore", "gcore-public-ip-list.json", "", 10, "5.188.7.0/24", "2a03:90c0:9992::/48"},
+		{"ripestat", "ripestat-announced-prefixes.json", "", 4, "185.178.208.0/22", "2a01:7e0::/32"},
+		{"text", "akamai-ipv4-cidrs.txt", "", 20, "2.16.0.0/13", "184.84.0.0/14"},
+		{"text", "akamai-ipv6-cidrs.txt", "", 3, "2600:1400::/24", "2405:9600::/32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.parser+"/"+tt.fixture, func(t *testing.T) {
+			parse, ok := GetParser(tt.parser)
+			if !ok {
+				t.Fatalf("parser %q is not registered", tt.parser)
+			}
+			ranges, err := parse(readFixture(t, tt.fixture), &UpdateSource{Filter: tt.filter})
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if len(ranges) != tt.count {
+				t.Fatalf("count = %d, want %d: %v", len(ranges), tt.count, ranges)
+			}
+			if ranges[0] != tt.first || ranges[len(ranges)-1] != tt.last {
+				t.Errorf("ranges = %s ... %s, want %s ... %s", ranges[0], ranges[len(ranges)-1], tt.first, tt.last)
+			}
+			if valid, dropped := normalizeRanges(ranges); dropped != 0 || len(valid) != tt.count {
+				t.Errorf("normalizeRanges() dropped %d entries", dropped)
+			}
+		})
+	}
+}
+
+func TestParsers_Errors(t *testing.T) {
+	tests := []struct {
+		parser string
+		data   string
+	}{
+		{"aws", "<html>"},
+		{"json", `{"unknown": []}`},
+		{"azure-service-tags", `{"values": [{"name": "AzureCloud", "properties": {"addressPrefixes": ["20.0.0.0/8"]}}]}`},
+		{"imperva", `{"res": 2, "res_message": "Invalid input"}`},
+		{"ripestat", `{"status": "error", "data": {}}`},
+	}
+	for _, tt := range tests {
+		parse, _ := GetParser(tt.parser)
+		if _, err := parse([]byte(tt.data), &UpdateSource{}); err == nil {
+			t.Errorf("%s: expected an error for %s", tt.parser, tt.data)
+		}
+	}
+}
+
+func TestRegisterParser(t *testing.T) {
+	RegisterParser("test-semicolons", func(data []byte, source *UpdateSource) ([]string, error) {
+		return strings.Split(strings.TrimSpace(string(data)), ";"), nil
+	})
+	defer func() {
+		parsersMu.Lock()
+		delete(parsers, "test-semicolons")
+		parsersMu.Unlock()
+	}()
+
+	found := false
+	for _, name := range ParserNames() {
+		found = found || name == "test-semicolons"
+	}
+	if !found {
+		t.Fatalf("ParserNames() = %v, missing the registered parser", ParserNames())
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("192.0.2.0/24;198.51.100.7/24;not-a-range;192.0.2.0/24"))
+	}))
+	defer srv.Close()
+
+	u := &Updater{httpClient: srv.Client()}
+	ranges, err := u.fetchRanges(&UpdateSource{Provider: "test", URL: srv.URL, Parser: "test-semicolons"})
+	if err != nil {
+		t.Fatalf("fetchRanges() error: %v", err)
+	}
+	// Invalid entries are dropped, duplicates removed and host bits cleared
+	if strings.Join(ranges, ",") != "192.0.2.0/24,198.51.100.0/24" {
+		t.Errorf("fetchRanges() = %v", ranges)
+	}
+}
+
+func TestUpdater_FetchRanges(t *testing.T) {
+	var impervaForm string
+	mux := http.NewServeMux()
+	serve := func(path, fixture string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(readFixture(t, fixture))
+		})
+	}
+	serve("/ips-v4", "cloudflare-ips-v4.txt")
+	serve("/ips-v6", "cloudflare-ips-v6.txt")
+	serve("/download/7/1/d/71d86715-5596-4529-9b13-da13a5de5b63/ServiceTags_Public_20261013.json", "azure-service-tags.json")
+	mux.HandleFunc("/imperva", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		r.ParseForm()
+		impervaForm = r.PostForm.Encode()
+		w.Write(readFixture(t, "imperva-ips.json"))
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Service unavailable</body></html>"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// The Azure download page links to download.microsoft.com; point the
+	// fixture's link at the test server
+	page := strings.ReplaceAll(string(readFixture(t, "azure-download-page.html")), "https://download.microsoft.com", srv.URL)
+	mux.HandleFunc("/details", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page))
+	})
+
+	u := &Updater{httpClient: srv.Client()}
+
+	ranges, err := u.fetchRanges(&UpdateSource{Provider: "cloudflare", IPv4URL: srv.URL + "/ips-v4", IPv6URL: srv.URL + "/ips-v6", Format: "text"})
+	if err != nil || len(ranges) != 22 {
+		t.Errorf("cloudflare: fetchRanges() = %d ranges, %v; want 22", len(ranges), err)
+	}
+
+	ranges, err = u.fetchRanges(&UpdateSource{Provider: "incapsula", URL: srv.URL + "/imperva", Format: "json", Parser: "imperva", PostData: "resp_format=json"})
+	if err != nil || len(ranges) != 12 {
+		t.Errorf("imperva: fetchRanges() = %d ranges, %v; want 12", len(ranges), err)
+	}
+	if impervaForm != "resp_format=json" {
+		t.Errorf("imperva: form = %q", impervaForm)
+	}
+
+	azure := &UpdateSource{
+		Provider:    "azure-cdn",
+		URL:         srv.URL + "/details",
+		Parser:      "azure-service-tags",
+		LinkPattern: `http://[^"]+/ServiceTags_Public_[0-9]+\.json`,
+	}
+	ranges, err = u.fetchRanges(azure)
+	if err != nil || len(ranges) != 10 {
+		t.Errorf("azure: fetchRanges() = %d ranges, %v; want 10", len(ranges), err)
+	}
+
+	// A page without the link
+	azure.URL = srv.URL + "/html"
+	if _, err := u.fetchRanges(azure); err == nil || !strings.Contains(err.Error(), "no link") {
+		t.Errorf("azure: fetchRanges() error = %v, want a missing link error", err)
+	}
+
+	// An HTML error page parsed as a list yields nothing and must not
+	// replace the cached ranges
+	if _, err := u.fetchRanges(&UpdateSource{Provider: "stackpath", URL: srv.URL + "/html", Format: "text"}); err == nil {
+		t.Error("fetchRanges() expected an error when no valid ranges are parsed")
+	}
+
+	if _, err := u.fetchRanges(&UpdateSource{Provider: "x", URL: srv.URL + "/ips-v4", Format: "yaml"}); err == nil {
+		t.Error("fetchRanges() expected an error for an unknown parser")
+	}
+
+	if _, err := u.fetchRanges(&UpdateSource{Provider: "x", URL: srv.URL + "/missing", Format: "text"}); err == nil {
+		t.Error("fetchRanges() expected an error for HTTP 404")
+	}
+}
+
+func TestUpdater_FetchRangesDNS(t *testing.T) {
+	u := &Updater{}
+	u.SetResolver(&fakeDomainResolver{hosts: map[string][]string{
+		"cname.vercel-dns.com": {"76.76.21.21", "76.76.21.61"},
+		"apex.example.net":     {"2001:db8::1"},
+	}})
+
+	ranges, err := u.fetchRanges(&UpdateSource{Provider: "vercel", Hosts: []string{"cname.vercel-dns.com", "apex.example.net"}, Format: "dns"})
+	if err != nil {
+		t.Fatalf("fetchRanges() error: %v", err)
+	}
+	if strings.Join(ranges, ",") != "76.76.21.21/32,76.76.21.61/32,2001:db8::1/128" {
+		t.Errorf("fetchRanges() = %v", ranges)
+	}
+
+	if _, err := u.fetchRanges(&UpdateSource{Provider: "netlify", Hosts: []string{"missing.example.net"}, Format: "dns"}); err == nil {
+		t.Error("fetchRanges() expected an error for an unresolvable host")
+	}
+}
+
+func TestUpdater_Update(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(readFixture(t, "gcore-public-ip-list.json"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "waf_ranges.json")
+	u := &Updater{
+		config: &UpdateConfig{Sources: []UpdateSource{
+			{Provider: "gcore", Name: "Gcore", Category: CategoryCDN, URL: srv.URL, Format: "json", Parser: "gcore", Description: "Gcore CDN"},
+			{Provider: "broken", URL: srv.URL, Format: "text"}, // Every line is JSON, none valid
+		}},
+		dbPath:     dbPath,
+		httpClient: srv.Client(),
+	}
+	if _, err := u.Update(); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	db, err := LoadWAFDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("LoadWAFDatabase() error: %v", err)
+	}
+	p := db.GetProvider("gcore")
+	if p == nil || p.Name != "Gcore" || p.Category != CategoryCDN || len(p.Ranges) != 10 {
+		t.Errorf("gcore provider = %+v", p)
+	}
+	if db.GetProvider("broken") != nil {
+		t.Error("a source without valid ranges should not create a provider")
+	}
+}
+
+// The shipped source list only uses registered parsers
+func TestShippedSources(t *testing.T) {
+	config, err := LoadUpdateConfig("../../data/waf_sources.json")
+	if err != nil {
+		t.Fatalf("LoadUpdateConfig() error: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, source := range config.Sources {
+		if seen[source.Provider] {
+			t.Errorf("duplicate source %s", source.Provider)
+		}
+		seen[source.Provider] = true
+		if source.Category != "" && !IsValidCategory(source.Category) {
+			t.Errorf("%s: invalid category %q", source.Provider, source.Category)
+		}
+
+		if len(source.Hosts) > 0 {
+			continue
+		}
+		if source.URL == "" && source.IPv4URL == "" {
+			t.Errorf("%s: no URL", source.Provider)
+		}
+		name := source.Parser
+		if name == "" {
+			name = source.Format
+		}
+		if _, ok := GetParser(name); !ok {
+			t.Errorf("%s: parser %q is not registered", source.Provider, name)
+		}
+	}
+
+	for _, id := range []string{"akamai", "incapsula", "google-cdn", "azure-cdn", "stackpath", "bunnycdn", "gcore", "ddos-guard", "vercel", "netlify", "aws-ec2", "google-cloud", "azure-cloud"} {
+		if !seen[id] {
+			t.Errorf("no update source for %s", id)
+		}
+	}
+}
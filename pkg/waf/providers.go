@@ -9,14 +9,47 @@ import (
 	"time"
 )
 
+// Provider categories
+const (
+	CategoryCDN     = "cdn"     // CDN edge
+	CategoryWAF     = "waf"     // WAF / DDoS protection proxy
+	CategoryCloud   = "cloud"   // Cloud compute (EC2, GCE, Azure VMs)
+	CategoryHosting = "hosting" // VPS and dedicated hosting
+)
+
+// DefaultSkipCategories are the categories --skip-waf skips: addresses
+// there are proxies in front of the origin, while cloud and hosting
+// addresses may be the origin itself
+var DefaultSkipCategories = []string{CategoryCDN, CategoryWAF}
+
 // Provider represents a WAF/CDN provider with their IP ranges
 type Provider struct {
 	Name        string   `json:"name"`
 	ID          string   `json:"id"`
+	Category    string   `json:"category,omitempty"` // cdn, waf, cloud or hosting; empty is cdn
 	Description string   `json:"description"`
 	Ranges      []string `json:"ranges"` // CIDR notation
 }
 
+// GetCategory returns the provider's category
+// Databases written before categories existed only held CDN/WAF ranges,
+// so an empty category is CDN
+func (p *Provider) GetCategory() string {
+	if p.Category == "" {
+		return CategoryCDN
+	}
+	return p.Category
+}
+
+// IsValidCategory reports whether c is a known provider category
+func IsValidCategory(c string) bool {
+	switch c {
+	case CategoryCDN, CategoryWAF, CategoryCloud, CategoryHosting:
+		return true
+	}
+	return false
+}
+
 // WAFDatabase represents the complete WAF IP ranges database
 type WAFDatabase struct {
 	LastUpdated time.Time         `json:"last_updated"`
@@ -39,14 +72,29 @@ func LoadWAFDatabase(filepath string) (*WAFDatabase, error) {
 	return &db, nil
 }
 
-// SaveWAFDatabase saves the WAF ranges database to a JSON file
+// SaveWAFDatabase validates the database and saves it to a JSON file
+// The file is replaced atomically, and the version it replaces is kept at
+// BackupPath(filepath) for RollbackWAFDatabase. Concurrent writers should
+// hold LockWAFDatabase.
 func SaveWAFDatabase(filepath string, db *WAFDatabase) error {
+	if err := db.ValidateRanges(); err != nil {
+		return fmt.Errorf("refusing to save invalid WAF database: %w", err)
+	}
+
 	data, err := json.MarshalIndent(db, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal WAF database: %w", err)
 	}
 
-	if err := os.WriteFile(filepath, data, 0644); err != nil {
+	// Keep the current version, unless it is corrupt and the backup is
+	// the better one to roll back to
+	if previous, err := os.ReadFile(filepath); err == nil && json.Valid(previous) {
+		if err := writeFileAtomic(BackupPath(filepath), previous); err != nil {
+			return fmt.Errorf("failed to back up WAF database: %w", err)
+		}
+	}
+
+	if err := writeFileAtomic(filepath, data); err != nil {
 		return fmt.Errorf("failed to write WAF database: %w", err)
 	}
 
@@ -92,9 +140,12 @@ func (db *WAFDatabase) GetTotalRanges() int {
 	return total
 }
 
-// ValidateRanges validates all CIDR ranges in the database
+// ValidateRanges validates all CIDR ranges and categories in the database
 func (db *WAFDatabase) ValidateRanges() error {
 	for _, provider := range db.Providers {
+		if provider.Category != "" && !IsValidCategory(provider.Category) {
+			return fmt.Errorf("invalid category in provider %s: %s", provider.ID, provider.Category)
+		}
 		for _, cidr := range provider.Ranges {
 			if _, _, err := net.ParseCIDR(cidr); err != nil {
 				return fmt.Errorf("invalid CIDR in provider %s: %s - %w", provider.ID, cidr, err)
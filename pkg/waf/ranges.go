@@ -3,11 +3,16 @@ package waf
 
 import (
 	"bufio"
+	"container/heap"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // IPRange represents a parsed IP range for efficient lookup
@@ -18,9 +23,15 @@ type IPRange struct {
 }
 
 // RangeSet represents a collection of IP ranges for efficient lookup
+// Ranges are indexed as sorted, non-overlapping IPv4/IPv6 intervals, so a
+// lookup is a binary search. Where ranges overlap, the one added first wins.
+// The index is built by the first lookup after ranges are added; lookups
+// are safe for concurrent use, but not alongside AddProvider.
 type RangeSet struct {
 	ranges    []IPRange
 	providers map[string]bool // Set of active provider IDs
+	segments  []segment       // Sorted, disjoint intervals built from ranges
+	indexed   *sync.Once      // Builds segments; replaced when ranges change
 }
 
 // NewRangeSet creates a new empty range set
@@ -28,6 +39,7 @@ func NewRangeSet() *RangeSet {
 	return &RangeSet{
 		ranges:    make([]IPRange, 0),
 		providers: make(map[string]bool),
+		indexed:   new(sync.Once),
 	}
 }
 
@@ -51,6 +63,7 @@ func (rs *RangeSet) AddProvider(provider *Provider) error {
 	}
 
 	rs.providers[provider.ID] = true
+	rs.indexed = new(sync.Once)
 	return nil
 }
 
@@ -73,14 +86,65 @@ func (rs *RangeSet) Contains(ip net.IP) bool {
 // FindProvider returns the provider ID if the IP is in a WAF range
 // Returns (providerID, found)
 func (rs *RangeSet) FindProvider(ip net.IP) (string, bool) {
-	for _, r := range rs.ranges {
-		if r.Network.Contains(ip) {
-			return r.Provider, true
-		}
+	a, ok := toAddr(ip)
+	if !ok {
+		return "", false
+	}
+	if seg := rs.find(a); seg != nil {
+		return seg.provider, true
 	}
 	return "", false
 }
 
+// index returns the segments, building them if ranges were added since
+// the last lookup
+func (rs *RangeSet) index() []segment {
+	rs.indexed.Do(func() { rs.segments = buildSegments(rs.ranges) })
+	return rs.segments
+}
+
+// find returns the segment containing a, or nil
+func (rs *RangeSet) find(a addr) *segment {
+	segments := rs.index()
+	i := sort.Search(len(segments), func(i int) bool { return !segments[i].end.less(a) })
+	if i < len(segments) && !a.less(segments[i].start) {
+		return &segments[i]
+	}
+	return nil
+}
+
+// Subtract removes the WAF ranges from the IPv4 range [start, end]
+// Returns the uncovered parts in order and the number of addresses
+// removed per provider, so fully covered input is never iterated.
+func (rs *RangeSet) Subtract(start, end uint32) ([][2]uint32, map[string]uint64) {
+	covered := make(map[string]uint64)
+	var remaining [][2]uint32
+
+	segments := rs.index()
+	lo, hi := v4Addr(start), v4Addr(end)
+	i := sort.Search(len(segments), func(i int) bool { return !segments[i].end.less(lo) })
+	next := uint64(start) // First address not yet accounted for
+	for ; i < len(segments) && !hi.less(segments[i].start); i++ {
+		seg := segments[i]
+		from, to := uint64(start), uint64(end)
+		if lo.less(seg.start) {
+			from = seg.start.lo & 0xffffffff
+		}
+		if seg.end.less(hi) {
+			to = seg.end.lo & 0xffffffff
+		}
+		if from > next {
+			remaining = append(remaining, [2]uint32{uint32(next), uint32(from - 1)})
+		}
+		covered[seg.provider] += to - from + 1
+		next = to + 1
+	}
+	if next <= uint64(end) {
+		remaining = append(remaining, [2]uint32{uint32(next), end})
+	}
+	return remaining, covered
+}
+
 // Count returns the number of ranges in the set
 func (rs *RangeSet) Count() int {
 	return len(rs.ranges)
@@ -95,6 +159,135 @@ func (rs *RangeSet) Providers() []string {
 	return ids
 }
 
+// addr is an IP address as a 128-bit integer
+// IPv4 addresses are stored IPv4-mapped (::ffff:a.b.c.d), as net.IP.To16 does
+type addr struct{ hi, lo uint64 }
+
+// segment is an interval of addresses belonging to one provider
+type segment struct {
+	start, end addr // Inclusive
+	provider   string
+}
+
+// toAddr converts an IPv4 or IPv6 address
+func toAddr(ip net.IP) (addr, bool) {
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return addr{}, false
+	}
+	return addr{hi: binary.BigEndian.Uint64(ip16[:8]), lo: binary.BigEndian.Uint64(ip16[8:])}, true
+}
+
+// v4Addr returns the IPv4-mapped address of an IPv4 address in uint32 form
+func v4Addr(ip uint32) addr {
+	return addr{lo: 0xffff<<32 | uint64(ip)}
+}
+
+func (a addr) less(b addr) bool {
+	return a.hi < b.hi || (a.hi == b.hi && a.lo < b.lo)
+}
+
+// next returns a+1; false when a is the last address
+func (a addr) next() (addr, bool) {
+	if a.lo == ^uint64(0) {
+		return addr{hi: a.hi + 1}, a.hi != ^uint64(0)
+	}
+	return addr{hi: a.hi, lo: a.lo + 1}, true
+}
+
+// prev returns a-1 (a must not be the first address)
+func (a addr) prev() addr {
+	if a.lo == 0 {
+		return addr{hi: a.hi - 1, lo: ^uint64(0)}
+	}
+	return addr{hi: a.hi, lo: a.lo - 1}
+}
+
+// networkBounds returns the first and last address of a network
+func networkBounds(n *net.IPNet) (addr, addr) {
+	last := make(net.IP, len(n.IP))
+	for i := range n.IP {
+		last[i] = n.IP[i] | ^n.Mask[i]
+	}
+	start, _ := toAddr(n.IP)
+	end, _ := toAddr(last)
+	return start, end
+}
+
+// buildSegments flattens ranges into sorted, disjoint segments. Each
+// address belongs to the earliest range containing it; adjacent segments
+// of the same provider are merged.
+func buildSegments(ranges []IPRange) []segment {
+	type bound struct {
+		at   addr
+		idx  int
+		open bool
+	}
+	bounds := make([]bound, 0, 2*len(ranges))
+	for i, r := range ranges {
+		start, end := networkBounds(r.Network)
+		bounds = append(bounds, bound{at: start, idx: i, open: true})
+		if after, ok := end.next(); ok {
+			bounds = append(bounds, bound{at: after, idx: i})
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].at.less(bounds[j].at) })
+
+	// Sweep the boundaries keeping the open ranges in a min-heap by index
+	active := &indexHeap{}
+	closed := make([]bool, len(ranges))
+	segments := make([]segment, 0, len(ranges))
+	var cur *segment
+	for i := 0; i < len(bounds); {
+		at := bounds[i].at
+		for ; i < len(bounds) && bounds[i].at == at; i++ {
+			if bounds[i].open {
+				heap.Push(active, bounds[i].idx)
+			} else {
+				closed[bounds[i].idx] = true
+			}
+		}
+		for active.Len() > 0 && closed[(*active)[0]] {
+			heap.Pop(active)
+		}
+
+		provider, covered := "", active.Len() > 0
+		if covered {
+			provider = ranges[(*active)[0]].Provider
+		}
+		if cur != nil && covered && cur.provider == provider {
+			continue
+		}
+		if cur != nil {
+			cur.end = at.prev()
+			segments = append(segments, *cur)
+			cur = nil
+		}
+		if covered {
+			cur = &segment{start: at, provider: provider}
+		}
+	}
+	if cur != nil {
+		cur.end = addr{hi: ^uint64(0), lo: ^uint64(0)}
+		segments = append(segments, *cur)
+	}
+	return segments
+}
+
+// indexHeap is a min-heap of range indexes
+type indexHeap []int
+
+func (h indexHeap) Len() int            { return len(h) }
+func (h indexHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h indexHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *indexHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *indexHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
 // LoadFromDatabase loads ranges from a database for specific provider IDs
 func LoadFromDatabase(db *WAFDatabase, providerIDs []string) (*RangeSet, error) {
 	rs := NewRangeSet()
@@ -122,43 +315,127 @@ func LoadFromDatabase(db *WAFDatabase, providerIDs []string) (*RangeSet, error)
 	return rs, nil
 }
 
+// SkipProviders returns the providers --skip-waf skips: the named providers
+// when providerIDs is set, whatever their category, otherwise every
+// provider in categories (DefaultSkipCategories when empty)
+func SkipProviders(db *WAFDatabase, providerIDs, categories []string) ([]*Provider, error) {
+	var providers []*Provider
+	if len(providerIDs) > 0 {
+		for _, id := range providerIDs {
+			provider := db.GetProvider(id)
+			if provider == nil {
+				return nil, fmt.Errorf("provider not found: %s", id)
+			}
+			providers = append(providers, provider)
+		}
+		return providers, nil
+	}
+	if len(categories) == 0 {
+		categories = DefaultSkipCategories
+	}
+
+	skip := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		skip[strings.ToLower(strings.TrimSpace(c))] = true
+	}
+
+	for i := range db.Providers {
+		if skip[db.Providers[i].GetCategory()] {
+			providers = append(providers, &db.Providers[i])
+		}
+	}
+	return providers, nil
+}
+
+// LoadSkipRanges loads the ranges of SkipProviders
+func LoadSkipRanges(db *WAFDatabase, providerIDs, categories []string) (*RangeSet, error) {
+	providers, err := SkipProviders(db, providerIDs, categories)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := NewRangeSet()
+	if err := rs.AddProviders(providers); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
 // LoadCustomRanges loads custom CIDR ranges from a file
+// Supports the formats of LoadCustomProviders
+func LoadCustomRanges(filepath string) (*RangeSet, error) {
+	providers, err := LoadCustomProviders(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := NewRangeSet()
+	for i := range providers {
+		if err := rs.AddProvider(&providers[i]); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+// LoadCustomProviders loads the providers of a custom ranges file
 // Supports two formats:
 //  1. JSON format (same as waf_ranges.json):
 //     {"providers": [{"id": "custom", "name": "Custom", "ranges": ["1.2.3.0/24"]}]}
 //  2. Plain text format (one CIDR per line, comments with #)
-func LoadCustomRanges(filepath string) (*RangeSet, error) {
-	data, err := os.ReadFile(filepath)
+//
+// A text file becomes one provider named after the file, e.g.
+// "custom-office" for office.txt, as does a JSON provider without an ID.
+// Custom providers without a category are WAF ranges.
+func LoadCustomProviders(path string) ([]Provider, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read custom ranges file: %w", err)
 	}
 
-	rs := NewRangeSet()
+	base := filepath.Base(path)
+	id := "custom-" + strings.TrimSuffix(base, filepath.Ext(base))
+	name := fmt.Sprintf("Custom WAF Ranges (%s)", base)
 
 	// Try to parse as JSON first
-	if strings.TrimSpace(string(data))[0] == '{' {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
 		var db WAFDatabase
 		if err := json.Unmarshal(data, &db); err != nil {
 			return nil, fmt.Errorf("failed to parse JSON: %w", err)
 		}
+		if len(db.Providers) == 0 {
+			return nil, fmt.Errorf("no providers found in %s", base)
+		}
 
-		// Add all providers from custom file
 		for i := range db.Providers {
-			if err := rs.AddProvider(&db.Providers[i]); err != nil {
-				return nil, err
+			if db.Providers[i].ID == "" {
+				db.Providers[i].ID = id
+			}
+			if db.Providers[i].Name == "" {
+				db.Providers[i].Name = name
+			}
+			if db.Providers[i].Category == "" {
+				db.Providers[i].Category = CategoryWAF
+			} else if !IsValidCategory(db.Providers[i].Category) {
+				return nil, fmt.Errorf("invalid category %s for provider %s", db.Providers[i].Category, db.Providers[i].ID)
+			}
+			for _, cidr := range db.Providers[i].Ranges {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					return nil, fmt.Errorf("invalid CIDR %s for provider %s: %w", cidr, db.Providers[i].ID, err)
+				}
 			}
 		}
-
-		return rs, nil
+		return db.Providers, nil
 	}
 
 	// Parse as plain text (one CIDR per line)
 	scanner := bufio.NewScanner(strings.NewReader(string(data)))
 	lineNum := 0
-	customProvider := &Provider{
-		ID:     "custom",
-		Name:   "Custom WAF Ranges",
-		Ranges: make([]string, 0),
+	customProvider := Provider{
+		ID:       id,
+		Name:     name,
+		Category: CategoryWAF,
+		Ranges:   make([]string, 0),
 	}
 
 	for scanner.Scan() {
@@ -187,10 +464,29 @@ func LoadCustomRanges(filepath string) (*RangeSet, error) {
 		return nil, fmt.Errorf("no valid CIDR ranges found in file")
 	}
 
-	// Add custom provider to range set
-	if err := rs.AddProvider(customProvider); err != nil {
-		return nil, err
-	}
+	return []Provider{customProvider}, nil
+}
 
-	return rs, nil
+// LoadCustomProviderFiles loads the providers of several custom ranges
+// files in order. An ID already taken by an earlier provider gets an index
+// suffix, e.g. the second "custom-office" becomes "custom-office-2".
+func LoadCustomProviderFiles(paths []string) ([]Provider, error) {
+	var providers []Provider
+	used := make(map[string]bool)
+	for _, path := range paths {
+		loaded, err := LoadCustomProviders(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load custom WAF ranges from %s: %w", path, err)
+		}
+		for i := range loaded {
+			id := loaded[i].ID
+			for n := 2; used[id]; n++ {
+				id = fmt.Sprintf("%s-%d", loaded[i].ID, n)
+			}
+			loaded[i].ID = id
+			used[id] = true
+		}
+		providers = append(providers, loaded...)
+	}
+	return providers, nil
 }
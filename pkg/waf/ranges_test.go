@@ -0,0 +1,230 @@
+package waf
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRangeSet_IPv6(t *testing.T) {
+	rs := NewRangeSet()
+	rs.AddProvider(&Provider{ID: "cloudflare", Ranges: []string{"104.16.0.0/13", "2606:4700::/32"}})
+	rs.AddProvider(&Provider{ID: "fastly", Ranges: []string{"2a04:4e40::/32"}})
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"2606:4700::6810:1", "cloudflare"},
+		{"2606:4700:ffff:ffff:ffff:ffff:ffff:ffff", "cloudflare"},
+		{"2606:4701::1", ""},
+		{"2a04:4e40:1::1", "fastly"},
+		{"::ffff:104.16.0.1", "cloudflare"}, // IPv4-mapped
+		{"104.16.0.1", "cloudflare"},
+		{"::1", ""},
+	}
+	for _, tt := range tests {
+		id, _ := rs.FindProvider(net.ParseIP(tt.ip))
+		if id != tt.want {
+			t.Errorf("FindProvider(%s) = %q, want %q", tt.ip, id, tt.want)
+		}
+	}
+	if _, found := rs.FindProvider(nil); found {
+		t.Error("FindProvider(nil) found a provider")
+	}
+}
+
+func TestRangeSet_OverlapFirstWins(t *testing.T) {
+	rs := NewRangeSet()
+	rs.AddProvider(&Provider{ID: "small", Ranges: []string{"10.1.0.0/16"}})
+	rs.AddProvider(&Provider{ID: "big", Ranges: []string{"10.0.0.0/8", "10.1.2.0/24"}})
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.0.255.255", "big"},
+		{"10.1.0.0", "small"},
+		{"10.1.2.3", "small"}, // Inside both; small was added first
+		{"10.1.255.255", "small"},
+		{"10.2.0.0", "big"},
+		{"11.0.0.0", ""},
+	}
+	for _, tt := range tests {
+		id, _ := rs.FindProvider(net.ParseIP(tt.ip))
+		if id != tt.want {
+			t.Errorf("FindProvider(%s) = %q, want %q", tt.ip, id, tt.want)
+		}
+	}
+	if len(rs.segments) != 3 {
+		t.Errorf("segments = %d, want 3 (big, small, big)", len(rs.segments))
+	}
+}
+
+// The index is built once by the first lookup, and again after more ranges
+// are added
+func TestRangeSet_LazyIndex(t *testing.T) {
+	rs := NewRangeSet()
+	for i := 0; i < 100; i++ {
+		rs.AddProvider(&Provider{ID: fmt.Sprintf("p%d", i), Ranges: []string{fmt.Sprintf("10.%d.0.0/16", i)}})
+	}
+	if rs.segments != nil {
+		t.Fatal("AddProvider() built the index")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if id, _ := rs.FindProvider(net.ParseIP("10.42.1.1")); id != "p42" {
+				t.Errorf("FindProvider() = %q, want p42", id)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(rs.segments) != 100 {
+		t.Errorf("segments = %d, want 100", len(rs.segments))
+	}
+
+	rs.AddProvider(&Provider{ID: "late", Ranges: []string{"192.0.2.0/24"}})
+	if id, _ := rs.FindProvider(net.ParseIP("192.0.2.1")); id != "late" {
+		t.Errorf("FindProvider() after AddProvider = %q, want late", id)
+	}
+}
+
+func TestRangeSet_EdgesOfAddressSpace(t *testing.T) {
+	rs := NewRangeSet()
+	rs.AddProvider(&Provider{ID: "all", Ranges: []string{"::/0"}})
+	for _, ip := range []string{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "0.0.0.0", "255.255.255.255"} {
+		if !rs.Contains(net.ParseIP(ip)) {
+			t.Errorf("Contains(%s) = false for ::/0", ip)
+		}
+	}
+}
+
+func TestRangeSet_Subtract(t *testing.T) {
+	rs := NewRangeSet()
+	rs.AddProvider(&Provider{ID: "a", Ranges: []string{"10.0.0.16/28", "10.0.0.64/26"}})
+	rs.AddProvider(&Provider{ID: "b", Ranges: []string{"10.0.0.32/28"}})
+	rs.AddProvider(&Provider{ID: "v6", Ranges: []string{"2001:db8::/32"}})
+
+	ip4 := func(s string) uint32 {
+		b := net.ParseIP(s).To4()
+		return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	}
+	tests := []struct {
+		start, end string
+		remaining  [][2]string
+		covered    map[string]uint64
+	}{
+		{"10.0.0.0", "10.0.0.255", [][2]string{{"10.0.0.0", "10.0.0.15"}, {"10.0.0.48", "10.0.0.63"}, {"10.0.0.128", "10.0.0.255"}},
+			map[string]uint64{"a": 80, "b": 16}},
+		{"10.0.0.20", "10.0.0.40", nil, map[string]uint64{"a": 12, "b": 9}},
+		{"10.0.0.64", "10.0.0.127", nil, map[string]uint64{"a": 64}},
+		{"192.168.0.0", "192.168.0.255", [][2]string{{"192.168.0.0", "192.168.0.255"}}, map[string]uint64{}},
+		{"10.0.0.100", "10.0.0.200", [][2]string{{"10.0.0.128", "10.0.0.200"}}, map[string]uint64{"a": 28}},
+	}
+	for _, tt := range tests {
+		remaining, covered := rs.Subtract(ip4(tt.start), ip4(tt.end))
+		var want [][2]uint32
+		for _, r := range tt.remaining {
+			want = append(want, [2]uint32{ip4(r[0]), ip4(r[1])})
+		}
+		if !reflect.DeepEqual(remaining, want) {
+			t.Errorf("Subtract(%s-%s) remaining = %v, want %v", tt.start, tt.end, remaining, want)
+		}
+		if !reflect.DeepEqual(covered, tt.covered) {
+			t.Errorf("Subtract(%s-%s) covered = %v, want %v", tt.start, tt.end, covered, tt.covered)
+		}
+	}
+
+	// The whole IPv4 space
+	remaining, covered := rs.Subtract(0, ^uint32(0))
+	if len(remaining) != 3 || covered["a"]+covered["b"] != 96 {
+		t.Errorf("Subtract(0.0.0.0-255.255.255.255) = %v, %v", remaining, covered)
+	}
+}
+
+func TestFilter_SubtractRanges(t *testing.T) {
+	rs := NewRangeSet()
+	rs.AddProvider(&Provider{ID: "cloudflare", Ranges: []string{"104.16.0.0/13"}})
+	f := NewFilter(rs, true)
+
+	// 104.16.0.0/12: the lower half is Cloudflare
+	remaining := f.SubtractRanges([][2]uint32{{0x68100000, 0x681fffff}})
+	if !reflect.DeepEqual(remaining, [][2]uint32{{0x68180000, 0x681fffff}}) {
+		t.Errorf("SubtractRanges() = %x", remaining)
+	}
+	stats := f.GetStats()
+	if stats.TotalSkipped != 1<<19 || stats.TotalChecked != 1<<19 || stats.ByProvider["cloudflare"] != 1<<19 {
+		t.Errorf("GetStats() = %+v", stats)
+	}
+
+	// A disabled filter leaves the ranges alone
+	f.Disable()
+	in := [][2]uint32{{0x68100000, 0x681fffff}}
+	if got := f.SubtractRanges(in); !reflect.DeepEqual(got, in) {
+		t.Errorf("SubtractRanges() disabled = %x", got)
+	}
+}
+
+// benchmarkRangeSet builds a set of n /24 ranges spread over the IPv4 space,
+// the shape of AWS's published prefixes
+func benchmarkRangeSet(n int) *RangeSet {
+	rs := NewRangeSet()
+	p := &Provider{ID: "bench"}
+	for i := 0; i < n; i++ {
+		v := uint32(i) * 2654435761 // Spread the prefixes
+		p.Ranges = append(p.Ranges, fmt.Sprintf("%d.%d.%d.0/24", v>>24, v>>16&0xff, v>>8&0xff))
+	}
+	rs.AddProvider(p)
+	return rs
+}
+
+func BenchmarkRangeSet_FindProvider(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		rs := benchmarkRangeSet(n)
+		ips := make([]net.IP, 1024)
+		for i := range ips {
+			v := uint32(i) * 2246822519
+			ips[i] = net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+		}
+		b.Run(fmt.Sprintf("ranges=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				rs.FindProvider(ips[i%len(ips)])
+			}
+		})
+	}
+}
+
+func BenchmarkRangeSet_FindProviderIPv6(b *testing.B) {
+	rs := NewRangeSet()
+	p := &Provider{ID: "bench"}
+	for i := 0; i < 1000; i++ {
+		p.Ranges = append(p.Ranges, fmt.Sprintf("2600:%x::/32", i))
+	}
+	rs.AddProvider(p)
+	ip := net.ParseIP("2600:1f0::1")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rs.FindProvider(ip)
+	}
+}
+
+func BenchmarkRangeSet_Subtract(b *testing.B) {
+	rs := benchmarkRangeSet(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rs.Subtract(0x0a000000, 0x0affffff) // A /8 input range
+	}
+}
+
+func BenchmarkRangeSet_AddProvider(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkRangeSet(10000)
+	}
+}
@@ -0,0 +1,118 @@
+package waf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+// HeaderMatch matches a response header: present (Contains empty) or
+// whose value contains Contains, case-insensitively
+type HeaderMatch struct {
+	Name     string `json:"name"`
+	Contains string `json:"contains,omitempty"`
+}
+
+// pageClassOrder is the order body matches are tried in, so a challenge or
+// block page is not taken for the generic error page of the same provider
+var pageClassOrder = []string{core.PageClassChallenge, core.PageClassBlock, core.PageClassError, ""}
+
+// BodyMatch matches a response body that contains every marker,
+// case-insensitively. Class is the core.PageClass* of the CDN/WAF page it
+// identifies; empty for markers the CDN adds to the pages it proxies.
+type BodyMatch struct {
+	Class   string   `json:"class,omitempty"`
+	Markers []string `json:"markers"`
+}
+
+// EdgeSignature identifies responses served by a CDN edge rather than the
+// origin, from the headers and pages the CDN adds
+type EdgeSignature struct {
+	Name    string        `json:"name"`
+	ID      string        `json:"id"`
+	Headers []HeaderMatch `json:"headers"`
+	Body    []BodyMatch   `json:"body,omitempty"`
+}
+
+// SignatureDatabase is the CDN edge signature table (cdn_signatures.json)
+type SignatureDatabase struct {
+	LastUpdated time.Time       `json:"last_updated"`
+	Signatures  []EdgeSignature `json:"signatures"`
+}
+
+// LoadSignatureDatabase loads the edge signature table from a JSON file
+func LoadSignatureDatabase(filepath string) (*SignatureDatabase, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature database: %w", err)
+	}
+
+	var db SignatureDatabase
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("failed to parse signature database: %w", err)
+	}
+
+	return &db, nil
+}
+
+// Match returns the signature a response matches and the evidence, e.g.
+// "cf-ray: 8f1c2d3e4f5a6b7c-LAX" or `body "cloudflare ray id"`
+// Headers are checked before the body (see MatchBody), and signatures in
+// table order.
+func (db *SignatureDatabase) Match(header http.Header, body []byte) (*EdgeSignature, string, bool) {
+	if db == nil {
+		return nil, "", false
+	}
+
+	for i := range db.Signatures {
+		for _, m := range db.Signatures[i].Headers {
+			for _, value := range header.Values(m.Name) {
+				if m.Contains == "" || strings.Contains(strings.ToLower(value), strings.ToLower(m.Contains)) {
+					return &db.Signatures[i], fmt.Sprintf("%s: %s", strings.ToLower(m.Name), value), true
+				}
+			}
+		}
+	}
+
+	if sig, m, ok := db.MatchBody(body); ok {
+		return sig, fmt.Sprintf("body %q", m.Markers[0]), true
+	}
+	return nil, "", false
+}
+
+// MatchBody returns the signature and body match a response body matches
+// Block and challenge pages are tried before error pages, then markers
+// without a class, and signatures in table order within each class.
+func (db *SignatureDatabase) MatchBody(body []byte) (*EdgeSignature, *BodyMatch, bool) {
+	if db == nil || len(body) == 0 {
+		return nil, nil, false
+	}
+
+	lowerBody := strings.ToLower(string(body))
+	for _, class := range pageClassOrder {
+		for i := range db.Signatures {
+			for j := range db.Signatures[i].Body {
+				m := &db.Signatures[i].Body[j]
+				if m.Class == class && m.matches(lowerBody) {
+					return &db.Signatures[i], m, true
+				}
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// matches reports whether a lower-cased body contains every marker
+func (m *BodyMatch) matches(lowerBody string) bool {
+	for _, marker := range m.Markers {
+		if !strings.Contains(lowerBody, strings.ToLower(marker)) {
+			return false
+		}
+	}
+	return len(m.Markers) > 0
+}
@@ -0,0 +1,141 @@
+package waf
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jhaxce/origindive/pkg/core"
+)
+
+func TestSignatureDatabase_Match(t *testing.T) {
+	db, err := LoadSignatureDatabase("../../data/cdn_signatures.json")
+	if err != nil {
+		t.Fatalf("LoadSignatureDatabase() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		body     string
+		want     string
+		evidence string
+	}{
+		{"cf-ray", map[string]string{"CF-RAY": "8f1c-LAX"}, "", "cloudflare", "cf-ray: 8f1c-LAX"},
+		{"server cloudflare", map[string]string{"Server": "cloudflare"}, "", "cloudflare", "server: cloudflare"},
+		{"cloudfront", map[string]string{"X-Amz-Cf-Id": "abc=="}, "", "aws-cloudfront", "x-amz-cf-id: abc=="},
+		{"cloudfront via", map[string]string{"Via": "1.1 d111.cloudfront.net (CloudFront)"}, "", "aws-cloudfront", "via: 1.1 d111.cloudfront.net (CloudFront)"},
+		{"fastly", map[string]string{"X-Served-By": "cache-lax-123-LAX"}, "", "fastly", "x-served-by: cache-lax-123-LAX"},
+		{"sucuri", map[string]string{"X-Sucuri-ID": "11005"}, "", "sucuri", "x-sucuri-id: 11005"},
+		{"imperva", map[string]string{"X-Iinfo": "1-2-3"}, "", "incapsula", "x-iinfo: 1-2-3"},
+		{"akamai", map[string]string{"Server": "AkamaiGHost"}, "", "akamai", "server: AkamaiGHost"},
+		{"error page", nil, "<p>Cloudflare Ray ID: <strong>8f1c</strong></p>", "cloudflare", `body "cloudflare ray id"`},
+		{"headers before body", map[string]string{"X-Sucuri-ID": "1"}, "Cloudflare Ray ID", "sucuri", "x-sucuri-id: 1"},
+		{"origin nginx", map[string]string{"Server": "nginx", "X-Served-By": "web-01"}, "<title>Shop</title>", "", ""},
+		{"gcp load balancer", map[string]string{"Via": "1.1 google"}, "", "", ""}, // Also in front of real GCP origins
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for k, v := range tt.header {
+			header.Set(k, v)
+		}
+		sig, evidence, ok := db.Match(header, []byte(tt.body))
+		if tt.want == "" {
+			if ok {
+				t.Errorf("%s: Match() = %s (%s), want no match", tt.name, sig.ID, evidence)
+			}
+			continue
+		}
+		if !ok || sig.ID != tt.want || evidence != tt.evidence {
+			t.Errorf("%s: Match() = %v, %q, %v; want %s, %q", tt.name, sig, evidence, ok, tt.want, tt.evidence)
+		}
+	}
+
+	var nilDB *SignatureDatabase
+	if _, _, ok := nilDB.Match(http.Header{"Cf-Ray": {"1"}}, nil); ok {
+		t.Error("nil database matched")
+	}
+}
+
+// The shipped body matches classify CDN/WAF pages
+func TestSignatureDatabase_MatchBody(t *testing.T) {
+	db, err := EmbeddedSignatureDatabase()
+	if err != nil {
+		t.Fatalf("EmbeddedSignatureDatabase() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		provider string
+		class    string
+	}{
+		{"cloudflare 1003", `<title>Direct IP access not allowed | Cloudflare</title><div id="cf-error-details">Error 1003</div>`, "cloudflare", core.PageClassBlock},
+		{"cloudflare 1020", `<h1>Error 1020</h1><p>Access denied</p><span>Cloudflare Ray ID: 8f1c</span>`, "cloudflare", core.PageClassBlock},
+		{"cloudflare blocked", `<h1>Sorry, you have been blocked</h1> Cloudflare Ray ID`, "cloudflare", core.PageClassBlock},
+		{"cloudflare challenge", `<title>Just a moment...</title><script src="/cdn-cgi/challenge-platform/h/b/orchestrate/chl_page/v1"></script>`, "cloudflare", core.PageClassChallenge},
+		{"cloudflare 522", `<div id="cf-error-details"><h1>Connection timed out</h1> Error code 522</div>`, "cloudflare", core.PageClassError},
+		{"akamai denied", `<HTML><HEAD><TITLE>Access Denied</TITLE></HEAD><BODY>Reference&#32;&#35;18&#46;6b1c</BODY></HTML>`, "akamai", core.PageClassBlock},
+		{"akamai invalid url", `<TITLE>Invalid URL</TITLE>Reference&#32;&#35;9&#46;1a2b`, "akamai", core.PageClassError},
+		{"incapsula", `<html>Request unsuccessful. Incapsula incident ID: 123-456</html>`, "incapsula", core.PageClassBlock},
+		{"incapsula challenge", `<script src="/_Incapsula_Resource?SWJIYLWA=719d34d31c8e3a6e6fffd425f7e032f3&SWUDNSAI=9"></script>`, "incapsula", core.PageClassChallenge},
+		{"sucuri", `<title>Sucuri WebSite Firewall - Access Denied</title>`, "sucuri", core.PageClassBlock},
+		{"cloudfront", `<H1>403 ERROR</H1><H2>The request could not be satisfied.</H2>Generated by cloudfront (CloudFront)`, "aws-cloudfront", core.PageClassError},
+		{"fastly", `Fastly error: unknown domain: example.com.`, "fastly", core.PageClassError},
+		{"incapsula script", `<script src="/_Incapsula_Resource?SWJIYLWA=719d"></script><title>Shop</title>`, "incapsula", ""},
+		{"origin", `<html><title>Welcome to nginx!</title></html>`, "", ""},
+		{"empty", ``, "", ""},
+	}
+
+	for _, tt := range tests {
+		var provider, class string
+		sig, m, ok := db.MatchBody([]byte(tt.body))
+		if ok {
+			provider, class = sig.ID, m.Class
+		}
+		if provider != tt.provider || class != tt.class {
+			t.Errorf("%s: MatchBody() = %q, %q; want %q, %q", tt.name, provider, class, tt.provider, tt.class)
+		}
+	}
+}
+
+// Every shipped signature is usable and named after a known CDN
+func TestShippedSignatures(t *testing.T) {
+	db, err := EmbeddedSignatureDatabase()
+	if err != nil {
+		t.Fatalf("EmbeddedSignatureDatabase() error: %v", err)
+	}
+	cnames, _ := EmbeddedCNAMEDatabase()
+	known := make(map[string]bool)
+	for _, p := range cnames.Patterns {
+		known[p.ID] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, sig := range db.Signatures {
+		if seen[sig.ID] {
+			t.Errorf("duplicate signature %s", sig.ID)
+		}
+		seen[sig.ID] = true
+		if !known[sig.ID] {
+			t.Errorf("signature %s has no cdn_cnames.json entry", sig.ID)
+		}
+		if len(sig.Headers) == 0 && len(sig.Body) == 0 {
+			t.Errorf("signature %s matches nothing", sig.ID)
+		}
+		for _, m := range sig.Headers {
+			if m.Name == "" {
+				t.Errorf("signature %s has a header match without a name", sig.ID)
+			}
+		}
+		for _, m := range sig.Body {
+			if len(m.Markers) == 0 {
+				t.Errorf("signature %s has a body match without markers", sig.ID)
+			}
+			switch m.Class {
+			case "", core.PageClassBlock, core.PageClassChallenge, core.PageClassError:
+			default:
+				t.Errorf("signature %s has an unknown page class %q", sig.ID, m.Class)
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package waf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrDatabaseLocked is returned when another process holds the WAF database lock
+var ErrDatabaseLocked = errors.New("WAF database is locked by another process")
+
+// staleLockAge is how long a lock file must go without being refreshed
+// before it is considered left behind by a crashed process and removed
+const staleLockAge = 10 * time.Minute
+
+// lockRefreshInterval is how often a held lock's modification time is
+// renewed, so a slow update never looks stale
+var lockRefreshInterval = staleLockAge / 5
+
+// BackupPath returns where SaveWAFDatabase keeps the previous version of
+// the database at path
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// LockWAFDatabase takes the update lock for the database at path, waiting
+// up to timeout for another holder to release it. The lock is a
+// "<path>.lock" file holding the owner's PID and the time it was taken,
+// whose modification time is refreshed while it is held; a lock not
+// refreshed for 10 minutes was left by a crashed run and is broken. Call
+// the returned function to unlock.
+func LockWAFDatabase(path string, timeout time.Duration) (func(), error) {
+	lockPath := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			token := fmt.Sprintf("%d %d\n", os.Getpid(), time.Now().UnixNano())
+			_, writeErr := f.WriteString(token)
+			f.Close()
+			if writeErr != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("failed to write lock file: %w", writeErr)
+			}
+			return holdLock(lockPath, token), nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		// Break a lock left behind by a crashed run
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			breakStaleLock(lockPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w (%s)", ErrDatabaseLocked, lockPath)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// holdLock refreshes the lock file written with token until the returned
+// unlock function stops it and removes the file. Neither touches a lock
+// file that has since been replaced by another holder's.
+func holdLock(lockPath, token string) func() {
+	owned := func() bool {
+		data, err := os.ReadFile(lockPath)
+		return err == nil && string(data) == token
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if owned() {
+					now := time.Now()
+					os.Chtimes(lockPath, now, now)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			if owned() {
+				os.Remove(lockPath)
+			}
+		})
+	}
+}
+
+// breakStaleLock removes the lock file if it is stale. The file is moved
+// aside before it is checked, so when several waiters break the same lock
+// only one removes it, and a fresh lock another waiter took in the
+// meantime is put back instead of being deleted.
+func breakStaleLock(lockPath string) {
+	aside := fmt.Sprintf("%s.stale-%d-%d", lockPath, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(lockPath, aside); err != nil {
+		return // Already broken or released
+	}
+	if moved, err := os.Stat(aside); err == nil && time.Since(moved.ModTime()) <= staleLockAge {
+		os.Link(aside, lockPath) // Fails only if yet another lock was taken
+	}
+	os.Remove(aside)
+}
+
+// writeFileAtomic writes data to a temp file beside path and renames it
+// into place, so readers see either the old or the new file, never a
+// partial one
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// RollbackWAFDatabase restores the version of the database saved before
+// the last update. The two versions are swapped, so rolling back twice
+// returns to the newer one.
+func RollbackWAFDatabase(path string) error {
+	unlock, err := LockWAFDatabase(path, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	previous, err := os.ReadFile(BackupPath(path))
+	if err != nil {
+		return fmt.Errorf("no previous WAF database to roll back to: %w", err)
+	}
+
+	var db WAFDatabase
+	if err := json.Unmarshal(previous, &db); err != nil {
+		return fmt.Errorf("previous WAF database is corrupt: %w", err)
+	}
+	if err := db.ValidateRanges(); err != nil {
+		return fmt.Errorf("previous WAF database is invalid: %w", err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read WAF database: %w", err)
+	}
+
+	if err := writeFileAtomic(path, previous); err != nil {
+		return fmt.Errorf("failed to restore WAF database: %w", err)
+	}
+	if current != nil {
+		if err := writeFileAtomic(BackupPath(path), current); err != nil {
+			return fmt.Errorf("failed to keep replaced WAF database: %w", err)
+		}
+	}
+
+	return nil
+}
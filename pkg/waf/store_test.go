@@ -0,0 +1,294 @@
+package waf
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockWAFDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "waf_ranges.json")
+
+	unlock, err := LockWAFDatabase(path, time.Second)
+	if err != nil {
+		t.Fatalf("LockWAFDatabase() error: %v", err)
+	}
+
+	// A second holder waits, then gives up
+	if _, err := LockWAFDatabase(path, 200*time.Millisecond); !errors.Is(err, ErrDatabaseLocked) {
+		t.Errorf("LockWAFDatabase() while locked = %v, want ErrDatabaseLocked", err)
+	}
+
+	unlock()
+	unlock2, err := LockWAFDatabase(path, time.Second)
+	if err != nil {
+		t.Fatalf("LockWAFDatabase() after unlock error: %v", err)
+	}
+	unlock2()
+
+	// A lock left behind by a crashed run is broken
+	os.WriteFile(path+".lock", []byte("12345"), 0644)
+	old := time.Now().Add(-2 * staleLockAge)
+	os.Chtimes(path+".lock", old, old)
+	unlock3, err := LockWAFDatabase(path, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("LockWAFDatabase() with a stale lock error: %v", err)
+	}
+	unlock3()
+}
+
+// A held lock is refreshed, so it is not broken however long it is held,
+// and unlocking leaves another holder's lock alone
+func TestLockWAFDatabase_Refresh(t *testing.T) {
+	defer func(d time.Duration) { lockRefreshInterval = d }(lockRefreshInterval)
+	lockRefreshInterval = 20 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "waf_ranges.json")
+
+	unlock, err := LockWAFDatabase(path, time.Second)
+	if err != nil {
+		t.Fatalf("LockWAFDatabase() error: %v", err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	os.Chtimes(path+".lock", old, old) // As if the update ran for 20 minutes
+	time.Sleep(100 * time.Millisecond)
+
+	if info, err := os.Stat(path + ".lock"); err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Fatalf("held lock was not refreshed: %v", err)
+	}
+	if _, err := LockWAFDatabase(path, 200*time.Millisecond); !errors.Is(err, ErrDatabaseLocked) {
+		t.Errorf("LockWAFDatabase() while a slow update holds the lock = %v, want ErrDatabaseLocked", err)
+	}
+
+	// Once its lock was replaced, unlocking does not remove the new one
+	os.Remove(path + ".lock")
+	os.WriteFile(path+".lock", []byte("12345"), 0644)
+	unlock()
+	unlock()
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Errorf("unlock() removed another holder's lock: %v", err)
+	}
+}
+
+// Waiters breaking the same stale lock never hold the lock together
+func TestLockWAFDatabase_StaleLockRace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "waf_ranges.json")
+	os.WriteFile(path+".lock", []byte("12345"), 0644)
+	old := time.Now().Add(-2 * staleLockAge)
+	os.Chtimes(path+".lock", old, old)
+
+	var holders, maxHolders, acquired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := LockWAFDatabase(path, 10*time.Second)
+			if err != nil {
+				t.Errorf("LockWAFDatabase() error: %v", err)
+				return
+			}
+			acquired.Add(1)
+			n := holders.Add(1)
+			for m := maxHolders.Load(); n > m && !maxHolders.CompareAndSwap(m, n); m = maxHolders.Load() {
+			}
+			time.Sleep(20 * time.Millisecond)
+			holders.Add(-1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if acquired.Load() != 8 || maxHolders.Load() != 1 {
+		t.Errorf("acquired %d locks with up to %d holders at once, want 8 and 1", acquired.Load(), maxHolders.Load())
+	}
+	if matches, _ := filepath.Glob(path + ".lock*"); len(matches) != 0 {
+		t.Errorf("lock files left behind: %v", matches)
+	}
+}
+
+func TestSaveWAFDatabase_BackupAndValidation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "waf_ranges.json")
+
+	v1 := &WAFDatabase{Providers: []Provider{{ID: "cloudflare", Ranges: []string{"104.16.0.0/13"}}}}
+	v2 := &WAFDatabase{Providers: []Provider{{ID: "cloudflare", Ranges: []string{"104.16.0.0/13", "172.64.0.0/13"}}}}
+	if err := SaveWAFDatabase(path, v1); err != nil {
+		t.Fatalf("SaveWAFDatabase(v1) error: %v", err)
+	}
+	if _, err := os.Stat(BackupPath(path)); !os.IsNotExist(err) {
+		t.Error("the first save should not create a backup")
+	}
+	if err := SaveWAFDatabase(path, v2); err != nil {
+		t.Fatalf("SaveWAFDatabase(v2) error: %v", err)
+	}
+
+	backup, err := LoadWAFDatabase(BackupPath(path))
+	if err != nil || len(backup.Providers[0].Ranges) != 1 {
+		t.Errorf("backup = %+v, %v; want v1", backup, err)
+	}
+
+	// An invalid database is refused and the file left alone
+	bad := &WAFDatabase{Providers: []Provider{{ID: "x", Ranges: []string{"300.0.0.0/8"}}}}
+	if err := SaveWAFDatabase(path, bad); err == nil {
+		t.Error("SaveWAFDatabase() should refuse invalid CIDRs")
+	}
+	current, _ := LoadWAFDatabase(path)
+	if len(current.Providers[0].Ranges) != 2 {
+		t.Errorf("database changed by a refused save: %+v", current)
+	}
+
+	// No temp files are left behind
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp") {
+			t.Errorf("temp file left behind: %s", e.Name())
+		}
+	}
+}
+
+func TestRollbackWAFDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "waf_ranges.json")
+
+	if err := RollbackWAFDatabase(path); err == nil {
+		t.Error("RollbackWAFDatabase() without a backup should fail")
+	}
+
+	SaveWAFDatabase(path, &WAFDatabase{Providers: []Provider{{ID: "v1", Ranges: []string{"192.0.2.0/24"}}}})
+	SaveWAFDatabase(path, &WAFDatabase{Providers: []Provider{{ID: "v2", Ranges: []string{"192.0.2.0/24"}}}})
+
+	if err := RollbackWAFDatabase(path); err != nil {
+		t.Fatalf("RollbackWAFDatabase() error: %v", err)
+	}
+	if db, _ := LoadWAFDatabase(path); db.GetProvider("v1") == nil {
+		t.Errorf("after rollback = %+v, want v1", db)
+	}
+
+	// Rolling back again returns to the newer version
+	if err := RollbackWAFDatabase(path); err != nil {
+		t.Fatalf("RollbackWAFDatabase() error: %v", err)
+	}
+	if db, _ := LoadWAFDatabase(path); db.GetProvider("v2") == nil {
+		t.Errorf("after second rollback = %+v, want v2", db)
+	}
+
+	// A corrupt backup is not restored
+	os.WriteFile(BackupPath(path), []byte(`{"providers": [`), 0644)
+	if err := RollbackWAFDatabase(path); err == nil {
+		t.Error("RollbackWAFDatabase() should refuse a corrupt backup")
+	}
+	if db, _ := LoadWAFDatabase(path); db == nil || db.GetProvider("v2") == nil {
+		t.Error("a refused rollback changed the database")
+	}
+}
+
+func TestDiffRanges(t *testing.T) {
+	added, removed := DiffRanges(
+		[]string{"10.0.0.0/8", "192.0.2.0/24", "2001:db8::/32"},
+		[]string{"2001:db8::/32", "198.51.100.0/24", "10.0.0.0/8", "172.16.0.0/12"},
+	)
+	if !reflect.DeepEqual(added, []string{"172.16.0.0/12", "198.51.100.0/24"}) {
+		t.Errorf("added = %v", added)
+	}
+	if !reflect.DeepEqual(removed, []string{"192.0.2.0/24"}) {
+		t.Errorf("removed = %v", removed)
+	}
+
+	if added, removed := DiffRanges(nil, []string{"10.0.0.0/8"}); len(added) != 1 || len(removed) != 0 {
+		t.Errorf("DiffRanges(nil) = %v, %v", added, removed)
+	}
+}
+
+func TestUpdater_UpdateReportAndSanityChecks(t *testing.T) {
+	lists := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(lists[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	var big []string
+	for i := 0; i < 20; i++ {
+		big = append(big, fmt.Sprintf("10.%d.0.0/16", i))
+	}
+	dbPath := filepath.Join(t.TempDir(), "waf_ranges.json")
+	SaveWAFDatabase(dbPath, &WAFDatabase{Providers: []Provider{
+		{ID: "edge", Ranges: []string{"192.0.2.0/24", "198.51.100.0/24"}},
+		{ID: "big", Ranges: big},
+	}})
+
+	lists["/edge"] = "198.51.100.0/24\n203.0.113.0/24\n"
+	lists["/big"] = "10.0.0.0/16\n10.1.0.0/16\n" // Truncated download
+	lists["/tiny"] = "192.0.2.0/28\n"
+	u := &Updater{
+		config: &UpdateConfig{Sources: []UpdateSource{
+			{Provider: "edge", URL: srv.URL + "/edge", Format: "text"},
+			{Provider: "big", URL: srv.URL + "/big", Format: "text"},
+			{Provider: "tiny", URL: srv.URL + "/tiny", Format: "text", MinRanges: 2},
+		}},
+		dbPath:     dbPath,
+		httpClient: srv.Client(),
+	}
+
+	report, err := u.Update()
+	if err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	want := []ProviderChange{{Provider: "edge", Added: []string{"203.0.113.0/24"}, Removed: []string{"192.0.2.0/24"}}}
+	if !reflect.DeepEqual(report.Changes, want) {
+		t.Errorf("Changes = %+v, want %+v", report.Changes, want)
+	}
+	if !strings.Contains(report.Failed["big"], "dropped from 20 to 2") || !strings.Contains(report.Failed["tiny"], "at least 2") {
+		t.Errorf("Failed = %v", report.Failed)
+	}
+	text := report.String()
+	for _, substr := range []string{"edge: +1 -1", "+ 203.0.113.0/24", "- 192.0.2.0/24", "Kept cached big"} {
+		if !strings.Contains(text, substr) {
+			t.Errorf("report should contain %q, got:\n%s", substr, text)
+		}
+	}
+
+	// Rejected providers keep their cached ranges
+	db, _ := LoadWAFDatabase(dbPath)
+	if len(db.GetProvider("big").Ranges) != 20 || db.GetProvider("tiny") != nil {
+		t.Errorf("rejected updates were applied: %+v", db.Providers)
+	}
+
+	// Running again with the same lists changes nothing
+	report, err = u.Update()
+	if err != nil || len(report.Changes) != 0 || len(report.Unchanged) != 1 {
+		t.Errorf("second Update() = %+v, %v", report, err)
+	}
+
+	// When every source fails the database is not rewritten
+	before, _ := os.ReadFile(dbPath)
+	u.config.Sources = u.config.Sources[1:]
+	if _, err := u.Update(); err == nil {
+		t.Error("Update() should fail when no source could be updated")
+	}
+	if after, _ := os.ReadFile(dbPath); string(after) != string(before) {
+		t.Error("a failed update rewrote the database")
+	}
+
+	// A concurrent update is refused rather than interleaved
+	unlock, _ := LockWAFDatabase(dbPath, time.Second)
+	defer unlock()
+	u.config.Sources = []UpdateSource{{Provider: "edge", URL: srv.URL + "/edge", Format: "text"}}
+	done := make(chan error, 1)
+	go func() {
+		_, err := u.Update()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Errorf("Update() ran while the database was locked: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
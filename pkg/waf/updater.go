@@ -2,25 +2,37 @@
 package waf
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
 
 // UpdateSource represents a source for updating WAF ranges
 type UpdateSource struct {
-	Provider    string `json:"provider"`
-	URL         string `json:"url"`
-	IPv4URL     string `json:"ipv4_url,omitempty"`
-	IPv6URL     string `json:"ipv6_url,omitempty"`
-	Format      string `json:"format"` // "text" or "json"
-	JSONPath    string `json:"json_path,omitempty"`
-	Description string `json:"description"`
+	Provider    string   `json:"provider"`
+	Name        string   `json:"name,omitempty"`     // Display name for providers not yet in the database
+	Category    string   `json:"category,omitempty"` // Provider category (cdn, waf, cloud, hosting); empty is cdn
+	URL         string   `json:"url"`
+	IPv4URL     string   `json:"ipv4_url,omitempty"`
+	IPv6URL     string   `json:"ipv6_url,omitempty"`
+	Format      string   `json:"format"`                 // "text", "json", or "dns" for Hosts-only sources; the parser when Parser is empty
+	Parser      string   `json:"parser,omitempty"`       // Registered RangeParser name (see RegisterParser)
+	Filter      string   `json:"filter,omitempty"`       // Parser-specific selector: AWS service, Azure service tag, Google scope
+	PostData    string   `json:"post_data,omitempty"`    // Form body; the URLs are POSTed instead of fetched
+	LinkPattern string   `json:"link_pattern,omitempty"` // Regexp for the list's URL on the fetched page, for lists whose URL changes
+	Hosts       []string `json:"hosts,omitempty"`        // Hostnames whose addresses are the ranges, for providers without a list
+	MinRanges   int      `json:"min_ranges,omitempty"`   // Fewer ranges than this is treated as a broken download
+	JSONPath    string   `json:"json_path,omitempty"`
+	Description string   `json:"description"`
 }
 
 // UpdateConfig represents the WAF update configuration
@@ -44,16 +56,98 @@ func LoadUpdateConfig(filepath string) (*UpdateConfig, error) {
 	return &config, nil
 }
 
+// ProviderChange lists the prefixes an update added to and removed from
+// a provider
+type ProviderChange struct {
+	Provider string   `json:"provider"`
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+}
+
+// UpdateReport describes the outcome of an update
+type UpdateReport struct {
+	Changes   []ProviderChange  `json:"changes,omitempty"`   // Providers whose ranges changed
+	Unchanged []string          `json:"unchanged,omitempty"` // Providers updated with the same ranges
+	Failed    map[string]string `json:"failed,omitempty"`    // Provider -> why its cached ranges were kept
+}
+
+// DiffRanges returns the prefixes in updated but not in previous (added)
+// and the other way round (removed), sorted
+func DiffRanges(previous, updated []string) (added, removed []string) {
+	before := make(map[string]bool, len(previous))
+	for _, r := range previous {
+		before[r] = true
+	}
+	after := make(map[string]bool, len(updated))
+	for _, r := range updated {
+		after[r] = true
+		if !before[r] {
+			added = append(added, r)
+		}
+	}
+	for _, r := range previous {
+		if !after[r] {
+			removed = append(removed, r)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// String formats the report as a per-provider change list
+func (r *UpdateReport) String() string {
+	var sb strings.Builder
+
+	for _, c := range r.Changes {
+		sb.WriteString(fmt.Sprintf("  %s: +%d -%d\n", c.Provider, len(c.Added), len(c.Removed)))
+		for _, p := range c.Added {
+			sb.WriteString(fmt.Sprintf("    + %s\n", p))
+		}
+		for _, p := range c.Removed {
+			sb.WriteString(fmt.Sprintf("    - %s\n", p))
+		}
+	}
+	if len(r.Unchanged) > 0 {
+		sb.WriteString(fmt.Sprintf("  Unchanged: %s\n", strings.Join(r.Unchanged, ", ")))
+	}
+
+	failed := make([]string, 0, len(r.Failed))
+	for provider := range r.Failed {
+		failed = append(failed, provider)
+	}
+	sort.Strings(failed)
+	for _, provider := range failed {
+		sb.WriteString(fmt.Sprintf("  Kept cached %s: %s\n", provider, r.Failed[provider]))
+	}
+
+	return sb.String()
+}
+
 // Updater handles WAF range updates
 type Updater struct {
 	config     *UpdateConfig
 	dbPath     string
 	httpClient *http.Client
+	resolver   HostResolver
+}
+
+// HostResolver resolves the Hosts of DNS-based update sources;
+// *net.Resolver and *resolver.Resolver implement it
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
 }
 
 // NewUpdater creates a new WAF range updater
+// An empty configPath uses the sources embedded in the binary
 func NewUpdater(configPath, dbPath string) (*Updater, error) {
-	config, err := LoadUpdateConfig(configPath)
+	var config *UpdateConfig
+	var err error
+	if configPath == "" {
+		config, err = EmbeddedUpdateConfig()
+	} else {
+		config, err = LoadUpdateConfig(configPath)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -64,43 +158,97 @@ func NewUpdater(configPath, dbPath string) (*Updater, error) {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		resolver: net.DefaultResolver,
 	}, nil
 }
 
+// SetResolver sets the resolver used for DNS-based sources
+func (u *Updater) SetResolver(r HostResolver) {
+	if r != nil {
+		u.resolver = r
+	}
+}
+
+// Sanity limits for updated providers: a provider with at least
+// shrinkCheckMin ranges that loses more than half of them in one update is
+// more likely a truncated download than a real change
+const (
+	shrinkCheckMin = 10
+	maxShrink      = 0.5
+)
+
 // Update updates WAF ranges from configured sources
-func (u *Updater) Update() error {
-	// Load existing database
-	db, err := LoadWAFDatabase(u.dbPath)
+// The database is locked for the whole update, every source is checked
+// (CIDR validity, min_ranges, shrinkage) before it replaces the cached
+// ranges, and the previous database is kept for RollbackWAFDatabase.
+// The returned report lists the prefixes added and removed per provider.
+func (u *Updater) Update() (*UpdateReport, error) {
+	unlock, err := LockWAFDatabase(u.dbPath, 30*time.Second)
 	if err != nil {
-		// If database doesn't exist, create a new one
-		db = &WAFDatabase{
-			Sources:   make(map[string]string),
-			Providers: make([]Provider, 0),
+		return nil, err
+	}
+	defer unlock()
+
+	// Load existing database, starting from the embedded one when there is
+	// no usable copy so providers without a source keep their ranges
+	db, _, err := OpenWAFDatabase(u.dbPath)
+	if err != nil {
+		if db, err = EmbeddedWAFDatabase(); err != nil {
+			db = &WAFDatabase{
+				Sources:   make(map[string]string),
+				Providers: make([]Provider, 0),
+			}
 		}
 	}
+	if db.Sources == nil {
+		db.Sources = make(map[string]string)
+	}
+
+	report := &UpdateReport{Failed: make(map[string]string)}
 
 	// Update each provider
 	for _, source := range u.config.Sources {
 		fmt.Printf("Updating %s...\n", source.Provider)
 
 		ranges, err := u.fetchRanges(&source)
+		if err == nil {
+			err = checkRanges(&source, db.GetProvider(source.Provider), ranges)
+		}
 		if err != nil {
 			fmt.Printf("Warning: Failed to update %s: %v\n", source.Provider, err)
+			report.Failed[source.Provider] = err.Error()
 			continue
 		}
 
 		// Find or create provider in database
+		var previous []string
 		provider := db.GetProvider(source.Provider)
 		if provider == nil {
 			// Create new provider
+			name := source.Name
+			if name == "" {
+				name = strings.Title(strings.ReplaceAll(source.Provider, "-", " "))
+			}
 			db.Providers = append(db.Providers, Provider{
-				ID:     source.Provider,
-				Name:   strings.Title(strings.ReplaceAll(source.Provider, "-", " ")),
-				Ranges: ranges,
+				ID:          source.Provider,
+				Name:        name,
+				Category:    source.Category,
+				Description: source.Description,
+				Ranges:      ranges,
 			})
 		} else {
 			// Update existing provider
+			previous = provider.Ranges
 			provider.Ranges = ranges
+			if source.Category != "" {
+				provider.Category = source.Category
+			}
+		}
+
+		if added, removed := DiffRanges(previous, ranges); len(added) > 0 || len(removed) > 0 {
+			report.Changes = append(report.Changes, ProviderChange{Provider: source.Provider, Added: added, Removed: removed})
+		} else {
+			report.Unchanged = append(report.Unchanged, source.Provider)
 		}
 
 		// Update source URL
@@ -108,30 +256,62 @@ func (u *Updater) Update() error {
 		if url == "" && source.IPv4URL != "" {
 			url = source.IPv4URL
 		}
+		if url == "" && len(source.Hosts) > 0 {
+			url = "dns:" + strings.Join(source.Hosts, ",")
+		}
 		db.Sources[source.Provider] = url
 
 		fmt.Printf("  Updated %s with %d ranges\n", source.Provider, len(ranges))
 	}
 
+	if len(report.Changes) == 0 && len(report.Unchanged) == 0 && len(u.config.Sources) > 0 {
+		return report, fmt.Errorf("no source could be updated; the WAF database was not changed")
+	}
+
 	// Update last updated timestamp
 	db.LastUpdated = time.Now()
 
 	// Save updated database
 	if err := SaveWAFDatabase(u.dbPath, db); err != nil {
-		return fmt.Errorf("failed to save updated database: %w", err)
+		return report, fmt.Errorf("failed to save updated database: %w", err)
 	}
 
 	fmt.Printf("\nSuccessfully updated WAF database\n")
 	fmt.Printf("Total providers: %d\n", len(db.Providers))
 	fmt.Printf("Total ranges: %d\n", db.GetTotalRanges())
+	fmt.Printf("\nChanges:\n%s", report)
+
+	return report, nil
+}
 
+// checkRanges rejects an update that looks like a broken download: fewer
+// ranges than the source's min_ranges, or a large provider losing more
+// than half of its ranges at once
+func checkRanges(source *UpdateSource, current *Provider, ranges []string) error {
+	if source.MinRanges > 0 && len(ranges) < source.MinRanges {
+		return fmt.Errorf("only %d ranges, expected at least %d (keeping cached ranges)", len(ranges), source.MinRanges)
+	}
+	if current != nil && len(current.Ranges) >= shrinkCheckMin && float64(len(ranges)) < float64(len(current.Ranges))*(1-maxShrink) {
+		return fmt.Errorf("range count dropped from %d to %d (keeping cached ranges)", len(current.Ranges), len(ranges))
+	}
 	return nil
 }
 
 // fetchRanges fetches IP ranges from a source
+// The response is parsed by the source's parser and the ranges validated;
+// a source that yields no valid ranges is an error, so a changed or broken
+// list never empties a provider.
 func (u *Updater) fetchRanges(source *UpdateSource) ([]string, error) {
 	var ranges []string
 
+	if len(source.Hosts) > 0 {
+		hostRanges, err := u.resolveHosts(source.Hosts)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, hostRanges...)
+	}
+
 	// Handle multiple URLs (e.g., separate IPv4 and IPv6)
 	urls := make([]string, 0)
 	if source.IPv4URL != "" {
@@ -144,119 +324,132 @@ func (u *Updater) fetchRanges(source *UpdateSource) ([]string, error) {
 		urls = append(urls, source.URL)
 	}
 
-	for _, url := range urls {
-		fetchedRanges, err := u.fetchFromURL(url, source.Format, source.JSONPath)
-		if err != nil {
-			return nil, err
+	if len(urls) > 0 {
+		name := source.Parser
+		if name == "" {
+			name = source.Format
+		}
+		parse, ok := GetParser(name)
+		if !ok {
+			return nil, fmt.Errorf("unsupported format: %s", name)
 		}
-		ranges = append(ranges, fetchedRanges...)
-	}
-
-	return ranges, nil
-}
-
-// fetchFromURL fetches and parses ranges from a URL
-func (u *Updater) fetchFromURL(url, format, jsonPath string) ([]string, error) {
-	resp, err := u.httpClient.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from %s: %w (will use cached ranges if available)", url, err)
-	}
-	defer resp.Body.Close()
-
-	// Handle rate limiting
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, fmt.Errorf("API rate limit exceeded for %s (using cached ranges)", url)
-	}
 
-	// Handle service errors
-	if resp.StatusCode >= 500 {
-		return nil, fmt.Errorf("server error %d from %s (using cached ranges)", resp.StatusCode, url)
+		for _, url := range urls {
+			body, err := u.fetchFromURL(url, source)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := parse(body, source)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", url, err)
+			}
+			ranges = append(ranges, parsed...)
+		}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d from %s (using cached ranges)", resp.StatusCode, url)
+	ranges, dropped := normalizeRanges(ranges)
+	if dropped > 0 {
+		fmt.Printf("  Skipped %d invalid entries from %s\n", dropped, source.Provider)
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no valid ranges in response (keeping cached ranges)")
 	}
 
-	switch format {
-	case "text":
-		return u.parseTextRanges(body), nil
-	case "json":
-		return u.parseJSONRanges(body, jsonPath)
-	default:
-		return nil, fmt.Errorf("unsupported format: %s", format)
-	}
+	return ranges, nil
 }
 
-// parseTextRanges parses plain text CIDR list (one per line)
-func (u *Updater) parseTextRanges(data []byte) []string {
-	ranges := make([]string, 0)
-	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+// resolveHosts returns the addresses of hosts as host ranges
+func (u *Updater) resolveHosts(hosts []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			ranges = append(ranges, line)
+	ranges := make([]string, 0)
+	for _, host := range hosts {
+		addrs, err := u.resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		for _, addr := range addrs {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				continue
+			}
+			if ip.To4() != nil {
+				ranges = append(ranges, ip.String()+"/32")
+			} else {
+				ranges = append(ranges, ip.String()+"/128")
+			}
 		}
 	}
 
-	return ranges
+	return ranges, nil
 }
 
-// parseJSONRanges parses JSON response and extracts IP ranges
-// This is a simplified version - for production, use a proper JSON path library
-func (u *Updater) parseJSONRanges(data []byte, jsonPath string) ([]string, error) {
-	// Special handling for AWS IP ranges
-	if strings.Contains(string(data), `"service"`) {
-		return u.parseAWSRanges(data)
+// fetchFromURL fetches a range list from a URL
+// With a LinkPattern the URL is a page linking to the list, which is
+// followed first
+func (u *Updater) fetchFromURL(url string, source *UpdateSource) ([]byte, error) {
+	body, err := u.get(url, source.PostData)
+	if err != nil || source.LinkPattern == "" {
+		return body, err
 	}
 
-	// Special handling for Fastly
-	if strings.Contains(string(data), `"addresses"`) {
-		return u.parseFastlyRanges(data)
+	pattern, err := regexp.Compile(source.LinkPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link_pattern: %w", err)
+	}
+	link := pattern.Find(body)
+	if link == nil {
+		return nil, fmt.Errorf("no link matching %s on %s", source.LinkPattern, url)
 	}
 
-	return nil, fmt.Errorf("unsupported JSON format")
+	return u.get(html.UnescapeString(string(link)), "")
 }
 
-// parseAWSRanges parses AWS IP ranges JSON
-func (u *Updater) parseAWSRanges(data []byte) ([]string, error) {
-	var awsData struct {
-		Prefixes []struct {
-			IPPrefix string `json:"ip_prefix"`
-			Service  string `json:"service"`
-		} `json:"prefixes"`
+// get performs the request for fetchFromURL
+func (u *Updater) get(url, postData string) ([]byte, error) {
+	method := http.MethodGet
+	var reqBody io.Reader
+	if postData != "" {
+		method = http.MethodPost
+		reqBody = strings.NewReader(postData)
 	}
 
-	if err := json.Unmarshal(data, &awsData); err != nil {
-		return nil, err
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", url, err)
+	}
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+	if postData != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
 
-	ranges := make([]string, 0)
-	for _, prefix := range awsData.Prefixes {
-		if prefix.Service == "CLOUDFRONT" {
-			ranges = append(ranges, prefix.IPPrefix)
-		}
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from %s: %w (will use cached ranges if available)", url, err)
 	}
+	defer resp.Body.Close()
 
-	return ranges, nil
-}
+	// Handle rate limiting
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("API rate limit exceeded for %s (using cached ranges)", url)
+	}
 
-// parseFastlyRanges parses Fastly IP list JSON
-func (u *Updater) parseFastlyRanges(data []byte) ([]string, error) {
-	var fastlyData struct {
-		Addresses []string `json:"addresses"`
+	// Handle service errors
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("server error %d from %s (using cached ranges)", resp.StatusCode, url)
 	}
 
-	if err := json.Unmarshal(data, &fastlyData); err != nil {
-		return nil, err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d from %s (using cached ranges)", resp.StatusCode, url)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return fastlyData.Addresses, nil
+	return body, nil
 }
 
 // NeedsUpdate checks if the database needs updating based on last update time
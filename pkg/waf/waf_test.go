@@ -4,6 +4,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 	"testing"
 )
@@ -194,6 +195,78 @@ func TestLoadCustomRanges(t *testing.T) {
 	}
 }
 
+// Test LoadCustomProviders
+func TestLoadCustomProviders(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A text file becomes one provider named after the file
+	txtFile := filepath.Join(tmpDir, "office.txt")
+	os.WriteFile(txtFile, []byte("10.0.0.0/8\n2001:db8::/32\n"), 0644)
+	providers, err := LoadCustomProviders(txtFile)
+	if err != nil {
+		t.Fatalf("LoadCustomProviders(txt) error: %v", err)
+	}
+	if len(providers) != 1 || providers[0].ID != "custom-office" || providers[0].Category != CategoryWAF || len(providers[0].Ranges) != 2 {
+		t.Errorf("LoadCustomProviders(txt) = %+v", providers)
+	}
+
+	// JSON providers keep their IDs; missing ones are named after the file
+	jsonFile := filepath.Join(tmpDir, "cdns.json")
+	os.WriteFile(jsonFile, []byte(`{"providers":[{"id":"a","category":"hosting","ranges":["10.0.0.0/8"]},{"ranges":["11.0.0.0/8"]}]}`), 0644)
+	providers, err = LoadCustomProviders(jsonFile)
+	if err != nil {
+		t.Fatalf("LoadCustomProviders(JSON) error: %v", err)
+	}
+	if len(providers) != 2 || providers[0].ID != "a" || providers[1].ID != "custom-cdns" ||
+		providers[0].Category != CategoryHosting || providers[1].Category != CategoryWAF {
+		t.Errorf("LoadCustomProviders(JSON) = %+v", providers)
+	}
+
+	for name, content := range map[string]string{
+		"empty.txt":    "",
+		"comments.txt": "# nothing\n",
+		"bad.txt":      "10.0.0.0/33\n",
+		"bad.json":     `{"providers":[{"id":"x","ranges":["nope"]}]}`,
+		"none.json":    `{"providers":[]}`,
+		"cat.json":     `{"providers":[{"id":"x","category":"isp","ranges":["10.0.0.0/8"]}]}`,
+	} {
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := LoadCustomProviders(path); err == nil {
+			t.Errorf("LoadCustomProviders(%s) should fail", name)
+		}
+	}
+}
+
+// Test LoadCustomProviderFiles
+func TestLoadCustomProviderFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	var paths []string
+	for _, sub := range []string{"a", "b", "c"} {
+		os.Mkdir(filepath.Join(tmpDir, sub), 0755)
+		path := filepath.Join(tmpDir, sub, "office.txt")
+		os.WriteFile(path, []byte("10.0.0.0/8\n"), 0644)
+		paths = append(paths, path)
+	}
+
+	// Files with the same name get an index suffix
+	providers, err := LoadCustomProviderFiles(paths)
+	if err != nil {
+		t.Fatalf("LoadCustomProviderFiles() error: %v", err)
+	}
+	var ids []string
+	for _, p := range providers {
+		ids = append(ids, p.ID)
+	}
+	if want := []string{"custom-office", "custom-office-2", "custom-office-3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("LoadCustomProviderFiles() IDs = %v, want %v", ids, want)
+	}
+
+	if _, err := LoadCustomProviderFiles([]string{filepath.Join(tmpDir, "missing.txt")}); err == nil {
+		t.Error("LoadCustomProviderFiles() should fail for a missing file")
+	}
+}
+
 // Test Filter
 func TestNewFilter(t *testing.T) {
 	db := &WAFDatabase{
@@ -429,10 +502,8 @@ func TestNewUpdater_InvalidConfig(t *testing.T) {
 	}
 }
 
-// Test Updater parseTextRanges
+// Test the text range parser
 func TestUpdater_ParseTextRanges(t *testing.T) {
-	u := &Updater{}
-
 	data := []byte(`192.168.1.0/24
 10.0.0.0/8
 # This is a comment
@@ -440,9 +511,9 @@ func TestUpdater_ParseTextRanges(t *testing.T) {
 
 `)
 
-	ranges := u.parseTextRanges(data)
+	ranges, _ := parseTextList(data, nil)
 	if len(ranges) != 3 {
-		t.Errorf("parseTextRanges() count = %d, want 3", len(ranges))
+		t.Errorf("parseTextList() count = %d, want 3", len(ranges))
 	}
 
 	expected := map[string]bool{
@@ -458,10 +529,8 @@ func TestUpdater_ParseTextRanges(t *testing.T) {
 	}
 }
 
-// Test Updater parseAWSRanges
+// Test the AWS range parser
 func TestUpdater_ParseAWSRanges(t *testing.T) {
-	u := &Updater{}
-
 	data := []byte(`{
 		"prefixes": [
 			{"ip_prefix": "192.0.2.0/24", "service": "CLOUDFRONT"},
@@ -470,31 +539,29 @@ func TestUpdater_ParseAWSRanges(t *testing.T) {
 		]
 	}`)
 
-	ranges, err := u.parseAWSRanges(data)
+	ranges, err := parseAWSList(data, nil)
 	if err != nil {
-		t.Fatalf("parseAWSRanges() error: %v", err)
+		t.Fatalf("parseAWSList() error: %v", err)
 	}
 
 	if len(ranges) != 2 {
-		t.Errorf("parseAWSRanges() count = %d, want 2", len(ranges))
+		t.Errorf("parseAWSList() count = %d, want 2", len(ranges))
 	}
 }
 
-// Test Updater parseFastlyRanges
+// Test the Fastly range parser
 func TestUpdater_ParseFastlyRanges(t *testing.T) {
-	u := &Updater{}
-
 	data := []byte(`{
 		"addresses": ["192.0.2.0/24", "198.51.100.0/24"]
 	}`)
 
-	ranges, err := u.parseFastlyRanges(data)
+	ranges, err := parseFastlyList(data, nil)
 	if err != nil {
-		t.Fatalf("parseFastlyRanges() error: %v", err)
+		t.Fatalf("parseFastlyList() error: %v", err)
 	}
 
 	if len(ranges) != 2 {
-		t.Errorf("parseFastlyRanges() count = %d, want 2", len(ranges))
+		t.Errorf("parseFastlyList() count = %d, want 2", len(ranges))
 	}
 }
 